@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -20,8 +26,11 @@ import (
 	"github.com/SomeBlackMagic/stackman/internal/compose"
 	"github.com/SomeBlackMagic/stackman/internal/deployment"
 	"github.com/SomeBlackMagic/stackman/internal/health"
+	"github.com/SomeBlackMagic/stackman/internal/output"
+	"github.com/SomeBlackMagic/stackman/internal/plan"
 	"github.com/SomeBlackMagic/stackman/internal/snapshot"
 	"github.com/SomeBlackMagic/stackman/internal/swarm"
+	"github.com/SomeBlackMagic/stackman/internal/throttle"
 )
 
 // ExecuteApply runs the apply command
@@ -30,18 +39,60 @@ func ExecuteApply(args []string) {
 
 	// Required flags
 	stackName := fs.String("n", "", "Stack name (required)")
-	composeFile := fs.String("f", "", "Compose file path (required)")
+	composeFile := fs.String("f", "", "Compose file path or http(s)://, git:: URL (required)")
 
 	// Optional flags
-	valuesFile := fs.String("values", "", "Values file for templating")
-	setValues := fs.String("set", "", "Set values (comma-separated key=value pairs)")
+	valuesFile := fs.String("values", "", "Variables file for ${VAR} interpolation (.env, .yaml/.yml or .json)")
+	setValues := fs.String("set", "", "Set interpolation variables (comma-separated key=value pairs, override -values)")
 	timeout := fs.Duration("timeout", 15*time.Minute, "Deployment timeout")
 	rollbackTimeout := fs.Duration("rollback-timeout", 10*time.Minute, "Rollback timeout")
-	noWait := fs.Bool("no-wait", false, "Don't wait for deployment to complete")
-	prune := fs.Bool("prune", false, "Remove orphaned resources")
+	noWait := fs.Bool("no-wait", false, "Don't wait for deployment to complete; in-flight service updates still finish if interrupted")
+	detach := fs.Bool("detach", false, "Alias for -no-wait")
+	waitFor := fs.String("wait-for", "", "Only wait for these services (comma-separated); all services are still deployed")
+	waitMode := fs.String("wait-mode", waitModeHealth, "What to wait for: health (tasks running and healthchecks passing) or converge (rollout completed and tasks running, health ignored)")
+	prune := fs.Bool("prune", false, "Remove services, networks, volumes, secrets and configs the compose file no longer declares")
+	pruneWait := fs.Duration("prune-wait", 30*time.Second, "Maximum wait for tasks to release a pruned network before removing it (0 = don't wait)")
+	failOnOrphans := fs.Bool("fail-on-orphans", false, "Fail if the stack has resources the compose file doesn't declare, unless -prune is given")
 	allowLatest := fs.Bool("allow-latest", false, "Allow 'latest' tag in images")
 	parallel := fs.Int("parallel", 1, "Number of parallel service updates")
 	showLogs := fs.Bool("logs", true, "Show container logs during deployment")
+	showEvents := fs.Bool("events", true, "Show task lifecycle events during deployment (-logs=false -events=false disables streaming)")
+	logPrefixTemplate := fs.String("log-prefix-template", health.DefaultLogPrefixTemplate, "Go template for the container log prefix ({{.Service}}, {{.Stream}}, {{.Task}}, {{.TaskID}}, {{.Slot}}, {{.Source}}, {{.Icon}})")
+	collapseLogs := fs.Bool("log-collapse-duplicates", false, "Print identical consecutive log lines of a service's replicas once, with a repeat count")
+	logMaxLineLength := fs.Int("log-max-line-length", 0, "Truncate streamed container log lines longer than this many bytes (0 = unlimited)")
+	logRateLimit := fs.Int("log-rate-limit", 0, "Maximum log lines per second printed per container, excess lines are dropped (0 = unlimited)")
+	noColor := fs.Bool("no-color", false, "Strip ANSI color codes from streamed container logs (implied when stdout is not a terminal)")
+	healthLogLines := fs.Int("health-log-lines", health.DefaultHealthLogLines, "Lines of failed health check output to log (0 = unlimited)")
+	healthLogChars := fs.Int("health-log-chars", health.DefaultHealthLogChars, "Characters of passing health check output to log (0 = unlimited)")
+	maxInspects := fs.Int("max-concurrent-health-inspects", 0, "Maximum concurrent container inspects across all health checks and monitors (0 = unlimited)")
+	pullTimeout := fs.Duration("pull-timeout", 5*time.Minute, "Timeout for a single image pull (0 = no limit)")
+	pullRetries := fs.Int("pull-retries", 3, "Number of attempts per image pull")
+	pullPolicy := fs.String("pull", compose.PullPolicyAlways, "Default image pull policy: always, missing, never (overridden by service pull_policy)")
+	allowEmptyStack := fs.Bool("allow-empty-stack", false, "Allow deploying a compose file with no services (e.g. teardown via --prune)")
+	protocol := fs.String("protocol", "", "Machine-readable output protocol (jsonrpc)")
+	outputFormat := fs.String("output", outputText, "Progress output: text, or json for one JSON event per line on stdout")
+	validateSecrets := fs.Bool("compose-validate-secrets-exist", false, "Verify referenced external secrets and configs exist before deploying")
+	defaultRestartCondition := fs.String("compose-default-restart-condition", "", "Restart condition for services without deploy.restart_policy.condition: none, on-failure, any")
+	maxImageAge := fs.String("max-image-age", "", "Warn when a service image was created longer ago than this (e.g. 90d, 720h)")
+	failOnWarning := fs.Bool("fail-on-warning", false, "Abort deployment if any warning is raised")
+	warnMissingLimits := fs.Bool("warn-on-missing-resource-limits", false, "Warn about services without deploy.resources.limits.memory (fails with -fail-on-warning)")
+	pinDigests := fs.Bool("pin-digests", false, "Resolve image tags to registry digests and deploy image@sha256:...")
+	var profiles stringList
+	fs.Var(&profiles, "profile", "Enable compose services of this profile (repeatable or comma-separated); services without profiles always deploy")
+	noCleanupExited := fs.Bool("no-cleanup-exited", false, "Keep the stack's exited task containers instead of removing them before deploying")
+	dryRun := fs.Bool("dry-run", false, "Print the plan and exit without changing anything")
+	showPlan := fs.Bool("show-plan", false, "Print the plan before applying it")
+	confirmChanges := fs.Bool("confirm", false, "Print the plan and ask for confirmation before applying")
+	diffContext := fs.Bool("diff-context", false, "Show before/after values of changed service fields in the plan")
+	assumeYes := fs.Bool("yes", false, "Answer yes to --confirm (required when stdin is not a terminal)")
+	healthcheckDisable := fs.String("healthcheck-disable", "", "Deploy these services (comma-separated) with their healthcheck disabled")
+	annotations := fs.String("compose-treat-warnings-as-annotations", "", "Also write warnings and errors to stdout as CI annotations: github, json (stderr with -protocol or -output json)")
+	ignoreImageHealthcheck := fs.String("ignore-image-healthcheck", "", "Ignore the image's baked-in healthcheck for these services (comma-separated) unless the compose file defines a test; also set by the stackman.ignore_image_healthcheck label")
+	serviceFilter := fs.String("filter", "", "Only deploy and prune services with this compose label: label=key or label=key=value")
+	envPrefixAllow := fs.String("env-prefix-allow", "", "Only take these comma-separated variable prefixes from the process environment for bare environment entries (e.g. APP_)")
+	junitOut := fs.String("junit-out", "", "Write the deploy result as a JUnit XML report to this path (one test case per service)")
+	renderTo := fs.String("render-to", "", "Write the effective compose file (interpolated, with overrides applied) to this path")
+	healthcheckTest := fs.String("healthcheck-test", "", "Override the healthcheck test: '[service=]CMD curl localhost' (all services without a service prefix)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: stackman apply -n <stack> -f <compose-file> [flags]
@@ -70,39 +121,271 @@ Flags:
 		os.Exit(1)
 	}
 
+	switch *pullPolicy {
+	case compose.PullPolicyAlways, compose.PullPolicyMissing, compose.PullPolicyNever:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -pull value %q (supported: always, missing, never)\n\n", *pullPolicy)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	switch dockerswarm.RestartPolicyCondition(*defaultRestartCondition) {
+	case "", dockerswarm.RestartPolicyConditionNone, dockerswarm.RestartPolicyConditionOnFailure, dockerswarm.RestartPolicyConditionAny:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -compose-default-restart-condition value %q (supported: none, on-failure, any)\n\n", *defaultRestartCondition)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *waitMode != waitModeHealth && *waitMode != waitModeConverge {
+		fmt.Fprintf(os.Stderr, "Error: invalid -wait-mode value %q (supported: %s, %s)\n\n", *waitMode, waitModeHealth, waitModeConverge)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *protocol != "" && *protocol != protocolJSONRPC {
+		fmt.Fprintf(os.Stderr, "Error: unsupported protocol %q (supported: %s)\n\n", *protocol, protocolJSONRPC)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *outputFormat != outputText && *outputFormat != outputJSON {
+		fmt.Fprintf(os.Stderr, "Error: unsupported output %q (supported: %s, %s)\n\n", *outputFormat, outputText, outputJSON)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *outputFormat == outputJSON && *protocol != "" {
+		fmt.Fprintf(os.Stderr, "Error: -output json cannot be combined with -protocol\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var annotator *output.Annotator
+	if *annotations != "" {
+		// Machine-readable stdout must carry nothing but its own records
+		annotationOut := os.Stdout
+		if *protocol != "" || *outputFormat == outputJSON {
+			annotationOut = os.Stderr
+		}
+		a, err := output.NewAnnotator(annotationOut, *annotations)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -compose-treat-warnings-as-annotations: %v\n\n", err)
+			fs.Usage()
+			os.Exit(1)
+		}
+		annotator = a
+	}
+
+	if *healthLogLines < 0 || *healthLogChars < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -health-log-lines and -health-log-chars must not be negative\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *logMaxLineLength < 0 || *logRateLimit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -log-max-line-length and -log-rate-limit must not be negative\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var labelFilter *compose.LabelFilter
+	if *serviceFilter != "" {
+		f, err := compose.ParseLabelFilter(*serviceFilter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -filter: %v\n\n", err)
+			fs.Usage()
+			os.Exit(1)
+		}
+		labelFilter = f
+	}
+
+	imageAge, err := parseAge(*maxImageAge)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -max-image-age: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	logPrefix, err := health.ParseLogPrefix(*logPrefixTemplate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -log-prefix-template: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := &ApplyOptions{
+		ValuesFile:              *valuesFile,
+		SetValues:               *setValues,
+		Timeout:                 *timeout,
+		RollbackTimeout:         *rollbackTimeout,
+		NoWait:                  *noWait || *detach,
+		WaitMode:                *waitMode,
+		WaitFor:                 splitList(*waitFor),
+		Prune:                   *prune,
+		PruneWait:               *pruneWait,
+		FailOnOrphans:           *failOnOrphans,
+		AllowLatest:             *allowLatest,
+		Parallel:                *parallel,
+		ShowLogs:                *showLogs,
+		ShowEvents:              *showEvents,
+		LogPrefix:               logPrefix,
+		CollapseLogs:            *collapseLogs,
+		NoColor:                 *noColor || !isTerminal(os.Stdout),
+		LogLimits:               health.LogLimits{MaxLineLength: *logMaxLineLength, MaxLinesPerSecond: *logRateLimit},
+		PullTimeout:             *pullTimeout,
+		PullRetries:             *pullRetries,
+		PullPolicy:              *pullPolicy,
+		DefaultRestartCondition: *defaultRestartCondition,
+		ValidateSecrets:         *validateSecrets,
+		AllowEmptyStack:         *allowEmptyStack,
+		MaxImageAge:             imageAge,
+		FailOnWarning:           *failOnWarning,
+		WarnMissingLimits:       *warnMissingLimits,
+		PinDigests:              *pinDigests,
+		Profiles:                profiles,
+		NoCleanupExited:         *noCleanupExited,
+		DryRun:                  *dryRun,
+		ShowPlan:                *showPlan,
+		Confirm:                 *confirmChanges,
+		Yes:                     *assumeYes,
+		DiffContext:             *diffContext,
+		HealthcheckDisable:      splitList(*healthcheckDisable),
+		HealthcheckTest:         *healthcheckTest,
+		IgnoreImageHealthcheck:  splitList(*ignoreImageHealthcheck),
+		EnvPrefixAllow:          splitList(*envPrefixAllow),
+		RenderTo:                *renderTo,
+		JUnitOut:                *junitOut,
+		ServiceFilter:           labelFilter,
+		Annotations:             annotator,
+		MaxConcurrentInspects:   *maxInspects,
+		HealthLog:               health.HealthLogLimits{Lines: *healthLogLines, Chars: *healthLogChars},
+	}
+
+	switch {
+	case *outputFormat == outputJSON:
+		// JSON output keeps logs on stderr and writes only events to stdout
+		opts.Events = output.NewJSONEmitter(os.Stdout)
+	case *protocol == protocolJSONRPC:
+		// JSON-RPC mode replaces interactive output: everything on stdout is a notification
+		opts.Events = output.NewJSONRPCEmitter(jsonRPCOutput(os.Stdout))
+	}
+
 	// Run apply logic
-	if err := runApply(*stackName, *composeFile, &ApplyOptions{
-		ValuesFile:      *valuesFile,
-		SetValues:       *setValues,
-		Timeout:         *timeout,
-		RollbackTimeout: *rollbackTimeout,
-		NoWait:          *noWait,
-		Prune:           *prune,
-		AllowLatest:     *allowLatest,
-		Parallel:        *parallel,
-		ShowLogs:        *showLogs,
-	}); err != nil {
+	if err := runApply(*stackName, *composeFile, opts); err != nil {
+		if _, rpc := opts.Events.(*output.JSONRPCEmitter); rpc {
+			// Error has already been reported as a notification
+			os.Exit(1)
+		}
 		log.Fatalf("Apply failed: %v", err)
 	}
 }
 
+// protocolJSONRPC selects newline-delimited JSON-RPC notifications on stdout
+const protocolJSONRPC = "jsonrpc"
+
+// jsonRPCOutput returns a notification writer for w and routes the standard
+// logger through it, so log lines become deploy/progress notifications
+func jsonRPCOutput(w io.Writer) *output.JSONRPCWriter {
+	rpc := output.NewJSONRPCWriter(w)
+	log.SetOutput(rpc.ProgressWriter())
+	log.SetFlags(0)
+	return rpc
+}
+
+// Values of the -wait-mode flag
+const (
+	waitModeHealth   = "health"
+	waitModeConverge = "converge"
+)
+
+// Values of the -output flag
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
 // ApplyOptions contains options for the apply command
 type ApplyOptions struct {
-	ValuesFile      string
-	SetValues       string
-	Timeout         time.Duration
-	RollbackTimeout time.Duration
-	NoWait          bool
-	Prune           bool
-	AllowLatest     bool
-	Parallel        int
-	ShowLogs        bool
+	ValuesFile              string
+	SetValues               string
+	Timeout                 time.Duration
+	RollbackTimeout         time.Duration
+	NoWait                  bool
+	WaitMode                string   // waitModeHealth or waitModeConverge
+	WaitFor                 []string // Services the health or converge wait is limited to (empty = all updated services)
+	Prune                   bool
+	PruneWait               time.Duration // Maximum wait for tasks to release a pruned network
+	FailOnOrphans           bool
+	AllowLatest             bool
+	Parallel                int
+	ShowLogs                bool
+	ShowEvents              bool
+	LogPrefix               *health.LogPrefix
+	CollapseLogs            bool
+	NoColor                 bool             // Strip ANSI escape sequences from streamed container logs
+	LogLimits               health.LogLimits // Per-container line length and rate limits of streamed logs
+	PullTimeout             time.Duration
+	PullRetries             int
+	PullPolicy              string
+	DefaultRestartCondition string
+	ValidateSecrets         bool
+	AllowEmptyStack         bool
+	MaxImageAge             time.Duration
+	FailOnWarning           bool
+	WarnMissingLimits       bool
+	PinDigests              bool
+	Profiles                []string // Active compose profiles; services with other profiles are left out
+	NoCleanupExited         bool
+	DryRun                  bool
+	ShowPlan                bool
+	Confirm                 bool
+	Yes                     bool
+	DiffContext             bool
+	Events                  output.Emitter         // Machine-readable event sink that owns stdout (nil = text via the logger)
+	HealthcheckDisable      []string               // Services deployed with their healthcheck disabled
+	HealthcheckTest         string                 // Healthcheck test override, optionally prefixed with "service="
+	IgnoreImageHealthcheck  []string               // Services whose image healthcheck is ignored (no compose test = running is healthy)
+	EnvPrefixAllow          []string               // Prefixes of variables bare environment entries may take from the process (empty = all)
+	RenderTo                string                 // Path the effective compose file is written to ("" = disabled)
+	JUnitOut                string                 // Path the JUnit XML report is written to ("" = disabled)
+	ServiceFilter           *compose.LabelFilter   // Only services with this compose label are deployed or pruned (nil = all)
+	Annotations             *output.Annotator      // CI annotation sink for warnings and errors (nil = disabled)
+	MaxConcurrentInspects   int                    // Process-wide limit on concurrent container inspects (0 = unlimited)
+	HealthLog               health.HealthLogLimits // Truncation of health check output in logs
 }
 
 // runApply performs the actual deployment
-func runApply(stackName, composeFile string, opts *ApplyOptions) error {
-	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout+5*time.Minute)
-	defer cancel()
+func runApply(stackName, composeFile string, opts *ApplyOptions) (err error) {
+	var deployID string
+	// With a machine-readable emitter stdout carries only its records:
+	// everything printed there otherwise goes through the emitter or the logger
+	events := opts.Events
+	machine := events != nil
+	if !machine {
+		events = output.TextEmitter{}
+	}
+	if opts.JUnitOut != "" {
+		// Closed after the result event below has written the report
+		report, err := os.Create(opts.JUnitOut)
+		if err != nil {
+			return fmt.Errorf("failed to create JUnit report: %w", err)
+		}
+		defer report.Close()
+		events = output.NewJUnitRecorder(report, stackName, events)
+	}
+	defer func() {
+		events.Emit(resultEvent(stackName, deployID, err))
+	}()
+	if opts.Annotations != nil {
+		defer func() {
+			if err != nil {
+				opts.Annotations.Annotate(output.Annotation{Level: output.AnnotationError, File: composeFile, Message: err.Error()})
+			}
+		}()
+	}
+
+	// Bound container inspects from all monitors so large deploys don't overwhelm the daemon
+	throttle.SetInspectLimit(opts.MaxConcurrentInspects)
 
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
@@ -115,27 +398,138 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 	}
 	defer cli.Close()
 
+	vars, err := interpolationVars(opts.ValuesFile, opts.SetValues)
+	if err != nil {
+		return err
+	}
+
 	// Parse compose file
 	log.Printf("Parsing compose file: %s", composeFile)
-	composeSpec, err := compose.ParseComposeFile(composeFile)
+	composeSpec, err := compose.ParseComposeFileWithVars(composeFile, vars)
 	if err != nil {
 		return fmt.Errorf("failed to parse compose file: %w", err)
 	}
+	for _, name := range composeSpec.UnsetVariables {
+		log.Printf("WARNING: variable %s is not set, substituting an empty string", name)
+	}
+
+	if err := applyProfiles(composeSpec, opts.Profiles); err != nil {
+		return err
+	}
+	if err := checkEmptyStack(composeSpec, opts.AllowEmptyStack); err != nil {
+		return err
+	}
+	if err := compose.Validate(composeSpec); err != nil {
+		return fmt.Errorf("invalid compose file:\n%w", err)
+	}
+	if err := checkLatestTags(composeSpec, opts.AllowLatest); err != nil {
+		return err
+	}
+
+	if err := checkWaitFor(composeSpec, opts.WaitFor); err != nil {
+		return err
+	}
+
+	if err := applyHealthcheckOverrides(composeSpec, opts.HealthcheckDisable, opts.HealthcheckTest); err != nil {
+		return err
+	}
+	if err := applyImageHealthcheckIgnores(composeSpec, opts.IgnoreImageHealthcheck); err != nil {
+		return err
+	}
+	if err := resolveServiceEnvironment(composeSpec, opts.EnvPrefixAllow); err != nil {
+		return err
+	}
+	if opts.RenderTo != "" {
+		if err := writeRenderedCompose(opts.RenderTo, composeSpec); err != nil {
+			return err
+		}
+		log.Printf("Effective compose file written to %s", opts.RenderTo)
+	}
+
+	healthTimeouts, err := serviceHealthTimeouts(stackName, composeSpec)
+	if err != nil {
+		return err
+	}
+	waitTimeout := longestTimeout(opts.Timeout, healthTimeouts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout+5*time.Minute)
+	defer cancel()
 
-	// TODO: Apply templating if valuesFile or setValues provided
+	// The plan is computed before any mutating Docker API call.
+	// Machine-readable output always reports it, printed only when asked for.
+	// -fail-on-orphans needs the plan's orphan detection even when it isn't printed.
+	showPlan := opts.DryRun || opts.ShowPlan || opts.Confirm
+	orphanCheck := opts.FailOnOrphans && !opts.Prune
+	if showPlan || machine || orphanCheck {
+		var planOut io.Writer = os.Stdout
+		if !showPlan {
+			planOut = io.Discard
+		} else if machine {
+			// stderr for JSON output, progress notifications for JSON-RPC
+			planOut = log.Writer()
+		}
+		deployPlan, err := previewPlan(ctx, cli, stackName, composeSpec, planOut, opts)
+		if err != nil {
+			return err
+		}
+		if machine {
+			events.Emit(output.Event{Type: output.EventPlan, Stack: stackName, Message: planSummary(deployPlan), Data: deployPlan})
+		}
+		if err := checkOrphans(deployPlan, opts.FailOnOrphans, opts.Prune); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			log.Println("Dry run: no changes applied")
+			return nil
+		}
+		if opts.Confirm {
+			proceed, err := confirmPlan(deployPlan, os.Stdin, os.Stderr, isTerminal(os.Stdin), opts.Yes)
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				return fmt.Errorf("apply aborted: changes were not confirmed")
+			}
+		}
+	}
 
 	// Generate deployment ID
-	deployID := deployment.GenerateDeployID()
+	deployID = deployment.GenerateDeployID()
 	log.Printf("[Deploy] Generated deployment ID: %s", deployID)
 
+	// Deployment calls fail over to STACKMAN_MANAGER_HOSTS when the manager loses leadership
+	managerCli, err := swarm.NewManagerClient(cli)
+	if err != nil {
+		return err
+	}
+	// Waits and task monitors read cluster state the same way; container
+	// inspects, logs and events stay on the local daemon
+	clusterCli := swarm.NewClusterClient(cli, managerCli)
+
 	// Create deployer
-	stackDeployer := swarm.NewStackDeployer(cli, stackName, 3)
+	stackDeployer := newApplyDeployer(managerCli, stackName, opts)
+	if opts.Annotations != nil {
+		stackDeployer.OnWarning = warningAnnotator(opts.Annotations, composeFile, composeSpec.ServiceLines)
+	}
 
 	// Create snapshot before deployment
 	snap := snapshot.CreateSnapshot(ctx, stackDeployer)
 
 	// Track deployment state
 	deploymentComplete := make(chan bool, 1)
+	interrupted := make(chan struct{})
+	interruptExit := make(chan int, 1)
+
+	// Once interrupted, the signal handler decides the exit code: keep the
+	// deployment path from returning (and exiting with its own code) until the
+	// rollback has been reported
+	defer func() {
+		select {
+		case <-interrupted:
+			os.Exit(awaitInterruptExit(interruptExit, opts.RollbackTimeout+interruptReportGrace))
+		default:
+		}
+	}()
 
 	// Handle signals
 	go func() {
@@ -147,9 +541,17 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 			log.Println("Deployment already completed, exiting...")
 			os.Exit(0)
 		default:
+			close(interrupted)
 			log.Println("Deployment interrupted, initiating rollback...")
-			snapshot.Rollback(context.Background(), stackDeployer, snap)
-			os.Exit(130)
+			// Stop the deployment before restoring the snapshot
+			cancel()
+			code := rollbackOnInterrupt(sig, opts.RollbackTimeout, func(ctx context.Context) error {
+				return snapshot.Rollback(ctx, stackDeployer, snap, opts.RollbackTimeout)
+			}, os.Stderr)
+			result := resultEvent(stackName, deployID, fmt.Errorf("deployment interrupted by %v", sig))
+			result.ExitCode = &code
+			events.Emit(result)
+			interruptExit <- code
 		}
 	}()
 
@@ -160,10 +562,17 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 		return fmt.Errorf("failed to deploy stack: %w", err)
 	}
 
-	fmt.Println("Stack deployed successfully.")
+	deployed := output.Event{Type: output.EventStackDeployed, Stack: stackName}
+	if machine {
+		deployed.Message = "Stack deployed successfully."
+	} else {
+		fmt.Println("Stack deployed successfully.")
+	}
+	events.Emit(deployed)
 
 	// If --no-wait, exit now
 	if opts.NoWait {
+		saveSnapshot(snap)
 		deploymentComplete <- true
 		return nil
 	}
@@ -172,13 +581,48 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 	if len(deployResult.UpdatedServices) > 0 {
 		log.Printf("Services updated/created: %d", len(deployResult.UpdatedServices))
 		for _, svc := range deployResult.UpdatedServices {
-			log.Printf("  - %s (version: %d)", svc.ServiceName, svc.Version.Index)
+			events.Emit(output.Event{
+				Type:    output.EventServiceUpdate,
+				Stack:   stackName,
+				Service: svc.ServiceName,
+				State:   "started",
+				Message: fmt.Sprintf("  - %s (version: %d)", svc.ServiceName, svc.Version.Index),
+			})
 		}
 
-		// Start event-driven monitoring with watchers
-		log.Println("[TaskMonitor] Starting watchers and monitors for updated services...")
-		if opts.ShowLogs {
-			log.Println("[TaskMonitor] Container logs will be streamed below...")
+		// Watchers and task monitors stream events and logs until the deployment
+		// completes or fails. They are stopped before rollback so their output
+		// doesn't interleave with it.
+		streamCtx, cancelStreams := context.WithCancel(ctx)
+		var streams sync.WaitGroup
+		stopStreaming := func() {
+			cancelStreams()
+			streams.Wait()
+		}
+		defer stopStreaming()
+
+		streaming := opts.ShowLogs || opts.ShowEvents
+		if streaming {
+			log.Println("[TaskMonitor] Starting watchers and monitors for updated services...")
+			if opts.ShowLogs {
+				log.Println("[TaskMonitor] Container logs will be streamed below...")
+			}
+		} else {
+			log.Println("[TaskMonitor] Event and log streaming disabled")
+		}
+
+		// Route container logs through the machine-readable emitter instead of stdout
+		var logHandler health.LogHandler
+		if machine {
+			logHandler = func(serviceName, taskID, stream, line string) {
+				events.Emit(output.Event{
+					Type:    output.EventLog,
+					Service: serviceName,
+					Task:    taskID,
+					State:   stream,
+					Message: strings.TrimRight(line, "\r\n"),
+				})
+			}
 		}
 
 		var wg sync.WaitGroup
@@ -192,29 +636,66 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 				defer wg.Done()
 
 				// Monitor service update status
-				updateMonitor := health.NewServiceUpdateMonitor(cli, s.ServiceID, s.ServiceName)
+				updateMonitor := health.NewServiceUpdateMonitor(clusterCli, s.ServiceID, s.ServiceName)
 				if err := updateMonitor.WaitForUpdateComplete(ctx); err != nil {
-					log.Printf("[ServiceUpdateMonitor] ❌ Service %s update failed: %v", s.ServiceName, err)
+					events.Emit(output.Event{
+						Type:    output.EventServiceUpdate,
+						Stack:   stackName,
+						Service: s.ServiceName,
+						State:   "failed",
+						Message: fmt.Sprintf("[ServiceUpdateMonitor] ❌ Service %s update failed: %v", s.ServiceName, err),
+					})
 					updateErrors <- fmt.Errorf("service %s update failed: %w", s.ServiceName, err)
 					return
 				}
 
-				log.Printf("[ServiceUpdateMonitor] ✅ Service %s update completed successfully", s.ServiceName)
+				events.Emit(output.Event{
+					Type:    output.EventServiceUpdate,
+					Stack:   stackName,
+					Service: s.ServiceName,
+					State:   "completed",
+					Message: fmt.Sprintf("[ServiceUpdateMonitor] ✅ Service %s update completed successfully", s.ServiceName),
+				})
 			}(svc)
 
+			if !streaming {
+				continue
+			}
+
 			// Create dedicated watcher filtered for this service, version and deployID
-			serviceWatcher := health.NewServiceWatcher(cli, stackName, svc.ServiceID, svc.Version.Index, deployResult.DeployID)
+			serviceWatcher := health.NewServiceWatcher(clusterCli, stackName, svc.ServiceID, svc.Version.Index, deployResult.DeployID)
 			serviceEventsChan := serviceWatcher.Subscribe()
 
 			// Start watcher in background
+			streams.Add(2)
 			go func(w *health.Watcher, svcName string) {
-				if err := w.Start(ctx); err != nil && err != context.Canceled {
+				defer streams.Done()
+				if err := w.Start(streamCtx); err != nil && err != context.Canceled {
 					log.Printf("[TaskWatcher] Error for service %s: %v", svcName, err)
 				}
 			}(serviceWatcher, svc.ServiceName)
 
 			// Start monitor for this service
-			go monitorServiceTasks(ctx, cli, svc, serviceEventsChan, opts.ShowLogs, deployResult.DeployID)
+			monitorOpts := taskMonitorOptions{
+				ShowLogs:   opts.ShowLogs,
+				ShowEvents: opts.ShowEvents,
+				Log: health.StreamOptions{
+					Handler:    logHandler,
+					Prefix:     opts.LogPrefix,
+					StripColor: opts.NoColor,
+					Limits:     opts.LogLimits,
+				},
+				Events:   events,
+				DeployID: deployResult.DeployID,
+			}
+			if opts.CollapseLogs {
+				// Replicas share one collapser so their identical lines are printed once
+				monitorOpts.Log.Collapser = &health.LineCollapser{}
+			}
+			go func(s swarm.ServiceUpdateResult) {
+				defer streams.Done()
+				monitorServiceTasks(streamCtx, clusterCli, s, serviceEventsChan, monitorOpts)
+			}(svc)
 
 			log.Printf("[TaskMonitor] Started watcher for service %s version %d+ (deployID: %s)", svc.ServiceName, svc.Version.Index, deployResult.DeployID)
 		}
@@ -229,54 +710,585 @@ func runApply(stackName, composeFile string, opts *ApplyOptions) error {
 		for err := range updateErrors {
 			if err != nil {
 				log.Printf("ERROR: %v", err)
-				snapshot.Rollback(ctx, stackDeployer, snap)
+				stopStreaming()
+				snapshot.Rollback(ctx, stackDeployer, snap, opts.RollbackTimeout)
 				return err
 			}
 		}
 
 		log.Println("[ServiceUpdateMonitor] All service updates completed successfully")
 
-		// Now wait for all tasks to become healthy
-		log.Println("[TaskMonitor] Waiting for all tasks to become healthy...")
+		waitServices := filterWaitServices(stackName, deployResult.UpdatedServices, opts.WaitFor)
+		if len(opts.WaitFor) > 0 {
+			log.Printf("Waiting only for %d of %d updated services (--wait-for)", len(waitServices), len(deployResult.UpdatedServices))
+		}
+
+		var err error
+		if opts.WaitMode == waitModeConverge {
+			// Only swarm convergence matters: container health is ignored
+			log.Println("[Converge] Waiting for all services to converge...")
+
+			convergeCtx, convergeCancel := context.WithTimeout(ctx, opts.Timeout)
+			defer convergeCancel()
 
-		// Create health check context with timeout
-		healthCtx, healthCancel := context.WithTimeout(ctx, opts.Timeout)
-		defer healthCancel()
+			err = waitForConvergence(convergeCtx, clusterCli, waitServices, deployResult.DeployID)
+		} else {
+			// Now wait for all tasks to become healthy
+			log.Println("[TaskMonitor] Waiting for all tasks to become healthy...")
 
-		// Wait for all tasks to report healthy status
-		if err := waitForAllTasksHealthy(healthCtx, cli, stackName, deployResult.UpdatedServices, deployResult.DeployID); err != nil {
+			// Create health check context with timeout; per-service deadlines are enforced inside
+			healthCtx, healthCancel := context.WithTimeout(ctx, waitTimeout)
+			defer healthCancel()
+
+			// Wait for all tasks to report healthy status
+			err = waitForAllTasksHealthy(healthCtx, clusterCli, stackName, waitServices, deployResult.DeployID, opts.Timeout, healthTimeouts, opts.HealthLog, events)
+		}
+		stopStreaming()
+		if err != nil {
 			log.Printf("ERROR: %v", err)
-			snapshot.Rollback(ctx, stackDeployer, snap)
+			snapshot.Rollback(ctx, stackDeployer, snap, opts.RollbackTimeout)
 			return err
 		}
 
-		log.Println("[TaskMonitor] All tasks are healthy")
+		if opts.WaitMode == waitModeConverge {
+			log.Println("[Converge] All services converged")
+		} else {
+			log.Println("[TaskMonitor] All tasks are healthy")
+		}
 	} else {
 		log.Println("No services were changed during this deployment")
 	}
 
+	saveSnapshot(snap)
+
+	// Old secret/config versions are no longer needed once the deployment is healthy,
+	// unless a saved snapshot still uses them
+	if retained, err := snapshotResources(stackName); err != nil {
+		log.Printf("WARNING: keeping old secret and config versions: %v", err)
+	} else {
+		stackDeployer.RetainedResources = retained
+		stackDeployer.RemoveRotatedResources(ctx)
+	}
+
 	// Mark deployment as successful
 	deploymentComplete <- true
 
 	return nil
 }
 
+// saveSnapshot persists the pre-deploy state so 'stackman rollback -rollback-to' can restore it later.
+// Failing to save never fails the deployment.
+func saveSnapshot(snap *swarm.StackSnapshot) {
+	if snap == nil || snap.IsFirstDeploy {
+		return
+	}
+
+	dir, err := snapshot.DefaultDir()
+	if err != nil {
+		log.Printf("WARNING: failed to save snapshot: %v", err)
+		return
+	}
+	id, err := snapshot.NewStore(dir).Save(snap)
+	if err != nil {
+		log.Printf("WARNING: failed to save snapshot: %v", err)
+		return
+	}
+	log.Printf("Saved pre-deploy snapshot %s", id)
+}
+
+// snapshotResources returns the secret and config IDs the saved snapshots of a stack use
+func snapshotResources(stackName string) (map[string]bool, error) {
+	dir, err := snapshot.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.NewStore(dir).ReferencedResources(stackName)
+}
+
+// newApplyDeployer creates the deployer for stackName configured from the apply options
+func newApplyDeployer(cli swarm.DockerClient, stackName string, opts *ApplyOptions) *swarm.StackDeployer {
+	stackDeployer := swarm.NewStackDeployer(cli, stackName, 3)
+	stackDeployer.PullTimeout = opts.PullTimeout
+	stackDeployer.PullRetries = opts.PullRetries
+	stackDeployer.PullPolicy = opts.PullPolicy
+	stackDeployer.DefaultRestartCondition = opts.DefaultRestartCondition
+	stackDeployer.Parallel = opts.Parallel
+	stackDeployer.ValidateExternalResources = opts.ValidateSecrets
+	stackDeployer.MaxImageAge = opts.MaxImageAge
+	stackDeployer.FailOnWarning = opts.FailOnWarning
+	stackDeployer.WarnMissingMemoryLimit = opts.WarnMissingLimits
+	stackDeployer.PinDigests = opts.PinDigests
+	stackDeployer.KeepExitedContainers = opts.NoCleanupExited
+	stackDeployer.Prune = opts.Prune
+	stackDeployer.PruneWait = opts.PruneWait
+	stackDeployer.Detach = opts.NoWait
+	stackDeployer.ServiceFilter = opts.ServiceFilter
+	return stackDeployer
+}
+
+// previewPlan computes the plan for composeSpec against the live stack and prints it.
+// It only reads cluster state.
+func previewPlan(ctx context.Context, cli swarm.DockerClient, stackName string, composeSpec *compose.ComposeFile, w io.Writer, opts *ApplyOptions) (*plan.Plan, error) {
+	log.Printf("Computing plan for stack %s", stackName)
+	deployPlan, err := planFromSpec(ctx, cli, stackName, composeSpec, opts.PinDigests, opts.DefaultRestartCondition)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ServiceFilter != nil {
+		filterPlanServices(deployPlan, composeSpec, opts.ServiceFilter)
+	}
+	if err := printPlan(w, deployPlan, false, opts.DiffContext); err != nil {
+		return nil, err
+	}
+	return deployPlan, nil
+}
+
+// filterPlanServices drops service actions for services outside filter, which apply leaves untouched
+func filterPlanServices(deployPlan *plan.Plan, composeSpec *compose.ComposeFile, filter *compose.LabelFilter) {
+	var kept []plan.ServiceAction
+	for _, action := range deployPlan.Services {
+		selected := false
+		if svc := composeSpec.Services[action.Name]; svc != nil {
+			selected = filter.MatchService(svc)
+		} else if action.CurrentSpec != nil {
+			selected = filter.MatchServiceSpec(action.CurrentSpec)
+		}
+		if selected {
+			kept = append(kept, action)
+		}
+	}
+	deployPlan.Services = kept
+}
+
+// confirmPlan asks the operator to approve the plan by typing "yes".
+// An empty plan needs no approval. Without a terminal on stdin the answer
+// cannot be trusted, so assumeYes must be given explicitly.
+func confirmPlan(deployPlan *plan.Plan, in io.Reader, out io.Writer, interactive, assumeYes bool) (bool, error) {
+	if deployPlan.IsEmpty() || assumeYes {
+		return true, nil
+	}
+	if !interactive {
+		return false, fmt.Errorf("--confirm needs an interactive terminal; pass --yes to apply non-interactively")
+	}
+
+	fmt.Fprint(out, "Type 'yes' to apply these changes: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+	return strings.TrimSpace(answer) == "yes", nil
+}
+
+// isTerminal reports whether f is a character device (an interactive terminal)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// rollbackOnInterrupt runs the rollback of an interrupted deployment, waits for it
+// (at most timeout) and prints a summary of the outcome. Returns the exit code.
+func rollbackOnInterrupt(sig os.Signal, timeout time.Duration, rollback func(ctx context.Context) error, w io.Writer) int {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rollback(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("rollback did not finish within %v", timeout)
+	}
+
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== Interrupted ===")
+	fmt.Fprintf(w, "Signal:   %v\n", sig)
+	if err != nil {
+		fmt.Fprintf(w, "Rollback: FAILED after %v: %v\n", elapsed, err)
+		fmt.Fprintln(w, "State:    stack may be partially updated, manual intervention may be required")
+	} else {
+		fmt.Fprintf(w, "Rollback: completed in %v\n", elapsed)
+		fmt.Fprintln(w, "State:    stack restored to the pre-deployment snapshot")
+	}
+
+	return 130
+}
+
+// interruptReportGrace is how long after the rollback timeout the deployment
+// path still waits for the interrupted rollback to be reported
+const interruptReportGrace = 5 * time.Second
+
+// awaitInterruptExit returns the exit code the signal handler sends once the
+// interrupted deployment has been rolled back, or 130 if none arrives within timeout
+func awaitInterruptExit(exitCode <-chan int, timeout time.Duration) int {
+	select {
+	case code := <-exitCode:
+		return code
+	case <-time.After(timeout):
+		log.Printf("WARNING: rollback was not reported within %v, exiting", timeout)
+		return 130
+	}
+}
+
+// checkEmptyStack rejects a compose file without services unless explicitly allowed.
+// An empty stack is almost always a wrong -f path or a mis-rendered file.
+func checkEmptyStack(composeSpec *compose.ComposeFile, allowEmpty bool) error {
+	if len(composeSpec.Services) > 0 {
+		return nil
+	}
+	if allowEmpty {
+		log.Println("WARNING: compose file defines no services (allowed by --allow-empty-stack)")
+		return nil
+	}
+	return fmt.Errorf("compose file defines no services; pass --allow-empty-stack to deploy an empty stack")
+}
+
+// checkOrphans rejects a plan that leaves live resources the compose file doesn't
+// declare when -fail-on-orphans is set, so they are removed by an explicit -prune
+// or resolved by the operator instead of being handled silently
+func checkOrphans(deployPlan *plan.Plan, failOnOrphans, prune bool) error {
+	if !failOnOrphans || prune {
+		return nil
+	}
+	orphans := deployPlan.Orphans()
+	if len(orphans) == 0 {
+		return nil
+	}
+	return fmt.Errorf("stack %s has resources not declared in the compose file: %s (pass -prune to remove them)",
+		deployPlan.StackName, strings.Join(orphans, ", "))
+}
+
+// checkLatestTags rejects images that use the latest tag, explicitly or by having
+// no tag, unless allowed. Digest-pinned images are always accepted.
+func checkLatestTags(composeSpec *compose.ComposeFile, allowLatest bool) error {
+	if allowLatest {
+		return nil
+	}
+
+	names := make([]string, 0, len(composeSpec.Services))
+	for name, svc := range composeSpec.Services {
+		if svc != nil && svc.Image != "" && compose.UsesLatestTag(svc.Image) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	images := make([]string, 0, len(names))
+	for _, name := range names {
+		images = append(images, fmt.Sprintf("%s (%s)", name, composeSpec.Services[name].Image))
+	}
+	return fmt.Errorf("images must be pinned to a version tag or digest, latest is used by: %s (pass -allow-latest to deploy anyway)", strings.Join(images, ", "))
+}
+
+// checkWaitFor rejects -wait-for names that are not services of the compose file
+func checkWaitFor(composeSpec *compose.ComposeFile, waitFor []string) error {
+	for _, name := range waitFor {
+		if _, ok := composeSpec.Services[name]; !ok {
+			return fmt.Errorf("--wait-for: unknown service %q", name)
+		}
+	}
+	return nil
+}
+
+// filterWaitServices limits the updated services to those named by -wait-for.
+// Services that were not updated in this deployment have nothing to wait for.
+func filterWaitServices(stackName string, updated []swarm.ServiceUpdateResult, waitFor []string) []swarm.ServiceUpdateResult {
+	if len(waitFor) == 0 {
+		return updated
+	}
+
+	wanted := make(map[string]bool, len(waitFor))
+	for _, name := range waitFor {
+		wanted[stackName+"_"+name] = true
+	}
+
+	var filtered []swarm.ServiceUpdateResult
+	for _, svc := range updated {
+		if wanted[svc.ServiceName] {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered
+}
+
+// applyHealthcheckOverrides applies -healthcheck-disable and -healthcheck-test to the parsed compose file.
+// The test applies to every service unless prefixed with "service=".
+func applyHealthcheckOverrides(composeSpec *compose.ComposeFile, disable []string, test string) error {
+	for _, name := range disable {
+		svc, ok := composeSpec.Services[name]
+		if !ok {
+			return fmt.Errorf("--healthcheck-disable: unknown service %q", name)
+		}
+		compose.DisableHealthcheck(svc)
+		log.Printf("WARNING: healthcheck disabled for service %s", name)
+	}
+
+	if test == "" {
+		return nil
+	}
+
+	targets := make([]string, 0, len(composeSpec.Services))
+	if name, rest, ok := strings.Cut(test, "="); ok && composeSpec.Services[name] != nil {
+		targets = append(targets, name)
+		test = rest
+	} else {
+		for name := range composeSpec.Services {
+			targets = append(targets, name)
+		}
+		sort.Strings(targets)
+	}
+
+	parsed, err := compose.ParseHealthcheckTest(test)
+	if err != nil {
+		return fmt.Errorf("--healthcheck-test: %w", err)
+	}
+	for _, name := range targets {
+		compose.OverrideHealthcheck(composeSpec.Services[name], parsed)
+		log.Printf("WARNING: healthcheck of service %s overridden with %q", name, parsed)
+	}
+	return nil
+}
+
+// resolveServiceEnvironment fills bare environment entries from the process environment,
+// warning about the ones -env-prefix-allow keeps out
+func resolveServiceEnvironment(composeSpec *compose.ComposeFile, allow []string) error {
+	names := make([]string, 0, len(composeSpec.Services))
+	for name := range composeSpec.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if composeSpec.Services[name] == nil {
+			continue
+		}
+		blocked, err := compose.ResolveEnvironment(composeSpec.Services[name], allow)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		for _, variable := range blocked {
+			log.Printf("WARNING: service %s: environment variable %s is not taken from the process environment (not allowed by -env-prefix-allow)", name, variable)
+		}
+	}
+	return nil
+}
+
+// applyProfiles leaves out the services not enabled by the active profiles
+func applyProfiles(composeSpec *compose.ComposeFile, profiles []string) error {
+	disabled, err := compose.ApplyProfiles(composeSpec, profiles)
+	if err != nil {
+		return fmt.Errorf("invalid profiles:\n%w", err)
+	}
+	if len(disabled) > 0 {
+		log.Printf("Skipping service(s) not enabled by the active profiles %v: %v", profiles, disabled)
+	}
+	return nil
+}
+
+// stringList is a repeatable flag; each value may also be a comma-separated list
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// warningAnnotator reports deployer warnings as annotations on the compose file,
+// pointing at the service definition when the warning belongs to one
+func warningAnnotator(a *output.Annotator, composeFile string, lines map[string]int) func(service, message string) {
+	return func(service, message string) {
+		a.Annotate(output.Annotation{
+			Level:   output.AnnotationWarning,
+			File:    composeFile,
+			Line:    lines[service],
+			Message: message,
+		})
+	}
+}
+
+// resultEvent describes the final outcome of apply
+func resultEvent(stackName, deployID string, err error) output.Event {
+	exitCode := 0
+	result := output.Event{Type: output.EventResult, Stack: stackName, State: "success", ExitCode: &exitCode}
+	if deployID != "" {
+		result.Data = output.ResultData{DeployID: deployID}
+	}
+	if err != nil {
+		exitCode = 1
+		result.State = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// planSummary counts the planned changes for the plan event
+func planSummary(p *plan.Plan) string {
+	counts := map[plan.ActionType]int{}
+	for _, svc := range p.Services {
+		counts[svc.Action]++
+	}
+	return fmt.Sprintf("Plan: %d to create, %d to update, %d to delete (services)",
+		counts[plan.ActionCreate], counts[plan.ActionUpdate], counts[plan.ActionDelete])
+}
+
+// applyImageHealthcheckIgnores disables the image healthcheck of services named by
+// -ignore-image-healthcheck or labelled stackman.ignore_image_healthcheck=true.
+// It runs after applyHealthcheckOverrides so a -healthcheck-test override is kept.
+func applyImageHealthcheckIgnores(composeSpec *compose.ComposeFile, services []string) error {
+	targets := make(map[string]bool, len(services))
+	for _, name := range services {
+		if _, ok := composeSpec.Services[name]; !ok {
+			return fmt.Errorf("--ignore-image-healthcheck: unknown service %q", name)
+		}
+		targets[name] = true
+	}
+
+	names := make([]string, 0, len(composeSpec.Services))
+	for name := range composeSpec.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		svc := composeSpec.Services[name]
+		labelled, err := compose.IgnoresImageHealthcheck(svc)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if !targets[name] && !labelled {
+			continue
+		}
+
+		if compose.IgnoreImageHealthcheck(svc) {
+			log.Printf("WARNING: image healthcheck ignored for service %s; running tasks count as healthy", name)
+		} else {
+			log.Printf("Service %s defines its own healthcheck test; it is used instead of the image healthcheck", name)
+		}
+	}
+	return nil
+}
+
+// serviceHealthTimeouts collects per-service health timeout overrides from the
+// stackman.health_timeout service or deploy label, keyed by full service name.
+// The deploy label wins when both are set, as it does for the service spec.
+func serviceHealthTimeouts(stackName string, composeSpec *compose.ComposeFile) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for name, svc := range composeSpec.Services {
+		timeout, err := health.ParseHealthTimeout(svc.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		if svc.Deploy != nil {
+			deployTimeout, err := health.ParseHealthTimeout(svc.Deploy.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+			if deployTimeout > 0 {
+				timeout = deployTimeout
+			}
+		}
+		if timeout > 0 {
+			timeouts[fmt.Sprintf("%s_%s", stackName, name)] = timeout
+		}
+	}
+	return timeouts, nil
+}
+
+// longestTimeout returns the largest of the default and the per-service timeouts
+func longestTimeout(defaultTimeout time.Duration, timeouts map[string]time.Duration) time.Duration {
+	longest := defaultTimeout
+	for _, timeout := range timeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	return longest
+}
+
+// parseAge parses a duration that additionally accepts a day suffix ("90d").
+// An empty string yields 0 (disabled).
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid day count %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return d, nil
+}
+
+// taskMonitorOptions configures the task monitors monitorServiceTasks starts
+type taskMonitorOptions struct {
+	ShowLogs   bool                 // Stream the containers' logs
+	ShowEvents bool                 // Report task lifecycle events
+	Log        health.StreamOptions // How the logs of the service's tasks are printed
+	Events     output.Emitter
+	DeployID   string
+}
+
 // monitorServiceTasks monitors task lifecycle events for a service and logs them
-func monitorServiceTasks(ctx context.Context, cli *client.Client, svc swarm.ServiceUpdateResult, eventChan <-chan health.Event, showLogs bool, deployID string) {
-	log.Printf("[ServiceMonitor] Started monitoring service: %s (version: %d, deployID: %s)", svc.ServiceName, svc.Version.Index, deployID)
+func monitorServiceTasks(ctx context.Context, cli client.APIClient, svc swarm.ServiceUpdateResult, eventChan <-chan health.Event, opts taskMonitorOptions) {
+	log.Printf("[ServiceMonitor] Started monitoring service: %s (version: %d, deployID: %s)", svc.ServiceName, svc.Version.Index, opts.DeployID)
 
 	// Track active task monitors
 	taskMonitors := make(map[string]*health.Monitor)
 	var mu sync.Mutex
 
-	// Create cleanup goroutine
+	// Stop all task monitors and wait for their log streams to close
 	defer func() {
 		mu.Lock()
+		running := make([]*health.Monitor, 0, len(taskMonitors))
 		for taskID, monitor := range taskMonitors {
 			log.Printf("[ServiceMonitor] Stopping monitor for task %s", taskID[:12])
 			monitor.Stop()
+			running = append(running, monitor)
 		}
 		mu.Unlock()
+		for _, monitor := range running {
+			select {
+			case <-monitor.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
 		log.Printf("[ServiceMonitor] Stopped monitoring service: %s", svc.ServiceName)
 	}()
 
@@ -301,7 +1313,9 @@ func monitorServiceTasks(ctx context.Context, cli *client.Client, svc swarm.Serv
 				log.Printf("[ServiceMonitor] New task detected: %s for service %s",
 					taskID[:12], svc.ServiceName)
 
-				monitor = health.NewMonitorWithLogs(cli, taskID, svc.ServiceID, svc.ServiceName, showLogs)
+				monitor = health.NewMonitorWithLogs(cli, taskID, svc.ServiceID, svc.ServiceName, opts.ShowLogs)
+				monitor.SetLogOptions(opts.Log)
+				monitor.SetShowEvents(opts.ShowEvents)
 				taskMonitors[taskID] = monitor
 
 				// Start monitor in background
@@ -325,47 +1339,203 @@ func monitorServiceTasks(ctx context.Context, cli *client.Client, svc swarm.Serv
 
 			mu.Unlock()
 
-			// Log important events at service level
+			// Report important events at service level
+			var message string
 			switch event.Type {
 			case health.EventTypeCreated:
-				log.Printf("[ServiceMonitor] 🆕 Service %s: Task %s created",
+				message = fmt.Sprintf("[ServiceMonitor] 🆕 Service %s: Task %s created",
 					svc.ServiceName, taskID[:12])
 			case health.EventTypeFailed:
-				log.Printf("[ServiceMonitor] ❌ Service %s: Task %s failed - %s",
+				message = fmt.Sprintf("[ServiceMonitor] ❌ Service %s: Task %s failed - %s",
 					svc.ServiceName, taskID[:12], event.Message)
 			case health.EventTypeHealthy:
-				log.Printf("[ServiceMonitor] 💚 Service %s: Task %s is healthy",
+				message = fmt.Sprintf("[ServiceMonitor] 💚 Service %s: Task %s is healthy",
 					svc.ServiceName, taskID[:12])
 			case health.EventTypeUnhealthy:
-				log.Printf("[ServiceMonitor] 💔 Service %s: Task %s is unhealthy - %s",
+				message = fmt.Sprintf("[ServiceMonitor] 💔 Service %s: Task %s is unhealthy - %s",
 					svc.ServiceName, taskID[:12], event.Message)
 			case health.EventTypeRunning:
-				log.Printf("[ServiceMonitor] ✅ Service %s: Task %s is running",
+				message = fmt.Sprintf("[ServiceMonitor] ✅ Service %s: Task %s is running",
 					svc.ServiceName, taskID[:12])
 			}
+			if message != "" && opts.ShowEvents {
+				opts.Events.Emit(output.Event{
+					Type:    output.EventTaskState,
+					Service: svc.ServiceName,
+					Task:    taskID,
+					State:   string(event.Type),
+					Message: message,
+				})
+			}
 		}
 	}
 }
 
-// waitForAllTasksHealthy waits for all tasks of updated services to become healthy
-func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName string, updatedServices []swarm.ServiceUpdateResult, deployID string) error {
-	ticker := time.NewTicker(2 * time.Second)
+// waitStopped describes why a wait ended before its services were ready:
+// cancelled (e.g. the operator pressed Ctrl-C) or out of time
+func waitStopped(ctx context.Context, startTime time.Time, what string) error {
+	elapsed := time.Since(startTime).Round(time.Second)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("cancelled after %v waiting for %s: %w", elapsed, what, ctx.Err())
+	}
+	return fmt.Errorf("timeout after %v waiting for %s", elapsed, what)
+}
+
+// convergePollInterval is how often waitForConvergence checks the services
+var convergePollInterval = 2 * time.Second
+
+// waitForConvergence waits until swarm reports every updated service as converged:
+// the rollout is no longer in progress and the service runs as many tasks of this
+// deployment as it wants (one per eligible node for global services). Container health is ignored.
+func waitForConvergence(ctx context.Context, cli swarm.DockerClient, updatedServices []swarm.ServiceUpdateResult, deployID string) error {
+	ticker := time.NewTicker(convergePollInterval)
 	defer ticker.Stop()
 
+	startTime := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return waitStopped(ctx, startTime, "services to converge")
+
+		case <-ticker.C:
+			var pending []string
+			for _, svc := range updatedServices {
+				if ctx.Err() != nil {
+					return waitStopped(ctx, startTime, "services to converge")
+				}
+				converged, status, err := serviceConverged(ctx, cli, svc, deployID)
+				if err != nil {
+					log.Printf("[Converge] Failed to check service %s: %v", svc.ServiceName, err)
+					pending = append(pending, svc.ServiceName+" (check failed)")
+					continue
+				}
+				if !converged {
+					pending = append(pending, fmt.Sprintf("%s (%s)", svc.ServiceName, status))
+				}
+			}
+
+			if len(pending) == 0 {
+				return nil
+			}
+			log.Printf("[Converge] Waiting for: %v", pending)
+		}
+	}
+}
+
+// serviceConverged reports whether a service finished its rollout and runs all desired
+// tasks of this deployment, along with a short status for progress output
+func serviceConverged(ctx context.Context, cli swarm.DockerClient, svc swarm.ServiceUpdateResult, deployID string) (bool, string, error) {
+	service, _, err := cli.ServiceInspectWithRaw(ctx, svc.ServiceID, types.ServiceInspectOptions{})
+	if err != nil {
+		return false, "", err
+	}
+
+	if service.UpdateStatus != nil && service.UpdateStatus.State != "" && service.UpdateStatus.State != dockerswarm.UpdateStateCompleted {
+		return false, "update " + string(service.UpdateStatus.State), nil
+	}
+
+	filter := filters.NewArgs()
+	filter.Add("service", svc.ServiceID)
+	tasks, err := cli.TaskList(ctx, types.TaskListOptions{Filters: filter})
+	if err != nil {
+		return false, "", err
+	}
+
+	// Count replica positions, not tasks: with update order start-first a position
+	// briefly has two tasks, which must not make up for a position with none
+	desired := make(map[string]bool)
+	running := make(map[string]bool)
+	for _, t := range tasks {
+		if t.DesiredState != dockerswarm.TaskStateRunning {
+			continue
+		}
+		if t.Spec.ContainerSpec == nil || t.Spec.ContainerSpec.Labels["com.stackman.deploy.id"] != deployID {
+			continue
+		}
+		position := taskPosition(t)
+		desired[position] = true
+		if t.Status.State == dockerswarm.TaskStateRunning {
+			running[position] = true
+		}
+	}
+
+	want := len(desired)
+	if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+		want = int(*service.Spec.Mode.Replicated.Replicas)
+	}
+	if service.Spec.Mode.Global != nil {
+		// One task per eligible node
+		want, err = swarm.GlobalTaskTarget(ctx, cli, service.Spec.TaskTemplate.Placement)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	status := fmt.Sprintf("%d/%d running", len(running), want)
+	return len(running) >= want && len(running) == len(desired), status, nil
+}
+
+// taskPosition identifies the replica a task fills: its slot for replicated
+// services, its node for global ones
+func taskPosition(t dockerswarm.Task) string {
+	switch {
+	case t.Slot > 0:
+		return fmt.Sprintf("slot %d", t.Slot)
+	case t.NodeID != "":
+		return "node " + t.NodeID
+	}
+	return "task " + t.ID
+}
+
+// Bounds of the backoff between health polls in waitForAllTasksHealthy
+var (
+	healthPollInitial = health.DefaultPollInitial
+	healthPollMax     = health.DefaultPollMax
+)
+
+// waitForAllTasksHealthy waits for all tasks of updated services to become healthy.
+// Each service must be healthy within its own timeout (healthTimeouts, falling back to defaultTimeout).
+// Polls back off from healthPollInitial to healthPollMax, but never sleep past a pending service's timeout.
+// New health check output is logged once per check, truncated to healthLog.
+func waitForAllTasksHealthy(ctx context.Context, cli client.APIClient, stackName string, updatedServices []swarm.ServiceUpdateResult, deployID string, defaultTimeout time.Duration, healthTimeouts map[string]time.Duration, healthLog health.HealthLogLimits, events output.Emitter) error {
+	backoff := health.NewPollBackoff(healthPollInitial, healthPollMax)
+	timer := time.NewTimer(backoff.Next())
+	defer timer.Stop()
+
 	startTime := time.Now()
 	serviceHealthyCount := make(map[string]int)
+	serviceDesiredCount := make(map[string]int)
+	serviceReady := make(map[string]bool)
+	lastHealthCheck := make(map[string]time.Time)
+
+	emitHealth := func(service, taskID, state, format string, args ...interface{}) {
+		events.Emit(output.Event{
+			Type:    output.EventHealth,
+			Stack:   stackName,
+			Service: service,
+			Task:    taskID,
+			State:   state,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			elapsed := time.Since(startTime).Round(time.Second)
-			return fmt.Errorf("timeout after %v waiting for services to become healthy", elapsed)
+			return waitStopped(ctx, startTime, "services to become healthy")
 
-		case <-ticker.C:
+		case <-timer.C:
 			allHealthy := true
 			unhealthyTasks := []string{}
 
 			for _, svc := range updatedServices {
+				// Don't keep polling the remaining services once cancelled
+				if ctx.Err() != nil {
+					return waitStopped(ctx, startTime, "services to become healthy")
+				}
+				serviceReady[svc.ServiceName] = false
+				unhealthyBefore := len(unhealthyTasks)
+
 				// Get current service by name to get updated service ID
 				// During updates, service ID remains the same but this ensures we have the latest service state
 				serviceFilter := filters.NewArgs()
@@ -386,6 +1556,12 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 
 				currentServiceID := services[0].ID
 
+				if status, err := health.GetServiceStatus(ctx, cli, services[0]); err != nil {
+					log.Printf("[HealthCheck] Failed to get status of service %s: %v", svc.ServiceName, err)
+				} else {
+					serviceDesiredCount[svc.ServiceName] = status.DesiredTasks
+				}
+
 				// Get ALL tasks for this service
 				// Note: Docker API does not support label filtering for tasks, only for containers
 				// So we get all tasks and filter manually
@@ -414,6 +1590,11 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 				log.Printf("[HealthCheck] Service %s: found %d tasks with deployID %s (total tasks: %d)",
 					svc.ServiceName, len(tasks), deployID, len(allTasks))
 
+				// Surface tasks stuck before running (e.g. a node still pulling the image)
+				if pending := health.SummarizePendingTasks(tasks, time.Now()); pending != "" {
+					log.Printf("[HealthCheck] ⏳ Service %s pending tasks: %s", svc.ServiceName, pending)
+				}
+
 				healthyTaskCount := 0
 				hasRunningTask := false
 
@@ -421,6 +1602,10 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 					// deployID label guarantees correct tasks - no version check needed
 
 					// Log failed/shutdown tasks but don't fail immediately (Docker Swarm may restart)
+					// A missing or inaccessible image won't fix itself by waiting
+					if reason := health.ImagePullFailure(t); reason != "" {
+						return fmt.Errorf("image pull failed for service %s: %s", svc.ServiceName, reason)
+					}
 					if t.Status.State == dockerswarm.TaskStateFailed ||
 						t.Status.State == dockerswarm.TaskStateShutdown ||
 						t.Status.State == dockerswarm.TaskStateRejected {
@@ -440,13 +1625,13 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 					if t.Status.State != dockerswarm.TaskStateRunning {
 						allHealthy = false
 						unhealthyTasks = append(unhealthyTasks, fmt.Sprintf("%s/%s (state: %s)", svc.ServiceName, t.ID[:12], t.Status.State))
-						log.Printf("[HealthCheck] ⏳ Task %s (%s) is %s", t.ID[:12], svc.ServiceName, t.Status.State)
+						emitHealth(svc.ServiceName, t.ID, string(t.Status.State), "[HealthCheck] ⏳ Task %s (%s) is %s", t.ID[:12], svc.ServiceName, t.Status.State)
 						continue
 					}
 
 					// Check container health if healthcheck is defined
 					if t.Status.ContainerStatus != nil && t.Status.ContainerStatus.ContainerID != "" {
-						containerInfo, err := cli.ContainerInspect(ctx, t.Status.ContainerStatus.ContainerID)
+						containerInfo, err := throttle.ContainerInspect(ctx, cli, t.Status.ContainerStatus.ContainerID)
 						if err != nil {
 							log.Printf("[HealthCheck] Failed to inspect container %s for task %s (%s): %v",
 								t.Status.ContainerStatus.ContainerID[:12], t.ID[:12], svc.ServiceName, err)
@@ -455,26 +1640,34 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 							continue
 						}
 
+						// Log the output of each new health check result once
+						if last := health.LastHealthCheck(containerInfo.State.Health); last != nil && !last.End.Equal(lastHealthCheck[containerInfo.ID]) {
+							lastHealthCheck[containerInfo.ID] = last.End
+							if out := health.FormatHealthLog(last, healthLog); out != "" {
+								log.Printf("[HealthCheck] Task %s (%s) health check exited %d:\n%s", t.ID[:12], svc.ServiceName, last.ExitCode, out)
+							}
+						}
+
 						// If container has health check, wait for healthy status
 						if containerInfo.State.Health != nil {
 							if containerInfo.State.Health.Status != container.Healthy {
 								allHealthy = false
 								unhealthyTasks = append(unhealthyTasks, fmt.Sprintf("%s/%s (health: %s)", svc.ServiceName, t.ID[:12], containerInfo.State.Health.Status))
-								log.Printf("[HealthCheck] ⏳ Task %s (%s) is %s", t.ID[:12], svc.ServiceName, containerInfo.State.Health.Status)
+								emitHealth(svc.ServiceName, t.ID, containerInfo.State.Health.Status, "[HealthCheck] ⏳ Task %s (%s) is %s", t.ID[:12], svc.ServiceName, containerInfo.State.Health.Status)
 							} else {
-								log.Printf("[HealthCheck] ✅ Task %s (%s) is healthy", t.ID[:12], svc.ServiceName)
+								emitHealth(svc.ServiceName, t.ID, container.Healthy, "[HealthCheck] ✅ Task %s (%s) is healthy", t.ID[:12], svc.ServiceName)
 								healthyTaskCount++
 							}
 						} else {
 							// No healthcheck defined, just check if running
-							log.Printf("[HealthCheck] ✅ Task %s (%s) is running (no healthcheck)", t.ID[:12], svc.ServiceName)
+							emitHealth(svc.ServiceName, t.ID, string(dockerswarm.TaskStateRunning), "[HealthCheck] ✅ Task %s (%s) is running (no healthcheck)", t.ID[:12], svc.ServiceName)
 							healthyTaskCount++
 						}
 					} else {
 						// No container status yet
 						allHealthy = false
 						unhealthyTasks = append(unhealthyTasks, fmt.Sprintf("%s/%s (no container)", svc.ServiceName, t.ID[:12]))
-						log.Printf("[HealthCheck] ⏳ Task %s (%s) has no container yet", t.ID[:12], svc.ServiceName)
+						emitHealth(svc.ServiceName, t.ID, "no_container", "[HealthCheck] ⏳ Task %s (%s) has no container yet", t.ID[:12], svc.ServiceName)
 					}
 				}
 
@@ -485,6 +1678,35 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 				}
 
 				serviceHealthyCount[svc.ServiceName] = healthyTaskCount
+				serviceReady[svc.ServiceName] = hasRunningTask && healthyTaskCount > 0 && len(unhealthyTasks) == unhealthyBefore
+			}
+
+			progress := deployProgress(serviceHealthyCount, serviceDesiredCount)
+			events.Emit(output.Event{
+				Type:    output.EventProgress,
+				Stack:   stackName,
+				Message: fmt.Sprintf("[HealthCheck] Progress: %d%% (%d/%d tasks healthy)", progress.Percent, progress.Healthy, progress.Desired),
+				Data:    progress,
+			})
+
+			// Fail as soon as a service exceeds its own timeout
+			elapsed := time.Since(startTime)
+			nextPoll := backoff.Next()
+			for _, svc := range updatedServices {
+				timeout := defaultTimeout
+				if override, ok := healthTimeouts[svc.ServiceName]; ok {
+					timeout = override
+				}
+				if serviceReady[svc.ServiceName] {
+					continue
+				}
+				if elapsed > timeout {
+					return fmt.Errorf("service %s did not become healthy within %v", svc.ServiceName, timeout)
+				}
+				// Poll again just after the deadline so the failure is reported on time
+				if untilDeadline := timeout - elapsed + time.Millisecond; untilDeadline < nextPoll {
+					nextPoll = untilDeadline
+				}
 			}
 
 			// Check that all services have at least one healthy task
@@ -501,6 +1723,64 @@ func waitForAllTasksHealthy(ctx context.Context, cli *client.Client, stackName s
 			if len(unhealthyTasks) > 0 {
 				log.Printf("[HealthCheck] Waiting for: %v", unhealthyTasks)
 			}
+			timer.Reset(nextPoll)
+		}
+	}
+}
+
+// healthProgress is the share of desired tasks that are healthy across the updated services
+type healthProgress struct {
+	Healthy int `json:"healthy"`
+	Desired int `json:"desired"`
+	Percent int `json:"percent"`
+}
+
+// deployProgress sums healthy tasks against desired tasks per service. Healthy tasks
+// beyond a service's desired count (the overlap of a start-first update) are not counted,
+// so progress never passes 100%.
+func deployProgress(healthy, desired map[string]int) healthProgress {
+	var progress healthProgress
+	for service, want := range desired {
+		progress.Desired += want
+		progress.Healthy += min(healthy[service], want)
+	}
+	if progress.Desired > 0 {
+		progress.Percent = progress.Healthy * 100 / progress.Desired
+	}
+	return progress
+}
+
+// writeRenderedCompose writes the effective compose file to path for audit trails
+func writeRenderedCompose(path string, composeSpec *compose.ComposeFile) error {
+	data, err := compose.Render(composeSpec)
+	if err != nil {
+		return fmt.Errorf("failed to render compose file: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write rendered compose file: %w", err)
+	}
+	return nil
+}
+
+// interpolationVars merges the -values file and -set pairs; -set wins on conflicts
+func interpolationVars(valuesFile, setValues string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if valuesFile != "" {
+		fileVars, err := compose.LoadVarFile(valuesFile)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileVars {
+			vars[k] = v
 		}
 	}
+
+	setVars, err := compose.ParseSetValues(setValues)
+	if err != nil {
+		return nil, fmt.Errorf("-set: %w", err)
+	}
+	for k, v := range setVars {
+		vars[k] = v
+	}
+	return vars, nil
 }
@@ -0,0 +1,1086 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/output"
+	"github.com/SomeBlackMagic/stackman/internal/plan"
+	"github.com/SomeBlackMagic/stackman/internal/snapshot"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+func TestCheckEmptyStack(t *testing.T) {
+	empty := &compose.ComposeFile{Services: map[string]*compose.Service{}}
+	nonEmpty := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"web": {Image: "nginx:1.25"},
+	}}
+
+	tests := []struct {
+		name        string
+		composeFile *compose.ComposeFile
+		allowEmpty  bool
+		wantErr     bool
+	}{
+		{"empty rejected by default", empty, false, true},
+		{"empty allowed with flag", empty, true, false},
+		{"services present", nonEmpty, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEmptyStack(tt.composeFile, tt.allowEmpty)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEmptyStack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckLatestTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		allowLatest bool
+		wantErr     bool
+	}{
+		{"tagged", "nginx:1.25", false, false},
+		{"untagged", "nginx", false, true},
+		{"latest", "nginx:latest", false, true},
+		{"registry port untagged", "localhost:5000/app", false, true},
+		{"digest", "nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac", false, false},
+		{"latest allowed", "nginx:latest", true, false},
+		{"build only", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			composeFile := &compose.ComposeFile{Services: map[string]*compose.Service{
+				"web": {Image: tt.image},
+			}}
+			err := checkLatestTags(composeFile, tt.allowLatest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkLatestTags(%q) error = %v, wantErr %v", tt.image, err, tt.wantErr)
+			}
+		})
+	}
+
+	composeFile := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"web":    {Image: "nginx"},
+		"api":    {Image: "api:latest"},
+		"worker": {Image: "worker:2.0"},
+	}}
+	err := checkLatestTags(composeFile, false)
+	if err == nil || !strings.Contains(err.Error(), "api (api:latest), web (nginx)") {
+		t.Errorf("Expected every offending service in the error, got %v", err)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"xd", 0, true},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestServiceHealthTimeouts(t *testing.T) {
+	composeSpec := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"migrate": {Image: "app:1", Labels: map[string]string{"stackman.health_timeout": "15m"}},
+		"web": {Image: "nginx:1.25", Deploy: &compose.DeployConfig{
+			Labels: map[string]string{"stackman.health_timeout": "2m"},
+		}},
+		"both": {
+			Image:  "app:1",
+			Labels: map[string]string{"stackman.health_timeout": "1m"},
+			Deploy: &compose.DeployConfig{Labels: map[string]string{"stackman.health_timeout": "3m"}},
+		},
+		"plain": {Image: "redis:7"},
+	}}
+
+	timeouts, err := serviceHealthTimeouts("app", composeSpec)
+	if err != nil {
+		t.Fatalf("serviceHealthTimeouts failed: %v", err)
+	}
+	want := map[string]time.Duration{
+		"app_migrate": 15 * time.Minute,
+		"app_web":     2 * time.Minute,
+		"app_both":    3 * time.Minute,
+	}
+	if len(timeouts) != len(want) {
+		t.Errorf("Expected %d overrides, got %v", len(want), timeouts)
+	}
+	for name, d := range want {
+		if timeouts[name] != d {
+			t.Errorf("Expected %s timeout %v, got %v", name, d, timeouts[name])
+		}
+	}
+
+	if got := longestTimeout(10*time.Minute, timeouts); got != 15*time.Minute {
+		t.Errorf("Expected longest timeout 15m, got %v", got)
+	}
+
+	invalid := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"web": {Image: "nginx:1.25", Labels: map[string]string{"stackman.health_timeout": "fast"}},
+	}}
+	if _, err := serviceHealthTimeouts("app", invalid); err == nil || !strings.Contains(err.Error(), "service web") {
+		t.Errorf("Expected error naming service web, got %v", err)
+	}
+}
+
+func TestRollbackOnInterrupt_WaitsForRollback(t *testing.T) {
+	finished := false
+	rollback := func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		finished = true
+		return nil
+	}
+
+	var out bytes.Buffer
+	code := rollbackOnInterrupt(os.Interrupt, time.Second, rollback, &out)
+
+	if !finished {
+		t.Error("Expected rollback to finish before returning")
+	}
+	if code != 130 {
+		t.Errorf("Expected exit code 130, got %d", code)
+	}
+	if !strings.Contains(out.String(), "Rollback: completed") {
+		t.Errorf("Expected successful rollback summary, got:\n%s", out.String())
+	}
+}
+
+func TestRollbackOnInterrupt_ReportsFailure(t *testing.T) {
+	rollback := func(ctx context.Context) error {
+		return errors.New("service web: update out of sequence")
+	}
+
+	var out bytes.Buffer
+	rollbackOnInterrupt(os.Interrupt, time.Second, rollback, &out)
+
+	if !strings.Contains(out.String(), "Rollback: FAILED") || !strings.Contains(out.String(), "out of sequence") {
+		t.Errorf("Expected failed rollback summary, got:\n%s", out.String())
+	}
+}
+
+func TestRollbackOnInterrupt_BoundedByTimeout(t *testing.T) {
+	rollback := func(ctx context.Context) error {
+		time.Sleep(5 * time.Second)
+		return nil
+	}
+
+	var out bytes.Buffer
+	start := time.Now()
+	rollbackOnInterrupt(os.Interrupt, 100*time.Millisecond, rollback, &out)
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected rollback wait to be bounded by timeout, took %v", time.Since(start))
+	}
+	if !strings.Contains(out.String(), "did not finish within") {
+		t.Errorf("Expected timeout in summary, got:\n%s", out.String())
+	}
+}
+
+func TestNewApplyDeployer_PruneWait(t *testing.T) {
+	opts := &ApplyOptions{Prune: true, PruneWait: 45 * time.Second, Parallel: 2}
+	deployer := newApplyDeployer(&swarm.MockDockerClient{}, "mystack", opts)
+
+	if !deployer.Prune || deployer.PruneWait != 45*time.Second {
+		t.Errorf("Expected -prune with a 45s -prune-wait, got Prune=%v PruneWait=%v", deployer.Prune, deployer.PruneWait)
+	}
+	if deployer.Parallel != 2 {
+		t.Errorf("Expected Parallel 2, got %d", deployer.Parallel)
+	}
+}
+
+func TestAwaitInterruptExit(t *testing.T) {
+	exitCode := make(chan int, 1)
+	exitCode <- 1
+	if code := awaitInterruptExit(exitCode, time.Second); code != 1 {
+		t.Errorf("Expected the signal handler's exit code 1, got %d", code)
+	}
+
+	start := time.Now()
+	if code := awaitInterruptExit(make(chan int), 100*time.Millisecond); code != 130 {
+		t.Errorf("Expected exit code 130 without a report, got %d", code)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the wait to be bounded by the timeout, took %v", time.Since(start))
+	}
+}
+
+func TestRollback_JSONRPCStdoutIsNDJSON(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	stdout, logOut, logFlags := os.Stdout, log.Writer(), log.Flags()
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+		log.SetOutput(logOut)
+		log.SetFlags(logFlags)
+	}()
+
+	captured := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		captured <- string(data)
+	}()
+
+	// Set up stdout the way -protocol jsonrpc does, then roll back a first deploy
+	rpc := jsonRPCOutput(os.Stdout)
+	deployer := swarm.NewStackDeployer(&swarm.MockDockerClient{}, "mystack", 3)
+	if err := snapshot.Rollback(context.Background(), deployer, &swarm.StackSnapshot{StackName: "mystack", IsFirstDeploy: true}, time.Second); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	rpc.Done("mystack", "", errors.New("deployment failed"))
+	w.Close()
+	os.Stdout = stdout
+
+	out := <-captured
+	sawRollback := false
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		var n output.Notification
+		if err := json.Unmarshal([]byte(line), &n); err != nil || n.JSONRPC != "2.0" {
+			t.Errorf("stdout line is not a JSON-RPC notification: %q", line)
+			continue
+		}
+		if strings.Contains(line, "Rollback completed successfully") {
+			sawRollback = true
+		}
+	}
+	if !sawRollback {
+		t.Errorf("Expected the rollback result as a notification, got:\n%s", out)
+	}
+}
+
+// planWriter records whether cli had mutated anything when the plan was written
+type planWriter struct {
+	cli              *swarm.MockDockerClient
+	mutationsAtWrite []int
+	buf              bytes.Buffer
+}
+
+func (w *planWriter) Write(p []byte) (int, error) {
+	w.mutationsAtWrite = append(w.mutationsAtWrite, w.cli.MutationCount())
+	return w.buf.Write(p)
+}
+
+func TestPreviewPlan_PrintedBeforeMutations(t *testing.T) {
+	cli := &swarm.MockDockerClient{}
+	out := &planWriter{cli: cli}
+
+	composeSpec := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.25"},
+		},
+	}
+
+	deployPlan, err := previewPlan(context.Background(), cli, "mystack", composeSpec, out, &ApplyOptions{})
+	if err != nil {
+		t.Fatalf("previewPlan failed: %v", err)
+	}
+	if deployPlan.IsEmpty() {
+		t.Fatal("Expected a non-empty plan for a new service")
+	}
+	if !strings.Contains(out.buf.String(), "web") {
+		t.Errorf("Expected plan to mention web, got:\n%s", out.buf.String())
+	}
+
+	if len(out.mutationsAtWrite) == 0 {
+		t.Fatal("Expected the plan to be written")
+	}
+	for i, n := range out.mutationsAtWrite {
+		if n != 0 {
+			t.Fatalf("Write %d of the plan happened after %d mutations", i, n)
+		}
+	}
+	if n := cli.MutationCount(); n != 0 {
+		t.Fatalf("Expected no mutations while planning, got %d", n)
+	}
+
+	deployer := swarm.NewStackDeployer(cli, "mystack", 3)
+	if _, err := deployer.Deploy(context.Background(), composeSpec, "deploy-1"); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if cli.MutationCount() == 0 {
+		t.Fatal("Expected Deploy to perform mutations")
+	}
+}
+
+func TestConfirmPlan(t *testing.T) {
+	composeSpec := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.25"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		interactive bool
+		assumeYes   bool
+		wantProceed bool
+		wantErr     bool
+	}{
+		{name: "no aborts", input: "no\n", interactive: true, wantProceed: false},
+		{name: "yes proceeds", input: "yes\n", interactive: true, wantProceed: true},
+		{name: "y is not enough", input: "y\n", interactive: true, wantProceed: false},
+		{name: "closed stdin aborts", input: "", interactive: true, wantProceed: false},
+		{name: "non-tty requires --yes", input: "yes\n", interactive: false, wantErr: true},
+		{name: "non-tty with --yes", input: "", interactive: false, assumeYes: true, wantProceed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &swarm.MockDockerClient{}
+			out := &planWriter{cli: cli}
+
+			deployPlan, err := previewPlan(context.Background(), cli, "mystack", composeSpec, out, &ApplyOptions{})
+			if err != nil {
+				t.Fatalf("previewPlan failed: %v", err)
+			}
+
+			proceed, err := confirmPlan(deployPlan, strings.NewReader(tt.input), io.Discard, tt.interactive, tt.assumeYes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("confirmPlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if proceed != tt.wantProceed {
+				t.Fatalf("confirmPlan() = %v, want %v", proceed, tt.wantProceed)
+			}
+
+			// Only a confirmed plan may be applied
+			if proceed {
+				deployer := swarm.NewStackDeployer(cli, "mystack", 3)
+				if _, err := deployer.Deploy(context.Background(), composeSpec, "deploy-1"); err != nil {
+					t.Fatalf("Deploy failed: %v", err)
+				}
+			}
+
+			if mutated := cli.MutationCount() > 0; mutated != tt.wantProceed {
+				t.Errorf("mutations performed = %v, want %v", mutated, tt.wantProceed)
+			}
+		})
+	}
+}
+
+func TestConfirmPlan_EmptyPlanNeedsNoAnswer(t *testing.T) {
+	proceed, err := confirmPlan(&plan.Plan{StackName: "mystack"}, strings.NewReader(""), io.Discard, false, false)
+	if err != nil || !proceed {
+		t.Errorf("Expected empty plan to proceed without confirmation, got %v, %v", proceed, err)
+	}
+}
+
+func TestResultEvent(t *testing.T) {
+	ok := resultEvent("app", "deploy-1", nil)
+	if ok.State != "success" || ok.ExitCode == nil || *ok.ExitCode != 0 || ok.Error != "" {
+		t.Errorf("Unexpected success result: %+v", ok)
+	}
+
+	failed := resultEvent("app", "deploy-1", errors.New("health check timed out"))
+	if failed.State != "failed" || failed.ExitCode == nil || *failed.ExitCode != 1 || failed.Error != "health check timed out" {
+		t.Errorf("Unexpected failure result: %+v", failed)
+	}
+}
+
+func TestApplyHealthcheckOverrides(t *testing.T) {
+	newSpec := func() *compose.ComposeFile {
+		return &compose.ComposeFile{Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.25", HealthCheck: &compose.HealthCheck{Test: "wget -q localhost"}},
+			"api": {Image: "app:1"},
+		}}
+	}
+	testOf := func(t *testing.T, svc *compose.Service) []string {
+		t.Helper()
+		spec, err := compose.ConvertToSwarmSpec("svc", svc, "mystack", "")
+		if err != nil {
+			t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+		}
+		if spec.TaskTemplate.ContainerSpec.Healthcheck == nil {
+			return nil
+		}
+		return spec.TaskTemplate.ContainerSpec.Healthcheck.Test
+	}
+
+	t.Run("disable one service", func(t *testing.T) {
+		composeSpec := newSpec()
+		if err := applyHealthcheckOverrides(composeSpec, []string{"web"}, ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := testOf(t, composeSpec.Services["web"]); strings.Join(got, " ") != "NONE" {
+			t.Errorf("Expected web healthcheck disabled, got %q", got)
+		}
+		if got := testOf(t, composeSpec.Services["api"]); got != nil {
+			t.Errorf("Expected api untouched, got %q", got)
+		}
+	})
+
+	t.Run("replace for one service", func(t *testing.T) {
+		composeSpec := newSpec()
+		if err := applyHealthcheckOverrides(composeSpec, nil, "web=CMD curl localhost"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := testOf(t, composeSpec.Services["web"]); strings.Join(got, " ") != "CMD curl localhost" {
+			t.Errorf("Expected web test replaced, got %q", got)
+		}
+		if got := testOf(t, composeSpec.Services["api"]); got != nil {
+			t.Errorf("Expected api untouched, got %q", got)
+		}
+	})
+
+	t.Run("replace for all services", func(t *testing.T) {
+		composeSpec := newSpec()
+		if err := applyHealthcheckOverrides(composeSpec, nil, "CMD-SHELL curl -f http://localhost/?a=b"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, name := range []string{"web", "api"} {
+			if got := testOf(t, composeSpec.Services[name]); strings.Join(got, "|") != "CMD-SHELL|curl -f http://localhost/?a=b" {
+				t.Errorf("Expected %s test replaced, got %q", name, got)
+			}
+		}
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		if err := applyHealthcheckOverrides(newSpec(), []string{"db"}, ""); err == nil {
+			t.Error("Expected error for unknown service")
+		}
+	})
+}
+
+func TestWarningAnnotator_GitHubFormat(t *testing.T) {
+	data := `services:
+  web:
+    image: nginx:1.25
+  worker:
+    image: busybox:1.36
+    cpuset: "0,1"
+`
+	path := filepath.Join(t.TempDir(), "stack.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeSpec, err := compose.ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	annotator, err := output.NewAnnotator(&buf, output.AnnotationGitHub)
+	if err != nil {
+		t.Fatalf("NewAnnotator failed: %v", err)
+	}
+
+	deployer := swarm.NewStackDeployer(&swarm.MockDockerClient{}, "mystack", 3)
+	deployer.OnWarning = warningAnnotator(annotator, "stack.yml", composeSpec.ServiceLines)
+	deployer.FailOnWarning = true
+	if _, err := deployer.Deploy(context.Background(), composeSpec, "deploy-1"); err == nil {
+		t.Fatal("Expected deploy to abort on warning")
+	}
+
+	want := "::warning file=stack.yml,line=4::service worker: cpuset \"0,1\" is not supported by Docker Swarm and will be ignored\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected annotations:\ngot:  %q\nwant: %q", buf.String(), want)
+	}
+}
+
+// convergingClient reports a finished rollout whose tasks run but never pass a healthcheck
+type convergingClient struct {
+	*swarm.MockDockerClient
+	t        *testing.T
+	replicas uint64
+	polls    int
+}
+
+func (c *convergingClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (dockerswarm.Service, []byte, error) {
+	return dockerswarm.Service{
+		ID: serviceID,
+		Spec: dockerswarm.ServiceSpec{
+			Mode: dockerswarm.ServiceMode{Replicated: &dockerswarm.ReplicatedService{Replicas: &c.replicas}},
+		},
+		UpdateStatus: &dockerswarm.UpdateStatus{State: dockerswarm.UpdateStateCompleted},
+	}, nil, nil
+}
+
+func (c *convergingClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]dockerswarm.Task, error) {
+	// One more task reaches running on every poll
+	c.polls++
+	var tasks []dockerswarm.Task
+	for i := 0; i < int(c.replicas); i++ {
+		state := dockerswarm.TaskStateStarting
+		if i < c.polls {
+			state = dockerswarm.TaskStateRunning
+		}
+		tasks = append(tasks, dockerswarm.Task{
+			ID:           fmt.Sprintf("task%d", i),
+			DesiredState: dockerswarm.TaskStateRunning,
+			Spec: dockerswarm.TaskSpec{ContainerSpec: &dockerswarm.ContainerSpec{
+				Labels: map[string]string{"com.stackman.deploy.id": "deploy-1"},
+			}},
+			Status: dockerswarm.TaskStatus{State: state},
+		})
+	}
+	// A stale task from the previous deployment is ignored
+	tasks = append(tasks, dockerswarm.Task{
+		ID:           "old",
+		DesiredState: dockerswarm.TaskStateRunning,
+		Spec: dockerswarm.TaskSpec{ContainerSpec: &dockerswarm.ContainerSpec{
+			Labels: map[string]string{"com.stackman.deploy.id": "deploy-0"},
+		}},
+		Status: dockerswarm.TaskStatus{State: dockerswarm.TaskStateStarting},
+	})
+	return tasks, nil
+}
+
+func (c *convergingClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	c.t.Errorf("converge mode must not inspect container health (inspected %s)", containerID)
+	return types.ContainerJSON{}, nil
+}
+
+func TestWaitForConvergence_IgnoresHealth(t *testing.T) {
+	defer func(interval time.Duration) { convergePollInterval = interval }(convergePollInterval)
+	convergePollInterval = time.Millisecond
+
+	cli := &convergingClient{MockDockerClient: &swarm.MockDockerClient{}, t: t, replicas: 3}
+	services := []swarm.ServiceUpdateResult{{ServiceID: "svc1", ServiceName: "mystack_worker"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := waitForConvergence(ctx, cli, services, "deploy-1"); err != nil {
+		t.Fatalf("waitForConvergence failed: %v", err)
+	}
+	if cli.polls < 3 {
+		t.Errorf("Expected to wait until all 3 replicas run, returned after %d polls", cli.polls)
+	}
+}
+
+func TestWaitForConvergence_Timeout(t *testing.T) {
+	defer func(interval time.Duration) { convergePollInterval = interval }(convergePollInterval)
+	convergePollInterval = time.Millisecond
+
+	// No replica ever runs
+	cli := &convergingClient{MockDockerClient: &swarm.MockDockerClient{}, t: t, replicas: 2, polls: -1000000}
+	services := []swarm.ServiceUpdateResult{{ServiceID: "svc1", ServiceName: "mystack_worker"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitForConvergence(ctx, cli, services, "deploy-1"); err == nil || !strings.Contains(err.Error(), "converge") {
+		t.Errorf("Expected convergence timeout, got %v", err)
+	}
+}
+
+// cancellingClient cancels the wait's context while the first service is being checked
+type cancellingClient struct {
+	*convergingClient
+	cancel  context.CancelFunc
+	checked []string
+}
+
+func (c *cancellingClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (dockerswarm.Service, []byte, error) {
+	c.checked = append(c.checked, serviceID)
+	c.cancel()
+	return c.convergingClient.ServiceInspectWithRaw(ctx, serviceID, options)
+}
+
+func TestWaitForConvergence_CancelledMidWait(t *testing.T) {
+	defer func(interval time.Duration) { convergePollInterval = interval }(convergePollInterval)
+	convergePollInterval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// No replica ever runs, so only the cancellation can end the wait
+	cli := &cancellingClient{
+		convergingClient: &convergingClient{MockDockerClient: &swarm.MockDockerClient{}, t: t, replicas: 2, polls: -1000000},
+		cancel:           cancel,
+	}
+	services := []swarm.ServiceUpdateResult{
+		{ServiceID: "svc1", ServiceName: "mystack_api"},
+		{ServiceID: "svc2", ServiceName: "mystack_worker"},
+	}
+
+	start := time.Now()
+	err := waitForConvergence(ctx, cli, services, "deploy-1")
+	if err == nil || !strings.Contains(err.Error(), "cancelled") || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to stop promptly, took %v", elapsed)
+	}
+	if !reflect.DeepEqual(cli.checked, []string{"svc1"}) {
+		t.Errorf("Expected polling to stop after the cancellation, checked %v", cli.checked)
+	}
+}
+
+// overlapClient returns a fixed task list for a replicated service
+type overlapClient struct {
+	convergingClient
+	tasks []dockerswarm.Task
+}
+
+func (c *overlapClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]dockerswarm.Task, error) {
+	return c.tasks, nil
+}
+
+func TestServiceConverged_StartFirstOverlap(t *testing.T) {
+	task := func(id string, slot int, deployID string, state dockerswarm.TaskState) dockerswarm.Task {
+		return dockerswarm.Task{
+			ID:           id,
+			Slot:         slot,
+			DesiredState: dockerswarm.TaskStateRunning,
+			Spec: dockerswarm.TaskSpec{ContainerSpec: &dockerswarm.ContainerSpec{
+				Labels: map[string]string{"com.stackman.deploy.id": deployID},
+			}},
+			Status: dockerswarm.TaskStatus{State: state},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		tasks     []dockerswarm.Task
+		converged bool
+		status    string
+	}{
+		{
+			name: "old and new tasks overlap in every slot",
+			tasks: []dockerswarm.Task{
+				task("old1", 1, "deploy-0", dockerswarm.TaskStateRunning),
+				task("new1", 1, "deploy-1", dockerswarm.TaskStateRunning),
+				task("old2", 2, "deploy-0", dockerswarm.TaskStateRunning),
+				task("new2", 2, "deploy-1", dockerswarm.TaskStateStarting),
+			},
+			converged: false,
+			status:    "1/2 running",
+		},
+		{
+			name: "two running tasks in one slot don't cover an empty slot",
+			tasks: []dockerswarm.Task{
+				task("new1", 1, "deploy-1", dockerswarm.TaskStateRunning),
+				task("retry1", 1, "deploy-1", dockerswarm.TaskStateRunning),
+				task("old2", 2, "deploy-0", dockerswarm.TaskStateRunning),
+			},
+			converged: false,
+			status:    "1/2 running",
+		},
+		{
+			name: "every slot runs a new task while old ones drain",
+			tasks: []dockerswarm.Task{
+				task("old1", 1, "deploy-0", dockerswarm.TaskStateRunning),
+				task("new1", 1, "deploy-1", dockerswarm.TaskStateRunning),
+				task("old2", 2, "deploy-0", dockerswarm.TaskStateRunning),
+				task("new2", 2, "deploy-1", dockerswarm.TaskStateRunning),
+			},
+			converged: true,
+			status:    "2/2 running",
+		},
+		{
+			name: "a replacement starting alongside a running task",
+			tasks: []dockerswarm.Task{
+				task("new1", 1, "deploy-1", dockerswarm.TaskStateRunning),
+				task("next1", 1, "deploy-1", dockerswarm.TaskStateStarting),
+				task("new2", 2, "deploy-1", dockerswarm.TaskStateRunning),
+			},
+			converged: true,
+			status:    "2/2 running",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &overlapClient{
+				convergingClient: convergingClient{MockDockerClient: &swarm.MockDockerClient{}, t: t, replicas: 2},
+				tasks:            tt.tasks,
+			}
+			svc := swarm.ServiceUpdateResult{ServiceID: "svc1", ServiceName: "mystack_web"}
+
+			converged, status, err := serviceConverged(context.Background(), cli, svc, "deploy-1")
+			if err != nil {
+				t.Fatalf("serviceConverged failed: %v", err)
+			}
+			if converged != tt.converged || status != tt.status {
+				t.Errorf("Expected converged=%v (%s), got converged=%v (%s)", tt.converged, tt.status, converged, status)
+			}
+		})
+	}
+}
+
+// globalClient reports a global service restricted to workers on a mixed cluster
+type globalClient struct {
+	*swarm.MockDockerClient
+	nodes []dockerswarm.Node
+	tasks []dockerswarm.Task
+}
+
+func (c *globalClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (dockerswarm.Service, []byte, error) {
+	return dockerswarm.Service{
+		ID: serviceID,
+		Spec: dockerswarm.ServiceSpec{
+			Mode: dockerswarm.ServiceMode{Global: &dockerswarm.GlobalService{}},
+			TaskTemplate: dockerswarm.TaskSpec{
+				Placement: &dockerswarm.Placement{Constraints: []string{"node.role == worker"}},
+			},
+		},
+		UpdateStatus: &dockerswarm.UpdateStatus{State: dockerswarm.UpdateStateCompleted},
+	}, nil, nil
+}
+
+func (c *globalClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]dockerswarm.Node, error) {
+	return c.nodes, nil
+}
+
+func (c *globalClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]dockerswarm.Task, error) {
+	return c.tasks, nil
+}
+
+func TestServiceConverged_GlobalMode(t *testing.T) {
+	node := func(id string, role dockerswarm.NodeRole) dockerswarm.Node {
+		return dockerswarm.Node{
+			ID:     id,
+			Spec:   dockerswarm.NodeSpec{Role: role, Availability: dockerswarm.NodeAvailabilityActive},
+			Status: dockerswarm.NodeStatus{State: dockerswarm.NodeStateReady},
+		}
+	}
+	task := func(nodeID string, state dockerswarm.TaskState) dockerswarm.Task {
+		return dockerswarm.Task{
+			ID:           "task-" + nodeID,
+			NodeID:       nodeID,
+			DesiredState: dockerswarm.TaskStateRunning,
+			Spec: dockerswarm.TaskSpec{ContainerSpec: &dockerswarm.ContainerSpec{
+				Labels: map[string]string{"com.stackman.deploy.id": "deploy-1"},
+			}},
+			Status: dockerswarm.TaskStatus{State: state},
+		}
+	}
+	nodes := []dockerswarm.Node{
+		node("manager1", dockerswarm.NodeRoleManager),
+		node("worker1", dockerswarm.NodeRoleWorker),
+		node("worker2", dockerswarm.NodeRoleWorker),
+		node("worker3", dockerswarm.NodeRoleWorker),
+	}
+
+	tests := []struct {
+		name      string
+		tasks     []dockerswarm.Task
+		converged bool
+		status    string
+	}{
+		{
+			name:      "a single running task is not enough",
+			tasks:     []dockerswarm.Task{task("worker1", dockerswarm.TaskStateRunning)},
+			converged: false,
+			status:    "1/3 running",
+		},
+		{
+			name: "one node still starting",
+			tasks: []dockerswarm.Task{
+				task("worker1", dockerswarm.TaskStateRunning),
+				task("worker2", dockerswarm.TaskStateRunning),
+				task("worker3", dockerswarm.TaskStateStarting),
+			},
+			converged: false,
+			status:    "2/3 running",
+		},
+		{
+			name: "every eligible node runs a task",
+			tasks: []dockerswarm.Task{
+				task("worker1", dockerswarm.TaskStateRunning),
+				task("worker2", dockerswarm.TaskStateRunning),
+				task("worker3", dockerswarm.TaskStateRunning),
+			},
+			converged: true,
+			status:    "3/3 running",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &globalClient{MockDockerClient: &swarm.MockDockerClient{}, nodes: nodes, tasks: tt.tasks}
+			svc := swarm.ServiceUpdateResult{ServiceID: "svc1", ServiceName: "mystack_agent"}
+
+			converged, status, err := serviceConverged(context.Background(), cli, svc, "deploy-1")
+			if err != nil {
+				t.Fatalf("serviceConverged failed: %v", err)
+			}
+			if converged != tt.converged || status != tt.status {
+				t.Errorf("Expected converged=%v (%s), got converged=%v (%s)", tt.converged, tt.status, converged, status)
+			}
+		})
+	}
+}
+
+func TestApplyImageHealthcheckIgnores(t *testing.T) {
+	composeSpec := &compose.ComposeFile{Services: map[string]*compose.Service{
+		// No compose healthcheck: the image one would be inherited
+		"web": {Image: "nginx:1.25"},
+		// Only timing settings: the image test would still be inherited
+		"api": {Image: "app:1", HealthCheck: &compose.HealthCheck{Interval: "5s"}},
+		// Own compose test: kept
+		"db": {Image: "postgres:16", HealthCheck: &compose.HealthCheck{Test: "pg_isready"}},
+		"worker": {Image: "worker:1", Deploy: &compose.DeployConfig{
+			Labels: map[string]string{compose.IgnoreImageHealthcheckLabel: "true"},
+		}},
+		"cache": {Image: "redis:7"},
+	}}
+
+	if err := applyImageHealthcheckIgnores(composeSpec, []string{"web", "api", "db"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testOf := func(name string) []string {
+		spec, err := compose.ConvertToSwarmSpec(name, composeSpec.Services[name], "mystack", "")
+		if err != nil {
+			t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+		}
+		if spec.TaskTemplate.ContainerSpec.Healthcheck == nil {
+			return nil
+		}
+		return spec.TaskTemplate.ContainerSpec.Healthcheck.Test
+	}
+
+	for _, name := range []string{"web", "api", "worker"} {
+		if got := testOf(name); strings.Join(got, " ") != "NONE" {
+			t.Errorf("Expected %s image healthcheck overridden to NONE, got %q", name, got)
+		}
+	}
+	if got := testOf("db"); strings.Join(got, " ") != "CMD-SHELL pg_isready" {
+		t.Errorf("Expected db to keep its compose healthcheck, got %q", got)
+	}
+	if got := testOf("cache"); got != nil {
+		t.Errorf("Expected cache untouched, got %q", got)
+	}
+
+	if err := applyImageHealthcheckIgnores(composeSpec, []string{"missing"}); err == nil {
+		t.Error("Expected an error for an unknown service")
+	}
+
+	composeSpec.Services["cache"].Labels = map[string]string{compose.IgnoreImageHealthcheckLabel: "maybe"}
+	if err := applyImageHealthcheckIgnores(composeSpec, nil); err == nil {
+		t.Error("Expected an error for an invalid label value")
+	}
+}
+
+func TestWriteRenderedCompose(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	data := "services:\n  web:\n    image: nginx:${TAG}\n  worker:\n    image: worker:1\n"
+	if err := os.WriteFile(composePath, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	composeSpec, err := compose.ParseComposeFileWithVars(composePath, map[string]string{"TAG": "1.25"})
+	if err != nil {
+		t.Fatalf("ParseComposeFileWithVars failed: %v", err)
+	}
+	if err := applyHealthcheckOverrides(composeSpec, []string{"worker"}, ""); err != nil {
+		t.Fatalf("applyHealthcheckOverrides failed: %v", err)
+	}
+
+	renderPath := filepath.Join(dir, "rendered.yml")
+	if err := writeRenderedCompose(renderPath, composeSpec); err != nil {
+		t.Fatalf("writeRenderedCompose failed: %v", err)
+	}
+
+	rendered, err := compose.ParseComposeFile(renderPath)
+	if err != nil {
+		t.Fatalf("Rendered compose does not parse: %v", err)
+	}
+	if got := rendered.Services["web"].Image; got != "nginx:1.25" {
+		t.Errorf("Expected the interpolated image nginx:1.25, got %s", got)
+	}
+	if hc := rendered.Services["worker"].HealthCheck; hc == nil || !hc.Disable {
+		t.Errorf("Expected the healthcheck override to be rendered, got %+v", hc)
+	}
+
+	if err := writeRenderedCompose(filepath.Join(dir, "missing", "rendered.yml"), composeSpec); err == nil {
+		t.Error("Expected an error for an unwritable path")
+	}
+}
+
+func TestFilterPlanServices(t *testing.T) {
+	composeSpec := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"api": {Image: "api:1", Labels: map[string]string{"tier": "backend"}},
+		"web": {Image: "nginx:1.25", Labels: map[string]string{"tier": "frontend"}},
+	}}
+	orphanSpec := func(tier string) *dockerswarm.ServiceSpec {
+		return &dockerswarm.ServiceSpec{TaskTemplate: dockerswarm.TaskSpec{
+			ContainerSpec: &dockerswarm.ContainerSpec{Labels: map[string]string{"tier": tier}},
+		}}
+	}
+	deployPlan := &plan.Plan{Services: []plan.ServiceAction{
+		{Name: "api", Action: plan.ActionUpdate},
+		{Name: "web", Action: plan.ActionUpdate},
+		{Name: "old-worker", Action: plan.ActionDelete, CurrentSpec: orphanSpec("backend")},
+		{Name: "old-web", Action: plan.ActionDelete, CurrentSpec: orphanSpec("frontend")},
+	}}
+
+	filterPlanServices(deployPlan, composeSpec, &compose.LabelFilter{Key: "tier", Value: "backend"})
+
+	var names []string
+	for _, action := range deployPlan.Services {
+		names = append(names, action.Name)
+	}
+	if strings.Join(names, ",") != "api,old-worker" {
+		t.Errorf("Expected only backend service actions, got %v", names)
+	}
+}
+
+func TestFilterWaitServices(t *testing.T) {
+	updated := []swarm.ServiceUpdateResult{
+		{ServiceID: "1", ServiceName: "mystack_api"},
+		{ServiceID: "2", ServiceName: "mystack_web"},
+		{ServiceID: "3", ServiceName: "mystack_worker"},
+	}
+
+	tests := []struct {
+		name    string
+		waitFor []string
+		want    []string
+	}{
+		{"all services without -wait-for", nil, []string{"mystack_api", "mystack_web", "mystack_worker"}},
+		{"single service", []string{"api"}, []string{"mystack_api"}},
+		{"keeps deploy order", []string{"worker", "api"}, []string{"mystack_api", "mystack_worker"}},
+		{"service not updated", []string{"db"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, svc := range filterWaitServices("mystack", updated, tt.waitFor) {
+				got = append(got, svc.ServiceName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterWaitServices(%v) = %v, want %v", tt.waitFor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWaitFor(t *testing.T) {
+	composeFile := &compose.ComposeFile{Services: map[string]*compose.Service{
+		"api": {Image: "api:1.0"},
+		"web": {Image: "nginx:1.25"},
+	}}
+
+	if err := checkWaitFor(composeFile, []string{"api", "web"}); err != nil {
+		t.Errorf("checkWaitFor() unexpected error: %v", err)
+	}
+
+	err := checkWaitFor(composeFile, []string{"api", "apii"})
+	if err == nil || !strings.Contains(err.Error(), `unknown service "apii"`) {
+		t.Errorf("checkWaitFor() error = %v, want unknown service \"apii\"", err)
+	}
+}
+
+func TestCheckOrphans(t *testing.T) {
+	deployPlan := &plan.Plan{
+		StackName: "mystack",
+		Services: []plan.ServiceAction{
+			{Name: "web", Action: plan.ActionUpdate},
+			{Name: "old-worker", Action: plan.ActionDelete},
+		},
+	}
+
+	err := checkOrphans(deployPlan, true, false)
+	if err == nil || !strings.Contains(err.Error(), "service old-worker") || !strings.Contains(err.Error(), "-prune") {
+		t.Errorf("checkOrphans() error = %v, want the orphaned service and a -prune hint", err)
+	}
+
+	if err := checkOrphans(deployPlan, true, true); err != nil {
+		t.Errorf("checkOrphans() with -prune error = %v, want nil", err)
+	}
+	if err := checkOrphans(deployPlan, false, false); err != nil {
+		t.Errorf("checkOrphans() without -fail-on-orphans error = %v, want nil", err)
+	}
+
+	deployPlan.Services = deployPlan.Services[:1]
+	if err := checkOrphans(deployPlan, true, false); err != nil {
+		t.Errorf("checkOrphans() without orphans error = %v, want nil", err)
+	}
+}
+
+func TestDeployProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		healthy map[string]int
+		desired map[string]int
+		want    healthProgress
+	}{
+		{
+			name: "mixed task states",
+			// api: 2 healthy, 1 starting; worker: both tasks still pulling; web: start-first overlap
+			healthy: map[string]int{"mystack_api": 2, "mystack_web": 2},
+			desired: map[string]int{"mystack_api": 3, "mystack_worker": 2, "mystack_web": 1},
+			want:    healthProgress{Healthy: 3, Desired: 6, Percent: 50},
+		},
+		{
+			name:    "all healthy",
+			healthy: map[string]int{"mystack_api": 3},
+			desired: map[string]int{"mystack_api": 3},
+			want:    healthProgress{Healthy: 3, Desired: 3, Percent: 100},
+		},
+		{
+			name:    "rounds down",
+			healthy: map[string]int{"mystack_api": 2},
+			desired: map[string]int{"mystack_api": 3},
+			want:    healthProgress{Healthy: 2, Desired: 3, Percent: 66},
+		},
+		{
+			name: "nothing desired yet",
+			want: healthProgress{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deployProgress(tt.healthy, tt.desired); got != tt.want {
+				t.Errorf("deployProgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringList(t *testing.T) {
+	var profiles stringList
+	for _, v := range []string{"debug", "migration, ops", ""} {
+		if err := profiles.Set(v); err != nil {
+			t.Fatalf("Set(%q) failed: %v", v, err)
+		}
+	}
+	want := []string{"debug", "migration", "ops"}
+	if !reflect.DeepEqual([]string(profiles), want) {
+		t.Errorf("stringList = %v, want %v", profiles, want)
+	}
+}
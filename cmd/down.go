@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// ExecuteDown runs the down command
+func ExecuteDown(args []string) {
+	fs := flag.NewFlagSet("down", flag.ExitOnError)
+
+	// Required flags
+	stackName := fs.String("n", "", "Stack name (required)")
+
+	// Optional flags
+	composeFile := fs.String("f", "", "Compose file path or URL of the stack (validated before removal)")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Timeout for removing the stack")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	serviceFilter := fs.String("filter", "", "Only remove services with this compose label (label=key or label=key=value); networks, secrets and configs are kept")
+	pruneWait := fs.Duration("prune-wait", 30*time.Second, "Maximum wait for removed services' tasks to release a network before removing it (0 = don't wait)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman down -n <stack> [flags]
+
+Remove a stack: its services, networks, secrets and configs.
+Volumes are kept.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Validate required flags
+	if *stackName == "" {
+		fmt.Fprintf(os.Stderr, "Error: -n (stack name) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := &DownOptions{
+		ComposeFile: *composeFile,
+		Timeout:     *timeout,
+		Yes:         *yes,
+		PruneWait:   *pruneWait,
+	}
+	if *serviceFilter != "" {
+		f, err := compose.ParseLabelFilter(*serviceFilter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -filter: %v\n\n", err)
+			fs.Usage()
+			os.Exit(1)
+		}
+		opts.ServiceFilter = f
+	}
+
+	if err := runDown(*stackName, opts); err != nil {
+		log.Fatalf("Down failed: %v", err)
+	}
+}
+
+// DownOptions contains options for the down command
+type DownOptions struct {
+	ComposeFile   string
+	Timeout       time.Duration
+	Yes           bool
+	PruneWait     time.Duration
+	ServiceFilter *compose.LabelFilter // Only remove services with this label (nil = whole stack)
+}
+
+// runDown removes all resources of a stack
+func runDown(stackName string, opts *DownOptions) error {
+	if opts.ComposeFile != "" {
+		composeSpec, err := compose.ParseComposeFile(opts.ComposeFile)
+		if err != nil {
+			return fmt.Errorf("failed to parse compose file: %w", err)
+		}
+		log.Printf("Compose file %s declares %d service(s)", opts.ComposeFile, len(composeSpec.Services))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// Initialize Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("docker client init: %w", err)
+	}
+	defer cli.Close()
+
+	stackDeployer := swarm.NewStackDeployer(cli, stackName, 3)
+	stackDeployer.PruneWait = opts.PruneWait
+	stackDeployer.ServiceFilter = opts.ServiceFilter
+
+	services, err := stackDeployer.GetStackServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+
+	if !opts.Yes {
+		prompt := fmt.Sprintf("Remove stack %s (%d service(s), networks, secrets and configs)?", stackName, len(services))
+		if opts.ServiceFilter != nil {
+			prompt = fmt.Sprintf("Remove %d service(s) matching %s from stack %s?", countMatching(services, opts.ServiceFilter), opts.ServiceFilter, stackName)
+		}
+		if !confirm(os.Stdin, os.Stderr, prompt) {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return nil
+		}
+	}
+
+	if err := stackDeployer.RemoveStack(ctx); err != nil {
+		return err
+	}
+
+	if opts.ServiceFilter != nil {
+		fmt.Printf("Services matching %s removed from stack %s.\n", opts.ServiceFilter, stackName)
+		return nil
+	}
+	fmt.Printf("Stack %s removed.\n", stackName)
+	return nil
+}
+
+// countMatching counts the deployed services matching filter
+func countMatching(services []dockerswarm.Service, filter *compose.LabelFilter) int {
+	count := 0
+	for _, svc := range services {
+		if filter.MatchServiceSpec(&svc.Spec) {
+			count++
+		}
+	}
+	return count
+}
+
+// confirm asks a yes/no question and returns true only for an explicit yes
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", prompt)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -26,12 +31,15 @@ func ExecuteEvents(args []string) {
 	serviceName := fs.String("service", "", "Service name (optional, shows events for all services if not specified)")
 	since := fs.String("since", "", "Show events since timestamp (e.g. 2023-01-01T00:00:00Z) or duration (e.g. 10m)")
 	until := fs.String("until", "", "Show events until timestamp (e.g. 2023-01-01T00:00:00Z) or duration (e.g. 10m)")
-	follow := fs.Bool("follow", false, "Follow event stream (default: show past events)")
+	follow := fs.Bool("follow", true, "Keep streaming new events; -follow=false stops once the -since replay reaches the present")
+	var eventTypeFilter stringList
+	fs.Var(&eventTypeFilter, "type", "Only show events of this type: container, service or node (repeatable or comma-separated, default: all)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: stackman events -n <stack> [flags]
 
-Show events for stack services and their tasks.
+Stream container, service and node events of the stack until interrupted.
+Use -since to replay recent events first, and -follow=false to stop after them.
 
 Flags:
 `)
@@ -55,6 +63,7 @@ Flags:
 		Since:       *since,
 		Until:       *until,
 		Follow:      *follow,
+		Types:       eventTypeFilter,
 	}); err != nil {
 		log.Fatalf("Events failed: %v", err)
 	}
@@ -65,12 +74,14 @@ type EventsOptions struct {
 	ServiceName string
 	Since       string
 	Until       string
-	Follow      bool
+	Follow      bool     // Keep streaming once past events are replayed
+	Types       []string // Event types to show (empty = container, service and node)
 }
 
 // runEvents retrieves and displays events for stack services and tasks
 func runEvents(stackName string, opts *EventsOptions) error {
-	ctx := context.Background()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
 	// Initialize Docker client
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -120,35 +131,9 @@ func runEvents(stackName string, opts *EventsOptions) error {
 		return fmt.Errorf("no services found")
 	}
 
-	// Prepare event filters
-	eventFilters := filters.NewArgs()
-	eventFilters.Add("type", "service")
-	eventFilters.Add("type", "task")
-	eventFilters.Add("type", "container")
-
-	// Filter by stack label
-	eventFilters.Add("label", stackLabel)
-
-	// Build options for event stream
-	eventOpts := events.ListOptions{
-		Filters: eventFilters,
-	}
-
-	// Parse since/until times
-	if opts.Since != "" {
-		if duration, err := time.ParseDuration(opts.Since); err == nil {
-			eventOpts.Since = time.Now().Add(-duration).Format(time.RFC3339Nano)
-		} else {
-			eventOpts.Since = opts.Since
-		}
-	}
-
-	if opts.Until != "" {
-		if duration, err := time.ParseDuration(opts.Until); err == nil {
-			eventOpts.Until = time.Now().Add(-duration).Format(time.RFC3339Nano)
-		} else {
-			eventOpts.Until = opts.Until
-		}
+	eventOpts, err := eventListOptions(opts.Types, opts.Since, opts.Until, opts.Follow, time.Now())
+	if err != nil {
+		return err
 	}
 
 	// Get event stream
@@ -160,25 +145,100 @@ func runEvents(stackName string, opts *EventsOptions) error {
 	}
 	fmt.Println()
 
-	// Process events
+	// Process events until Ctrl-C or the end of the -until window (the present with -follow=false)
 	for {
 		select {
 		case event := <-eventChan:
-			displayEvent(event, serviceNameMap, stackName)
+			if stackEvent(event, stackName, serviceNameMap, opts.ServiceName) {
+				displayEvent(event, serviceNameMap, stackName)
+			}
 
 		case err := <-errChan:
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
 			if err != nil {
 				return fmt.Errorf("error receiving events: %w", err)
 			}
-			// Channel closed, exit if not following
-			if !opts.Follow {
-				return nil
-			}
+			return nil
 
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil
+		}
+	}
+}
+
+// eventTypes are the Docker event types the events command can show
+var eventTypes = []string{"container", "service", "node"}
+
+// eventListOptions builds the event stream options for the requested types
+// (all of eventTypes when empty) and the since/until window, given as a
+// timestamp or as a duration before now. Without follow and an until, the
+// stream ends at now.
+// Node events carry no stack label, so the stream is not filtered by label;
+// stackEvent narrows container and service events down to the stack.
+func eventListOptions(types []string, since, until string, follow bool, now time.Time) (events.ListOptions, error) {
+	if len(types) == 0 {
+		types = eventTypes
+	}
+
+	eventFilters := filters.NewArgs()
+	for _, t := range types {
+		if !slices.Contains(eventTypes, t) {
+			return events.ListOptions{}, fmt.Errorf("invalid -type %q, expected %s", t, strings.Join(eventTypes, ", "))
 		}
+		eventFilters.Add("type", t)
+	}
+
+	eventOpts := events.ListOptions{
+		Filters: eventFilters,
+		Since:   sinceTimestamp(since, now),
+		Until:   sinceTimestamp(until, now),
+	}
+	if !follow && until == "" {
+		eventOpts.Until = now.Format(time.RFC3339Nano)
+	}
+	return eventOpts, nil
+}
+
+// sinceTimestamp converts a duration such as 10m to the timestamp that long before
+// now; other values are passed to the daemon as they are
+func sinceTimestamp(value string, now time.Time) string {
+	if value == "" {
+		return ""
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration).Format(time.RFC3339Nano)
 	}
+	return value
+}
+
+// stackEvent reports whether an event belongs to the stack, or to serviceName when
+// set. Node events are always shown since they affect where stack tasks run.
+// Services created after the stream started are matched by their name for
+// service events and by the stack namespace label for container events.
+func stackEvent(event events.Message, stackName string, serviceNameMap map[string]string, serviceName string) bool {
+	var serviceID, fullName string
+	var inStack bool
+	switch event.Type {
+	case events.NodeEventType:
+		return true
+	case events.ServiceEventType:
+		// Service events only carry the name, not the service labels
+		serviceID, fullName = event.Actor.ID, event.Actor.Attributes["name"]
+		inStack = strings.HasPrefix(fullName, stackName+"_")
+	case events.ContainerEventType:
+		// Container events carry the container labels, which include the stack namespace
+		serviceID, fullName = event.Actor.Attributes["com.docker.swarm.service.id"], event.Actor.Attributes["com.docker.swarm.service.name"]
+		inStack = event.Actor.Attributes["com.docker.stack.namespace"] == stackName
+	default:
+		return false
+	}
+
+	if _, ok := serviceNameMap[serviceID]; ok {
+		return true
+	}
+	return inStack && (serviceName == "" || strings.TrimPrefix(fullName, stackName+"_") == serviceName)
 }
 
 // displayEvent formats and displays a Docker event
@@ -260,6 +320,16 @@ func displayEvent(event events.Message, serviceNameMap map[string]string, stackN
 		}
 		fmt.Println()
 
+	case "node":
+		fmt.Printf("[%s] NODE %s: %s", timestamp, event.Actor.Attributes["name"], event.Action)
+		if state := event.Actor.Attributes["state.new"]; state != "" {
+			fmt.Printf(" state=%s", state)
+		}
+		if availability := event.Actor.Attributes["availability.new"]; availability != "" {
+			fmt.Printf(" availability=%s", availability)
+		}
+		fmt.Println()
+
 	default:
 		// Unknown event type
 		fmt.Printf("[%s] %s %s: %s\n",
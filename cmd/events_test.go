@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -86,3 +88,94 @@ func TestDisplayEvent(t *testing.T) {
 
 	displayEvent(containerEvent, serviceNameMap, "test-stack")
 }
+
+func TestEventListOptions(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	opts, err := eventListOptions(nil, "10m", "", true, now)
+	if err != nil {
+		t.Fatalf("eventListOptions failed: %v", err)
+	}
+	if got := opts.Filters.Get("type"); !reflect.DeepEqual(sortedStrings(got), []string{"container", "node", "service"}) {
+		t.Errorf("Expected all event types by default, got %v", got)
+	}
+	if opts.Filters.Contains("label") {
+		t.Errorf("Expected no label filter, node events carry no stack label")
+	}
+	if want := "2024-05-01T11:50:00Z"; opts.Since != want {
+		t.Errorf("Since = %q, want %q", opts.Since, want)
+	}
+	if opts.Until != "" {
+		t.Errorf("Until = %q, want empty", opts.Until)
+	}
+
+	opts, err = eventListOptions([]string{"node"}, "2024-05-01T00:00:00Z", "", true, now)
+	if err != nil {
+		t.Fatalf("eventListOptions failed: %v", err)
+	}
+	if got := opts.Filters.Get("type"); !reflect.DeepEqual(got, []string{"node"}) {
+		t.Errorf("Expected only node events, got %v", got)
+	}
+	if opts.Since != "2024-05-01T00:00:00Z" {
+		t.Errorf("Expected a timestamp to be passed through, got %q", opts.Since)
+	}
+
+	if _, err := eventListOptions([]string{"task"}, "", "", true, now); err == nil {
+		t.Error("Expected an unknown event type to be rejected")
+	}
+
+	// -follow=false ends the stream once the replay reaches the present
+	opts, err = eventListOptions(nil, "10m", "", false, now)
+	if err != nil {
+		t.Fatalf("eventListOptions failed: %v", err)
+	}
+	if want := "2024-05-01T12:00:00Z"; opts.Until != want {
+		t.Errorf("Until = %q, want %q", opts.Until, want)
+	}
+	opts, err = eventListOptions(nil, "10m", "5m", false, now)
+	if err != nil {
+		t.Fatalf("eventListOptions failed: %v", err)
+	}
+	if want := "2024-05-01T11:55:00Z"; opts.Until != want {
+		t.Errorf("Expected an explicit -until to be kept, Until = %q, want %q", opts.Until, want)
+	}
+}
+
+func TestStackEvent(t *testing.T) {
+	serviceNameMap := map[string]string{"svc-web": "web"}
+
+	tests := []struct {
+		name    string
+		event   events.Message
+		service string
+		want    bool
+	}{
+		// Payloads as the daemon sends them: service events carry the name (and
+		// image/replicas/updatestate keys), container events the container labels
+		{"known service", events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "svc-web", Attributes: map[string]string{"name": "shop_web"}}}, "", true},
+		{"new stack service", events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "svc-new", Attributes: map[string]string{"name": "shop_worker", "image.new": "worker:1.0"}}}, "", true},
+		{"other stack service", events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "svc-x", Attributes: map[string]string{"name": "other_web"}}}, "", false},
+		{"new service outside -service", events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "svc-new", Attributes: map[string]string{"name": "shop_worker"}}}, "web", false},
+		{"new service of -service", events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "svc-new", Attributes: map[string]string{"name": "shop_worker"}}}, "worker", true},
+		{"stack container", events.Message{Type: events.ContainerEventType, Actor: events.Actor{ID: "c1", Attributes: map[string]string{"com.docker.swarm.service.id": "svc-web", "com.docker.swarm.service.name": "shop_web", "com.docker.stack.namespace": "shop"}}}, "", true},
+		{"new stack container", events.Message{Type: events.ContainerEventType, Actor: events.Actor{ID: "c3", Attributes: map[string]string{"com.docker.swarm.service.id": "svc-new", "com.docker.swarm.service.name": "shop_worker", "com.docker.stack.namespace": "shop"}}}, "worker", true},
+		{"container of a prefixed service outside the stack", events.Message{Type: events.ContainerEventType, Actor: events.Actor{ID: "c4", Attributes: map[string]string{"com.docker.swarm.service.id": "svc-y", "com.docker.swarm.service.name": "shop_manual"}}}, "", false},
+		{"plain container", events.Message{Type: events.ContainerEventType, Actor: events.Actor{ID: "c2", Attributes: map[string]string{"name": "shop_debug"}}}, "", false},
+		{"node", events.Message{Type: events.NodeEventType, Actor: events.Actor{ID: "node1", Attributes: map[string]string{"name": "worker-1"}}}, "web", true},
+		{"network", events.Message{Type: events.NetworkEventType, Actor: events.Actor{ID: "net1"}}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stackEvent(tt.event, "shop", serviceNameMap, tt.service); got != tt.want {
+				t.Errorf("stackEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sortedStrings(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// lintFailNever disables the lint exit code threshold
+const lintFailNever = "never"
+
+// ExecuteLint runs the lint command
+func ExecuteLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+
+	// Required flags
+	composeFile := fs.String("f", "", "Compose file path or http(s)://, git:: URL (required)")
+
+	// Optional flags
+	valuesFile := fs.String("values", "", "Variables file for ${VAR} interpolation (.env, .yaml/.yml or .json)")
+	setValues := fs.String("set", "", "Set interpolation variables (comma-separated key=value pairs, override -values)")
+	disable := fs.String("disable", "", "Comma-separated lint rules to skip")
+	failOn := fs.String("fail-on", compose.LintWarning, "Exit 1 when a finding has at least this severity (error, warning, info, never)")
+	jsonOutput := fs.Bool("json", false, "Print findings as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman lint -f <compose-file> [flags]
+
+Check a compose file for best-practice issues. No Docker connection is needed.
+
+Rules:
+`)
+		for _, rule := range compose.LintRules {
+			fmt.Fprintf(os.Stderr, "  %-20s %-8s %s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Validate required flags
+	if *composeFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -f (compose file) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *failOn != lintFailNever && compose.LintSeverityRank(*failOn) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -fail-on must be error, warning, info or never\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	disabled, err := compose.ParseLintRules(*disable)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -disable: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	vars, err := interpolationVars(*valuesFile, *setValues)
+	if err != nil {
+		log.Fatalf("Lint failed: %v", err)
+	}
+
+	composeSpec, err := compose.ParseComposeFileWithVars(*composeFile, vars)
+	if err != nil {
+		log.Fatalf("Lint failed: failed to parse compose file: %v", err)
+	}
+	for _, name := range composeSpec.UnsetVariables {
+		log.Printf("WARNING: variable %s is not set, substituting an empty string", name)
+	}
+
+	findings := compose.Lint(composeSpec, disabled)
+	if err := printLint(os.Stdout, *composeFile, findings, *jsonOutput); err != nil {
+		log.Fatalf("Lint failed: %v", err)
+	}
+
+	if lintFailed(findings, *failOn) {
+		os.Exit(1)
+	}
+}
+
+// printLint writes lint findings as text lines or a JSON array
+func printLint(w io.Writer, composeFile string, findings []compose.LintFinding, asJSON bool) error {
+	if asJSON {
+		if findings == nil {
+			findings = []compose.LintFinding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	}
+
+	if len(findings) == 0 {
+		_, err := fmt.Fprintf(w, "%s: no issues found\n", composeFile)
+		return err
+	}
+
+	for _, f := range findings {
+		location := composeFile
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", composeFile, f.Line)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s: service %s: %s [%s]\n", location, f.Severity, f.Service, f.Message, f.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lintFailed reports whether any finding reaches the -fail-on severity
+func lintFailed(findings []compose.LintFinding, failOn string) bool {
+	if failOn == lintFailNever {
+		return false
+	}
+	threshold := compose.LintSeverityRank(failOn)
+	for _, f := range findings {
+		if compose.LintSeverityRank(f.Severity) >= threshold {
+			return true
+		}
+	}
+	return false
+}
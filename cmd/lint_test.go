@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestPrintLint(t *testing.T) {
+	findings := []compose.LintFinding{
+		{Rule: "latest-tag", Severity: compose.LintWarning, Service: "web", Line: 3, Message: "image nginx is not pinned to a version tag"},
+		{Rule: "no-restart-policy", Severity: compose.LintInfo, Service: "web", Message: "no deploy.restart_policy; Swarm restarts on any exit"},
+	}
+
+	var buf bytes.Buffer
+	if err := printLint(&buf, "stack.yml", findings, false); err != nil {
+		t.Fatalf("printLint failed: %v", err)
+	}
+	want := "stack.yml:3: warning: service web: image nginx is not pinned to a version tag [latest-tag]\n" +
+		"stack.yml: info: service web: no deploy.restart_policy; Swarm restarts on any exit [no-restart-policy]\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := printLint(&buf, "stack.yml", nil, false); err != nil {
+		t.Fatalf("printLint failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "no issues found") {
+		t.Errorf("Expected a clean message, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := printLint(&buf, "stack.yml", nil, true); err != nil {
+		t.Fatalf("printLint failed: %v", err)
+	}
+	var decoded []compose.LintFinding
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded == nil {
+		t.Errorf("Expected an empty JSON array, got %q (err %v)", buf.String(), err)
+	}
+}
+
+func TestLintFailed(t *testing.T) {
+	findings := []compose.LintFinding{{Rule: "single-replica", Severity: compose.LintInfo}}
+
+	tests := []struct {
+		failOn string
+		want   bool
+	}{
+		{compose.LintInfo, true},
+		{compose.LintWarning, false},
+		{compose.LintError, false},
+		{lintFailNever, false},
+	}
+
+	for _, tt := range tests {
+		if got := lintFailed(findings, tt.failOn); got != tt.want {
+			t.Errorf("lintFailed(-fail-on %s) = %v, want %v", tt.failOn, got, tt.want)
+		}
+	}
+}
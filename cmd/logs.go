@@ -7,7 +7,12 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -15,6 +20,8 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/health"
 )
 
 // ExecuteLogs runs the logs command
@@ -27,14 +34,21 @@ func ExecuteLogs(args []string) {
 	// Optional flags
 	serviceName := fs.String("service", "", "Service name (optional, shows logs for all services if not specified)")
 	follow := fs.Bool("follow", false, "Follow log output")
-	tail := fs.String("tail", "100", "Number of lines to show from the end of the logs")
+	tail := fs.String("tail", "100", "Number of lines to show from the end of the logs, or all")
 	since := fs.String("since", "", "Show logs since timestamp (e.g. 2023-01-01T00:00:00Z) or duration (e.g. 10m)")
 	timestamps := fs.Bool("timestamps", false, "Show timestamps")
+	logPrefixTemplate := fs.String("log-prefix-template", defaultLogsPrefixTemplate, "Go template for the log line prefix ({{.Service}}, {{.Stream}}, {{.Task}}, {{.TaskID}}, {{.Slot}}, {{.Source}}, {{.Icon}})")
+	logMaxLineLength := fs.Int("log-max-line-length", 0, "Truncate log lines longer than this many bytes (0 = unlimited)")
+	logRateLimit := fs.Int("log-rate-limit", 0, "Maximum log lines per second printed per container, excess lines are dropped (0 = unlimited)")
+	noColor := fs.Bool("no-color", false, "Strip ANSI color codes from container logs (implied when stdout is not a terminal)")
 
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: stackman logs -n <stack> [flags]
+		fmt.Fprintf(os.Stderr, `Usage: stackman logs -n <stack> [flags] [service]
 
-Show logs for stack services and their tasks.
+Show logs of the running tasks of stack services, each line prefixed with
+its service and replica. Unlike the log streaming during apply, existing
+output is shown: the last -tail lines, or everything since -since.
+With -follow, logs are streamed until interrupted.
 
 Flags:
 `)
@@ -52,18 +66,45 @@ Flags:
 		os.Exit(1)
 	}
 
+	service, err := logsServiceArg(*serviceName, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *logMaxLineLength < 0 || *logRateLimit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -log-max-line-length and -log-rate-limit must not be negative\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	logPrefix, err := health.ParseLogPrefix(*logPrefixTemplate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -log-prefix-template: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
 	// Run logs logic
 	if err := runLogs(*stackName, &LogsOptions{
-		ServiceName: *serviceName,
+		ServiceName: service,
 		Follow:      *follow,
 		Tail:        *tail,
 		Since:       *since,
 		Timestamps:  *timestamps,
+		LogPrefix:   logPrefix,
+		NoColor:     *noColor || !isTerminal(os.Stdout),
+		LogLimits:   health.LogLimits{MaxLineLength: *logMaxLineLength, MaxLinesPerSecond: *logRateLimit},
 	}); err != nil {
 		log.Fatalf("Logs failed: %v", err)
 	}
 }
 
+// defaultLogsPrefixTemplate prefixes log lines with the service and replica slot,
+// or the task ID for global services
+const defaultLogsPrefixTemplate = "{{.Service}}.{{if .Slot}}{{.Slot}}{{else}}{{.Task}}{{end}} |"
+
 // LogsOptions contains options for the logs command
 type LogsOptions struct {
 	ServiceName string
@@ -71,11 +112,45 @@ type LogsOptions struct {
 	Tail        string
 	Since       string
 	Timestamps  bool
+	LogPrefix   *health.LogPrefix // Prefix of each line (nil = defaultLogsPrefixTemplate)
+	NoColor     bool              // Strip ANSI escape sequences from the lines
+	LogLimits   health.LogLimits  // Per-container line length and rate limits
+}
+
+// logsServiceArg returns the service named by -service or the positional argument
+func logsServiceArg(flagValue string, args []string) (string, error) {
+	switch {
+	case len(args) > 1:
+		return "", fmt.Errorf("expected at most one service, got %v", args)
+	case len(args) == 1 && flagValue != "" && flagValue != args[0]:
+		return "", fmt.Errorf("-service %s conflicts with service argument %s", flagValue, args[0])
+	case len(args) == 1:
+		return args[0], nil
+	}
+	return flagValue, nil
+}
+
+// containerLogsOptions builds the options for reading a task container's logs
+func containerLogsOptions(opts *LogsOptions, now time.Time) (container.LogsOptions, error) {
+	if opts.Tail != "" && opts.Tail != "all" {
+		if n, err := strconv.Atoi(opts.Tail); err != nil || n < 0 {
+			return container.LogsOptions{}, fmt.Errorf("invalid -tail %q, expected a number of lines or all", opts.Tail)
+		}
+	}
+	return container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Timestamps: opts.Timestamps,
+		Tail:       opts.Tail,
+		Since:      sinceTimestamp(opts.Since, now),
+	}, nil
 }
 
 // runLogs retrieves and displays logs for stack services
 func runLogs(stackName string, opts *LogsOptions) error {
-	ctx := context.Background()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
 	// Initialize Docker client
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -84,127 +159,140 @@ func runLogs(stackName string, opts *LogsOptions) error {
 	}
 	defer cli.Close()
 
-	// Get services in the stack
-	stackLabel := fmt.Sprintf("com.docker.stack.namespace=%s", stackName)
-	serviceFilters := filters.NewArgs()
-	serviceFilters.Add("label", stackLabel)
+	return streamStackLogs(ctx, cli, os.Stdout, stackName, opts)
+}
 
+// logsClient is the part of the Docker API the logs command uses
+type logsClient interface {
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+	TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}
+
+// streamStackLogs writes the logs of the stack's running and completed tasks to w.
+// Containers are read concurrently so -follow streams every task at once.
+func streamStackLogs(ctx context.Context, cli logsClient, w io.Writer, stackName string, opts *LogsOptions) error {
+	logOpts, err := containerLogsOptions(opts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Get services in the stack
 	services, err := cli.ServiceList(ctx, types.ServiceListOptions{
-		Filters: serviceFilters,
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", stackName))),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to list services: %w", err)
 	}
 
 	if len(services) == 0 {
-		fmt.Printf("No services found in stack '%s'\n", stackName)
+		fmt.Fprintf(w, "No services found in stack '%s'\n", stackName)
 		return nil
 	}
 
 	// Filter by service name if specified
-	var targetServices []string
+	serviceNames := make(map[string]string)
+	taskFilters := filters.NewArgs()
 	for _, svc := range services {
-		serviceName := svc.Spec.Name
-		// Remove stack prefix
-		if strings.HasPrefix(serviceName, stackName+"_") {
-			serviceName = serviceName[len(stackName)+1:]
-		}
-
+		serviceName := strings.TrimPrefix(svc.Spec.Name, stackName+"_")
 		if opts.ServiceName == "" || serviceName == opts.ServiceName {
-			targetServices = append(targetServices, svc.ID)
+			serviceNames[svc.ID] = serviceName
+			taskFilters.Add("service", svc.ID)
 		}
 	}
 
-	if len(targetServices) == 0 {
+	if len(serviceNames) == 0 {
 		if opts.ServiceName != "" {
 			return fmt.Errorf("service '%s' not found in stack '%s'", opts.ServiceName, stackName)
 		}
 		return fmt.Errorf("no services found")
 	}
 
-	// Get tasks for these services
-	taskFilters := filters.NewArgs()
-	for _, svcID := range targetServices {
-		taskFilters.Add("service", svcID)
-	}
-
-	tasks, err := cli.TaskList(ctx, types.TaskListOptions{
-		Filters: taskFilters,
-	})
+	tasks, err := cli.TaskList(ctx, types.TaskListOptions{Filters: taskFilters})
 	if err != nil {
 		return fmt.Errorf("failed to list tasks: %w", err)
 	}
 
-	if len(tasks) == 0 {
-		fmt.Printf("No tasks found for services in stack '%s'\n", stackName)
-		return nil
-	}
-
-	// Show logs for each task's container
+	var logTasks []swarm.Task
 	for _, task := range tasks {
 		// Skip tasks without containers
 		if task.Status.ContainerStatus == nil || task.Status.ContainerStatus.ContainerID == "" {
 			continue
 		}
-
-		// Skip non-running tasks unless we're showing history
 		if task.Status.State != swarm.TaskStateRunning && task.Status.State != swarm.TaskStateComplete {
 			continue
 		}
+		logTasks = append(logTasks, task)
+	}
 
-		containerID := task.Status.ContainerStatus.ContainerID
-
-		// Get service name for display
-		var serviceName string
-		for _, svc := range services {
-			if svc.ID == task.ServiceID {
-				serviceName = svc.Spec.Name
-				if strings.HasPrefix(serviceName, stackName+"_") {
-					serviceName = serviceName[len(stackName)+1:]
-				}
-				break
-			}
-		}
-
-		fmt.Printf("==> Logs for service: %s, task: %s (container: %s)\n",
-			serviceName, task.ID[:12], containerID[:12])
+	if len(logTasks) == 0 {
+		fmt.Fprintf(w, "No tasks found for services in stack '%s'\n", stackName)
+		return nil
+	}
 
-		// Prepare log options
-		logOpts := container.LogsOptions{
-			ShowStdout: true,
-			ShowStderr: true,
-			Follow:     opts.Follow,
-			Timestamps: opts.Timestamps,
-			Tail:       opts.Tail,
+	sort.Slice(logTasks, func(i, j int) bool {
+		a, b := logTasks[i], logTasks[j]
+		if serviceNames[a.ServiceID] != serviceNames[b.ServiceID] {
+			return serviceNames[a.ServiceID] < serviceNames[b.ServiceID]
+		}
+		if a.Slot != b.Slot {
+			return a.Slot < b.Slot
 		}
+		return a.ID < b.ID
+	})
 
-		// Parse since if provided
-		if opts.Since != "" {
-			// Try parsing as duration first
-			if duration, err := time.ParseDuration(opts.Since); err == nil {
-				logOpts.Since = time.Now().Add(-duration).Format(time.RFC3339Nano)
-			} else {
-				// Otherwise use as-is (timestamp)
-				logOpts.Since = opts.Since
+	prefix := opts.LogPrefix
+	if prefix == nil {
+		prefix = health.MustParseLogPrefix(defaultLogsPrefixTemplate)
+	}
+	streamOpts := health.StreamOptions{
+		Output:     &syncWriter{w: w},
+		Prefix:     prefix,
+		StripColor: opts.NoColor,
+		Limits:     opts.LogLimits,
+	}
+
+	var wg sync.WaitGroup
+	for _, task := range logTasks {
+		wg.Add(1)
+		go func(task swarm.Task) {
+			defer wg.Done()
+			if err := copyTaskLogs(ctx, cli, serviceNames[task.ServiceID], task, logOpts, streamOpts); err != nil && ctx.Err() == nil {
+				log.Printf("WARNING: failed to read logs of %s task %s: %v", serviceNames[task.ServiceID], shortID(task.ID), err)
 			}
-		}
+		}(task)
+	}
+	wg.Wait()
 
-		// Get logs
-		logReader, err := cli.ContainerLogs(ctx, containerID, logOpts)
-		if err != nil {
-			log.Printf("Warning: failed to get logs for container %s: %v", containerID[:12], err)
-			continue
-		}
+	return nil
+}
 
-		// Copy logs to stdout
-		// Docker multiplexes stdout/stderr, we need to handle the stream format
-		if _, err := io.Copy(os.Stdout, logReader); err != nil {
-			log.Printf("Warning: error reading logs for container %s: %v", containerID[:12], err)
-		}
+// copyTaskLogs prints the logs of a task's container through a health.LogStreamer
+func copyTaskLogs(ctx context.Context, cli logsClient, serviceName string, task swarm.Task, logOpts container.LogsOptions, streamOpts health.StreamOptions) error {
+	logReader, err := cli.ContainerLogs(ctx, task.Status.ContainerStatus.ContainerID, logOpts)
+	if err != nil {
+		return err
+	}
+	defer logReader.Close()
 
-		logReader.Close()
-		fmt.Println() // Add newline between containers
+	slot := ""
+	if task.Slot > 0 {
+		slot = strconv.Itoa(task.Slot)
 	}
 
-	return nil
+	// Docker multiplexes stdout/stderr unless the container has a TTY
+	tty := task.Spec.ContainerSpec != nil && task.Spec.ContainerSpec.TTY
+	return health.NewLogStreamer(serviceName, task.ID, slot, streamOpts).Stream(logReader, tty)
+}
+
+// syncWriter serializes the writes of concurrent log streams, so their lines don't interleave
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
 }
@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -12,12 +17,18 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/SomeBlackMagic/stackman/internal/health"
 )
 
 // mockLogsClient implements minimal DockerClient for logs testing
 type mockLogsClient struct {
 	services []swarm.Service
 	tasks    []swarm.Task
+
+	mu      sync.Mutex
+	logOpts map[string]container.LogsOptions // Options ContainerLogs was called with, by container
 }
 
 func (m *mockLogsClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
@@ -29,8 +40,18 @@ func (m *mockLogsClient) TaskList(ctx context.Context, options types.TaskListOpt
 }
 
 func (m *mockLogsClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
-	// Return a simple reader with test logs
-	return io.NopCloser(strings.NewReader("test log output\n")), nil
+	m.mu.Lock()
+	if m.logOpts == nil {
+		m.logOpts = make(map[string]container.LogsOptions)
+	}
+	m.logOpts[containerID] = options
+	m.mu.Unlock()
+
+	// Multiplex stdout and stderr like the daemon does
+	var buf bytes.Buffer
+	fmt.Fprint(stdcopy.NewStdWriter(&buf, stdcopy.Stdout), "test log output\n")
+	fmt.Fprint(stdcopy.NewStdWriter(&buf, stdcopy.Stderr), "warning from "+containerID)
+	return io.NopCloser(&buf), nil
 }
 
 // Stub implementations
@@ -103,3 +124,157 @@ func TestLogsOptions(t *testing.T) {
 		t.Error("Expected Timestamps to be true")
 	}
 }
+
+func TestContainerLogsOptions(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		opts    LogsOptions
+		want    container.LogsOptions
+		wantErr bool
+	}{
+		{
+			name: "tail and duration since",
+			opts: LogsOptions{Tail: "50", Since: "30m"},
+			want: container.LogsOptions{ShowStdout: true, ShowStderr: true, Tail: "50", Since: "2024-05-01T11:30:00Z"},
+		},
+		{
+			name: "all lines since a timestamp, followed",
+			opts: LogsOptions{Tail: "all", Since: "2024-04-30T00:00:00Z", Follow: true, Timestamps: true},
+			want: container.LogsOptions{ShowStdout: true, ShowStderr: true, Tail: "all", Since: "2024-04-30T00:00:00Z", Follow: true, Timestamps: true},
+		},
+		{name: "negative tail", opts: LogsOptions{Tail: "-5"}, wantErr: true},
+		{name: "tail not a number", opts: LogsOptions{Tail: "last"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := containerLogsOptions(&tt.opts, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("containerLogsOptions failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("containerLogsOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogsServiceArg(t *testing.T) {
+	tests := []struct {
+		flagValue string
+		args      []string
+		want      string
+		wantErr   bool
+	}{
+		{"", nil, "", false},
+		{"web", nil, "web", false},
+		{"", []string{"api"}, "api", false},
+		{"api", []string{"api"}, "api", false},
+		{"web", []string{"api"}, "", true},
+		{"", []string{"web", "api"}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := logsServiceArg(tt.flagValue, tt.args)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("logsServiceArg(%q, %v) = %q, %v; want %q, error %v", tt.flagValue, tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStreamStackLogs(t *testing.T) {
+	running := func(id, serviceID string, slot int, state swarm.TaskState) swarm.Task {
+		return swarm.Task{
+			ID:        id,
+			ServiceID: serviceID,
+			Slot:      slot,
+			Status: swarm.TaskStatus{
+				State:           state,
+				ContainerStatus: &swarm.ContainerStatus{ContainerID: "c-" + id},
+			},
+		}
+	}
+	cli := &mockLogsClient{
+		services: []swarm.Service{
+			{ID: "svc-web", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "shop_web"}}},
+			{ID: "svc-api", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "shop_api"}}},
+		},
+		tasks: []swarm.Task{
+			running("web1", "svc-web", 1, swarm.TaskStateRunning),
+			running("web2", "svc-web", 2, swarm.TaskStateRunning),
+			running("web0", "svc-web", 1, swarm.TaskStateShutdown),
+		},
+	}
+
+	var out bytes.Buffer
+	opts := &LogsOptions{ServiceName: "web", Tail: "10", Since: "5m"}
+	if err := streamStackLogs(context.Background(), cli, &out, "shop", opts); err != nil {
+		t.Fatalf("streamStackLogs failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(lines)
+	want := []string{
+		"web.1 | test log output",
+		"web.1 | warning from c-web1",
+		"web.2 | test log output",
+		"web.2 | warning from c-web2",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	if len(cli.logOpts) != 2 {
+		t.Fatalf("Expected logs of the two running tasks to be read, got %v", cli.logOpts)
+	}
+	for id, o := range cli.logOpts {
+		if o.Tail != "10" || o.Since == "" || o.Follow {
+			t.Errorf("%s: unexpected log options %+v", id, o)
+		}
+	}
+}
+
+func TestStreamStackLogs_StreamOptions(t *testing.T) {
+	cli := &mockLogsClient{
+		services: []swarm.Service{
+			{ID: "svc-web", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "shop_web"}}},
+		},
+		tasks: []swarm.Task{{
+			ID:        "web1",
+			ServiceID: "svc-web",
+			Slot:      1,
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateRunning,
+				ContainerStatus: &swarm.ContainerStatus{ContainerID: "c-web1"},
+			},
+		}},
+	}
+
+	var out bytes.Buffer
+	opts := &LogsOptions{
+		Tail:      "all",
+		LogPrefix: health.MustParseLogPrefix("{{.Service}}/{{.Slot}} {{.Stream}}:"),
+		LogLimits: health.LogLimits{MaxLineLength: 4},
+	}
+	if err := streamStackLogs(context.Background(), cli, &out, "shop", opts); err != nil {
+		t.Fatalf("streamStackLogs failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(lines)
+	want := []string{
+		"web/1 stderr: warn…",
+		"web/1 stdout: test…",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
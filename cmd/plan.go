@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/plan"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// Exit codes for the plan command
+const (
+	planExitNoChanges = 0
+	planExitError     = 1
+	planExitChanges   = 2
+)
+
+// ExecutePlan runs the plan command
+func ExecutePlan(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+
+	// Required flags
+	stackName := fs.String("n", "", "Stack name (required)")
+	composeFile := fs.String("f", "", "Compose file path or http(s)://, git:: URL (required)")
+
+	// Optional flags
+	valuesFile := fs.String("values", "", "Variables file for ${VAR} interpolation (.env, .yaml/.yml or .json)")
+	setValues := fs.String("set", "", "Set interpolation variables (comma-separated key=value pairs, override -values)")
+	jsonOutput := fs.Bool("json", false, "Print the plan as JSON")
+	diffContext := fs.Bool("diff-context", false, "Show before/after values of changed service fields")
+	pinDigests := fs.Bool("pin-digests", false, "Compare images by registry digest, as apply -pin-digests deploys them")
+	defaultRestartCondition := fs.String("compose-default-restart-condition", "", "Restart condition for services without deploy.restart_policy.condition, as apply uses it: none, on-failure, any")
+	var profiles stringList
+	fs.Var(&profiles, "profile", "Enable compose services of this profile (repeatable or comma-separated), as apply -profile does")
+	timeout := fs.Duration("timeout", 1*time.Minute, "Timeout for reading the current stack state")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman plan -n <stack> -f <compose-file> [flags]
+
+Show what apply would change without applying it.
+
+Exit codes:
+  0  No changes
+  1  Error
+  2  Changes detected
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(planExitError)
+	}
+
+	// Validate required flags
+	if *stackName == "" {
+		fmt.Fprintf(os.Stderr, "Error: -n (stack name) is required\n\n")
+		fs.Usage()
+		os.Exit(planExitError)
+	}
+
+	if *composeFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -f (compose file) is required\n\n")
+		fs.Usage()
+		os.Exit(planExitError)
+	}
+
+	switch dockerswarm.RestartPolicyCondition(*defaultRestartCondition) {
+	case "", dockerswarm.RestartPolicyConditionNone, dockerswarm.RestartPolicyConditionOnFailure, dockerswarm.RestartPolicyConditionAny:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -compose-default-restart-condition value %q (supported: none, on-failure, any)\n\n", *defaultRestartCondition)
+		fs.Usage()
+		os.Exit(planExitError)
+	}
+
+	vars, err := interpolationVars(*valuesFile, *setValues)
+	if err != nil {
+		log.Printf("Plan failed: %v", err)
+		os.Exit(planExitError)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// Initialize Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		log.Printf("Plan failed: docker client init: %v", err)
+		os.Exit(planExitError)
+	}
+	defer cli.Close()
+
+	deployPlan, err := runPlan(ctx, cli, *stackName, *composeFile, vars, *pinDigests, *defaultRestartCondition, profiles)
+	if err != nil {
+		log.Printf("Plan failed: %v", err)
+		os.Exit(planExitError)
+	}
+
+	if err := printPlan(os.Stdout, deployPlan, *jsonOutput, *diffContext); err != nil {
+		log.Printf("Plan failed: %v", err)
+		os.Exit(planExitError)
+	}
+
+	os.Exit(planExitCode(deployPlan))
+}
+
+// runPlan compares the compose file with the current stack state
+func runPlan(ctx context.Context, cli swarm.DockerClient, stackName, composeFile string, vars map[string]string, pinDigests bool, defaultRestartCondition string, profiles []string) (*plan.Plan, error) {
+	composeSpec, err := compose.ParseComposeFileWithVars(composeFile, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	for _, name := range composeSpec.UnsetVariables {
+		log.Printf("WARNING: variable %s is not set, substituting an empty string", name)
+	}
+	if err := applyProfiles(composeSpec, profiles); err != nil {
+		return nil, err
+	}
+	if err := compose.Validate(composeSpec); err != nil {
+		return nil, fmt.Errorf("invalid compose file:\n%w", err)
+	}
+	if err := resolveServiceEnvironment(composeSpec, nil); err != nil {
+		return nil, err
+	}
+
+	return planFromSpec(ctx, cli, stackName, composeSpec, pinDigests, defaultRestartCondition)
+}
+
+// planFromSpec compares an already parsed compose file with the current stack state.
+// With pinDigests, images are resolved to digests first and compared by digest.
+// defaultRestartCondition is applied to services without one, as the deployer does.
+func planFromSpec(ctx context.Context, cli swarm.DockerClient, stackName string, composeSpec *compose.ComposeFile, pinDigests bool, defaultRestartCondition string) (*plan.Plan, error) {
+	desired := plan.BuildDesiredState(composeSpec)
+	desired.DefaultRestartCondition = defaultRestartCondition
+	if pinDigests {
+		pinned, err := swarm.ResolveImageDigests(ctx, cli, composeSpec.Services)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pin image digests: %w", err)
+		}
+		desired.PinnedImages = pinned
+	}
+
+	current, err := swarm.GetCurrentState(ctx, cli, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current state: %w", err)
+	}
+
+	deployPlan, err := plan.NewPlanner(nil, stackName).CreatePlan(ctx, current, desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plan: %w", err)
+	}
+
+	return deployPlan, nil
+}
+
+// printPlan writes the plan as a human-readable diff or as JSON.
+// withContext adds before/after values of changed fields to the diff.
+func printPlan(w io.Writer, deployPlan *plan.Plan, asJSON, withContext bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(deployPlan)
+	}
+
+	if deployPlan.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No changes detected.")
+		return err
+	}
+
+	if withContext {
+		_, err := fmt.Fprint(w, plan.FormatDetailedDiff(deployPlan))
+		return err
+	}
+	_, err := fmt.Fprint(w, plan.FormatDiff(deployPlan))
+	return err
+}
+
+// planExitCode returns 0 for an empty plan and 2 when there are changes
+func planExitCode(deployPlan *plan.Plan) int {
+	if deployPlan.IsEmpty() {
+		return planExitNoChanges
+	}
+	return planExitChanges
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SomeBlackMagic/stackman/internal/plan"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+func TestPrintPlan_NoChanges(t *testing.T) {
+	p := &plan.Plan{
+		StackName: "mystack",
+		Services:  []plan.ServiceAction{{Name: "web", Action: plan.ActionNone}},
+	}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, p, false, false); err != nil {
+		t.Fatalf("printPlan failed: %v", err)
+	}
+
+	if strings.TrimSpace(buf.String()) != "No changes detected." {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+	if code := planExitCode(p); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+}
+
+func TestPrintPlan_Changes(t *testing.T) {
+	p := &plan.Plan{
+		StackName: "mystack",
+		Services: []plan.ServiceAction{
+			{Name: "web", Action: plan.ActionUpdate, Changes: []string{"image"}},
+		},
+		Secrets: []plan.SecretAction{
+			{Name: "db_password", Action: plan.ActionCreate, Data: []byte("s3cret")},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, p, false, false); err != nil {
+		t.Fatalf("printPlan failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "~ update web (changes: image)") {
+		t.Errorf("Expected diff output, got: %s", buf.String())
+	}
+	if code := planExitCode(p); code != 2 {
+		t.Errorf("Expected exit code 2, got %d", code)
+	}
+
+	buf.Reset()
+	if err := printPlan(&buf, p, true, false); err != nil {
+		t.Fatalf("printPlan json failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if decoded["stack"] != "mystack" {
+		t.Errorf("Expected stack mystack, got %v", decoded["stack"])
+	}
+	services := decoded["services"].([]interface{})
+	if services[0].(map[string]interface{})["action"] != "update" {
+		t.Errorf("Expected update action, got %v", services[0])
+	}
+	if strings.Contains(buf.String(), "s3cret") || strings.Contains(buf.String(), "czNjcmV0") {
+		t.Error("Secret data must not be included in JSON output")
+	}
+}
+
+func TestRunPlan_InterpolatesVariables(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "stack.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  web:\n    image: nginx:${TAG}\n"), 0644); err != nil {
+		t.Fatalf("failed to write compose file: %v", err)
+	}
+
+	deployPlan, err := runPlan(context.Background(), &swarm.MockDockerClient{}, "mystack", composeFile, map[string]string{"TAG": "1.25"}, false, "", nil)
+	if err != nil {
+		t.Fatalf("runPlan failed: %v", err)
+	}
+	if len(deployPlan.Services) != 1 || deployPlan.Services[0].DesiredSpec.TaskTemplate.ContainerSpec.Image != "nginx:1.25" {
+		t.Errorf("Expected web to be planned with the interpolated image nginx:1.25, got %+v", deployPlan.Services)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/health"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// ExecutePs runs the ps command
+func ExecutePs(args []string) {
+	fs := flag.NewFlagSet("ps", flag.ExitOnError)
+
+	// Required flags
+	stackName := fs.String("n", "", "Stack name (required)")
+
+	// Optional flags
+	jsonOutput := fs.Bool("json", false, "Print service status as JSON")
+	watch := fs.Bool("watch", false, "Refresh the status until interrupted")
+	interval := fs.Duration("interval", 3*time.Second, "Refresh interval for -watch")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman ps -n <stack> [flags]
+
+List stack services with their running/desired task counts and failed tasks.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Validate required flags
+	if *stackName == "" {
+		fmt.Fprintf(os.Stderr, "Error: -n (stack name) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := runPs(*stackName, &PsOptions{
+		JSON:     *jsonOutput,
+		Watch:    *watch,
+		Interval: *interval,
+	}); err != nil {
+		log.Fatalf("Ps failed: %v", err)
+	}
+}
+
+// PsOptions contains options for the ps command
+type PsOptions struct {
+	JSON     bool
+	Watch    bool
+	Interval time.Duration
+}
+
+// runPs prints the status of every service in the stack, once or until interrupted
+func runPs(stackName string, opts *PsOptions) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Initialize Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("docker client init: %w", err)
+	}
+	defer cli.Close()
+
+	for {
+		statuses, err := collectStackStatus(ctx, cli, stackName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if opts.Watch && !opts.JSON {
+			// Clear the screen before redrawing
+			fmt.Print("\033[H\033[2J")
+		}
+		if err := printPs(os.Stdout, stackName, statuses, opts.JSON); err != nil {
+			return err
+		}
+
+		if !opts.Watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// collectStackStatus returns the status of all stack services, sorted by name
+func collectStackStatus(ctx context.Context, cli swarm.DockerClient, stackName string) ([]*health.ServiceStatus, error) {
+	services, err := swarm.NewStackDeployer(cli, stackName, 3).GetStackServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	statuses := make([]*health.ServiceStatus, 0, len(services))
+	for _, svc := range services {
+		status, err := health.GetServiceStatus(ctx, cli, svc)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ServiceName < statuses[j].ServiceName
+	})
+
+	return statuses, nil
+}
+
+// printPs writes the service status as a table or as JSON
+func printPs(w io.Writer, stackName string, statuses []*health.ServiceStatus, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+
+	if len(statuses) == 0 {
+		_, err := fmt.Fprintf(w, "No services found in stack '%s'\n", stackName)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tMODE\tREPLICAS\tIMAGE\tPENDING")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%s\t%s\n",
+			status.ServiceName, status.Mode, status.RunningTasks, status.DesiredTasks, imageWithoutDigest(status.Image), status.Pending)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Failed tasks are listed below the table with their error
+	for _, status := range statuses {
+		for _, task := range status.FailedTasks() {
+			reason := task.Error
+			if reason == "" {
+				reason = task.Message
+			}
+			fmt.Fprintf(w, "  ✗ %s task %s %s: %s\n", status.ServiceName, shortID(task.ID), task.State, reason)
+		}
+	}
+
+	return nil
+}
+
+// imageWithoutDigest strips the @sha256 digest that swarm pins onto service images
+func imageWithoutDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
+
+// shortID truncates a Docker ID to 12 characters
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/SomeBlackMagic/stackman/internal/health"
+)
+
+func TestPrintPs(t *testing.T) {
+	statuses := []*health.ServiceStatus{
+		{
+			ServiceName:  "mystack_api",
+			Image:        "api:2.0@sha256:abcdef",
+			Mode:         "replicated",
+			DesiredTasks: 2,
+			RunningTasks: 1,
+			Tasks: []health.TaskStatus{
+				{ID: "task1234567890abcdef", State: "running", DesiredState: "running"},
+				{ID: "task2234567890abcdef", State: "failed", DesiredState: "running", Error: "task: non-zero exit (1)"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printPs(&buf, "mystack", statuses, false); err != nil {
+		t.Fatalf("printPs failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"mystack_api", "1/2", "api:2.0", "task22345678", "non-zero exit (1)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sha256") {
+		t.Errorf("Expected image digest to be stripped, got:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := printPs(&buf, "mystack", statuses, true); err != nil {
+		t.Fatalf("printPs JSON failed: %v", err)
+	}
+	var decoded []health.ServiceStatus
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].RunningTasks != 1 {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+}
+
+func TestPrintPs_PendingTasks(t *testing.T) {
+	statuses := []*health.ServiceStatus{
+		{
+			ServiceName:  "mystack_worker",
+			Image:        "worker:1.0",
+			Mode:         "replicated",
+			DesiredTasks: 3,
+			RunningTasks: 1,
+			Pending:      "2 preparing (2m0s)",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printPs(&buf, "mystack", statuses, false); err != nil {
+		t.Fatalf("printPs failed: %v", err)
+	}
+
+	for _, want := range []string{"PENDING", "1/3", "2 preparing (2m0s)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
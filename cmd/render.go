@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// Values of the render -o flag
+const (
+	renderJSON = "json"
+	renderYAML = "yaml"
+)
+
+// ExecuteRender runs the render command
+func ExecuteRender(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+
+	// Required flags
+	stackName := fs.String("n", "", "Stack name (required)")
+	composeFile := fs.String("f", "", "Compose file path or http(s)://, git:: URL (required)")
+
+	// Optional flags
+	valuesFile := fs.String("values", "", "Variables file for ${VAR} interpolation (.env, .yaml/.yml or .json)")
+	setValues := fs.String("set", "", "Set interpolation variables (comma-separated key=value pairs, override -values)")
+	format := fs.String("o", renderJSON, "Output format: json or yaml")
+	var profiles stringList
+	fs.Var(&profiles, "profile", "Enable compose services of this profile (repeatable or comma-separated), as apply -profile does")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman render -n <stack> -f <compose-file> [flags]
+
+Print the swarm service, network, secret and config specs apply would create
+from the compose file. No Docker connection is needed; secret data is omitted.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Validate required flags
+	if *stackName == "" {
+		fmt.Fprintf(os.Stderr, "Error: -n (stack name) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *composeFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -f (compose file) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *format != renderJSON && *format != renderYAML {
+		fmt.Fprintf(os.Stderr, "Error: -o must be json or yaml\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	vars, err := interpolationVars(*valuesFile, *setValues)
+	if err != nil {
+		log.Fatalf("Render failed: %v", err)
+	}
+
+	if err := renderStack(os.Stdout, *stackName, *composeFile, vars, profiles, *format); err != nil {
+		log.Fatalf("Render failed: %v", err)
+	}
+}
+
+// renderStack parses, validates and converts the compose file as apply does and
+// writes the resulting swarm specs in the given format
+func renderStack(w io.Writer, stackName, composeFile string, vars map[string]string, profiles []string, format string) error {
+	composeSpec, err := compose.ParseComposeFileWithVars(composeFile, vars)
+	if err != nil {
+		return fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	for _, name := range composeSpec.UnsetVariables {
+		log.Printf("WARNING: variable %s is not set, substituting an empty string", name)
+	}
+	if err := applyProfiles(composeSpec, profiles); err != nil {
+		return err
+	}
+	if err := compose.Validate(composeSpec); err != nil {
+		return fmt.Errorf("invalid compose file:\n%w", err)
+	}
+	if err := applyImageHealthcheckIgnores(composeSpec, nil); err != nil {
+		return err
+	}
+	if err := resolveServiceEnvironment(composeSpec, nil); err != nil {
+		return err
+	}
+
+	rendered, err := swarm.RenderStack(stackName, composeSpec)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(rendered, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode specs: %w", err)
+	}
+	if format == renderJSON {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	// YAML keeps the JSON field names of the Docker API types
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to encode specs: %w", err)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode specs: %w", err)
+	}
+	return enc.Close()
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite golden files with the current output")
+
+func TestRenderStack_Golden(t *testing.T) {
+	// Secret and config files are relative to the working directory
+	t.Setenv("STACKMAN_WORKDIR", renderTestdata(t))
+
+	for _, format := range []string{renderJSON, renderYAML} {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			vars := map[string]string{"API_TAG": "2.3.1"}
+			if err := renderStack(&out, "shop", filepath.Join("testdata", "render", "docker-compose.yml"), vars, nil, format); err != nil {
+				t.Fatalf("renderStack() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", "render", "stack."+format+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("renderStack() output differs from %s (run with -update to accept):\n%s", golden, out.String())
+			}
+		})
+	}
+}
+
+// renderTestdata returns the absolute path of the render test fixtures
+func renderTestdata(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.Abs(filepath.Join("testdata", "render"))
+	if err != nil {
+		t.Fatalf("Failed to resolve testdata: %v", err)
+	}
+	return dir
+}
+
+func TestRenderStack_OmitsSecretData(t *testing.T) {
+	// Secret and config files are relative to the working directory
+	t.Setenv("STACKMAN_WORKDIR", renderTestdata(t))
+
+	var out bytes.Buffer
+	if err := renderStack(&out, "shop", filepath.Join("testdata", "render", "docker-compose.yml"), map[string]string{"API_TAG": "2.3.1"}, nil, renderJSON); err != nil {
+		t.Fatalf("renderStack() error = %v", err)
+	}
+	// base64 of the secret file content "s3cr3t\n"
+	if strings.Contains(out.String(), "czNjcjN0Cg==") {
+		t.Error("Expected secret data to be left out of the rendered specs")
+	}
+}
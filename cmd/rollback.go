@@ -4,13 +4,16 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 
+	"github.com/SomeBlackMagic/stackman/internal/snapshot"
 	"github.com/SomeBlackMagic/stackman/internal/swarm"
 )
 
@@ -23,16 +26,20 @@ func ExecuteRollback(args []string) {
 
 	// Optional flags
 	rollbackTimeout := fs.Duration("rollback-timeout", 10*time.Minute, "Rollback timeout")
-	// TODO: Add --snapshot flag for manual rollback from saved snapshot
+	rollbackTo := fs.String("rollback-to", "", "Restore a saved snapshot by ID or timestamp (RFC3339): the newest snapshot at or before it (default: latest)")
+	list := fs.Bool("list", false, "List saved snapshots of the stack and exit")
+	previousSpec := fs.Bool("previous-spec", false, "Use Docker Swarm's built-in rollback to each service's previous spec instead of a snapshot")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: stackman rollback -n <stack> [flags]
 
 Rollback stack services to their previous state.
 
-Note: This command performs automatic rollback using Docker Swarm's built-in
-rollback mechanism. For custom rollback from snapshot, use apply with appropriate
-compose file.
+Every successful apply saves the pre-deploy state as a snapshot (in
+STACKMAN_SNAPSHOT_DIR, default ~/.stackman/snapshots). By default the latest
+snapshot is restored; use -list to see them and -rollback-to to pick another.
+With -previous-spec, Docker Swarm's built-in rollback to each service's
+previous spec is used instead.
 
 Flags:
 `)
@@ -50,9 +57,18 @@ Flags:
 		os.Exit(1)
 	}
 
+	if *previousSpec && (*list || *rollbackTo != "") {
+		fmt.Fprintf(os.Stderr, "Error: -previous-spec cannot be combined with -list or -rollback-to\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
 	// Run rollback logic
 	if err := runRollback(*stackName, &RollbackOptions{
-		Timeout: *rollbackTimeout,
+		Timeout:      *rollbackTimeout,
+		RollbackTo:   *rollbackTo,
+		List:         *list,
+		PreviousSpec: *previousSpec,
 	}); err != nil {
 		log.Fatalf("Rollback failed: %v", err)
 		os.Exit(3) // Exit code 3 for rollback failure
@@ -61,11 +77,28 @@ Flags:
 
 // RollbackOptions contains options for the rollback command
 type RollbackOptions struct {
-	Timeout time.Duration
+	Timeout    time.Duration
+	RollbackTo string
+	List       bool
+
+	PreviousSpec bool // Use Swarm's per-service PreviousSpec instead of a snapshot
 }
 
 // runRollback performs automatic rollback of stack services
 func runRollback(stackName string, opts *RollbackOptions) error {
+	if !opts.PreviousSpec {
+		dir, err := snapshot.DefaultDir()
+		if err != nil {
+			return err
+		}
+		store := snapshot.NewStore(dir)
+
+		if opts.List {
+			return listSnapshots(os.Stdout, store, stackName)
+		}
+		return rollbackToSnapshot(stackName, store, opts)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
 	defer cancel()
 
@@ -79,7 +112,11 @@ func runRollback(stackName string, opts *RollbackOptions) error {
 	log.Printf("Starting rollback for stack: %s", stackName)
 
 	// Create deployer
-	stackDeployer := swarm.NewStackDeployer(cli, stackName, 3)
+	managerCli, err := swarm.NewManagerClient(cli)
+	if err != nil {
+		return err
+	}
+	stackDeployer := swarm.NewStackDeployer(managerCli, stackName, 3)
 
 	// Get current services
 	services, err := stackDeployer.GetStackServices(ctx)
@@ -134,3 +171,47 @@ func runRollback(stackName string, opts *RollbackOptions) error {
 
 	return nil
 }
+
+// listSnapshots prints the saved snapshots of a stack, newest first
+func listSnapshots(w io.Writer, store *snapshot.Store, stackName string) error {
+	infos, err := store.List(stackName)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		fmt.Fprintf(w, "No snapshots found for stack '%s' in %s\n", stackName, store.Dir)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\n", info.ID, info.CreatedAt.Local().Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// rollbackToSnapshot restores the stack to a saved snapshot, the latest when no -rollback-to is given
+func rollbackToSnapshot(stackName string, store *snapshot.Store, opts *RollbackOptions) error {
+	snap, err := store.Load(stackName, opts.RollbackTo)
+	if err != nil {
+		return err
+	}
+
+	// Initialize Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("docker client init: %w", err)
+	}
+	defer cli.Close()
+
+	log.Printf("Restoring stack %s to snapshot taken at %s (%d services)",
+		stackName, snap.CreatedAt.Format(time.RFC3339), len(snap.Services))
+
+	managerCli, err := swarm.NewManagerClient(cli)
+	if err != nil {
+		return err
+	}
+	stackDeployer := swarm.NewStackDeployer(managerCli, stackName, 3)
+	return snapshot.Rollback(context.Background(), stackDeployer, snap, opts.Timeout)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SomeBlackMagic/stackman/internal/snapshot"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+func TestListSnapshots(t *testing.T) {
+	store := snapshot.NewStore(t.TempDir())
+
+	var buf bytes.Buffer
+	if err := listSnapshots(&buf, store, "mystack"); err != nil {
+		t.Fatalf("listSnapshots failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "No snapshots found") {
+		t.Errorf("Expected empty listing message, got: %s", buf.String())
+	}
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		snap := &swarm.StackSnapshot{StackName: "mystack", CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if _, err := store.Save(snap); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	buf.Reset()
+	if err := listSnapshots(&buf, store, "mystack"); err != nil {
+		t.Fatalf("listSnapshots failed: %v", err)
+	}
+	out := buf.String()
+	newer, older := strings.Index(out, "20261001T130000Z"), strings.Index(out, "20261001T120000Z")
+	if newer < 0 || older < 0 || newer > older {
+		t.Errorf("Expected both snapshots listed newest first, got:\n%s", out)
+	}
+}
@@ -18,6 +18,18 @@ func Execute() {
 	switch command {
 	case "apply":
 		ExecuteApply(args)
+	case "plan":
+		ExecutePlan(args)
+	case "lint":
+		ExecuteLint(args)
+	case "render":
+		ExecuteRender(args)
+	case "ps":
+		ExecutePs(args)
+	case "scale":
+		ExecuteScale(args)
+	case "down", "rm":
+		ExecuteDown(args)
 	case "rollback":
 		ExecuteRollback(args)
 	case "diff":
@@ -47,6 +59,12 @@ Usage:
 
 Available Commands:
   apply       Deploy or update a stack
+  plan        Show what apply would change (exit 2 on changes)
+  lint        Check a compose file for best-practice issues
+  render      Print the swarm specs apply would create from a compose file
+  ps          List stack services with task counts and failures
+  scale       Set the replica count of stack services
+  down, rm    Remove a stack (services, networks, secrets, configs)
   rollback    Rollback stack to previous state
   diff        Show deployment plan without applying
   status      Show current stack status
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/deployment"
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// ExecuteScale runs the scale command
+func ExecuteScale(args []string) {
+	fs := flag.NewFlagSet("scale", flag.ExitOnError)
+
+	// Required flags
+	stackName := fs.String("n", "", "Stack name (required)")
+
+	// Optional flags
+	timeout := fs.Duration("timeout", 10*time.Minute, "Maximum time to wait for the new task counts")
+	noWait := fs.Bool("no-wait", false, "Return once the services are updated, without waiting for their tasks")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: stackman scale -n <stack> [flags] <service>=<count> [<service>=<count>...]
+
+Set the replica count of stack services without re-applying the compose file,
+then wait until each service runs the new number of tasks.
+Global services cannot be scaled.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Validate required flags
+	if *stackName == "" {
+		fmt.Fprintf(os.Stderr, "Error: -n (stack name) is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	targets, err := parseScaleArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := runScale(*stackName, targets, &ScaleOptions{
+		Timeout: *timeout,
+		NoWait:  *noWait,
+	}); err != nil {
+		log.Fatalf("Scale failed: %v", err)
+	}
+}
+
+// ScaleOptions contains options for the scale command
+type ScaleOptions struct {
+	Timeout time.Duration
+	NoWait  bool
+}
+
+// scaleTarget is one <service>=<count> argument of the scale command
+type scaleTarget struct {
+	Service  string
+	Replicas uint64
+}
+
+// parseScaleArgs parses <service>=<count> arguments, keeping their order
+func parseScaleArgs(args []string) ([]scaleTarget, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("at least one <service>=<count> argument is required")
+	}
+
+	seen := make(map[string]bool)
+	targets := make([]scaleTarget, 0, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid scale argument %q, expected <service>=<count>", arg)
+		}
+		replicas, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica count %q for service %s: must be a non-negative integer", value, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("service %s is given more than once", name)
+		}
+		seen[name] = true
+		targets = append(targets, scaleTarget{Service: name, Replicas: replicas})
+	}
+	return targets, nil
+}
+
+// runScale updates the replica counts and waits for the services to converge
+func runScale(stackName string, targets []scaleTarget, opts *ScaleOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// Initialize Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("docker client init: %w", err)
+	}
+	defer cli.Close()
+
+	scaled, err := scaleServices(ctx, cli, stackName, targets)
+	if err != nil {
+		return err
+	}
+	if opts.NoWait {
+		return nil
+	}
+
+	// Each service keeps the deploy ID of the apply that created its tasks
+	for _, svc := range scaled {
+		if err := waitForConvergence(ctx, cli, []swarm.ServiceUpdateResult{svc}, svc.DeployID); err != nil {
+			return err
+		}
+		log.Printf("✅ Service %s scaled", svc.ServiceName)
+	}
+	return nil
+}
+
+// scaleServices sets Mode.Replicated.Replicas of each target service. All targets
+// are inspected before any is updated, so an unknown or global service leaves the
+// stack untouched.
+func scaleServices(ctx context.Context, cli swarm.DockerClient, stackName string, targets []scaleTarget) ([]swarm.ServiceUpdateResult, error) {
+	type scaledService struct {
+		service  dockerswarm.Service
+		replicas uint64
+	}
+
+	services := make([]scaledService, 0, len(targets))
+	for _, target := range targets {
+		fullName := stackName + "_" + target.Service
+		service, _, err := cli.ServiceInspectWithRaw(ctx, fullName, types.ServiceInspectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("service %s: failed to inspect: %w", target.Service, err)
+		}
+		if service.Spec.Labels["com.docker.stack.namespace"] != stackName {
+			return nil, fmt.Errorf("service %s is not part of stack %s", target.Service, stackName)
+		}
+		if service.Spec.Mode.Replicated == nil {
+			return nil, fmt.Errorf("service %s: cannot scale a global service", target.Service)
+		}
+		services = append(services, scaledService{service: service, replicas: target.Replicas})
+	}
+
+	// Updates go through the deployer, which retries conflicts and passes registry auth
+	deployer := swarm.NewStackDeployer(cli, stackName, 3)
+	results := make([]swarm.ServiceUpdateResult, 0, len(services))
+	for _, s := range services {
+		spec := s.service.Spec
+		replicated := *spec.Mode.Replicated
+		replicated.Replicas = &s.replicas
+		spec.Mode.Replicated = &replicated
+
+		log.Printf("Scaling service %s to %d replica(s)", spec.Name, s.replicas)
+		resp, err := deployer.UpdateService(ctx, s.service, spec)
+		if err != nil {
+			return results, fmt.Errorf("service %s: failed to scale: %w", spec.Name, err)
+		}
+		for _, warning := range resp.Warnings {
+			log.Printf("WARNING: service %s: %s", spec.Name, warning)
+		}
+
+		var deployID string
+		if spec.TaskTemplate.ContainerSpec != nil {
+			deployID = spec.TaskTemplate.ContainerSpec.Labels[deployment.DeployIDLabel]
+		}
+		results = append(results, swarm.ServiceUpdateResult{
+			ServiceID:   s.service.ID,
+			ServiceName: spec.Name,
+			Warnings:    resp.Warnings,
+			Changed:     true,
+			DeployID:    deployID,
+		})
+	}
+	return results, nil
+}
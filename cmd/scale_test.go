@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+func TestParseScaleArgs(t *testing.T) {
+	targets, err := parseScaleArgs([]string{"web=3", "worker=0"})
+	if err != nil {
+		t.Fatalf("parseScaleArgs failed: %v", err)
+	}
+	want := []scaleTarget{{Service: "web", Replicas: 3}, {Service: "worker", Replicas: 0}}
+	if fmt.Sprint(targets) != fmt.Sprint(want) {
+		t.Errorf("parseScaleArgs() = %v, want %v", targets, want)
+	}
+
+	for _, args := range [][]string{nil, {"web"}, {"=3"}, {"web=-1"}, {"web=many"}, {"web=1", "web=2"}} {
+		if _, err := parseScaleArgs(args); err == nil {
+			t.Errorf("parseScaleArgs(%q) should fail", args)
+		}
+	}
+}
+
+// scaleClient serves services by name and records the specs they are updated to
+type scaleClient struct {
+	*swarm.MockDockerClient
+	services map[string]dockerswarm.Service
+	updated  map[string]dockerswarm.ServiceSpec
+}
+
+func (c *scaleClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (dockerswarm.Service, []byte, error) {
+	service, ok := c.services[serviceID]
+	if !ok {
+		return dockerswarm.Service{}, nil, fmt.Errorf("service not found: %s", serviceID)
+	}
+	return service, nil, nil
+}
+
+func (c *scaleClient) ServiceUpdate(ctx context.Context, serviceID string, version dockerswarm.Version, service dockerswarm.ServiceSpec, options types.ServiceUpdateOptions) (dockerswarm.ServiceUpdateResponse, error) {
+	if c.updated == nil {
+		c.updated = make(map[string]dockerswarm.ServiceSpec)
+	}
+	c.updated[serviceID] = service
+	return dockerswarm.ServiceUpdateResponse{}, nil
+}
+
+func newScaleClient() *scaleClient {
+	one := uint64(1)
+	stackLabels := map[string]string{"com.docker.stack.namespace": "mystack"}
+	return &scaleClient{
+		MockDockerClient: &swarm.MockDockerClient{},
+		services: map[string]dockerswarm.Service{
+			"mystack_web": {
+				ID: "svc-web",
+				Spec: dockerswarm.ServiceSpec{
+					Annotations: dockerswarm.Annotations{Name: "mystack_web", Labels: stackLabels},
+					Mode:        dockerswarm.ServiceMode{Replicated: &dockerswarm.ReplicatedService{Replicas: &one}},
+					TaskTemplate: dockerswarm.TaskSpec{ContainerSpec: &dockerswarm.ContainerSpec{
+						Image:  "nginx:1.25",
+						Labels: map[string]string{"com.stackman.deploy.id": "deploy-1"},
+					}},
+				},
+			},
+			"mystack_agent": {
+				ID: "svc-agent",
+				Spec: dockerswarm.ServiceSpec{
+					Annotations: dockerswarm.Annotations{Name: "mystack_agent", Labels: stackLabels},
+					Mode:        dockerswarm.ServiceMode{Global: &dockerswarm.GlobalService{}},
+				},
+			},
+		},
+	}
+}
+
+func TestScaleServices_UpdatesReplicas(t *testing.T) {
+	cli := newScaleClient()
+
+	results, err := scaleServices(context.Background(), cli, "mystack", []scaleTarget{{Service: "web", Replicas: 3}})
+	if err != nil {
+		t.Fatalf("scaleServices failed: %v", err)
+	}
+
+	spec, ok := cli.updated["svc-web"]
+	if !ok {
+		t.Fatal("Expected service web to be updated")
+	}
+	if spec.Mode.Replicated == nil || spec.Mode.Replicated.Replicas == nil || *spec.Mode.Replicated.Replicas != 3 {
+		t.Errorf("Expected 3 replicas, got %+v", spec.Mode)
+	}
+	if spec.TaskTemplate.ContainerSpec.Image != "nginx:1.25" {
+		t.Errorf("Expected the rest of the spec to be kept, got image %q", spec.TaskTemplate.ContainerSpec.Image)
+	}
+	if got := *cli.services["mystack_web"].Spec.Mode.Replicated.Replicas; got != 1 {
+		t.Errorf("The inspected spec must not be modified in place, replicas now %d", got)
+	}
+
+	if len(results) != 1 || results[0].ServiceID != "svc-web" || results[0].DeployID != "deploy-1" {
+		t.Errorf("Expected a result for svc-web keeping deploy-1, got %+v", results)
+	}
+}
+
+func TestScaleServices_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []scaleTarget
+		wantErr string
+	}{
+		{"global service", []scaleTarget{{Service: "web", Replicas: 2}, {Service: "agent", Replicas: 2}}, "cannot scale a global service"},
+		{"unknown service", []scaleTarget{{Service: "web", Replicas: 2}, {Service: "db", Replicas: 2}}, "service db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newScaleClient()
+			_, err := scaleServices(context.Background(), cli, "mystack", tt.targets)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if len(cli.updated) != 0 {
+				t.Errorf("Expected no service to be updated, got %v", cli.updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// convertCommand normalizes a compose command or entrypoint to an argv slice.
+// The string form is split like a POSIX shell would (quotes and escapes are
+// honoured, no expansion is done); the list form is used verbatim.
+func convertCommand(cmd interface{}) ([]string, error) {
+	switch v := cmd.(type) {
+	case string:
+		return splitShellWords(v)
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for i, item := range v {
+			switch item := item.(type) {
+			case string:
+				result = append(result, item)
+			case int, int64, float64, bool:
+				result = append(result, fmt.Sprint(item))
+			default:
+				return nil, fmt.Errorf("item %d: unsupported type %T", i, item)
+			}
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported command type: %T", cmd)
+	}
+}
+
+// splitShellWords tokenizes s using POSIX shell quoting rules:
+// single quotes are literal, double quotes allow \" \\ \$ and \` escapes,
+// and an unquoted backslash escapes the next character.
+func splitShellWords(s string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '\\':
+			inWord = true
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			// Backslash-newline is a line continuation
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+			}
+
+		case r == '\'':
+			inWord = true
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote in %q", s)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in %q", s)
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,123 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected []string
+	}{
+		{"simple", "nginx -g daemon", []string{"nginx", "-g", "daemon"}},
+		{"extra whitespace", "  echo \t hi  ", []string{"echo", "hi"}},
+		{"single quotes", `sh -c 'echo hi'`, []string{"sh", "-c", "echo hi"}},
+		{"double quotes", `sh -c "echo \"hi there\""`, []string{"sh", "-c", `echo "hi there"`}},
+		{"single quotes keep backslashes", `echo 'a\b'`, []string{"echo", `a\b`}},
+		{"escaped space", `ls my\ file`, []string{"ls", "my file"}},
+		{"adjacent quoted parts", `--name="my app"'s'`, []string{"--name=my apps"}},
+		{"empty quoted argument", `printf ''`, []string{"printf", ""}},
+		{"no variable expansion", `echo "$HOME"`, []string{"echo", "$HOME"}},
+		{"list form verbatim", []interface{}{"sh", "-c", "echo 'a  b'"}, []string{"sh", "-c", "echo 'a  b'"}},
+		{"list form numbers", []interface{}{"sleep", 30}, []string{"sleep", "30"}},
+		{"empty string", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertCommand(tt.input)
+			if err != nil {
+				t.Fatalf("convertCommand(%v) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("convertCommand(%v) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertCommand_Invalid(t *testing.T) {
+	tests := []struct {
+		input   interface{}
+		wantErr string
+	}{
+		{`sh -c 'echo hi`, "unterminated single quote"},
+		{`echo "hi`, "unterminated double quote"},
+		{`echo hi\`, "trailing backslash"},
+		{[]interface{}{"echo", map[string]interface{}{}}, "item 1"},
+		{42, "unsupported command type"},
+	}
+
+	for _, tt := range tests {
+		_, err := convertCommand(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("convertCommand(%v): expected error containing %q, got %v", tt.input, tt.wantErr, err)
+		}
+	}
+}
+
+func TestConvertToSwarmSpec_CommandAndEntrypoint(t *testing.T) {
+	service := &Service{
+		Image:      "alpine:3.20",
+		Entrypoint: "/bin/sh -c",
+		Command:    `'echo "hello world"'`,
+	}
+
+	spec, err := ConvertToSwarmSpec("app", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if !reflect.DeepEqual(containerSpec.Command, []string{"/bin/sh", "-c"}) {
+		t.Errorf("Expected entrypoint as container command, got %q", containerSpec.Command)
+	}
+	if !reflect.DeepEqual(containerSpec.Args, []string{`echo "hello world"`}) {
+		t.Errorf("Expected command as container args, got %q", containerSpec.Args)
+	}
+}
+
+func TestConvertToSwarmSpec_EmptyEntrypointClearsImageEntrypoint(t *testing.T) {
+	data := `
+services:
+  reset:
+    image: alpine:3.20
+    entrypoint: []
+    command: ["echo", "hi"]
+  inherit:
+    image: alpine:3.20
+`
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	spec, err := ConvertToSwarmSpec("reset", composeFile.Services["reset"], "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if !reflect.DeepEqual(spec.TaskTemplate.ContainerSpec.Command, []string{""}) {
+		t.Errorf("Expected cleared entrypoint [\"\"], got %q", spec.TaskTemplate.ContainerSpec.Command)
+	}
+	if !reflect.DeepEqual(spec.TaskTemplate.ContainerSpec.Args, []string{"echo", "hi"}) {
+		t.Errorf("Expected command kept as args, got %q", spec.TaskTemplate.ContainerSpec.Args)
+	}
+
+	// Omitting entrypoint keeps the image's entrypoint
+	spec, err = ConvertToSwarmSpec("inherit", composeFile.Services["inherit"], "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if spec.TaskTemplate.ContainerSpec.Command != nil {
+		t.Errorf("Expected no entrypoint override, got %q", spec.TaskTemplate.ContainerSpec.Command)
+	}
+}
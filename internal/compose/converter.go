@@ -2,6 +2,8 @@ package compose
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,8 +17,9 @@ import (
 	"github.com/SomeBlackMagic/stackman/internal/paths"
 )
 
-// ConvertToSwarmSpec converts a compose service to Docker Swarm ServiceSpec
-func ConvertToSwarmSpec(serviceName string, service *Service, stackName string) (*swarm.ServiceSpec, error) {
+// ConvertToSwarmSpec converts a compose service to Docker Swarm ServiceSpec.
+// baseDir is the compose file directory used for relative bind sources ("" = current directory).
+func ConvertToSwarmSpec(serviceName string, service *Service, stackName, baseDir string) (*swarm.ServiceSpec, error) {
 	// Set hostname: use service name if not specified
 	hostname := service.Hostname
 	if hostname == "" {
@@ -44,25 +47,28 @@ func ConvertToSwarmSpec(serviceName string, service *Service, stackName string)
 		},
 	}
 
-	// Add custom labels
-	if service.Labels != nil {
-		for k, v := range service.Labels {
-			spec.Annotations.Labels[k] = v
-		}
+	// Compose service labels are container labels; the namespace label is always
+	// set, and set last so a compose label can't move the container out of its
+	// stack. Container events carry it, which is how events tells stack containers.
+	labels := make(map[string]string, len(service.Labels)+1)
+	for k, v := range service.Labels {
+		labels[k] = v
 	}
+	labels["com.docker.stack.namespace"] = stackName
+	spec.TaskTemplate.ContainerSpec.Labels = labels
 
 	// Convert StopGracePeriod
 	if service.StopGracePeriod != "" {
 		duration, err := time.ParseDuration(service.StopGracePeriod)
 		if err != nil {
-			return nil, fmt.Errorf("invalid stop_grace_period: %w", err)
+			return nil, fmt.Errorf("service %s: invalid stop_grace_period: %w", serviceName, err)
 		}
 		spec.TaskTemplate.ContainerSpec.StopGracePeriod = &duration
 	}
 
 	// Convert Domainname, Stdin, TTY
 	if service.Domainname != "" {
-		spec.TaskTemplate.ContainerSpec.Hostname = service.Hostname + "." + service.Domainname
+		spec.TaskTemplate.ContainerSpec.Hostname = hostname + "." + service.Domainname
 	}
 	spec.TaskTemplate.ContainerSpec.OpenStdin = service.StdinOpen
 	spec.TaskTemplate.ContainerSpec.TTY = service.Tty
@@ -100,7 +106,16 @@ func ConvertToSwarmSpec(serviceName string, service *Service, stackName string)
 
 	// Convert ExtraHosts
 	if len(service.ExtraHosts) > 0 {
-		spec.TaskTemplate.ContainerSpec.Hosts = service.ExtraHosts
+		hosts, err := convertExtraHosts(service.ExtraHosts)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		spec.TaskTemplate.ContainerSpec.Hosts = hosts
+	}
+
+	// Swarm services can't run privileged; failing beats deploying without it
+	if service.Privileged {
+		return nil, fmt.Errorf("service %s: privileged is not supported by Docker Swarm, grant the required capabilities with cap_add instead", serviceName)
 	}
 
 	// Convert CapAdd
@@ -113,8 +128,26 @@ func ConvertToSwarmSpec(serviceName string, service *Service, stackName string)
 		spec.TaskTemplate.ContainerSpec.CapabilityDrop = service.CapDrop
 	}
 
-	// Note: SecurityOpt, Sysctls, and Ulimits are not supported in Docker Swarm API
-	// These fields are stored in compose types but won't be applied
+	// Convert Ulimits
+	if len(service.Ulimits) > 0 {
+		ulimits, err := convertUlimits(service.Ulimits)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		spec.TaskTemplate.ContainerSpec.Ulimits = ulimits
+	}
+
+	// Convert Sysctls
+	if service.Sysctls != nil {
+		sysctls, err := convertSysctls(service.Sysctls)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		spec.TaskTemplate.ContainerSpec.Sysctls = sysctls
+	}
+
+	// Note: SecurityOpt is not supported in the Docker Swarm API
+	// It is stored in compose types but won't be applied
 
 	// Convert environment variables
 	if service.Environment != nil {
@@ -125,35 +158,79 @@ func ConvertToSwarmSpec(serviceName string, service *Service, stackName string)
 		spec.TaskTemplate.ContainerSpec.Env = env
 	}
 
-	// Convert command
+	// Convert command (compose command maps to the container's args)
 	if service.Command != nil {
 		cmd, err := convertCommand(service.Command)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert command: %w", err)
 		}
-		spec.TaskTemplate.ContainerSpec.Command = cmd
+		spec.TaskTemplate.ContainerSpec.Args = cmd
 	}
 
-	// Convert entrypoint
+	// Convert entrypoint (compose entrypoint maps to the container's command)
 	if service.Entrypoint != nil {
 		entrypoint, err := convertCommand(service.Entrypoint)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert entrypoint: %w", err)
 		}
+		// entrypoint: [] (or "") clears the image entrypoint. An empty Command is
+		// dropped from the API request, so send [""] like `docker run --entrypoint ""`
+		if len(entrypoint) == 0 {
+			entrypoint = []string{""}
+		}
 		spec.TaskTemplate.ContainerSpec.Command = entrypoint
 	}
 
 	// Convert volumes/mounts
 	if len(service.Volumes) > 0 {
-		mounts, err := convertVolumes(service.Volumes)
+		mounts, err := convertVolumes(service.Volumes, stackName, baseDir)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert volumes: %w", err)
 		}
 		spec.TaskTemplate.ContainerSpec.Mounts = mounts
 	}
 
+	// Convert tmpfs mounts
+	if service.Tmpfs != nil {
+		mounts, err := convertTmpfs(service.Tmpfs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert tmpfs: %w", err)
+		}
+		spec.TaskTemplate.ContainerSpec.Mounts = append(spec.TaskTemplate.ContainerSpec.Mounts, mounts...)
+	}
+
+	// Convert networks
+	if service.Networks != nil {
+		networks, err := convertNetworks(service.Networks, service.NetworkOrder, stackName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert networks: %w", err)
+		}
+		spec.TaskTemplate.Networks = networks
+	}
+
+	// Convert secrets
+	if len(service.Secrets) > 0 {
+		secrets, err := convertSecrets(service.Secrets, stackName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert secrets: %w", err)
+		}
+		spec.TaskTemplate.ContainerSpec.Secrets = secrets
+	}
+
+	// Convert configs
+	if len(service.Configs) > 0 {
+		configs, err := convertConfigs(service.Configs, stackName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert configs: %w", err)
+		}
+		spec.TaskTemplate.ContainerSpec.Configs = configs
+	}
+
 	// Convert healthcheck
-	if service.HealthCheck != nil && !service.HealthCheck.Disable {
+	if service.HealthCheck != nil && service.HealthCheck.Disable {
+		// NONE also turns off a healthcheck defined in the image
+		spec.TaskTemplate.ContainerSpec.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+	} else if service.HealthCheck != nil {
 		healthcheck, err := convertHealthCheck(service.HealthCheck)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert healthcheck: %w", err)
@@ -179,12 +256,20 @@ func ConvertToSwarmSpec(serviceName string, service *Service, stackName string)
 		}
 	}
 
-	// Add deploy labels
-	if service.Deploy != nil && service.Deploy.Labels != nil {
+	// Convert endpoint mode
+	if service.Deploy != nil && service.Deploy.EndpointMode != "" {
+		if err := convertEndpointMode(spec, service.Deploy.EndpointMode); err != nil {
+			return nil, err
+		}
+	}
+
+	// Deploy labels are service labels; the namespace label can't be overridden
+	if service.Deploy != nil {
 		for k, v := range service.Deploy.Labels {
 			spec.Annotations.Labels[k] = v
 		}
 	}
+	spec.Annotations.Labels["com.docker.stack.namespace"] = stackName
 
 	return spec, nil
 }
@@ -217,34 +302,26 @@ func convertEnvironment(env interface{}) ([]string, error) {
 	}
 }
 
-func convertCommand(cmd interface{}) ([]string, error) {
-	switch v := cmd.(type) {
-	case string:
-		// Shell form: split by spaces (simple approach)
-		return strings.Fields(v), nil
-	case []interface{}:
-		result := make([]string, 0, len(v))
-		for _, item := range v {
-			if str, ok := item.(string); ok {
-				result = append(result, str)
-			}
-		}
-		return result, nil
-	default:
-		return nil, fmt.Errorf("unsupported command type: %T", cmd)
-	}
-}
-
-func convertVolumes(volumes []interface{}) ([]mount.Mount, error) {
+func convertVolumes(volumes []interface{}, stackName, baseDir string) ([]mount.Mount, error) {
 	var mounts []mount.Mount
 
-	// Create path resolver using STACKMAN_WORKDIR or current directory
-	resolver, err := paths.NewResolver()
+	// Create path resolver using STACKMAN_WORKDIR or the compose file directory
+	resolver, err := paths.NewResolverFor(baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create path resolver: %w", err)
 	}
 
 	for _, vol := range volumes {
+		// Long syntax: {type: bind, source: ./x, target: /y, read_only: true}
+		if long, ok := vol.(map[string]interface{}); ok {
+			m, err := convertLongVolume(long, resolver, stackName)
+			if err != nil {
+				return nil, err
+			}
+			mounts = append(mounts, m)
+			continue
+		}
+
 		volStr, ok := vol.(string)
 		if !ok {
 			continue
@@ -280,6 +357,268 @@ func convertVolumes(volumes []interface{}) ([]mount.Mount, error) {
 	return mounts, nil
 }
 
+// fileReference is a parsed service-level secret or config entry
+type fileReference struct {
+	Source string
+	Target string
+	UID    string
+	GID    string
+	Mode   os.FileMode
+}
+
+// parseFileReference parses the short (- name) or long (- source: name, target: ..., uid: ..., gid: ..., mode: ...)
+// syntax shared by service secrets and configs. Target defaults to the source name.
+func parseFileReference(item interface{}) (*fileReference, error) {
+	ref := &fileReference{
+		UID:  "0",
+		GID:  "0",
+		Mode: 0444,
+	}
+
+	switch v := item.(type) {
+	case string:
+		ref.Source = v
+	case map[string]interface{}:
+		src, ok := v["source"].(string)
+		if !ok || src == "" {
+			return nil, fmt.Errorf("entry is missing source")
+		}
+		ref.Source = src
+
+		if t, ok := v["target"].(string); ok {
+			ref.Target = t
+		}
+		if uid, ok := v["uid"]; ok {
+			ref.UID = fmt.Sprintf("%v", uid)
+		}
+		if gid, ok := v["gid"]; ok {
+			ref.GID = fmt.Sprintf("%v", gid)
+		}
+		if m, ok := v["mode"]; ok {
+			mode, err := parseFileMode(m)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ref.Source, err)
+			}
+			ref.Mode = mode
+		}
+	default:
+		return nil, fmt.Errorf("unsupported entry type: %T", item)
+	}
+
+	if ref.Target == "" {
+		ref.Target = ref.Source
+	}
+
+	return ref, nil
+}
+
+// convertNetworks builds network attachments for a service.
+// Targets are stack-scoped names (<stack>_<network>); the deployer rewrites
+// them for external networks.
+// Attachments keep the compose-declared order; order lists the keys of the map
+// form as written in the file (see Service.NetworkOrder).
+func convertNetworks(networks interface{}, order []string, stackName string) ([]swarm.NetworkAttachmentConfig, error) {
+	var names []string
+	options := make(map[string]interface{})
+
+	switch v := networks.(type) {
+	case []interface{}:
+		// List form: networks: [frontend, backend]
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported network entry type: %T", item)
+			}
+			names = append(names, name)
+		}
+	case map[string]interface{}:
+		// Map form: networks: {frontend: {aliases: [...]}}
+		options = v
+		if sameKeys(order, v) {
+			names = append(names, order...)
+			break
+		}
+		// Without a recorded order (service built in code) sort for deterministic output
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	default:
+		return nil, fmt.Errorf("unsupported networks type: %T", networks)
+	}
+
+	attachments := make([]swarm.NetworkAttachmentConfig, 0, len(names))
+	for _, name := range names {
+		attachment := swarm.NetworkAttachmentConfig{
+			Target: fmt.Sprintf("%s_%s", stackName, name),
+		}
+		if err := applyNetworkOptions(&attachment, options[name]); err != nil {
+			return nil, fmt.Errorf("network %s: %w", name, err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// applyNetworkOptions copies the aliases and driver_opts of a map-form network entry
+// to its attachment. Static addresses (ipv4_address, ipv6_address) have no Swarm
+// equivalent; ServiceWarnings reports them.
+func applyNetworkOptions(attachment *swarm.NetworkAttachmentConfig, options interface{}) error {
+	if options == nil {
+		return nil
+	}
+	m, ok := options.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unsupported network options type: %T", options)
+	}
+
+	if raw, ok := m["aliases"]; ok && raw != nil {
+		aliases, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("aliases must be a list, got %T", raw)
+		}
+		for _, item := range aliases {
+			alias, ok := item.(string)
+			if !ok || alias == "" {
+				return fmt.Errorf("unsupported alias: %v", item)
+			}
+			attachment.Aliases = append(attachment.Aliases, alias)
+		}
+	}
+
+	if raw, ok := m["driver_opts"]; ok && raw != nil {
+		opts, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("driver_opts must be a mapping, got %T", raw)
+		}
+		attachment.DriverOpts = make(map[string]string, len(opts))
+		for key, value := range opts {
+			attachment.DriverOpts[key] = fmt.Sprint(value)
+		}
+	}
+
+	return nil
+}
+
+// staticNetworkAddresses lists the ipv4_address and ipv6_address entries of the
+// map form of service networks as "<network>: <address>", sorted by network
+func staticNetworkAddresses(networks interface{}) []string {
+	m, ok := networks.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var addresses []string
+	for name, options := range m {
+		opts, ok := options.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"ipv4_address", "ipv6_address"} {
+			if address, ok := opts[key].(string); ok && address != "" {
+				addresses = append(addresses, fmt.Sprintf("%s: %s", name, address))
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// sameKeys reports whether order lists exactly the keys of m
+func sameKeys(order []string, m map[string]interface{}) bool {
+	if len(order) != len(m) {
+		return false
+	}
+	for _, k := range order {
+		if _, ok := m[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ReferenceSources returns the source names of service-level secret or config entries
+func ReferenceSources(entries []interface{}) ([]string, error) {
+	sources := make([]string, 0, len(entries))
+	for _, item := range entries {
+		ref, err := parseFileReference(item)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, ref.Source)
+	}
+	return sources, nil
+}
+
+// convertSecrets builds secret references for a service.
+// SecretName is set to the stack-scoped name (<stack>_<source>); SecretID is
+// resolved by the deployer once the secret exists in the swarm.
+func convertSecrets(secrets []interface{}, stackName string) ([]*swarm.SecretReference, error) {
+	refs := make([]*swarm.SecretReference, 0, len(secrets))
+
+	for _, item := range secrets {
+		ref, err := parseFileReference(item)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, &swarm.SecretReference{
+			File: &swarm.SecretReferenceFileTarget{
+				Name: ref.Target,
+				UID:  ref.UID,
+				GID:  ref.GID,
+				Mode: ref.Mode,
+			},
+			SecretName: fmt.Sprintf("%s_%s", stackName, ref.Source),
+		})
+	}
+
+	return refs, nil
+}
+
+// convertConfigs builds config references for a service.
+// ConfigName is set to the stack-scoped name (<stack>_<source>); ConfigID is
+// resolved by the deployer once the config exists in the swarm.
+func convertConfigs(configs []interface{}, stackName string) ([]*swarm.ConfigReference, error) {
+	refs := make([]*swarm.ConfigReference, 0, len(configs))
+
+	for _, item := range configs {
+		ref, err := parseFileReference(item)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, &swarm.ConfigReference{
+			File: &swarm.ConfigReferenceFileTarget{
+				Name: ref.Target,
+				UID:  ref.UID,
+				GID:  ref.GID,
+				Mode: ref.Mode,
+			},
+			ConfigName: fmt.Sprintf("%s_%s", stackName, ref.Source),
+		})
+	}
+
+	return refs, nil
+}
+
+// parseFileMode parses a file mode given either as a YAML integer (0440) or an octal string ("0440")
+func parseFileMode(value interface{}) (os.FileMode, error) {
+	switch v := value.(type) {
+	case int:
+		return os.FileMode(v), nil
+	case string:
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid mode %q: %w", v, err)
+		}
+		return os.FileMode(mode), nil
+	default:
+		return 0, fmt.Errorf("unsupported mode type: %T", value)
+	}
+}
+
 func convertHealthCheck(hc *HealthCheck) (*container.HealthConfig, error) {
 	config := &container.HealthConfig{}
 
@@ -332,16 +671,31 @@ func convertHealthCheck(hc *HealthCheck) (*container.HealthConfig, error) {
 	return config, nil
 }
 
+// resolveReplicas returns the replica count Swarm should run, or nil to leave it
+// at the Swarm default of one. Compose stores replicas as an int, so negative
+// values are rejected here rather than wrapping around in the uint64 conversion.
+func resolveReplicas(deploy *DeployConfig) (*uint64, error) {
+	if deploy == nil || deploy.Replicas == nil {
+		return nil, nil
+	}
+	if *deploy.Replicas < 0 {
+		return nil, fmt.Errorf("invalid replicas %d: must not be negative", *deploy.Replicas)
+	}
+	replicas := uint64(*deploy.Replicas)
+	return &replicas, nil
+}
+
 func convertDeploy(spec *swarm.ServiceSpec, deploy *DeployConfig) error {
 	// Set mode
 	if deploy.Mode == "" || deploy.Mode == "replicated" {
 		spec.Mode = swarm.ServiceMode{
 			Replicated: &swarm.ReplicatedService{},
 		}
-		if deploy.Replicas != nil {
-			replicas := uint64(*deploy.Replicas)
-			spec.Mode.Replicated.Replicas = &replicas
+		replicas, err := resolveReplicas(deploy)
+		if err != nil {
+			return err
 		}
+		spec.Mode.Replicated.Replicas = replicas
 	} else if deploy.Mode == "global" {
 		spec.Mode = swarm.ServiceMode{
 			Global: &swarm.GlobalService{},
@@ -451,8 +805,33 @@ func convertDeploy(spec *swarm.ServiceSpec, deploy *DeployConfig) error {
 				}
 				reservations.MemoryBytes = memory
 			}
+			genericResources, err := convertGenericResources(deploy.Resources.Reservations.GenericResources)
+			if err != nil {
+				return err
+			}
+			reservations.GenericResources = genericResources
 			spec.TaskTemplate.Resources.Reservations = reservations
 		}
+
+		// Swarm has no device requests: devices are reserved as generic resources
+		var devices []DeviceRequest
+		if deploy.Resources.Limits != nil {
+			devices = append(devices, deploy.Resources.Limits.Devices...)
+		}
+		if deploy.Resources.Reservations != nil {
+			devices = append(devices, deploy.Resources.Reservations.Devices...)
+		}
+		if len(devices) > 0 {
+			deviceResources, err := convertDeviceRequests(devices)
+			if err != nil {
+				return err
+			}
+			if spec.TaskTemplate.Resources.Reservations == nil {
+				spec.TaskTemplate.Resources.Reservations = &swarm.Resources{}
+			}
+			reservations := spec.TaskTemplate.Resources.Reservations
+			reservations.GenericResources = append(reservations.GenericResources, deviceResources...)
+		}
 	}
 
 	// Convert placement
@@ -469,18 +848,101 @@ func convertDeploy(spec *swarm.ServiceSpec, deploy *DeployConfig) error {
 	return nil
 }
 
+// convertGenericResources converts discrete (count) and named (string) generic resource reservations
+func convertGenericResources(resources []GenericResource) ([]swarm.GenericResource, error) {
+	var result []swarm.GenericResource
+	for i, res := range resources {
+		switch {
+		case res.DiscreteResourceSpec != nil && res.NamedResourceSpec != nil:
+			return nil, fmt.Errorf("generic resource %d: discrete_resource_spec and named_resource_spec are mutually exclusive", i)
+		case res.DiscreteResourceSpec != nil:
+			if res.DiscreteResourceSpec.Kind == "" {
+				return nil, fmt.Errorf("generic resource %d: kind is required", i)
+			}
+			result = append(result, swarm.GenericResource{
+				DiscreteResourceSpec: &swarm.DiscreteGenericResource{
+					Kind:  res.DiscreteResourceSpec.Kind,
+					Value: res.DiscreteResourceSpec.Value,
+				},
+			})
+		case res.NamedResourceSpec != nil:
+			if res.NamedResourceSpec.Kind == "" || res.NamedResourceSpec.Value == "" {
+				return nil, fmt.Errorf("generic resource %d: kind and value are required", i)
+			}
+			result = append(result, swarm.GenericResource{
+				NamedResourceSpec: &swarm.NamedGenericResource{
+					Kind:  res.NamedResourceSpec.Kind,
+					Value: res.NamedResourceSpec.Value,
+				},
+			})
+		default:
+			return nil, fmt.Errorf("generic resource %d: expected discrete_resource_spec or named_resource_spec", i)
+		}
+	}
+	return result, nil
+}
+
+// nvidiaGPUKind is the generic resource kind NVIDIA GPUs are advertised under
+// in the node-generic-resources of the daemon configuration
+const nvidiaGPUKind = "NVIDIA-GPU"
+
+// convertDeviceRequests converts device requests into generic resource reservations:
+// a count becomes a discrete resource, each device ID a named resource.
+// The kind is taken from options.kind and defaults to NVIDIA-GPU for nvidia or gpu devices,
+// so "driver: nvidia, count: 1, capabilities: [gpu]" reserves one NVIDIA-GPU.
+func convertDeviceRequests(devices []DeviceRequest) ([]swarm.GenericResource, error) {
+	var result []swarm.GenericResource
+	for i, device := range devices {
+		kind := device.Options["kind"]
+		if kind == "" {
+			if device.Driver != "nvidia" && !(device.Driver == "" && device.hasCapability("gpu")) {
+				return nil, fmt.Errorf("device %d: options.kind is required for driver %q: it must name a generic resource the nodes advertise", i, device.Driver)
+			}
+			kind = nvidiaGPUKind
+		}
+
+		switch {
+		case device.Count != 0 && len(device.DeviceIDs) > 0:
+			return nil, fmt.Errorf("device %d: count and device_ids are mutually exclusive", i)
+		case device.Count > 0:
+			result = append(result, swarm.GenericResource{
+				DiscreteResourceSpec: &swarm.DiscreteGenericResource{Kind: kind, Value: int64(device.Count)},
+			})
+		case len(device.DeviceIDs) > 0:
+			for _, id := range device.DeviceIDs {
+				result = append(result, swarm.GenericResource{
+					NamedResourceSpec: &swarm.NamedGenericResource{Kind: kind, Value: id},
+				})
+			}
+		default:
+			return nil, fmt.Errorf("device %d: a positive count or device_ids is required, swarm cannot reserve all devices", i)
+		}
+	}
+	return result, nil
+}
+
+// hasCapability reports whether the device request lists the capability
+func (d DeviceRequest) hasCapability(capability string) bool {
+	for _, c := range d.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 func convertPorts(ports []interface{}) ([]swarm.PortConfig, error) {
 	var result []swarm.PortConfig
 
 	for _, p := range ports {
 		switch v := p.(type) {
 		case string:
-			// Short syntax: "8080:80" or "8080:80/tcp"
-			portConfig, err := parsePortString(v)
+			// Short syntax: "8080:80", "8080-8090:80-90/udp", "127.0.0.1:8080:80"
+			portConfigs, err := parsePortString(v)
 			if err != nil {
 				return nil, err
 			}
-			result = append(result, *portConfig)
+			result = append(result, portConfigs...)
 
 		case map[string]interface{}:
 			// Long syntax
@@ -510,61 +972,87 @@ func convertPorts(ports []interface{}) ([]swarm.PortConfig, error) {
 	return result, nil
 }
 
-func parsePortString(portStr string) (*swarm.PortConfig, error) {
-	config := &swarm.PortConfig{
-		Protocol:    swarm.PortConfigProtocolTCP,
-		PublishMode: swarm.PortConfigPublishModeIngress,
+// convertEndpointMode sets the service resolution mode (vip or dnsrr).
+// DNS round-robin has no virtual IP for the routing mesh, so it only works
+// with host-mode published ports.
+func convertEndpointMode(spec *swarm.ServiceSpec, endpointMode string) error {
+	mode := swarm.ResolutionMode(endpointMode)
+	switch mode {
+	case swarm.ResolutionModeVIP, swarm.ResolutionModeDNSRR:
+	default:
+		return fmt.Errorf("invalid endpoint_mode %q (supported: vip, dnsrr)", endpointMode)
 	}
 
-	// Split by protocol if present
-	parts := strings.Split(portStr, "/")
-	portPart := parts[0]
-	if len(parts) > 1 {
-		config.Protocol = swarm.PortConfigProtocol(parts[1])
+	if spec.EndpointSpec == nil {
+		spec.EndpointSpec = &swarm.EndpointSpec{}
 	}
+	spec.EndpointSpec.Mode = mode
 
-	// Split published and target ports
-	portParts := strings.Split(portPart, ":")
-	if len(portParts) == 1 {
-		// Only target port specified
-		target, err := strconv.ParseUint(portParts[0], 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("invalid target port: %w", err)
+	if mode == swarm.ResolutionModeDNSRR {
+		for _, p := range spec.EndpointSpec.Ports {
+			if p.PublishMode != swarm.PortConfigPublishModeHost {
+				return fmt.Errorf("endpoint_mode dnsrr cannot be combined with ingress-published port %d/%s (use mode: host)", p.TargetPort, p.Protocol)
+			}
 		}
-		config.TargetPort = uint32(target)
-		config.PublishedPort = uint32(target)
-	} else if len(portParts) == 2 {
-		// Published:target format
-		published, err := strconv.ParseUint(portParts[0], 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("invalid published port: %w", err)
+	}
+	return nil
+}
+
+// convertExtraHosts converts compose "hostname:ip" (or "hostname=ip") entries to
+// the "ip hostname" form of ContainerSpec.Hosts. The IP may be IPv6, with or without brackets.
+func convertExtraHosts(extraHosts []string) ([]string, error) {
+	hosts := make([]string, 0, len(extraHosts))
+	for _, entry := range extraHosts {
+		sep := ":"
+		if strings.Contains(entry, "=") {
+			sep = "="
 		}
-		target, err := strconv.ParseUint(portParts[1], 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("invalid target port: %w", err)
+		host, ip, ok := strings.Cut(entry, sep)
+		host = strings.TrimSpace(host)
+		ip = strings.Trim(strings.TrimSpace(ip), "[]")
+		if !ok || host == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid extra_hosts entry %q: expected hostname:ip", entry)
 		}
-		config.PublishedPort = uint32(published)
-		config.TargetPort = uint32(target)
+		hosts = append(hosts, ip+" "+host)
 	}
-
-	return config, nil
+	return hosts, nil
 }
 
+// convertToStringSlice normalizes a field that compose allows as a string or a list of strings
 func convertToStringSlice(input interface{}) ([]string, error) {
 	switch v := input.(type) {
 	case []interface{}:
 		result := make([]string, 0, len(v))
 		for _, item := range v {
-			if str, ok := item.(string); ok {
-				result = append(result, str)
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported list item %v (%T), expected a string", item, item)
 			}
+			result = append(result, str)
 		}
 		return result, nil
 	case []string:
 		return v, nil
 	case string:
+		if v == "" {
+			return nil, nil
+		}
 		return []string{v}, nil
 	default:
 		return nil, fmt.Errorf("unsupported type for string slice: %T", input)
 	}
 }
+
+// ApplyDefaultRestartCondition sets the stack-wide restart condition on a service
+// whose restart_policy doesn't specify one. Explicit conditions are kept.
+func ApplyDefaultRestartCondition(spec *swarm.ServiceSpec, condition string) {
+	if condition == "" {
+		return
+	}
+	if spec.TaskTemplate.RestartPolicy == nil {
+		spec.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{}
+	}
+	if spec.TaskTemplate.RestartPolicy.Condition == "" {
+		spec.TaskTemplate.RestartPolicy.Condition = swarm.RestartPolicyCondition(condition)
+	}
+}
@@ -3,7 +3,13 @@ package compose
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"gopkg.in/yaml.v3"
 )
 
 func TestConvertVolumes_PathResolution(t *testing.T) {
@@ -44,7 +50,7 @@ func TestConvertVolumes_PathResolution(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			volumes := []interface{}{tt.volumeSpec}
-			mounts, err := convertVolumes(volumes)
+			mounts, err := convertVolumes(volumes, "mystack", "")
 
 			if err != nil {
 				t.Fatalf("convertVolumes() error = %v", err)
@@ -86,7 +92,7 @@ func TestConvertVolumes_WithSWARMSTACKPATH(t *testing.T) {
 	os.Setenv("STACKMAN_WORKDIR", customPath)
 
 	volumes := []interface{}{"./data:/app/data"}
-	mounts, err := convertVolumes(volumes)
+	mounts, err := convertVolumes(volumes, "mystack", "")
 
 	if err != nil {
 		t.Fatalf("convertVolumes() error = %v", err)
@@ -104,7 +110,7 @@ func TestConvertVolumes_WithSWARMSTACKPATH(t *testing.T) {
 
 func TestConvertVolumes_ReadOnly(t *testing.T) {
 	volumes := []interface{}{"/var/log:/logs:ro"}
-	mounts, err := convertVolumes(volumes)
+	mounts, err := convertVolumes(volumes, "mystack", "")
 
 	if err != nil {
 		t.Fatalf("convertVolumes() error = %v", err)
@@ -118,3 +124,838 @@ func TestConvertVolumes_ReadOnly(t *testing.T) {
 		t.Error("Expected mount to be read-only")
 	}
 }
+
+func TestConvertSecrets(t *testing.T) {
+	secrets := []interface{}{
+		"db_password",
+		map[string]interface{}{
+			"source": "api_key",
+			"target": "api.key",
+			"uid":    "103",
+			"gid":    103,
+			"mode":   0440,
+		},
+		map[string]interface{}{
+			"source": "tls_cert",
+			"mode":   "0400",
+		},
+	}
+
+	refs, err := convertSecrets(secrets, "mystack")
+	if err != nil {
+		t.Fatalf("convertSecrets() error = %v", err)
+	}
+
+	if len(refs) != 3 {
+		t.Fatalf("Expected 3 secret references, got %d", len(refs))
+	}
+
+	tests := []struct {
+		secretName string
+		target     string
+		uid        string
+		gid        string
+		mode       os.FileMode
+	}{
+		{"mystack_db_password", "db_password", "0", "0", 0444},
+		{"mystack_api_key", "api.key", "103", "103", 0440},
+		{"mystack_tls_cert", "tls_cert", "0", "0", 0400},
+	}
+
+	for i, tt := range tests {
+		ref := refs[i]
+		if ref.SecretName != tt.secretName {
+			t.Errorf("refs[%d].SecretName = %q, want %q", i, ref.SecretName, tt.secretName)
+		}
+		if ref.File == nil {
+			t.Fatalf("refs[%d].File is nil", i)
+		}
+		if ref.File.Name != tt.target {
+			t.Errorf("refs[%d].File.Name = %q, want %q", i, ref.File.Name, tt.target)
+		}
+		if ref.File.UID != tt.uid {
+			t.Errorf("refs[%d].File.UID = %q, want %q", i, ref.File.UID, tt.uid)
+		}
+		if ref.File.GID != tt.gid {
+			t.Errorf("refs[%d].File.GID = %q, want %q", i, ref.File.GID, tt.gid)
+		}
+		if ref.File.Mode != tt.mode {
+			t.Errorf("refs[%d].File.Mode = %o, want %o", i, ref.File.Mode, tt.mode)
+		}
+	}
+}
+
+func TestConvertSecrets_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []interface{}
+	}{
+		{"missing source", []interface{}{map[string]interface{}{"target": "x"}}},
+		{"invalid mode", []interface{}{map[string]interface{}{"source": "x", "mode": "rw"}}},
+		{"unsupported type", []interface{}{42}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := convertSecrets(tt.secrets, "mystack"); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConvertConfigs(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      interface{}
+		configName string
+		target     string
+		uid        string
+		gid        string
+		mode       os.FileMode
+	}{
+		{
+			name:       "short syntax",
+			entry:      "nginx_conf",
+			configName: "mystack_nginx_conf",
+			target:     "nginx_conf",
+			uid:        "0",
+			gid:        "0",
+			mode:       0444,
+		},
+		{
+			name: "long syntax",
+			entry: map[string]interface{}{
+				"source": "nginx_conf",
+				"target": "/etc/nginx/nginx.conf",
+				"uid":    "101",
+				"gid":    "101",
+				"mode":   0440,
+			},
+			configName: "mystack_nginx_conf",
+			target:     "/etc/nginx/nginx.conf",
+			uid:        "101",
+			gid:        "101",
+			mode:       0440,
+		},
+		{
+			name: "long syntax with defaults",
+			entry: map[string]interface{}{
+				"source": "app_yaml",
+			},
+			configName: "mystack_app_yaml",
+			target:     "app_yaml",
+			uid:        "0",
+			gid:        "0",
+			mode:       0444,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := convertConfigs([]interface{}{tt.entry}, "mystack")
+			if err != nil {
+				t.Fatalf("convertConfigs() error = %v", err)
+			}
+			if len(refs) != 1 {
+				t.Fatalf("Expected 1 config reference, got %d", len(refs))
+			}
+
+			ref := refs[0]
+			if ref.ConfigName != tt.configName {
+				t.Errorf("ConfigName = %q, want %q", ref.ConfigName, tt.configName)
+			}
+			if ref.File == nil {
+				t.Fatal("File target is nil")
+			}
+			if ref.File.Name != tt.target {
+				t.Errorf("File.Name = %q, want %q", ref.File.Name, tt.target)
+			}
+			if ref.File.UID != tt.uid || ref.File.GID != tt.gid {
+				t.Errorf("File UID/GID = %q/%q, want %q/%q", ref.File.UID, ref.File.GID, tt.uid, tt.gid)
+			}
+			if ref.File.Mode != tt.mode {
+				t.Errorf("File.Mode = %o, want %o", ref.File.Mode, tt.mode)
+			}
+		})
+	}
+}
+
+func TestConvertNetworks(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks interface{}
+		expected []string
+	}{
+		{
+			name:     "list form",
+			networks: []interface{}{"frontend", "backend"},
+			expected: []string{"mystack_frontend", "mystack_backend"},
+		},
+		{
+			name: "map form",
+			networks: map[string]interface{}{
+				"frontend": nil,
+				"backend":  map[string]interface{}{"aliases": []interface{}{"db"}},
+			},
+			expected: []string{"mystack_backend", "mystack_frontend"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments, err := convertNetworks(tt.networks, nil, "mystack")
+			if err != nil {
+				t.Fatalf("convertNetworks() error = %v", err)
+			}
+			if len(attachments) != len(tt.expected) {
+				t.Fatalf("Expected %d attachments, got %d", len(tt.expected), len(attachments))
+			}
+			for i, target := range tt.expected {
+				if attachments[i].Target != target {
+					t.Errorf("attachments[%d].Target = %s, want %s", i, attachments[i].Target, target)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_NetworkOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		networks    string
+		want        []swarm.NetworkAttachmentConfig
+		wantWarning string
+	}{
+		{
+			name:     "list form",
+			networks: "[front, back]",
+			want: []swarm.NetworkAttachmentConfig{
+				{Target: "mystack_front"},
+				{Target: "mystack_back"},
+			},
+		},
+		{
+			name: "aliases",
+			networks: `
+      front:
+      back:
+        aliases: [db, primary.db]
+        driver_opts:
+          com.example.mtu: 1400`,
+			want: []swarm.NetworkAttachmentConfig{
+				{Target: "mystack_front"},
+				{Target: "mystack_back", Aliases: []string{"db", "primary.db"}, DriverOpts: map[string]string{"com.example.mtu": "1400"}},
+			},
+		},
+		{
+			name: "static IP",
+			networks: `
+      back:
+        aliases: [db]
+        ipv4_address: 10.0.9.10`,
+			want: []swarm.NetworkAttachmentConfig{
+				{Target: "mystack_back", Aliases: []string{"db"}},
+			},
+			wantWarning: "static network addresses (back: 10.0.9.10)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := "services:\n  api:\n    image: api:1.0\n    networks: " + tt.networks + "\nnetworks:\n  front:\n  back:\n"
+			var file ComposeFile
+			if err := yaml.Unmarshal([]byte(data), &file); err != nil {
+				t.Fatalf("Failed to parse compose file: %v", err)
+			}
+			if err := Validate(&file); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			spec, err := ConvertToSwarmSpec("api", file.Services["api"], "mystack", "")
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec() error = %v", err)
+			}
+			if !reflect.DeepEqual(spec.TaskTemplate.Networks, tt.want) {
+				t.Errorf("Networks = %+v, want %+v", spec.TaskTemplate.Networks, tt.want)
+			}
+
+			warnings := ServiceWarnings("api", file.Services["api"])
+			switch {
+			case tt.wantWarning == "" && len(warnings) != 0:
+				t.Errorf("Expected no warnings, got %v", warnings)
+			case tt.wantWarning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.wantWarning)):
+				t.Errorf("Expected a warning containing %q, got %v", tt.wantWarning, warnings)
+			}
+		})
+	}
+
+	// Referenced networks must be declared
+	file := &ComposeFile{Services: map[string]*Service{
+		"api": {Image: "api:1.0", Networks: map[string]interface{}{"shared": map[string]interface{}{"aliases": []interface{}{"api"}}}},
+	}}
+	if err := Validate(file); err == nil || !strings.Contains(err.Error(), "network shared is not declared") {
+		t.Errorf("Validate() error = %v, want undeclared network shared", err)
+	}
+}
+
+func TestConvertToSwarmSpec_NetworksKeepDeclaredOrder(t *testing.T) {
+	data := `
+services:
+  mapped:
+    image: nginx:1.25
+    networks:
+      public:
+      backend:
+        aliases: [api]
+      admin:
+  listed:
+    image: nginx:1.25
+    networks: [public, admin, backend]
+networks:
+  public:
+  backend:
+  admin:
+`
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	tests := []struct {
+		service  string
+		expected []string
+	}{
+		{"mapped", []string{"mystack_public", "mystack_backend", "mystack_admin"}},
+		{"listed", []string{"mystack_public", "mystack_admin", "mystack_backend"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			spec, err := ConvertToSwarmSpec(tt.service, composeFile.Services[tt.service], "mystack", "")
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+			}
+			var targets []string
+			for _, n := range spec.TaskTemplate.Networks {
+				targets = append(targets, n.Target)
+			}
+			if !reflect.DeepEqual(targets, tt.expected) {
+				t.Errorf("Expected attachments %v, got %v", tt.expected, targets)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_NamedGenericResource(t *testing.T) {
+	service := &Service{
+		Image: "nginx:1.25",
+		Deploy: &DeployConfig{
+			Resources: &Resources{
+				Reservations: &ResourceLimit{
+					GenericResources: []GenericResource{
+						{NamedResourceSpec: &NamedResourceSpec{Kind: "ssd", Value: "fast"}},
+						{DiscreteResourceSpec: &DiscreteResourceSpec{Kind: "gpu", Value: 2}},
+					},
+				},
+			},
+		},
+	}
+
+	spec, err := ConvertToSwarmSpec("web", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec() error = %v", err)
+	}
+
+	resources := spec.TaskTemplate.Resources.Reservations.GenericResources
+	if len(resources) != 2 {
+		t.Fatalf("Expected 2 generic resources, got %d", len(resources))
+	}
+
+	named := resources[0].NamedResourceSpec
+	if named == nil || named.Kind != "ssd" || named.Value != "fast" {
+		t.Errorf("Expected named resource ssd=fast, got %+v", named)
+	}
+
+	discrete := resources[1].DiscreteResourceSpec
+	if discrete == nil || discrete.Kind != "gpu" || discrete.Value != 2 {
+		t.Errorf("Expected discrete resource gpu=2, got %+v", discrete)
+	}
+}
+
+func TestConvertGenericResources_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []GenericResource
+	}{
+		{"empty entry", []GenericResource{{}}},
+		{"both specs", []GenericResource{{
+			NamedResourceSpec:    &NamedResourceSpec{Kind: "ssd", Value: "fast"},
+			DiscreteResourceSpec: &DiscreteResourceSpec{Kind: "gpu", Value: 1},
+		}}},
+		{"named without value", []GenericResource{{NamedResourceSpec: &NamedResourceSpec{Kind: "ssd"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := convertGenericResources(tt.resources); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_DeviceRequests(t *testing.T) {
+	data := `
+services:
+  trainer:
+    image: trainer:1.0
+    deploy:
+      resources:
+        limits:
+          memory: 1G
+          devices:
+            - driver: nvidia
+              count: 1
+              capabilities: [gpu]
+        reservations:
+          devices:
+            - capabilities: [gpu]
+              device_ids: ["GPU-0a1b", "GPU-2c3d"]
+            - driver: xilinx
+              count: 2
+              options:
+                kind: FPGA
+`
+	var file ComposeFile
+	if err := yaml.Unmarshal([]byte(data), &file); err != nil {
+		t.Fatalf("Failed to parse compose file: %v", err)
+	}
+
+	spec, err := ConvertToSwarmSpec("trainer", file.Services["trainer"], "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec() error = %v", err)
+	}
+
+	if spec.TaskTemplate.Resources.Limits == nil || spec.TaskTemplate.Resources.Limits.MemoryBytes == 0 {
+		t.Errorf("Expected the memory limit to be kept, got %+v", spec.TaskTemplate.Resources.Limits)
+	}
+	if spec.TaskTemplate.Resources.Reservations == nil {
+		t.Fatal("Expected device requests to be reserved")
+	}
+
+	want := []swarm.GenericResource{
+		{DiscreteResourceSpec: &swarm.DiscreteGenericResource{Kind: "NVIDIA-GPU", Value: 1}},
+		{NamedResourceSpec: &swarm.NamedGenericResource{Kind: "NVIDIA-GPU", Value: "GPU-0a1b"}},
+		{NamedResourceSpec: &swarm.NamedGenericResource{Kind: "NVIDIA-GPU", Value: "GPU-2c3d"}},
+		{DiscreteResourceSpec: &swarm.DiscreteGenericResource{Kind: "FPGA", Value: 2}},
+	}
+	if got := spec.TaskTemplate.Resources.Reservations.GenericResources; !reflect.DeepEqual(got, want) {
+		t.Errorf("Reserved generic resources = %+v, want %+v", got, want)
+	}
+}
+
+func TestConvertDeviceRequests_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []DeviceRequest
+	}{
+		{"no count or ids", []DeviceRequest{{Driver: "nvidia"}}},
+		{"count and ids", []DeviceRequest{{Driver: "nvidia", Count: 1, DeviceIDs: []string{"GPU-0"}}}},
+		{"unknown driver without kind", []DeviceRequest{{Driver: "xilinx", Count: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := convertDeviceRequests(tt.devices); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_BindSourcesRelativeToComposeDir(t *testing.T) {
+	root := t.TempDir()
+	stackDir := filepath.Join(root, "subdir")
+	if err := os.Mkdir(stackDir, 0755); err != nil {
+		t.Fatalf("Failed to create stack dir: %v", err)
+	}
+	data := `
+services:
+  app:
+    image: alpine:3.20
+    volumes:
+      - ./data:/data
+      - ../shared:/shared:ro
+      - type: bind
+        source: ./conf
+        target: /etc/app
+`
+	if err := os.WriteFile(filepath.Join(stackDir, "stack.yml"), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	// Run from a different directory, like `stackman -f subdir/stack.yml`
+	t.Chdir(root)
+	t.Setenv("STACKMAN_WORKDIR", "")
+
+	composeFile, err := ParseComposeFile("subdir/stack.yml")
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+	if composeFile.Dir != stackDir {
+		t.Errorf("Expected compose dir %s, got %s", stackDir, composeFile.Dir)
+	}
+
+	spec, err := ConvertToSwarmSpec("app", composeFile.Services["app"], "mystack", composeFile.Dir)
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(stackDir, "data"),
+		filepath.Join(root, "shared"),
+		filepath.Join(stackDir, "conf"),
+	}
+	mounts := spec.TaskTemplate.ContainerSpec.Mounts
+	if len(mounts) != len(want) {
+		t.Fatalf("Expected %d mounts, got %d", len(want), len(mounts))
+	}
+	for i, source := range want {
+		if mounts[i].Source != source {
+			t.Errorf("mounts[%d].Source = %s, want %s", i, mounts[i].Source, source)
+		}
+	}
+
+	// STACKMAN_WORKDIR still overrides the compose file directory
+	t.Setenv("STACKMAN_WORKDIR", "/srv/stack")
+	spec, err = ConvertToSwarmSpec("app", composeFile.Services["app"], "mystack", composeFile.Dir)
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if source := spec.TaskTemplate.ContainerSpec.Mounts[0].Source; source != "/srv/stack/data" {
+		t.Errorf("Expected STACKMAN_WORKDIR to win, got %s", source)
+	}
+}
+
+func TestConvertToSwarmSpec_Labels(t *testing.T) {
+	service := &Service{
+		Image: "traefik/whoami:v1.10",
+		Labels: map[string]string{
+			"prometheus.io/scrape":       "true",
+			"com.docker.stack.namespace": "other",
+		},
+		Deploy: &DeployConfig{
+			Labels: map[string]string{
+				"traefik.http.routers.web.rule": "Host(`example.com`)",
+				"com.docker.stack.namespace":    "other",
+			},
+		},
+	}
+
+	spec, err := ConvertToSwarmSpec("web", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	wantService := map[string]string{
+		"traefik.http.routers.web.rule": "Host(`example.com`)",
+		"com.docker.stack.namespace":    "mystack",
+	}
+	if !reflect.DeepEqual(spec.Labels, wantService) {
+		t.Errorf("Expected service labels %v, got %v", wantService, spec.Labels)
+	}
+
+	wantContainer := map[string]string{
+		"prometheus.io/scrape":       "true",
+		"com.docker.stack.namespace": "mystack",
+	}
+	if !reflect.DeepEqual(spec.TaskTemplate.ContainerSpec.Labels, wantContainer) {
+		t.Errorf("Expected container labels %v, got %v", wantContainer, spec.TaskTemplate.ContainerSpec.Labels)
+	}
+}
+
+func TestConvertToSwarmSpec_NamespaceContainerLabelWithoutLabels(t *testing.T) {
+	spec, err := ConvertToSwarmSpec("web", &Service{Image: "nginx:1.25"}, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	want := map[string]string{"com.docker.stack.namespace": "mystack"}
+	if !reflect.DeepEqual(spec.TaskTemplate.ContainerSpec.Labels, want) {
+		t.Errorf("Expected container labels %v, got %v", want, spec.TaskTemplate.ContainerSpec.Labels)
+	}
+}
+
+func TestConvertToSwarmSpec_ExtraHosts(t *testing.T) {
+	service := &Service{
+		Image: "app:1",
+		ExtraHosts: []string{
+			"ldap.corp:10.0.0.5",
+			"db.corp=10.0.0.6",
+			"v6host:::1",
+			"v6bracket=[2001:db8::1]",
+		},
+	}
+
+	spec, err := ConvertToSwarmSpec("api", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	want := []string{"10.0.0.5 ldap.corp", "10.0.0.6 db.corp", "::1 v6host", "2001:db8::1 v6bracket"}
+	if !reflect.DeepEqual(spec.TaskTemplate.ContainerSpec.Hosts, want) {
+		t.Errorf("Expected hosts %v, got %v", want, spec.TaskTemplate.ContainerSpec.Hosts)
+	}
+
+	for _, entry := range []string{"no-ip", "host:not-an-ip", ":10.0.0.5"} {
+		_, err := ConvertToSwarmSpec("api", &Service{Image: "app:1", ExtraHosts: []string{entry}}, "mystack", "")
+		if err == nil {
+			t.Errorf("Expected an error for extra_hosts entry %q", entry)
+			continue
+		}
+		if !strings.Contains(err.Error(), "service api") || !strings.Contains(err.Error(), entry) {
+			t.Errorf("Expected the error to name the service and entry, got: %v", err)
+		}
+	}
+}
+
+func TestConvertToStringSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		want    []string
+		wantErr bool
+	}{
+		{name: "scalar", input: "8.8.8.8", want: []string{"8.8.8.8"}},
+		{name: "empty scalar", input: "", want: nil},
+		{name: "list", input: []interface{}{"8.8.8.8", "1.1.1.1"}, want: []string{"8.8.8.8", "1.1.1.1"}},
+		{name: "string list", input: []string{"example.com"}, want: []string{"example.com"}},
+		{name: "empty list", input: []interface{}{}, want: []string{}},
+		{name: "non-string item", input: []interface{}{"8.8.8.8", 53}, wantErr: true},
+		{name: "mapping", input: map[string]interface{}{"a": "b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertToStringSlice(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_DNS(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want *swarm.DNSConfig
+	}{
+		{
+			name: "scalar forms",
+			yaml: "image: app:1\ndns: 10.0.0.2\ndns_search: corp.local\n",
+			want: &swarm.DNSConfig{Nameservers: []string{"10.0.0.2"}, Search: []string{"corp.local"}},
+		},
+		{
+			name: "list forms with options",
+			yaml: "image: app:1\ndns: [10.0.0.2, 10.0.0.3]\ndns_search: [corp.local, svc.local]\ndns_opt: [ndots:2, timeout:1]\n",
+			want: &swarm.DNSConfig{
+				Nameservers: []string{"10.0.0.2", "10.0.0.3"},
+				Search:      []string{"corp.local", "svc.local"},
+				Options:     []string{"ndots:2", "timeout:1"},
+			},
+		},
+		{
+			name: "options only",
+			yaml: "image: app:1\ndns_opt: [use-vc]\n",
+			want: &swarm.DNSConfig{Options: []string{"use-vc"}},
+		},
+		{
+			name: "no dns settings",
+			yaml: "image: app:1\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var service Service
+			if err := yaml.Unmarshal([]byte(tt.yaml), &service); err != nil {
+				t.Fatalf("Failed to parse service: %v", err)
+			}
+
+			spec, err := ConvertToSwarmSpec("api", &service, "mystack", "")
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+			}
+			if got := spec.TaskTemplate.ContainerSpec.DNSConfig; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected DNS config %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	_, err := ConvertToSwarmSpec("api", &Service{Image: "app:1", DNS: []interface{}{8}}, "mystack", "")
+	if err == nil || !strings.Contains(err.Error(), "dns") {
+		t.Errorf("Expected a dns conversion error, got %v", err)
+	}
+}
+
+func TestConvertToSwarmSpec_Hardening(t *testing.T) {
+	service := &Service{
+		Image:    "app:1",
+		CapAdd:   []string{"NET_BIND_SERVICE", "CHOWN"},
+		CapDrop:  []string{"ALL"},
+		ReadOnly: true,
+	}
+
+	spec, err := ConvertToSwarmSpec("api", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if !reflect.DeepEqual(containerSpec.CapabilityAdd, []string{"NET_BIND_SERVICE", "CHOWN"}) {
+		t.Errorf("Expected cap_add to be applied, got %v", containerSpec.CapabilityAdd)
+	}
+	if !reflect.DeepEqual(containerSpec.CapabilityDrop, []string{"ALL"}) {
+		t.Errorf("Expected cap_drop to be applied, got %v", containerSpec.CapabilityDrop)
+	}
+	if !containerSpec.ReadOnly {
+		t.Error("Expected read_only to make the root filesystem read-only")
+	}
+
+	spec, err = ConvertToSwarmSpec("api", &Service{Image: "app:1"}, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if spec.TaskTemplate.ContainerSpec.CapabilityAdd != nil || spec.TaskTemplate.ContainerSpec.CapabilityDrop != nil {
+		t.Error("Expected no capability changes without cap_add or cap_drop")
+	}
+}
+
+func TestConvertToSwarmSpec_PrivilegedRejected(t *testing.T) {
+	_, err := ConvertToSwarmSpec("api", &Service{Image: "app:1", Privileged: true}, "mystack", "")
+	if err == nil {
+		t.Fatal("Expected privileged to be rejected")
+	}
+	if !strings.Contains(err.Error(), "service api") || !strings.Contains(err.Error(), "privileged") {
+		t.Errorf("Expected the error to name the service and privileged, got: %v", err)
+	}
+}
+
+func TestConvertToSwarmSpec_Replicas(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name     string
+		replicas *int
+		want     *uint64
+		wantErr  bool
+	}{
+		{name: "unset", replicas: nil, want: nil},
+		{name: "zero", replicas: intPtr(0), want: new(uint64)},
+		{name: "positive", replicas: intPtr(3), want: func() *uint64 { v := uint64(3); return &v }()},
+		{name: "negative", replicas: intPtr(-1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &Service{Image: "app:1", Deploy: &DeployConfig{Replicas: tt.replicas}}
+			spec, err := ConvertToSwarmSpec("api", service, "mystack", "")
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "invalid replicas -1") {
+					t.Fatalf("Expected a negative replicas error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+			}
+
+			got := spec.Mode.Replicated.Replicas
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("Expected replicas to be left unset, got %d", *got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("Expected %d replicas, got %v", *tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_StopSettings(t *testing.T) {
+	service := &Service{Image: "app:1", StopGracePeriod: "1m30s", StopSignal: "SIGQUIT"}
+
+	spec, err := ConvertToSwarmSpec("api", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if containerSpec.StopGracePeriod == nil || *containerSpec.StopGracePeriod != 90*time.Second {
+		t.Errorf("Expected a 90s stop grace period, got %v", containerSpec.StopGracePeriod)
+	}
+	if containerSpec.StopSignal != "SIGQUIT" {
+		t.Errorf("Expected stop signal SIGQUIT, got %q", containerSpec.StopSignal)
+	}
+
+	service.StopGracePeriod = "90 seconds"
+	_, err = ConvertToSwarmSpec("api", service, "mystack", "")
+	if err == nil {
+		t.Fatal("Expected a malformed stop_grace_period to fail")
+	}
+	if !strings.Contains(err.Error(), "service api") || !strings.Contains(err.Error(), "stop_grace_period") {
+		t.Errorf("Expected the error to name the service and field, got: %v", err)
+	}
+}
+
+func TestConvertToSwarmSpec_RuntimeFields(t *testing.T) {
+	service := &Service{
+		Image:      "app:1",
+		User:       "1000:1000",
+		WorkingDir: "/srv/app",
+		Hostname:   "api-host",
+		Tty:        true,
+		StdinOpen:  true,
+	}
+
+	spec, err := ConvertToSwarmSpec("api", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if containerSpec.User != "1000:1000" {
+		t.Errorf("Expected user 1000:1000, got %q", containerSpec.User)
+	}
+	if containerSpec.Dir != "/srv/app" {
+		t.Errorf("Expected working dir /srv/app, got %q", containerSpec.Dir)
+	}
+	if containerSpec.Hostname != "api-host" {
+		t.Errorf("Expected hostname api-host, got %q", containerSpec.Hostname)
+	}
+	if !containerSpec.TTY || !containerSpec.OpenStdin {
+		t.Errorf("Expected tty and stdin_open to be set, got TTY=%v OpenStdin=%v", containerSpec.TTY, containerSpec.OpenStdin)
+	}
+
+	// The hostname defaults to the service name and takes the domainname
+	spec, err = ConvertToSwarmSpec("api", &Service{Image: "app:1", Domainname: "corp.local"}, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if got := spec.TaskTemplate.ContainerSpec.Hostname; got != "api.corp.local" {
+		t.Errorf("Expected hostname api.corp.local, got %q", got)
+	}
+}
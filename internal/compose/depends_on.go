@@ -0,0 +1,37 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DependsOnServices returns the service names listed in depends_on.
+// Both the list form and the map form (with condition) are accepted;
+// conditions are not evaluated, only the ordering is used.
+func DependsOnServices(dependsOn interface{}) ([]string, error) {
+	switch v := dependsOn.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported depends_on entry type: %T", item)
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	case []string:
+		return v, nil
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	default:
+		return nil, fmt.Errorf("unsupported depends_on type: %T", dependsOn)
+	}
+}
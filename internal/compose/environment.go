@@ -0,0 +1,70 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ResolveEnvironment fills in service environment entries that only name a
+// variable (list form "- NAME" or map form "NAME:") from the process
+// environment, as docker stack deploy does. Unset variables are dropped.
+// When allow holds prefixes, only variables starting with one of them are
+// taken from the process; the names of the other entries are dropped and
+// returned so the caller can report them.
+func ResolveEnvironment(svc *Service, allow []string) ([]string, error) {
+	var blocked []string
+	lookup := func(name string) (string, bool) {
+		if !envAllowed(name, allow) {
+			blocked = append(blocked, name)
+			return "", false
+		}
+		return os.LookupEnv(name)
+	}
+
+	switch env := svc.Environment.(type) {
+	case nil:
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(env))
+		for _, item := range env {
+			str, ok := item.(string)
+			if !ok || strings.Contains(str, "=") {
+				resolved = append(resolved, item)
+				continue
+			}
+			if value, ok := lookup(str); ok {
+				resolved = append(resolved, str+"="+value)
+			}
+		}
+		svc.Environment = resolved
+	case map[string]interface{}:
+		for name, value := range env {
+			if value != nil {
+				continue
+			}
+			if resolved, ok := lookup(name); ok {
+				env[name] = resolved
+			} else {
+				delete(env, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported environment type: %T", svc.Environment)
+	}
+	sort.Strings(blocked)
+	return blocked, nil
+}
+
+// envAllowed reports whether a variable may be taken from the process environment
+func envAllowed(name string, allow []string) bool {
+	if len(allow) == 0 {
+		return true
+	}
+	for _, prefix := range allow {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveEnvironment_PrefixAllow(t *testing.T) {
+	t.Setenv("APP_DEBUG", "1")
+	t.Setenv("APP_NAME", "shop")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "hunter2")
+
+	svc := &Service{Environment: []interface{}{"APP_DEBUG", "AWS_SECRET_ACCESS_KEY", "APP_UNSET", "MODE=prod"}}
+	blocked, err := ResolveEnvironment(svc, []string{"APP_"})
+	if err != nil {
+		t.Fatalf("ResolveEnvironment failed: %v", err)
+	}
+	want := []interface{}{"APP_DEBUG=1", "MODE=prod"}
+	if !reflect.DeepEqual(svc.Environment, want) {
+		t.Errorf("Environment = %v, want %v", svc.Environment, want)
+	}
+	if !reflect.DeepEqual(blocked, []string{"AWS_SECRET_ACCESS_KEY"}) {
+		t.Errorf("Expected AWS_SECRET_ACCESS_KEY to be blocked, got %v", blocked)
+	}
+
+	mapped := &Service{Environment: map[string]interface{}{"APP_NAME": nil, "HOME": nil, "MODE": "prod"}}
+	blocked, err = ResolveEnvironment(mapped, []string{"APP_"})
+	if err != nil {
+		t.Fatalf("ResolveEnvironment failed: %v", err)
+	}
+	wantMap := map[string]interface{}{"APP_NAME": "shop", "MODE": "prod"}
+	if !reflect.DeepEqual(mapped.Environment, wantMap) {
+		t.Errorf("Environment = %v, want %v", mapped.Environment, wantMap)
+	}
+	if !reflect.DeepEqual(blocked, []string{"HOME"}) {
+		t.Errorf("Expected HOME to be blocked, got %v", blocked)
+	}
+}
+
+func TestResolveEnvironment_NoAllowList(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	svc := &Service{Environment: []interface{}{"AWS_REGION", "STACKMAN_TEST_UNSET"}}
+	blocked, err := ResolveEnvironment(svc, nil)
+	if err != nil {
+		t.Fatalf("ResolveEnvironment failed: %v", err)
+	}
+	if want := []interface{}{"AWS_REGION=eu-west-1"}; !reflect.DeepEqual(svc.Environment, want) {
+		t.Errorf("Environment = %v, want %v", svc.Environment, want)
+	}
+	if len(blocked) != 0 {
+		t.Errorf("Expected nothing blocked, got %v", blocked)
+	}
+}
@@ -0,0 +1,24 @@
+package compose
+
+// IsExternal reports whether a top-level resource's `external` field marks it as
+// managed outside the stack. Both `external: true` and `external: {name: x}` are accepted.
+func IsExternal(external interface{}) bool {
+	switch v := external.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// ExternalName returns the name set via the legacy `external: {name: x}` form, or "" if none
+func ExternalName(external interface{}) string {
+	if m, ok := external.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// LabelFilter selects services by a compose label: "label=tier" matches any
+// value of tier, "label=tier=backend" only that value
+type LabelFilter struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// ParseLabelFilter parses a -filter value of the form label=key or label=key=value
+func ParseLabelFilter(s string) (*LabelFilter, error) {
+	kind, expr, ok := strings.Cut(s, "=")
+	if !ok || kind != "label" {
+		return nil, fmt.Errorf("invalid filter %q, expected label=key or label=key=value", s)
+	}
+
+	key, value, hasValue := strings.Cut(expr, "=")
+	if key == "" {
+		return nil, fmt.Errorf("invalid filter %q: label key is empty", s)
+	}
+	return &LabelFilter{Key: key, Value: value, AnyValue: !hasValue}, nil
+}
+
+// Match reports whether labels satisfy the filter
+func (f *LabelFilter) Match(labels map[string]string) bool {
+	value, ok := labels[f.Key]
+	return ok && (f.AnyValue || value == f.Value)
+}
+
+// MatchService reports whether a compose service carries the label, either as a
+// service label or a deploy label
+func (f *LabelFilter) MatchService(service *Service) bool {
+	if f.Match(service.Labels) {
+		return true
+	}
+	return service.Deploy != nil && f.Match(service.Deploy.Labels)
+}
+
+// MatchServiceSpec reports whether a deployed service carries the label. Compose
+// service labels end up on the container spec, deploy labels on the service.
+func (f *LabelFilter) MatchServiceSpec(spec *swarm.ServiceSpec) bool {
+	if f.Match(spec.Labels) {
+		return true
+	}
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	return containerSpec != nil && f.Match(containerSpec.Labels)
+}
+
+// String returns the filter in its -filter form
+func (f *LabelFilter) String() string {
+	if f.AnyValue {
+		return "label=" + f.Key
+	}
+	return "label=" + f.Key + "=" + f.Value
+}
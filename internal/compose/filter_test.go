@@ -0,0 +1,68 @@
+package compose
+
+import "testing"
+
+func TestParseLabelFilter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    LabelFilter
+		wantErr bool
+	}{
+		{input: "label=tier=backend", want: LabelFilter{Key: "tier", Value: "backend"}},
+		{input: "label=tier", want: LabelFilter{Key: "tier", AnyValue: true}},
+		{input: "label=tier=", want: LabelFilter{Key: "tier"}},
+		{input: "label=url=http://x?a=b", want: LabelFilter{Key: "url", Value: "http://x?a=b"}},
+		{input: "name=web", wantErr: true},
+		{input: "label", wantErr: true},
+		{input: "label==backend", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseLabelFilter(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLabelFilter(%q): expected an error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLabelFilter(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if *f != tt.want {
+			t.Errorf("ParseLabelFilter(%q) = %+v, want %+v", tt.input, *f, tt.want)
+		}
+		if f.String() != tt.input {
+			t.Errorf("String() = %q, want %q", f.String(), tt.input)
+		}
+	}
+}
+
+func TestLabelFilter_MatchService(t *testing.T) {
+	f, err := ParseLabelFilter("label=tier=backend")
+	if err != nil {
+		t.Fatalf("ParseLabelFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		service *Service
+		want    bool
+	}{
+		{"service label", &Service{Labels: map[string]string{"tier": "backend"}}, true},
+		{"deploy label", &Service{Deploy: &DeployConfig{Labels: map[string]string{"tier": "backend"}}}, true},
+		{"other value", &Service{Labels: map[string]string{"tier": "frontend"}}, false},
+		{"no labels", &Service{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := f.MatchService(tt.service); got != tt.want {
+			t.Errorf("%s: MatchService() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	anyTier := &LabelFilter{Key: "tier", AnyValue: true}
+	if !anyTier.Match(map[string]string{"tier": "frontend"}) {
+		t.Error("Expected label=tier to match any value")
+	}
+}
@@ -0,0 +1,94 @@
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseHealthcheckTest converts a command-line healthcheck test to the exec form:
+// "NONE", "CMD <args>" (split with shell quoting rules), "CMD-SHELL <command>",
+// or a plain shell command, which runs via CMD-SHELL like the compose string form.
+func ParseHealthcheckTest(test string) ([]string, error) {
+	test = strings.TrimSpace(test)
+	if test == "" {
+		return nil, fmt.Errorf("healthcheck test is empty")
+	}
+
+	kind, rest, _ := strings.Cut(test, " ")
+	rest = strings.TrimSpace(rest)
+	switch kind {
+	case "NONE":
+		if rest != "" {
+			return nil, fmt.Errorf("healthcheck test NONE takes no arguments")
+		}
+		return []string{"NONE"}, nil
+	case "CMD":
+		args, err := splitShellWords(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck test: %w", err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("healthcheck test CMD needs a command")
+		}
+		return append([]string{"CMD"}, args...), nil
+	case "CMD-SHELL":
+		if rest == "" {
+			return nil, fmt.Errorf("healthcheck test CMD-SHELL needs a command")
+		}
+		return []string{"CMD-SHELL", rest}, nil
+	default:
+		return []string{"CMD-SHELL", test}, nil
+	}
+}
+
+// OverrideHealthcheck replaces the service's healthcheck test, keeping its timing settings
+func OverrideHealthcheck(svc *Service, test []string) {
+	if svc.HealthCheck == nil {
+		svc.HealthCheck = &HealthCheck{}
+	}
+	items := make([]interface{}, len(test))
+	for i, item := range test {
+		items[i] = item
+	}
+	svc.HealthCheck.Test = items
+	svc.HealthCheck.Disable = false
+}
+
+// DisableHealthcheck disables the service's healthcheck, including one baked into the image
+func DisableHealthcheck(svc *Service) {
+	svc.HealthCheck = &HealthCheck{Disable: true}
+}
+
+// IgnoreImageHealthcheckLabel makes a service ignore the healthcheck baked into its image
+const IgnoreImageHealthcheckLabel = "stackman.ignore_image_healthcheck"
+
+// IgnoresImageHealthcheck reports whether the stackman.ignore_image_healthcheck
+// service or deploy label is set; the deploy label wins when both are set
+func IgnoresImageHealthcheck(svc *Service) (bool, error) {
+	value, ok := svc.Labels[IgnoreImageHealthcheckLabel]
+	if svc.Deploy != nil {
+		if deployValue, found := svc.Deploy.Labels[IgnoreImageHealthcheckLabel]; found {
+			value, ok = deployValue, true
+		}
+	}
+	if !ok {
+		return false, nil
+	}
+	ignore, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", IgnoreImageHealthcheckLabel, value)
+	}
+	return ignore, nil
+}
+
+// IgnoreImageHealthcheck disables the image healthcheck when the compose file
+// doesn't define its own test, so running tasks count as healthy.
+// It returns false when a compose healthcheck test is kept instead.
+func IgnoreImageHealthcheck(svc *Service) bool {
+	if svc.HealthCheck != nil && svc.HealthCheck.Test != nil && !svc.HealthCheck.Disable {
+		return false
+	}
+	DisableHealthcheck(svc)
+	return true
+}
@@ -0,0 +1,91 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHealthcheckTest(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "NONE", want: []string{"NONE"}},
+		{input: "CMD curl localhost", want: []string{"CMD", "curl", "localhost"}},
+		{input: `CMD sh -c "curl -f localhost || exit 1"`, want: []string{"CMD", "sh", "-c", "curl -f localhost || exit 1"}},
+		{input: "CMD-SHELL curl -f localhost || exit 1", want: []string{"CMD-SHELL", "curl -f localhost || exit 1"}},
+		{input: "curl -f localhost", want: []string{"CMD-SHELL", "curl -f localhost"}},
+		{input: "", wantErr: true},
+		{input: "CMD", wantErr: true},
+		{input: "NONE now", wantErr: true},
+		{input: `CMD curl "localhost`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseHealthcheckTest(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHealthcheckOverrides_AppliedSpec(t *testing.T) {
+	retries := 5
+	newService := func() *Service {
+		return &Service{
+			Image: "nginx:1.25",
+			HealthCheck: &HealthCheck{
+				Test:     []interface{}{"CMD", "wget", "-q", "localhost"},
+				Interval: "5s",
+				Retries:  retries,
+			},
+		}
+	}
+
+	disabled := newService()
+	DisableHealthcheck(disabled)
+	spec, err := ConvertToSwarmSpec("web", disabled, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if hc := spec.TaskTemplate.ContainerSpec.Healthcheck; hc == nil || !reflect.DeepEqual(hc.Test, []string{"NONE"}) {
+		t.Errorf("Expected disabled healthcheck [NONE], got %+v", hc)
+	}
+
+	replaced := newService()
+	OverrideHealthcheck(replaced, []string{"CMD", "curl", "localhost"})
+	spec, err = ConvertToSwarmSpec("web", replaced, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	hc := spec.TaskTemplate.ContainerSpec.Healthcheck
+	if hc == nil || !reflect.DeepEqual(hc.Test, []string{"CMD", "curl", "localhost"}) {
+		t.Fatalf("Expected replaced test, got %+v", hc)
+	}
+	if hc.Retries != retries || hc.Interval.String() != "5s" {
+		t.Errorf("Expected timing settings to be kept, got %+v", hc)
+	}
+
+	// A service without a healthcheck gets one from the override
+	bare := &Service{Image: "nginx:1.25"}
+	OverrideHealthcheck(bare, []string{"NONE"})
+	spec, err = ConvertToSwarmSpec("web", bare, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if hc := spec.TaskTemplate.ContainerSpec.Healthcheck; hc == nil || !reflect.DeepEqual(hc.Test, []string{"NONE"}) {
+		t.Errorf("Expected [NONE] test on service without healthcheck, got %+v", hc)
+	}
+}
@@ -0,0 +1,296 @@
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadVarFile reads interpolation variables from a .env, YAML or JSON file,
+// detected by extension. YAML and JSON files must hold a flat mapping of scalars.
+func LoadVarFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables file: %w", err)
+	}
+
+	var vars map[string]string
+	base := filepath.Base(path)
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".env" || strings.HasPrefix(base, ".env."):
+		vars, err = parseEnvVars(data)
+	case ext == ".yaml" || ext == ".yml":
+		vars, err = parseYAMLVars(data)
+	case ext == ".json":
+		vars, err = parseJSONVars(data)
+	default:
+		return nil, fmt.Errorf("variables file %s: unsupported extension %q (use .env, .yaml, .yml or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("variables file %s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// ParseSetValues parses comma-separated key=value pairs
+func ParseSetValues(s string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value %q, expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// parseEnvVars parses KEY=VALUE lines; blank lines, # comments and an
+// "export " prefix are allowed, and matching surrounding quotes are removed
+func parseEnvVars(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseYAMLVars parses a flat YAML mapping
+func parseYAMLVars(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return scalarVars(raw)
+}
+
+// parseJSONVars parses a flat JSON object; numbers keep their literal form
+func parseJSONVars(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return scalarVars(raw)
+}
+
+// scalarVars converts decoded scalar values to strings, rejecting nested values
+func scalarVars(raw map[string]interface{}) (map[string]string, error) {
+	vars := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			vars[key] = ""
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("variable %s must be a scalar, got a nested value", key)
+		default:
+			vars[key] = fmt.Sprint(v)
+		}
+	}
+	return vars, nil
+}
+
+// interpolator substitutes ${VAR} references in compose values
+type interpolator struct {
+	lookup func(name string) (string, bool)
+	unset  map[string]bool
+}
+
+// interpolateNode substitutes variables in every scalar value below node.
+// Mapping keys are left alone, as in docker compose.
+func (ip *interpolator) interpolateNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := ip.interpolateNode(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := ip.interpolateNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, err := ip.interpolate(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		// Let plain scalars re-resolve so "${REPLICAS}" can become an int
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+// interpolate expands $VAR, ${VAR} and the ${VAR:-default}, ${VAR-default},
+// ${VAR:?error}, ${VAR?error}, ${VAR:+alt} and ${VAR+alt} forms; $$ is a literal $
+func (ip *interpolator) interpolate(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			value, err := ip.expandBraced(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(ip.value(s[i+1 : j]))
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// expandBraced expands the inside of a ${...} reference
+func (ip *interpolator) expandBraced(expr string) (string, error) {
+	j := 0
+	for j < len(expr) && isNameChar(expr[j]) {
+		j++
+	}
+	name, rest := expr[:j], expr[j:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	if rest == "" {
+		return ip.value(name), nil
+	}
+
+	value, set := ip.lookup(name)
+	op, arg := rest[:1], rest[1:]
+	if op == ":" && len(rest) > 1 {
+		op, arg = rest[:2], rest[2:]
+		// The colon forms treat an empty value like an unset one
+		set = set && value != ""
+	}
+
+	switch op {
+	case ":-", "-":
+		if set {
+			return value, nil
+		}
+		return ip.interpolate(arg)
+	case ":?", "?":
+		if set {
+			return value, nil
+		}
+		msg, err := ip.interpolate(arg)
+		if err != nil {
+			return "", err
+		}
+		if msg == "" {
+			msg = "required variable is not set"
+		}
+		return "", fmt.Errorf("variable %s: %s", name, msg)
+	case ":+", "+":
+		if set {
+			return ip.interpolate(arg)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+}
+
+// value returns a variable's value, remembering unset variables
+func (ip *interpolator) value(name string) string {
+	value, ok := ip.lookup(name)
+	if !ok {
+		ip.unset[name] = true
+	}
+	return value
+}
+
+// unsetNames returns the referenced variables that were not set, sorted
+func (ip *interpolator) unsetNames() []string {
+	names := make([]string, 0, len(ip.unset))
+	for name := range ip.unset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchingBrace returns the index of the } closing a ${ opened before start,
+// allowing nested ${...} in defaults, or -1
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,189 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadVarFile_Formats(t *testing.T) {
+	files := map[string]string{
+		"vars.env": `# deployment variables
+TAG=1.4.2
+export REPLICAS=3
+GREETING="hello world"
+EMPTY=
+`,
+		".env.prod": `TAG=1.4.2
+REPLICAS=3
+GREETING='hello world'
+EMPTY=
+`,
+		"vars.yaml": `TAG: "1.4.2"
+REPLICAS: 3
+GREETING: hello world
+EMPTY:
+`,
+		"vars.json": `{"TAG": "1.4.2", "REPLICAS": 3, "GREETING": "hello world", "EMPTY": null}`,
+	}
+	want := map[string]string{
+		"TAG":      "1.4.2",
+		"REPLICAS": "3",
+		"GREETING": "hello world",
+		"EMPTY":    "",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+
+		vars, err := LoadVarFile(path)
+		if err != nil {
+			t.Errorf("LoadVarFile(%s) failed: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(vars, want) {
+			t.Errorf("LoadVarFile(%s) = %v, want %v", name, vars, want)
+		}
+	}
+}
+
+func TestLoadVarFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vars.toml":   "TAG = 1",
+		"nested.yaml": "db:\n  host: x\n",
+		"nested.json": `{"LIST": [1, 2]}`,
+		"broken.env":  "just a line\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if _, err := LoadVarFile(path); err == nil {
+			t.Errorf("Expected LoadVarFile(%s) to fail", name)
+		}
+	}
+}
+
+func TestParseComposeFileWithVars_SameResultForEachFormat(t *testing.T) {
+	data := `
+services:
+  web:
+    image: "nginx:${TAG}"
+    environment:
+      - GREETING=${GREETING}
+    deploy:
+      replicas: ${REPLICAS}
+`
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	varFiles := map[string]string{
+		"vars.env":  "TAG=1.4.2\nREPLICAS=3\nGREETING=hi\n",
+		"vars.yml":  "TAG: 1.4.2\nREPLICAS: 3\nGREETING: hi\n",
+		"vars.json": `{"TAG": "1.4.2", "REPLICAS": 3, "GREETING": "hi"}`,
+	}
+
+	for name, content := range varFiles {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		vars, err := LoadVarFile(path)
+		if err != nil {
+			t.Fatalf("LoadVarFile(%s) failed: %v", name, err)
+		}
+
+		composeFile, err := ParseComposeFileWithVars(composePath, vars)
+		if err != nil {
+			t.Fatalf("%s: ParseComposeFileWithVars failed: %v", name, err)
+		}
+		web := composeFile.Services["web"]
+		if web.Image != "nginx:1.4.2" {
+			t.Errorf("%s: expected image nginx:1.4.2, got %s", name, web.Image)
+		}
+		if web.Deploy == nil || web.Deploy.Replicas == nil || *web.Deploy.Replicas != 3 {
+			t.Errorf("%s: expected 3 replicas, got %+v", name, web.Deploy)
+		}
+		if env, _ := web.Environment.([]interface{}); len(env) != 1 || env[0] != "GREETING=hi" {
+			t.Errorf("%s: expected GREETING=hi, got %v", name, web.Environment)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"TAG": "1.2", "EMPTY": "", "HOST": "db"}
+	ip := &interpolator{
+		lookup: func(name string) (string, bool) {
+			value, ok := vars[name]
+			return value, ok
+		},
+		unset: make(map[string]bool),
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"nginx:${TAG}", "nginx:1.2"},
+		{"nginx:$TAG", "nginx:1.2"},
+		{"$$TAG costs $5", "$TAG costs $5"},
+		{"${MISSING:-fallback}", "fallback"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${EMPTY-fallback}", ""},
+		{"${MISSING:-${HOST}:5432}", "db:5432"},
+		{"${TAG:+set}", "set"},
+		{"${MISSING+set}", ""},
+		{"${MISSING}", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := ip.interpolate(tt.input)
+		if err != nil {
+			t.Errorf("interpolate(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("interpolate(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if names := ip.unsetNames(); len(names) != 1 || names[0] != "MISSING" {
+		t.Errorf("Expected MISSING to be reported unset, got %v", names)
+	}
+
+	for _, input := range []string{"${MISSING:?must be set}", "${EMPTY:?}", "${TAG", "${1BAD}"} {
+		if _, err := ip.interpolate(input); err == nil {
+			t.Errorf("Expected interpolate(%q) to fail", input)
+		}
+	}
+	if _, err := ip.interpolate("${MISSING:?must be set}"); err == nil || !strings.Contains(err.Error(), "must be set") {
+		t.Errorf("Expected the custom error message, got %v", err)
+	}
+}
+
+func TestParseSetValues(t *testing.T) {
+	vars, err := ParseSetValues("TAG=1.2, URL=http://x?a=b,")
+	if err != nil {
+		t.Fatalf("ParseSetValues failed: %v", err)
+	}
+	want := map[string]string{"TAG": "1.2", "URL": "http://x?a=b"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("ParseSetValues = %v, want %v", vars, want)
+	}
+
+	if _, err := ParseSetValues("novalue"); err == nil {
+		t.Error("Expected an error for a pair without '='")
+	}
+}
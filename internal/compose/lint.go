@@ -0,0 +1,234 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Lint severities, from most to least severe
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintInfo    = "info"
+)
+
+// LintFinding is a single best-practice issue found in a compose file
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Service  string `json:"service"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintRule is an opinionated check applied to every service
+type LintRule struct {
+	ID          string
+	Severity    string
+	Description string
+	check       func(file *ComposeFile, service *Service) []string
+}
+
+// LintRules lists all lint rules in the order they are reported
+var LintRules = []LintRule{
+	{
+		ID:          "latest-tag",
+		Severity:    LintWarning,
+		Description: "image uses the latest tag or no tag, so deployments are not reproducible",
+		check:       lintLatestTag,
+	},
+	{
+		ID:          "no-healthcheck",
+		Severity:    LintWarning,
+		Description: "service has no healthcheck, so rollouts cannot tell healthy tasks from running ones",
+		check:       lintNoHealthcheck,
+	},
+	{
+		ID:          "no-resource-limits",
+		Severity:    LintWarning,
+		Description: "service has no CPU or memory limit and can starve its node",
+		check:       lintNoResourceLimits,
+	},
+	{
+		ID:          "bind-mount",
+		Severity:    LintWarning,
+		Description: "bind mounts depend on the host path existing on every node the task can land on",
+		check:       lintBindMount,
+	},
+	{
+		ID:          "single-replica",
+		Severity:    LintInfo,
+		Description: "stateless service runs a single replica and is unavailable during node failures",
+		check:       lintSingleReplica,
+	},
+	{
+		ID:          "no-restart-policy",
+		Severity:    LintInfo,
+		Description: "service has no deploy.restart_policy and relies on the Swarm default",
+		check:       lintNoRestartPolicy,
+	},
+}
+
+// LintSeverityRank orders severities; higher is more severe, unknown severities rank 0
+func LintSeverityRank(severity string) int {
+	switch severity {
+	case LintError:
+		return 3
+	case LintWarning:
+		return 2
+	case LintInfo:
+		return 1
+	}
+	return 0
+}
+
+// Lint runs every lint rule not named in disabled against all services.
+// Findings are sorted by service name, then rule order.
+func Lint(file *ComposeFile, disabled map[string]bool) []LintFinding {
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []LintFinding
+	for _, name := range names {
+		service := file.Services[name]
+		if service == nil {
+			continue
+		}
+		for _, rule := range LintRules {
+			if disabled[rule.ID] {
+				continue
+			}
+			for _, msg := range rule.check(file, service) {
+				findings = append(findings, LintFinding{
+					Rule:     rule.ID,
+					Severity: rule.Severity,
+					Service:  name,
+					Line:     file.ServiceLines[name],
+					Message:  msg,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// ParseLintRules splits a comma-separated list of rule IDs, rejecting unknown ones
+func ParseLintRules(list string) (map[string]bool, error) {
+	known := make(map[string]bool, len(LintRules))
+	for _, rule := range LintRules {
+		known[rule.ID] = true
+	}
+
+	rules := make(map[string]bool)
+	for _, id := range strings.Split(list, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !known[id] {
+			return nil, fmt.Errorf("unknown lint rule %q", id)
+		}
+		rules[id] = true
+	}
+	return rules, nil
+}
+
+func lintLatestTag(_ *ComposeFile, service *Service) []string {
+	// Build-only services have no image
+	if service.Image != "" && UsesLatestTag(service.Image) {
+		return []string{fmt.Sprintf("image %s is not pinned to a version tag", service.Image)}
+	}
+	return nil
+}
+
+func lintNoHealthcheck(_ *ComposeFile, service *Service) []string {
+	if service.HealthCheck == nil {
+		return []string{"no healthcheck defined; an image healthcheck is used if present"}
+	}
+	if service.HealthCheck.Disable {
+		return []string{"healthcheck is disabled"}
+	}
+	return nil
+}
+
+func lintNoResourceLimits(_ *ComposeFile, service *Service) []string {
+	var limits *ResourceLimit
+	if service.Deploy != nil && service.Deploy.Resources != nil {
+		limits = service.Deploy.Resources.Limits
+	}
+
+	var missing []string
+	if limits == nil || limits.CPUs == "" {
+		missing = append(missing, "cpus")
+	}
+	if limits == nil || limits.Memory == "" {
+		missing = append(missing, "memory")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("deploy.resources.limits has no %s", strings.Join(missing, " or "))}
+}
+
+func lintBindMount(file *ComposeFile, service *Service) []string {
+	// Invalid volume entries are reported by the converter at deploy time
+	mounts, err := convertVolumes(service.Volumes, "", file.Dir)
+	if err != nil {
+		return nil
+	}
+
+	var msgs []string
+	for _, m := range mounts {
+		if m.Type == mount.TypeBind {
+			msgs = append(msgs, fmt.Sprintf("bind mount %s -> %s requires the host path on every node", m.Source, m.Target))
+		}
+	}
+	return msgs
+}
+
+func lintSingleReplica(_ *ComposeFile, service *Service) []string {
+	if service.Deploy != nil && service.Deploy.Mode == "global" {
+		return nil
+	}
+	// Services with volumes are treated as stateful and usually can't scale out
+	if len(service.Volumes) > 0 {
+		return nil
+	}
+	if service.Deploy == nil || service.Deploy.Replicas == nil || *service.Deploy.Replicas == 1 {
+		return []string{"runs a single replica; consider replicas: 2 or more for availability"}
+	}
+	return nil
+}
+
+func lintNoRestartPolicy(_ *ComposeFile, service *Service) []string {
+	if service.Deploy == nil || service.Deploy.RestartPolicy == nil {
+		return []string{"no deploy.restart_policy; Swarm restarts on any exit"}
+	}
+	return nil
+}
+
+// UsesLatestTag reports whether an image reference resolves to the latest tag,
+// either explicitly or by having no tag. Digest references are pinned.
+func UsesLatestTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	tag := imageTag(image)
+	return tag == "" || tag == "latest"
+}
+
+// imageTag returns the tag of an image reference, or "" when it has none.
+// A registry port (localhost:5000/app) is not mistaken for a tag.
+func imageTag(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
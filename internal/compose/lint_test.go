@@ -0,0 +1,153 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const badLintCompose = `
+services:
+  web:
+    image: nginx
+    volumes:
+      - ./html:/usr/share/nginx/html:ro
+  api:
+    image: registry.local:5000/api:latest
+    healthcheck:
+      disable: true
+  worker:
+    image: worker:1.4.2
+    healthcheck:
+      test: ["CMD", "true"]
+    deploy:
+      replicas: 3
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 256M
+      restart_policy:
+        condition: on-failure
+`
+
+func parseLintCompose(t *testing.T, data string) *ComposeFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+	return composeFile
+}
+
+// findingRules groups finding rule IDs by service
+func findingRules(findings []LintFinding) map[string][]string {
+	rules := make(map[string][]string)
+	for _, f := range findings {
+		rules[f.Service] = append(rules[f.Service], f.Rule)
+	}
+	return rules
+}
+
+func TestLint_BadComposeFile(t *testing.T) {
+	composeFile := parseLintCompose(t, badLintCompose)
+
+	findings := Lint(composeFile, nil)
+	got := findingRules(findings)
+
+	want := map[string][]string{
+		"api": {"latest-tag", "no-healthcheck", "no-resource-limits", "single-replica", "no-restart-policy"},
+		"web": {"latest-tag", "no-healthcheck", "no-resource-limits", "bind-mount", "no-restart-policy"},
+	}
+	for service, rules := range want {
+		if len(got[service]) != len(rules) {
+			t.Errorf("Service %s: expected rules %v, got %v", service, rules, got[service])
+			continue
+		}
+		for i := range rules {
+			if got[service][i] != rules[i] {
+				t.Errorf("Service %s: expected rules %v, got %v", service, rules, got[service])
+				break
+			}
+		}
+	}
+	if len(got["worker"]) != 0 {
+		t.Errorf("Expected no findings for worker, got %v", got["worker"])
+	}
+
+	// Findings point at the service definition and carry the rule severity
+	for _, f := range findings {
+		if f.Service == "web" && f.Line != 3 {
+			t.Errorf("Expected web findings on line 3, got %d", f.Line)
+		}
+		if f.Rule == "single-replica" && f.Severity != LintInfo {
+			t.Errorf("Expected single-replica to be info, got %s", f.Severity)
+		}
+		if f.Rule == "latest-tag" && f.Severity != LintWarning {
+			t.Errorf("Expected latest-tag to be a warning, got %s", f.Severity)
+		}
+	}
+}
+
+func TestLint_DisabledRules(t *testing.T) {
+	composeFile := parseLintCompose(t, badLintCompose)
+
+	disabled, err := ParseLintRules("latest-tag, no-healthcheck")
+	if err != nil {
+		t.Fatalf("ParseLintRules failed: %v", err)
+	}
+
+	for _, f := range Lint(composeFile, disabled) {
+		if f.Rule == "latest-tag" || f.Rule == "no-healthcheck" {
+			t.Errorf("Disabled rule %s reported for %s", f.Rule, f.Service)
+		}
+	}
+
+	if _, err := ParseLintRules("latest-tag,no-such-rule"); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", ""},
+		{"nginx:1.25", "1.25"},
+		{"localhost:5000/app", ""},
+		{"localhost:5000/app:v2", "v2"},
+		{"ghcr.io/org/app:latest", "latest"},
+	}
+
+	for _, tt := range tests {
+		if got := imageTag(tt.image); got != tt.want {
+			t.Errorf("imageTag(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestUsesLatestTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"nginx:1.25", false},
+		{"localhost:5000/app:v2", false},
+		{"nginx", true},
+		{"localhost:5000/app", true},
+		{"nginx:latest", true},
+		{"ghcr.io/org/app:latest", true},
+		{"nginx@sha256:0123456789abcdef", false},
+		{"nginx:latest@sha256:0123456789abcdef", false},
+	}
+
+	for _, tt := range tests {
+		if got := UsesLatestTag(tt.image); got != tt.want {
+			t.Errorf("UsesLatestTag(%q) = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+}
@@ -3,22 +3,58 @@ package compose
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
-// ParseComposeFile reads and parses a docker-compose.yml file
+// ParseComposeFile reads and parses a docker-compose.yml file, interpolating
+// variables from the environment.
+// The path may also be an HTTP(S) URL or a git:: source (see IsRemote).
 func ParseComposeFile(path string) (*ComposeFile, error) {
-	data, err := os.ReadFile(path)
+	return ParseComposeFileWithVars(path, nil)
+}
+
+// ParseComposeFileWithVars parses a compose file, interpolating ${VAR} references
+// from vars first and the environment second
+func ParseComposeFileWithVars(path string, vars map[string]string) (*ComposeFile, error) {
+	var data []byte
+	var err error
+	if IsRemote(path) {
+		data, err = readRemote(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read compose file: %w", err)
 	}
 
-	var compose ComposeFile
-	if err := yaml.Unmarshal(data, &compose); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("failed to parse compose file: %w", err)
 	}
 
+	ip := &interpolator{
+		lookup: func(name string) (string, bool) {
+			if value, ok := vars[name]; ok {
+				return value, true
+			}
+			return os.LookupEnv(name)
+		},
+		unset: make(map[string]bool),
+	}
+	if err := ip.interpolateNode(&root); err != nil {
+		return nil, fmt.Errorf("failed to interpolate compose file: %w", err)
+	}
+
+	var compose ComposeFile
+	if len(root.Content) > 0 {
+		if err := root.Decode(&compose); err != nil {
+			return nil, fmt.Errorf("failed to parse compose file: %w", err)
+		}
+	}
+	compose.UnsetVariables = ip.unsetNames()
+
 	// Set defaults
 	if compose.Services == nil {
 		compose.Services = make(map[string]*Service)
@@ -30,5 +66,79 @@ func ParseComposeFile(path string) (*ComposeFile, error) {
 		compose.Volumes = make(map[string]*Volume)
 	}
 
+	// Remote files have no local directory; relative paths fall back to
+	// STACKMAN_WORKDIR or the current directory
+	if !IsRemote(path) {
+		dir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve compose file directory: %w", err)
+		}
+		compose.Dir = dir
+	}
+
 	return &compose, nil
 }
+
+// UnmarshalYAML decodes a compose file and records the line of each service
+func (c *ComposeFile) UnmarshalYAML(node *yaml.Node) error {
+	type plain ComposeFile
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.ServiceLines = keyLines(node, "services")
+	return nil
+}
+
+// UnmarshalYAML decodes a service and records the declared order of its
+// map-form networks, since attachment order matters for the default route
+func (s *Service) UnmarshalYAML(node *yaml.Node) error {
+	type plain Service
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.NetworkOrder = mappingKeys(node, "networks")
+	return nil
+}
+
+// keyLines returns the line of each key of the mapping stored under key
+func keyLines(node *yaml.Node, key string) map[string]int {
+	value := mappingValue(node, key)
+	if value == nil {
+		return nil
+	}
+	lines := make(map[string]int, len(value.Content)/2)
+	for j := 0; j+1 < len(value.Content); j += 2 {
+		lines[value.Content[j].Value] = value.Content[j].Line
+	}
+	return lines
+}
+
+// mappingKeys returns the keys of the mapping stored under key, in document order
+func mappingKeys(node *yaml.Node, key string) []string {
+	value := mappingValue(node, key)
+	if value == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(value.Content)/2)
+	for j := 0; j+1 < len(value.Content); j += 2 {
+		keys = append(keys, value.Content[j].Value)
+	}
+	return keys
+}
+
+// mappingValue returns the mapping stored under key, or nil if there is none
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		if value := node.Content[i+1]; value.Kind == yaml.MappingNode {
+			return value
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package compose
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// parsePortString parses the short port syntax [host_ip:][published[-end]:]target[-end][/protocol].
+// Ranges expand to one PortConfig per port. Swarm publishes on all interfaces,
+// so a host IP is validated but not applied (see ServiceWarnings).
+func parsePortString(portStr string) ([]swarm.PortConfig, error) {
+	spec, protocol, err := splitPortProtocol(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	hostIP, published, target, err := splitPortMapping(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+	if hostIP != "" && net.ParseIP(hostIP) == nil {
+		return nil, fmt.Errorf("invalid port %q: invalid host IP %q", portStr, hostIP)
+	}
+
+	targetStart, targetEnd, err := parsePortRange(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: invalid target port: %w", portStr, err)
+	}
+
+	// Only target given: publish each port on the same number
+	publishedStart, publishedEnd := targetStart, targetEnd
+	switch {
+	case published == "" && strings.Contains(spec, ":"):
+		// "127.0.0.1::80" lets Swarm pick the published port
+		publishedStart, publishedEnd = 0, 0
+	case published != "":
+		publishedStart, publishedEnd, err = parsePortRange(published)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: invalid published port: %w", portStr, err)
+		}
+		if publishedEnd-publishedStart != targetEnd-targetStart {
+			return nil, fmt.Errorf("invalid port %q: published range %s and target range %s have different sizes", portStr, published, target)
+		}
+	}
+
+	configs := make([]swarm.PortConfig, 0, targetEnd-targetStart+1)
+	for i := uint32(0); i <= targetEnd-targetStart; i++ {
+		config := swarm.PortConfig{
+			Protocol:    protocol,
+			TargetPort:  targetStart + i,
+			PublishMode: swarm.PortConfigPublishModeIngress,
+		}
+		if publishedStart != 0 {
+			config.PublishedPort = publishedStart + i
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// splitPortProtocol separates the optional /protocol suffix, defaulting to tcp
+func splitPortProtocol(portStr string) (string, swarm.PortConfigProtocol, error) {
+	spec, protocol, found := strings.Cut(portStr, "/")
+	if !found {
+		return spec, swarm.PortConfigProtocolTCP, nil
+	}
+
+	switch p := swarm.PortConfigProtocol(strings.ToLower(protocol)); p {
+	case swarm.PortConfigProtocolTCP, swarm.PortConfigProtocolUDP, swarm.PortConfigProtocolSCTP:
+		return spec, p, nil
+	default:
+		return "", "", fmt.Errorf("invalid port %q: unsupported protocol %q (expected tcp, udp or sctp)", portStr, protocol)
+	}
+}
+
+// splitPortMapping splits "[host_ip:][published:]target" into its parts.
+// IPv6 host addresses must be bracketed: "[::1]:8080:80".
+func splitPortMapping(spec string) (hostIP, published, target string, err error) {
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return "", "", "", fmt.Errorf("unterminated IPv6 host address")
+		}
+		hostIP = spec[1:end]
+		rest := strings.Split(spec[end+2:], ":")
+		if len(rest) != 2 {
+			return "", "", "", fmt.Errorf("expected [host_ip]:published:target")
+		}
+		return hostIP, rest[0], rest[1], nil
+	}
+
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		return "", "", parts[0], nil
+	case 2:
+		if parts[0] == "" {
+			return "", "", "", fmt.Errorf("empty published port")
+		}
+		return "", parts[0], parts[1], nil
+	case 3:
+		if parts[0] == "" {
+			return "", "", "", fmt.Errorf("empty host IP")
+		}
+		return parts[0], parts[1], parts[2], nil
+	default:
+		return "", "", "", fmt.Errorf("too many ':' separators (bracket IPv6 host addresses)")
+	}
+}
+
+// parsePortRange parses "8080" or "8080-8090" into an inclusive range
+func parsePortRange(value string) (uint32, uint32, error) {
+	startStr, endStr, isRange := strings.Cut(value, "-")
+
+	start, err := parsePortNumber(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+
+	end, err := parsePortNumber(endStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %s ends before it starts", value)
+	}
+	return start, end, nil
+}
+
+// parsePortNumber parses a single port in the 1-65535 range
+func parsePortNumber(value string) (uint32, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("%q is not a port number between 1 and 65535", value)
+	}
+	return uint32(port), nil
+}
+
+// portHostIP returns the host IP of a short port spec, or "" if none is set
+func portHostIP(portStr string) string {
+	spec, _, _ := strings.Cut(portStr, "/")
+	hostIP, _, _, err := splitPortMapping(spec)
+	if err != nil {
+		return ""
+	}
+	return hostIP
+}
+
+// ValidatePublishedPorts reports ingress ports published by more than one service,
+// and ingress ports on dnsrr services, which have no virtual IP for the routing mesh.
+// Swarm would accept the first service and reject the next one mid-deploy.
+// Host-mode ports bind per node and are not checked.
+func ValidatePublishedPorts(services map[string]*Service) error {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owners := make(map[string]string)
+	var conflicts []string
+	for _, name := range names {
+		ports, err := convertPorts(services[name].Ports)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		dnsrr := services[name].Deploy != nil && services[name].Deploy.EndpointMode == string(swarm.ResolutionModeDNSRR)
+		for _, p := range ports {
+			if p.PublishMode == swarm.PortConfigPublishModeHost {
+				continue
+			}
+			if dnsrr {
+				return fmt.Errorf("service %s: endpoint_mode dnsrr cannot be combined with ingress-published port %d/%s (publish it with mode: host or use endpoint_mode: vip)", name, p.TargetPort, p.Protocol)
+			}
+			if p.PublishedPort == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%d/%s", p.PublishedPort, p.Protocol)
+			owner, taken := owners[key]
+			if !taken {
+				owners[key] = name
+				continue
+			}
+			if owner != name {
+				conflicts = append(conflicts, fmt.Sprintf("services %s and %s both publish port %s", owner, name, key))
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("published port conflict: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func port(published, target uint32, protocol swarm.PortConfigProtocol) swarm.PortConfig {
+	return swarm.PortConfig{
+		Protocol:      protocol,
+		TargetPort:    target,
+		PublishedPort: published,
+		PublishMode:   swarm.PortConfigPublishModeIngress,
+	}
+}
+
+func TestParsePortString(t *testing.T) {
+	tcp, udp := swarm.PortConfigProtocolTCP, swarm.PortConfigProtocolUDP
+
+	tests := []struct {
+		spec     string
+		expected []swarm.PortConfig
+	}{
+		{"80", []swarm.PortConfig{port(80, 80, tcp)}},
+		{"8080:80", []swarm.PortConfig{port(8080, 80, tcp)}},
+		{"8080:80/udp", []swarm.PortConfig{port(8080, 80, udp)}},
+		{"53:53/UDP", []swarm.PortConfig{port(53, 53, udp)}},
+		{"3000-3002", []swarm.PortConfig{port(3000, 3000, tcp), port(3001, 3001, tcp), port(3002, 3002, tcp)}},
+		{"8080-8082:80-82", []swarm.PortConfig{port(8080, 80, tcp), port(8081, 81, tcp), port(8082, 82, tcp)}},
+		{"9000-9001:9000-9001/udp", []swarm.PortConfig{port(9000, 9000, udp), port(9001, 9001, udp)}},
+		{"127.0.0.1:8080:80", []swarm.PortConfig{port(8080, 80, tcp)}},
+		{"127.0.0.1:8080-8081:80-81/udp", []swarm.PortConfig{port(8080, 80, udp), port(8081, 81, udp)}},
+		{"127.0.0.1::80", []swarm.PortConfig{port(0, 80, tcp)}},
+		{"[::1]:8080:80", []swarm.PortConfig{port(8080, 80, tcp)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parsePortString(tt.spec)
+			if err != nil {
+				t.Fatalf("parsePortString(%q) failed: %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parsePortString(%q) = %+v, want %+v", tt.spec, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePortString_Invalid(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"", "invalid target port"},
+		{"http", "invalid target port"},
+		{"8080:", "invalid target port"},
+		{":80", "empty published port"},
+		{"0:80", "invalid published port"},
+		{"70000:80", "invalid published port"},
+		{"8080:80/icmp", "unsupported protocol"},
+		{"8080-8090:80-85", "different sizes"},
+		{"8080-8090:80", "different sizes"},
+		{"8090-8080:80-90", "ends before it starts"},
+		{"localhost:8080:80", "invalid host IP"},
+		{"1:2:3:4", "too many ':'"},
+		{"[::1:8080:80", "unterminated IPv6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parsePortString(tt.spec)
+			if err == nil {
+				t.Fatalf("Expected error for %q", tt.spec)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q for %q, got: %v", tt.wantErr, tt.spec, err)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_PortRanges(t *testing.T) {
+	service := &Service{
+		Image: "nginx:1.25",
+		Ports: []interface{}{"8080-8081:80-81", "127.0.0.1:9090:90/udp"},
+	}
+
+	spec, err := ConvertToSwarmSpec("web", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	if got := len(spec.EndpointSpec.Ports); got != 3 {
+		t.Fatalf("Expected 3 published ports, got %d", got)
+	}
+
+	warnings := ServiceWarnings("web", service)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "127.0.0.1") {
+		t.Errorf("Expected a host IP warning, got %v", warnings)
+	}
+
+	service.Ports = []interface{}{"8080:80/bogus"}
+	if _, err := ConvertToSwarmSpec("web", service, "mystack", ""); err == nil {
+		t.Error("Expected malformed port to fail conversion")
+	}
+}
+
+func TestValidatePublishedPorts(t *testing.T) {
+	tests := []struct {
+		name     string
+		services map[string]*Service
+		wantErr  string
+	}{
+		{
+			name: "no collision",
+			services: map[string]*Service{
+				"web": {Ports: []interface{}{"80:80", "443:443"}},
+				"api": {Ports: []interface{}{"8080:80", "80:80/udp"}},
+				"dns": {Ports: []interface{}{"53"}},
+			},
+		},
+		{
+			name: "same port and protocol on ingress",
+			services: map[string]*Service{
+				"web":   {Ports: []interface{}{"80:80"}},
+				"proxy": {Ports: []interface{}{"70-90:70-90"}},
+			},
+			wantErr: "services proxy and web both publish port 80/tcp",
+		},
+		{
+			name: "host mode is exempt",
+			services: map[string]*Service{
+				"web": {Ports: []interface{}{map[string]interface{}{"target": 80, "published": 80, "mode": "host"}}},
+				"api": {Ports: []interface{}{map[string]interface{}{"target": 80, "published": 80, "mode": "host"}}},
+			},
+		},
+		{
+			name: "long syntax ingress collides with short syntax",
+			services: map[string]*Service{
+				"web": {Ports: []interface{}{"443:8443"}},
+				"api": {Ports: []interface{}{map[string]interface{}{"target": 443, "published": 443}}},
+			},
+			wantErr: "services api and web both publish port 443/tcp",
+		},
+		{
+			name: "dnsrr with ingress port",
+			services: map[string]*Service{
+				"web": {Ports: []interface{}{"8080:80"}},
+				"db":  {Ports: []interface{}{"5432:5432"}, Deploy: &DeployConfig{EndpointMode: "dnsrr"}},
+			},
+			wantErr: "service db: endpoint_mode dnsrr cannot be combined with ingress-published port 5432/tcp",
+		},
+		{
+			name: "dnsrr with host-mode port",
+			services: map[string]*Service{
+				"db": {
+					Ports:  []interface{}{map[string]interface{}{"target": 5432, "published": 5432, "mode": "host"}},
+					Deploy: &DeployConfig{EndpointMode: "dnsrr"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePublishedPorts(tt.services)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_EndpointMode(t *testing.T) {
+	tests := []struct {
+		mode  string
+		ports []interface{}
+		want  swarm.ResolutionMode
+	}{
+		{mode: "vip", ports: []interface{}{"80:80"}, want: swarm.ResolutionModeVIP},
+		{mode: "dnsrr", want: swarm.ResolutionModeDNSRR},
+		{mode: "dnsrr", ports: []interface{}{map[string]interface{}{"target": 80, "published": 80, "mode": "host"}}, want: swarm.ResolutionModeDNSRR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			service := &Service{
+				Image:  "nginx:1.25",
+				Ports:  tt.ports,
+				Deploy: &DeployConfig{EndpointMode: tt.mode},
+			}
+			spec, err := ConvertToSwarmSpec("web", service, "mystack", "")
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+			}
+			if spec.EndpointSpec == nil || spec.EndpointSpec.Mode != tt.want {
+				t.Errorf("Expected endpoint mode %s, got %+v", tt.want, spec.EndpointSpec)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_EndpointModeErrors(t *testing.T) {
+	service := &Service{
+		Image:  "nginx:1.25",
+		Ports:  []interface{}{"8080:80"},
+		Deploy: &DeployConfig{EndpointMode: "dnsrr"},
+	}
+	if _, err := ConvertToSwarmSpec("web", service, "mystack", ""); err == nil || !strings.Contains(err.Error(), "ingress-published port 80/tcp") {
+		t.Errorf("Expected dnsrr with ingress port to fail, got %v", err)
+	}
+
+	service.Ports = nil
+	service.Deploy.EndpointMode = "roundrobin"
+	if _, err := ConvertToSwarmSpec("web", service, "mystack", ""); err == nil {
+		t.Error("Expected unknown endpoint_mode to fail")
+	}
+}
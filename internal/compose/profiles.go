@@ -0,0 +1,76 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ServiceEnabled reports whether a service runs with the given active profiles:
+// services without profiles always do, others when one of their profiles is active
+func ServiceEnabled(service *Service, active []string) bool {
+	if len(service.Profiles) == 0 {
+		return true
+	}
+	for _, profile := range service.Profiles {
+		for _, a := range active {
+			if profile == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ApplyProfiles removes the services that are not enabled by the active profiles
+// and returns their names, sorted, also recording them in file.DisabledServices. An enabled service that depends on a removed
+// one is an error, since it would be deployed without its dependency.
+func ApplyProfiles(file *ComposeFile, active []string) ([]string, error) {
+	var disabled []string
+	for name, service := range file.Services {
+		if service != nil && !ServiceEnabled(service, active) {
+			disabled = append(disabled, name)
+		}
+	}
+	if len(disabled) == 0 {
+		return nil, nil
+	}
+	sort.Strings(disabled)
+
+	isDisabled := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		isDisabled[name] = true
+	}
+
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		service := file.Services[name]
+		if service == nil || isDisabled[name] {
+			continue
+		}
+		deps, err := DependsOnServices(service.DependsOn)
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if isDisabled[dep] {
+				errs = append(errs, fmt.Errorf("service %s depends on service %s, which is not enabled by the active profiles", name, dep))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	for _, name := range disabled {
+		delete(file.Services, name)
+	}
+	file.DisabledServices = disabled
+	return disabled, nil
+}
@@ -0,0 +1,78 @@
+package compose
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const profilesCompose = `
+services:
+  web:
+    image: nginx:1.25
+  debug:
+    image: busybox:1.36
+    profiles: [debug]
+  migrate:
+    image: app:1.0
+    profiles: [migration, ops]
+`
+
+func TestApplyProfiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		active       []string
+		wantServices []string
+		wantDisabled []string
+	}{
+		{"no active profiles", nil, []string{"web"}, []string{"debug", "migrate"}},
+		{"one profile", []string{"debug"}, []string{"debug", "web"}, []string{"migrate"}},
+		{"any of the service profiles", []string{"ops"}, []string{"migrate", "web"}, []string{"debug"}},
+		{"all profiles", []string{"debug", "migration"}, []string{"debug", "migrate", "web"}, nil},
+		{"unknown profile", []string{"staging"}, []string{"web"}, []string{"debug", "migrate"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := parseLintCompose(t, profilesCompose)
+
+			disabled, err := ApplyProfiles(file, tt.active)
+			if err != nil {
+				t.Fatalf("ApplyProfiles failed: %v", err)
+			}
+			if !reflect.DeepEqual(disabled, tt.wantDisabled) {
+				t.Errorf("disabled = %v, want %v", disabled, tt.wantDisabled)
+			}
+
+			var services []string
+			for name := range file.Services {
+				services = append(services, name)
+			}
+			sort.Strings(services)
+			if !reflect.DeepEqual(services, tt.wantServices) {
+				t.Errorf("services = %v, want %v", services, tt.wantServices)
+			}
+		})
+	}
+}
+
+func TestApplyProfiles_DependencyDisabled(t *testing.T) {
+	file := parseLintCompose(t, `
+services:
+  web:
+    image: nginx:1.25
+    depends_on: [migrate]
+  migrate:
+    image: app:1.0
+    profiles: [migration]
+`)
+
+	_, err := ApplyProfiles(file, nil)
+	if err == nil || !strings.Contains(err.Error(), "service web depends on service migrate") {
+		t.Fatalf("Expected a disabled dependency error, got %v", err)
+	}
+	if len(file.Services) != 2 {
+		t.Errorf("Expected the services to be left untouched on error, got %d", len(file.Services))
+	}
+}
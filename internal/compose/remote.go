@@ -0,0 +1,161 @@
+package compose
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ComposeHeaderEnv holds an optional "Name: value" header sent when fetching a
+// remote compose file, e.g. "Authorization: Bearer <token>"
+const ComposeHeaderEnv = "STACKMAN_COMPOSE_HEADER"
+
+// remoteFetchTimeout bounds a single remote compose download
+const remoteFetchTimeout = 60 * time.Second
+
+// maxRemoteComposeSize bounds the size of a downloaded compose file
+var maxRemoteComposeSize int64 = 10 << 20
+
+const gitPrefix = "git::"
+
+// IsRemote reports whether a compose path refers to an HTTP(S) URL or a git:: source
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") ||
+		strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, gitPrefix)
+}
+
+// readRemote fetches a remote compose file
+func readRemote(path string) ([]byte, error) {
+	if strings.HasPrefix(path, gitPrefix) {
+		return readGit(strings.TrimPrefix(path, gitPrefix))
+	}
+	return readHTTP(path)
+}
+
+// readHTTP downloads a compose file, adding the STACKMAN_COMPOSE_HEADER header when set
+func readHTTP(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compose URL: %w", err)
+	}
+
+	if header := os.Getenv(ComposeHeaderEnv); header != "" {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s must be in \"Name: value\" form", ComposeHeaderEnv)
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	client := &http.Client{Timeout: remoteFetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", redactURL(rawURL), resp.Status)
+	}
+
+	// Read one byte past the limit to tell a file of exactly the limit from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteComposeSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxRemoteComposeSize {
+		return nil, fmt.Errorf("GET %s: compose file exceeds %d bytes", redactURL(rawURL), maxRemoteComposeSize)
+	}
+	return data, nil
+}
+
+// readGit reads a file from a git repository, addressed like
+// git::https://github.com/org/repo.git//path/to/stack.yml?ref=main.
+// The repository is shallow-cloned into a temporary directory that is removed afterwards.
+func readGit(source string) ([]byte, error) {
+	repo, file, ref, err := parseGitSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "stackman-git-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	// "--" keeps a repository starting with "-" from being read as a git option
+	args = append(args, "--", repo, dir)
+
+	cmd := exec.Command("git", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone %s failed: %w: %s", redactURL(repo), err, strings.TrimSpace(string(out)))
+	}
+
+	// A symlink in the repository must not lead outside the clone
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve clone directory: %w", err)
+	}
+	target, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, target); err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("git source file %s resolves outside the repository", file)
+	}
+	return os.ReadFile(target)
+}
+
+// parseGitSource splits a git source into repository URL, file path and optional ref
+func parseGitSource(source string) (repo, file, ref string, err error) {
+	if i := strings.LastIndex(source, "?"); i >= 0 {
+		query, err := url.ParseQuery(source[i+1:])
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid git source query: %w", err)
+		}
+		ref = query.Get("ref")
+		source = source[:i]
+	}
+
+	// The file path follows the first "//" after the scheme separator
+	start := 0
+	if i := strings.Index(source, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	i := strings.Index(source[start:], "//")
+	if i < 0 {
+		return "", "", "", fmt.Errorf("git source %q must name a file after '//'", redactURL(source))
+	}
+	repo = source[:start+i]
+	file = strings.TrimPrefix(source[start+i:], "//")
+	if file == "" {
+		return "", "", "", fmt.Errorf("git source %q must name a file after '//'", redactURL(source))
+	}
+	// The file is read from the clone directory and must stay inside it
+	if !filepath.IsLocal(filepath.FromSlash(file)) {
+		return "", "", "", fmt.Errorf("git source file %q must be a relative path inside the repository", file)
+	}
+	return repo, path.Clean(file), ref, nil
+}
+
+// redactURL hides credentials embedded in a URL before it is logged
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
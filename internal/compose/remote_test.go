@@ -0,0 +1,144 @@
+package compose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseComposeFile_RemoteURL(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Path != "/stack.yml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("services:\n  web:\n    image: nginx:1.25\n"))
+	}))
+	defer server.Close()
+
+	t.Setenv(ComposeHeaderEnv, "Authorization: Bearer secret")
+
+	composeFile, err := ParseComposeFile(server.URL + "/stack.yml")
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Expected auth header to be sent, got %q", gotAuth)
+	}
+	if composeFile.Services["web"] == nil || composeFile.Services["web"].Image != "nginx:1.25" {
+		t.Errorf("Unexpected services: %+v", composeFile.Services)
+	}
+	if composeFile.Dir != "" {
+		t.Errorf("Expected no local dir for a remote file, got %q", composeFile.Dir)
+	}
+
+	if _, err := ParseComposeFile(server.URL + "/missing.yml"); err == nil {
+		t.Error("Expected error for a 404 response")
+	}
+}
+
+func TestParseComposeFile_RemoteInvalidHeader(t *testing.T) {
+	t.Setenv(ComposeHeaderEnv, "no-separator")
+	if _, err := ParseComposeFile("https://example.invalid/stack.yml"); err == nil {
+		t.Error("Expected error for malformed header")
+	}
+}
+
+func TestReadHTTP_SizeLimit(t *testing.T) {
+	body := "services:\n  web:\n    image: nginx:1.25\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	defer func(limit int64) { maxRemoteComposeSize = limit }(maxRemoteComposeSize)
+
+	maxRemoteComposeSize = int64(len(body))
+	if _, err := readHTTP(server.URL); err != nil {
+		t.Errorf("Expected a file of exactly the limit to be read, got %v", err)
+	}
+
+	maxRemoteComposeSize = int64(len(body)) - 1
+	if _, err := readHTTP(server.URL); err == nil {
+		t.Error("Expected error for a compose file over the size limit")
+	}
+}
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		source  string
+		repo    string
+		file    string
+		ref     string
+		wantErr bool
+	}{
+		{source: "https://github.com/org/repo.git//stack.yml", repo: "https://github.com/org/repo.git", file: "stack.yml"},
+		{source: "https://github.com/org/repo.git//deploy/prod.yml?ref=v1.2.0", repo: "https://github.com/org/repo.git", file: "deploy/prod.yml", ref: "v1.2.0"},
+		{source: "git@github.com:org/repo.git//stack.yml", repo: "git@github.com:org/repo.git", file: "stack.yml"},
+		{source: "https://github.com/org/repo.git", wantErr: true},
+		{source: "https://github.com/org/repo.git//", wantErr: true},
+		{source: "https://github.com/org/repo.git//deploy/../stack.yml", repo: "https://github.com/org/repo.git", file: "stack.yml"},
+		{source: "https://github.com/org/repo.git//../../etc/passwd", wantErr: true},
+		{source: "https://github.com/org/repo.git//deploy/../../stack.yml", wantErr: true},
+		{source: "https://github.com/org/repo.git///etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			repo, file, ref, err := parseGitSource(tt.source)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.source)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGitSource failed: %v", err)
+			}
+			if repo != tt.repo || file != tt.file || ref != tt.ref {
+				t.Errorf("parseGitSource() = %q, %q, %q, want %q, %q, %q", repo, file, ref, tt.repo, tt.file, tt.ref)
+			}
+		})
+	}
+}
+
+func TestReadGit_SymlinkOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.yml")
+	if err := os.WriteFile(outside, []byte("services: {}\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "stack.yml"), []byte("services: {}\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(repo, "link.yml")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	if _, err := readGit("file://" + repo + "//stack.yml"); err != nil {
+		t.Fatalf("readGit failed: %v", err)
+	}
+	if _, err := readGit("file://" + repo + "//link.yml"); err == nil {
+		t.Error("Expected error for a symlink leading outside the repository")
+	}
+}
@@ -0,0 +1,22 @@
+package compose
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Render marshals a parsed compose file back to YAML, with variables already
+// interpolated and any overrides applied by the caller
+func Render(file *ComposeFile) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
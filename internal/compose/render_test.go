@@ -0,0 +1,65 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender_RoundTrip(t *testing.T) {
+	data := `
+services:
+  web:
+    image: "nginx:${TAG}"
+    ports:
+      - "80:80"
+    environment:
+      GREETING: hello
+    deploy:
+      replicas: ${REPLICAS}
+networks:
+  front: {}
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	composeFile, err := ParseComposeFileWithVars(path, map[string]string{"TAG": "1.25", "REPLICAS": "2"})
+	if err != nil {
+		t.Fatalf("ParseComposeFileWithVars failed: %v", err)
+	}
+
+	rendered, err := Render(composeFile)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(string(rendered), "${") {
+		t.Errorf("Expected variables to be interpolated, got:\n%s", rendered)
+	}
+
+	renderedPath := filepath.Join(dir, "rendered.yml")
+	if err := os.WriteFile(renderedPath, rendered, 0644); err != nil {
+		t.Fatalf("Failed to write rendered file: %v", err)
+	}
+	reparsed, err := ParseComposeFile(renderedPath)
+	if err != nil {
+		t.Fatalf("Rendered compose does not parse: %v\n%s", err, rendered)
+	}
+
+	web := reparsed.Services["web"]
+	if web == nil || web.Image != "nginx:1.25" {
+		t.Fatalf("Expected web with image nginx:1.25, got %+v", web)
+	}
+	if web.Deploy == nil || web.Deploy.Replicas == nil || *web.Deploy.Replicas != 2 {
+		t.Errorf("Expected 2 replicas, got %+v", web.Deploy)
+	}
+	if len(web.Ports) != 1 || web.Ports[0] != "80:80" {
+		t.Errorf("Expected port 80:80, got %v", web.Ports)
+	}
+	if _, ok := reparsed.Networks["front"]; !ok {
+		t.Errorf("Expected network front, got %v", reparsed.Networks)
+	}
+}
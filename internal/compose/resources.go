@@ -0,0 +1,49 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/SomeBlackMagic/stackman/internal/paths"
+)
+
+const (
+	// ResourceNameLabel records the compose-level name of a stack secret or config
+	ResourceNameLabel = "com.stackman.resource.name"
+	// ContentHashLabel records the SHA-256 of a stack secret or config's data
+	ContentHashLabel = "com.stackman.content.hash"
+)
+
+// ContentHash returns the hex-encoded SHA-256 of data
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VersionedName returns the swarm name for a secret or config version: <stack>_<name>_<hash8>.
+// Secrets and configs are immutable, so a content change produces a new object name.
+func VersionedName(stackName, name, hash string) string {
+	if len(hash) > 8 {
+		hash = hash[:8]
+	}
+	return fmt.Sprintf("%s_%s_%s", stackName, name, hash)
+}
+
+// ReadResourceFile reads the file backing a secret or config. Relative paths are
+// resolved against STACKMAN_WORKDIR, the compose file directory baseDir, or the
+// current directory, like bind mount sources.
+func ReadResourceFile(file, baseDir string) ([]byte, error) {
+	resolver, err := paths.NewResolverFor(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create path resolver: %w", err)
+	}
+
+	path := resolver.Resolve(file)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
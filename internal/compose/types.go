@@ -2,17 +2,39 @@ package compose
 
 // ComposeFile represents the structure of a docker-compose.yml file
 type ComposeFile struct {
-	Version  string              `yaml:"version"`
+	Version  string              `yaml:"version,omitempty"`
 	Services map[string]*Service `yaml:"services"`
 	Networks map[string]*Network `yaml:"networks,omitempty"`
 	Volumes  map[string]*Volume  `yaml:"volumes,omitempty"`
 	Secrets  map[string]*Secret  `yaml:"secrets,omitempty"`
 	Configs  map[string]*Config  `yaml:"configs,omitempty"`
+
+	// Dir is the directory of the compose file; relative bind sources resolve against it
+	Dir string `yaml:"-"`
+
+	// ServiceLines holds the line of each service key, for annotating warnings
+	ServiceLines map[string]int `yaml:"-"`
+
+	// UnsetVariables lists interpolated variables that were not set and became empty
+	UnsetVariables []string `yaml:"-"`
+
+	// DisabledServices lists the services left out by the active profiles; once
+	// deployed they are kept as they are rather than removed as obsolete
+	DisabledServices []string `yaml:"-"`
 }
 
+// Image pull policies (service-level `pull_policy` and apply --pull)
+const (
+	PullPolicyAlways  = "always"
+	PullPolicyMissing = "missing"
+	PullPolicyNever   = "never"
+	PullPolicyBuild   = "build"
+)
+
 type Service struct {
 	Image           string                 `yaml:"image,omitempty"`
 	Build           *BuildConfig           `yaml:"build,omitempty"`
+	PullPolicy      string                 `yaml:"pull_policy,omitempty"`
 	Command         interface{}            `yaml:"command,omitempty"`
 	Entrypoint      interface{}            `yaml:"entrypoint,omitempty"`
 	Environment     interface{}            `yaml:"environment,omitempty"`
@@ -24,6 +46,7 @@ type Service struct {
 	Networks        interface{}            `yaml:"networks,omitempty"`
 	Deploy          *DeployConfig          `yaml:"deploy,omitempty"`
 	DependsOn       interface{}            `yaml:"depends_on,omitempty"`
+	Profiles        []string               `yaml:"profiles,omitempty"`
 	Labels          map[string]string      `yaml:"labels,omitempty"`
 	HealthCheck     *HealthCheck           `yaml:"healthcheck,omitempty"`
 	Secrets         []interface{}          `yaml:"secrets,omitempty"`
@@ -53,13 +76,19 @@ type Service struct {
 	StorageOpt      map[string]string      `yaml:"storage_opt,omitempty"`
 	Sysctls         interface{}            `yaml:"sysctls,omitempty"`
 	Isolation       string                 `yaml:"isolation,omitempty"`
+	Runtime         string                 `yaml:"runtime,omitempty"`
 	Init            *bool                  `yaml:"init,omitempty"`
 	PidMode         string                 `yaml:"pid,omitempty"`
 	IpcMode         string                 `yaml:"ipc,omitempty"`
 	CgroupParent    string                 `yaml:"cgroup_parent,omitempty"`
+	Cpuset          string                 `yaml:"cpuset,omitempty"`
+	BlkioConfig     *BlkioConfig           `yaml:"blkio_config,omitempty"`
 	Devices         []string               `yaml:"devices,omitempty"`
 	Links           []string               `yaml:"links,omitempty"`
 	ExternalLinks   []string               `yaml:"external_links,omitempty"`
+
+	// NetworkOrder holds the declared order of map-form networks, which a Go map loses
+	NetworkOrder []string `yaml:"-"`
 }
 
 type BuildConfig struct {
@@ -74,6 +103,27 @@ type BuildConfig struct {
 	ExtraHosts []string          `yaml:"extra_hosts,omitempty"`
 }
 
+// BlkioConfig is the compose block I/O configuration. Swarm has no block I/O
+// controls, so it is only parsed to warn that it will be ignored.
+type BlkioConfig struct {
+	Weight          uint16              `yaml:"weight,omitempty"`
+	WeightDevice    []BlkioWeightDevice `yaml:"weight_device,omitempty"`
+	DeviceReadBps   []BlkioDeviceLimit  `yaml:"device_read_bps,omitempty"`
+	DeviceReadIOps  []BlkioDeviceLimit  `yaml:"device_read_iops,omitempty"`
+	DeviceWriteBps  []BlkioDeviceLimit  `yaml:"device_write_bps,omitempty"`
+	DeviceWriteIOps []BlkioDeviceLimit  `yaml:"device_write_iops,omitempty"`
+}
+
+type BlkioWeightDevice struct {
+	Path   string `yaml:"path"`
+	Weight uint16 `yaml:"weight"`
+}
+
+type BlkioDeviceLimit struct {
+	Path string      `yaml:"path"`
+	Rate interface{} `yaml:"rate"` // bytes or IOPS as a number, or a size such as "12mb"
+}
+
 type LoggingConfig struct {
 	Driver  string            `yaml:"driver,omitempty"`
 	Options map[string]string `yaml:"options,omitempty"`
@@ -123,6 +173,7 @@ type DeviceRequest struct {
 
 type GenericResource struct {
 	DiscreteResourceSpec *DiscreteResourceSpec `yaml:"discrete_resource_spec,omitempty"`
+	NamedResourceSpec    *NamedResourceSpec    `yaml:"named_resource_spec,omitempty"`
 }
 
 type DiscreteResourceSpec struct {
@@ -130,6 +181,11 @@ type DiscreteResourceSpec struct {
 	Value int64  `yaml:"value,omitempty"`
 }
 
+type NamedResourceSpec struct {
+	Kind  string `yaml:"kind,omitempty"`
+	Value string `yaml:"value,omitempty"`
+}
+
 type RestartPolicy struct {
 	Condition   string `yaml:"condition,omitempty"`
 	Delay       string `yaml:"delay,omitempty"`
@@ -163,6 +219,7 @@ type HealthCheck struct {
 }
 
 type Network struct {
+	Name       string            `yaml:"name,omitempty"`
 	Driver     string            `yaml:"driver,omitempty"`
 	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
 	External   interface{}       `yaml:"external,omitempty"`
@@ -194,12 +251,14 @@ type Volume struct {
 }
 
 type Secret struct {
+	Name     string            `yaml:"name,omitempty"`
 	File     string            `yaml:"file,omitempty"`
 	External interface{}       `yaml:"external,omitempty"`
 	Labels   map[string]string `yaml:"labels,omitempty"`
 }
 
 type Config struct {
+	Name     string            `yaml:"name,omitempty"`
 	File     string            `yaml:"file,omitempty"`
 	External interface{}       `yaml:"external,omitempty"`
 	Labels   map[string]string `yaml:"labels,omitempty"`
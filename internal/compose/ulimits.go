@@ -0,0 +1,98 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// convertUlimits converts compose ulimits, given either as a single number
+// (soft = hard) or as a {soft, hard} mapping, sorted by name
+func convertUlimits(ulimits map[string]interface{}) ([]*container.Ulimit, error) {
+	names := make([]string, 0, len(ulimits))
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*container.Ulimit, 0, len(names))
+	for _, name := range names {
+		ulimit := &container.Ulimit{Name: name}
+		switch v := ulimits[name].(type) {
+		case map[string]interface{}:
+			soft, err := ulimitValue(v["soft"])
+			if err != nil {
+				return nil, fmt.Errorf("ulimit %s: soft: %w", name, err)
+			}
+			hard, err := ulimitValue(v["hard"])
+			if err != nil {
+				return nil, fmt.Errorf("ulimit %s: hard: %w", name, err)
+			}
+			if soft > hard {
+				return nil, fmt.Errorf("ulimit %s: soft limit %d exceeds hard limit %d", name, soft, hard)
+			}
+			ulimit.Soft, ulimit.Hard = soft, hard
+		default:
+			limit, err := ulimitValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("ulimit %s: %w", name, err)
+			}
+			ulimit.Soft, ulimit.Hard = limit, limit
+		}
+		result = append(result, ulimit)
+	}
+	return result, nil
+}
+
+// ulimitValue parses a ulimit given as a YAML integer or a numeric string
+func ulimitValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", v)
+		}
+		return n, nil
+	case nil:
+		return 0, fmt.Errorf("value is missing")
+	default:
+		return 0, fmt.Errorf("unsupported value %v (%T)", value, value)
+	}
+}
+
+// convertSysctls converts compose sysctls given as a mapping or a list of key=value strings
+func convertSysctls(sysctls interface{}) (map[string]string, error) {
+	result := make(map[string]string)
+	switch v := sysctls.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if value == nil {
+				return nil, fmt.Errorf("sysctl %s has no value", key)
+			}
+			result[key] = fmt.Sprint(value)
+		}
+	case []interface{}:
+		for _, item := range v {
+			entry, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported sysctl entry %v (%T), expected key=value", item, item)
+			}
+			key, value, ok := strings.Cut(entry, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid sysctl %q, expected key=value", entry)
+			}
+			result[key] = strings.TrimSpace(value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sysctls type: %T", sysctls)
+	}
+	return result, nil
+}
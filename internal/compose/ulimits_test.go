@@ -0,0 +1,141 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConvertUlimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []*container.Ulimit
+		wantErr bool
+	}{
+		{
+			name: "single number",
+			yaml: "nproc: 65535",
+			want: []*container.Ulimit{{Name: "nproc", Soft: 65535, Hard: 65535}},
+		},
+		{
+			name: "soft and hard",
+			yaml: "nofile:\n  soft: 20000\n  hard: 40000",
+			want: []*container.Ulimit{{Name: "nofile", Soft: 20000, Hard: 40000}},
+		},
+		{
+			name: "mixed forms sorted by name",
+			yaml: "nproc: 512\nmemlock: {soft: -1, hard: -1}",
+			want: []*container.Ulimit{
+				{Name: "memlock", Soft: -1, Hard: -1},
+				{Name: "nproc", Soft: 512, Hard: 512},
+			},
+		},
+		{
+			name: "numeric string",
+			yaml: `nofile: "1024"`,
+			want: []*container.Ulimit{{Name: "nofile", Soft: 1024, Hard: 1024}},
+		},
+		{name: "missing hard", yaml: "nofile: {soft: 1024}", wantErr: true},
+		{name: "soft above hard", yaml: "nofile: {soft: 4096, hard: 1024}", wantErr: true},
+		{name: "not a number", yaml: "nofile: lots", wantErr: true},
+		{name: "list", yaml: "nofile: [1, 2]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ulimits map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &ulimits); err != nil {
+				t.Fatalf("Failed to parse ulimits: %v", err)
+			}
+
+			got, err := convertUlimits(ulimits)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertSysctls(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "map form",
+			yaml: "net.core.somaxconn: 1024\nnet.ipv4.tcp_syncookies: 0",
+			want: map[string]string{"net.core.somaxconn": "1024", "net.ipv4.tcp_syncookies": "0"},
+		},
+		{
+			name: "list form",
+			yaml: "- net.core.somaxconn=1024\n- net.ipv4.ip_local_port_range=1024 65000",
+			want: map[string]string{"net.core.somaxconn": "1024", "net.ipv4.ip_local_port_range": "1024 65000"},
+		},
+		{name: "list entry without value", yaml: "- net.core.somaxconn", wantErr: true},
+		{name: "map entry without value", yaml: "net.core.somaxconn:", wantErr: true},
+		{name: "scalar", yaml: "net.core.somaxconn=1024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sysctls interface{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &sysctls); err != nil {
+				t.Fatalf("Failed to parse sysctls: %v", err)
+			}
+
+			got, err := convertSysctls(sysctls)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertToSwarmSpec_UlimitsAndSysctls(t *testing.T) {
+	var service Service
+	data := "image: postgres:16\nulimits:\n  nofile: {soft: 20000, hard: 40000}\nsysctls:\n  net.core.somaxconn: 1024\n"
+	if err := yaml.Unmarshal([]byte(data), &service); err != nil {
+		t.Fatalf("Failed to parse service: %v", err)
+	}
+
+	spec, err := ConvertToSwarmSpec("db", &service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if len(containerSpec.Ulimits) != 1 || *containerSpec.Ulimits[0] != (container.Ulimit{Name: "nofile", Soft: 20000, Hard: 40000}) {
+		t.Errorf("Expected the nofile ulimit, got %+v", containerSpec.Ulimits)
+	}
+	if containerSpec.Sysctls["net.core.somaxconn"] != "1024" {
+		t.Errorf("Expected net.core.somaxconn=1024, got %v", containerSpec.Sysctls)
+	}
+
+	service.Ulimits = map[string]interface{}{"nofile": "many"}
+	if _, err := ConvertToSwarmSpec("db", &service, "mystack", ""); err == nil {
+		t.Error("Expected an invalid ulimit to fail conversion")
+	}
+}
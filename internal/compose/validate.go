@@ -0,0 +1,240 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// defaultNetwork is attached implicitly and never has to be declared
+const defaultNetwork = "default"
+
+// Validate checks a parsed compose file for problems that would otherwise
+// only surface during deployment: services without an image or build,
+// references to undeclared networks, volumes, secrets and configs,
+// unparsable durations and malformed placement constraints.
+// All problems are reported together, joined with errors.Join.
+func Validate(file *ComposeFile) error {
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		service := file.Services[name]
+		if service == nil {
+			continue
+		}
+		for _, msg := range validateService(file, service) {
+			errs = append(errs, fmt.Errorf("service %s: %s", name, msg))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateNetworks checks that every network a service attaches to is declared
+// in the top-level networks, as a stack network or an external one.
+// The default network is implicit and needs no declaration.
+func ValidateNetworks(file *ComposeFile) error {
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if file.Services[name] == nil {
+			continue
+		}
+		for _, msg := range networkReferenceErrors(file, file.Services[name]) {
+			errs = append(errs, fmt.Errorf("service %s: %s", name, msg))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UsesDefaultNetwork reports whether any service attaches to the stack's default
+// network, either by listing no networks or by naming it
+func UsesDefaultNetwork(services map[string]*Service) bool {
+	for _, service := range services {
+		if service == nil {
+			continue
+		}
+		if service.Networks == nil {
+			return true
+		}
+		names, _ := serviceNetworkNames(service.Networks)
+		for _, name := range names {
+			if name == defaultNetwork {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// networkReferenceErrors returns a message for each undeclared network a service references
+func networkReferenceErrors(file *ComposeFile, service *Service) []string {
+	networks, err := serviceNetworkNames(service.Networks)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var msgs []string
+	for _, name := range networks {
+		if _, ok := file.Networks[name]; !ok && name != defaultNetwork {
+			msgs = append(msgs, fmt.Sprintf("network %s is not declared in the top-level networks", name))
+		}
+	}
+	return msgs
+}
+
+// validateService returns every problem found in a single service
+func validateService(file *ComposeFile, service *Service) []string {
+	var msgs []string
+
+	if service.Image == "" && service.Build == nil {
+		msgs = append(msgs, "neither image nor build is set")
+	}
+
+	msgs = append(msgs, networkReferenceErrors(file, service)...)
+
+	for _, vol := range service.Volumes {
+		long, ok := vol.(map[string]interface{})
+		if !ok {
+			// The short syntax is mounted as a bind path
+			continue
+		}
+		volumeType, _ := long["type"].(string)
+		source, _ := long["source"].(string)
+		if (volumeType == "" || volumeType == string(mount.TypeVolume)) && source != "" {
+			if _, ok := file.Volumes[source]; !ok {
+				msgs = append(msgs, fmt.Sprintf("volume %s is not declared in the top-level volumes", source))
+			}
+		}
+	}
+
+	secrets, err := ReferenceSources(service.Secrets)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("secrets: %v", err))
+	}
+	for _, name := range secrets {
+		if _, ok := file.Secrets[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("secret %s is not declared in the top-level secrets", name))
+		}
+	}
+
+	configs, err := ReferenceSources(service.Configs)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("configs: %v", err))
+	}
+	for _, name := range configs {
+		if _, ok := file.Configs[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("config %s is not declared in the top-level configs", name))
+		}
+	}
+
+	for _, d := range serviceDurations(service) {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid %s %q: %v", d.field, d.value, err))
+		}
+	}
+
+	if _, err := resolveReplicas(service.Deploy); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+
+	if service.Deploy != nil && service.Deploy.Placement != nil {
+		for _, constraint := range service.Deploy.Placement.Constraints {
+			if !plausibleConstraint(constraint) {
+				msgs = append(msgs, fmt.Sprintf("invalid placement constraint %q, expected <attribute>==<value> or <attribute>!=<value>", constraint))
+			}
+		}
+	}
+
+	return msgs
+}
+
+// serviceNetworkNames returns the networks named in the list or map form of service networks
+func serviceNetworkNames(networks interface{}) ([]string, error) {
+	var names []string
+	switch v := networks.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported network entry type: %T", item)
+			}
+			names = append(names, name)
+		}
+	case map[string]interface{}:
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	default:
+		return nil, fmt.Errorf("unsupported networks type: %T", networks)
+	}
+	return names, nil
+}
+
+// namedDuration is a duration field of a service with its compose path
+type namedDuration struct {
+	field string
+	value string
+}
+
+// serviceDurations lists the duration fields of a service that the converter parses
+func serviceDurations(service *Service) []namedDuration {
+	durations := []namedDuration{{"stop_grace_period", service.StopGracePeriod}}
+	if hc := service.HealthCheck; hc != nil {
+		durations = append(durations,
+			namedDuration{"healthcheck.interval", hc.Interval},
+			namedDuration{"healthcheck.timeout", hc.Timeout},
+			namedDuration{"healthcheck.start_period", hc.StartPeriod},
+		)
+	}
+	if deploy := service.Deploy; deploy != nil {
+		if uc := deploy.UpdateConfig; uc != nil {
+			durations = append(durations,
+				namedDuration{"deploy.update_config.delay", uc.Delay},
+				namedDuration{"deploy.update_config.monitor", uc.Monitor},
+			)
+		}
+		if rc := deploy.RollbackConfig; rc != nil {
+			durations = append(durations,
+				namedDuration{"deploy.rollback_config.delay", rc.Delay},
+				namedDuration{"deploy.rollback_config.monitor", rc.Monitor},
+			)
+		}
+		if rp := deploy.RestartPolicy; rp != nil {
+			durations = append(durations,
+				namedDuration{"deploy.restart_policy.delay", rp.Delay},
+				namedDuration{"deploy.restart_policy.window", rp.Window},
+			)
+		}
+	}
+	return durations
+}
+
+// plausibleConstraint reports whether a placement constraint has the
+// <attribute>==<value> or <attribute>!=<value> shape Swarm expects
+func plausibleConstraint(constraint string) bool {
+	for _, op := range []string{"==", "!="} {
+		if key, value, ok := strings.Cut(constraint, op); ok {
+			return strings.TrimSpace(key) != "" && strings.TrimSpace(value) != ""
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	composeFile := parseLintCompose(t, `
+services:
+  web:
+    networks: [frontend, missing-net]
+    secrets: [db_password, missing_secret]
+    healthcheck:
+      test: ["CMD", "true"]
+      interval: 10 seconds
+  api:
+    image: api:1.0
+    stop_grace_period: soon
+    configs:
+      - source: missing_config
+        target: /etc/api.conf
+    volumes:
+      - type: volume
+        source: missing_data
+        target: /data
+      - ./html:/srv/html
+    deploy:
+      replicas: -2
+      placement:
+        constraints:
+          - node.role=manager
+          - node.labels.zone != eu-1
+      restart_policy:
+        window: 1 minute
+networks:
+  frontend: {}
+secrets:
+  db_password:
+    file: ./db_password.txt
+`)
+
+	err := Validate(composeFile)
+	if err == nil {
+		t.Fatal("Expected Validate to fail")
+	}
+
+	want := []string{
+		"service api: invalid stop_grace_period \"soon\"",
+		"service api: config missing_config is not declared",
+		"service api: volume missing_data is not declared",
+		"service api: invalid deploy.restart_policy.window \"1 minute\"",
+		"service api: invalid placement constraint \"node.role=manager\"",
+		"service api: invalid replicas -2: must not be negative",
+		"service web: neither image nor build is set",
+		"service web: network missing-net is not declared",
+		"service web: secret missing_secret is not declared",
+		"service web: invalid healthcheck.interval \"10 seconds\"",
+	}
+	msg := err.Error()
+	for _, w := range want {
+		if !strings.Contains(msg, w) {
+			t.Errorf("Expected error to contain %q, got:\n%s", w, msg)
+		}
+	}
+	if lines := strings.Split(msg, "\n"); len(lines) != len(want) {
+		t.Errorf("Expected %d errors, got %d:\n%s", len(want), len(lines), msg)
+	}
+
+	// Services are reported in name order
+	if !strings.HasPrefix(msg, "service api:") {
+		t.Errorf("Expected api errors first, got:\n%s", msg)
+	}
+}
+
+func TestValidate_ValidFile(t *testing.T) {
+	composeFile := parseLintCompose(t, `
+services:
+  web:
+    image: nginx:1.25
+    networks:
+      default: {}
+      frontend:
+        aliases: [www]
+    configs: [nginx_conf]
+    volumes:
+      - type: volume
+        source: html
+        target: /usr/share/nginx/html
+      - type: volume
+        target: /tmp/anonymous
+    deploy:
+      placement:
+        constraints: ["node.role == worker"]
+  builder:
+    build:
+      context: ./app
+networks:
+  frontend: {}
+volumes:
+  html: {}
+configs:
+  nginx_conf:
+    file: ./nginx.conf
+`)
+
+	if err := Validate(composeFile); err != nil {
+		t.Errorf("Expected a valid compose file, got: %v", err)
+	}
+}
+
+func TestValidateNetworks(t *testing.T) {
+	composeFile := &ComposeFile{
+		Services: map[string]*Service{
+			"web":    {Image: "nginx:1.25", Networks: map[string]interface{}{"traefik": nil, "default": nil}},
+			"api":    {Image: "api:1.0", Networks: []interface{}{"backend", "cache"}},
+			"worker": {Image: "worker:1.0"},
+		},
+		Networks: map[string]*Network{
+			"traefik": {External: true},
+			"backend": {},
+		},
+	}
+
+	err := ValidateNetworks(composeFile)
+	if err == nil || err.Error() != "service api: network cache is not declared in the top-level networks" {
+		t.Errorf("Expected only the undeclared cache network to be reported, got %v", err)
+	}
+
+	if !UsesDefaultNetwork(composeFile.Services) {
+		t.Error("Expected worker and web to use the default network")
+	}
+	delete(composeFile.Services, "worker")
+	delete(composeFile.Services, "web")
+	if UsesDefaultNetwork(composeFile.Services) {
+		t.Error("Expected api not to use the default network")
+	}
+}
@@ -0,0 +1,186 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-units"
+
+	"github.com/SomeBlackMagic/stackman/internal/paths"
+)
+
+// convertLongVolume converts a long-syntax volume entry (type, source, target,
+// read_only and the bind/volume/tmpfs option blocks).
+// Relative bind sources are resolved like the short form; named volume sources
+// are scoped to the stack like the volumes created for it.
+func convertLongVolume(v map[string]interface{}, resolver *paths.Resolver, stackName string) (mount.Mount, error) {
+	var m mount.Mount
+
+	target, _ := v["target"].(string)
+	if target == "" {
+		return m, fmt.Errorf("volume entry is missing target")
+	}
+	m.Target = target
+
+	source, _ := v["source"].(string)
+	readOnly, err := boolOption(v, "read_only")
+	if err != nil {
+		return m, fmt.Errorf("volume %s: %w", target, err)
+	}
+	m.ReadOnly = readOnly
+
+	volumeType, _ := v["type"].(string)
+	if volumeType == "" {
+		volumeType = string(mount.TypeVolume)
+	}
+
+	switch mount.Type(volumeType) {
+	case mount.TypeBind:
+		if source == "" {
+			return m, fmt.Errorf("bind mount %s is missing source", target)
+		}
+		m.Type = mount.TypeBind
+		m.Source = resolver.Resolve(source)
+
+		if bind, ok := v["bind"].(map[string]interface{}); ok {
+			if propagation, ok := bind["propagation"].(string); ok && propagation != "" {
+				m.BindOptions = &mount.BindOptions{Propagation: mount.Propagation(propagation)}
+			}
+		}
+
+	case mount.TypeVolume:
+		m.Type = mount.TypeVolume
+		if source != "" {
+			m.Source = fmt.Sprintf("%s_%s", stackName, source)
+		}
+
+		if vol, ok := v["volume"].(map[string]interface{}); ok {
+			nocopy, err := boolOption(vol, "nocopy")
+			if err != nil {
+				return m, fmt.Errorf("volume %s: %w", target, err)
+			}
+			subpath, _ := vol["subpath"].(string)
+			if nocopy || subpath != "" {
+				m.VolumeOptions = &mount.VolumeOptions{NoCopy: nocopy, Subpath: subpath}
+			}
+		}
+
+	case mount.TypeTmpfs:
+		if source != "" {
+			return m, fmt.Errorf("tmpfs mount %s does not take a source", target)
+		}
+		m.Type = mount.TypeTmpfs
+
+		if tmpfs, ok := v["tmpfs"].(map[string]interface{}); ok {
+			opts, err := tmpfsOptions(tmpfs)
+			if err != nil {
+				return m, fmt.Errorf("tmpfs mount %s: %w", target, err)
+			}
+			m.TmpfsOptions = opts
+		}
+
+	default:
+		return m, fmt.Errorf("volume %s: unsupported type %q (supported: bind, volume, tmpfs)", target, volumeType)
+	}
+
+	return m, nil
+}
+
+// tmpfsOptions parses the size (bytes or a human size such as "64m") and octal mode of a tmpfs mount
+func tmpfsOptions(v map[string]interface{}) (*mount.TmpfsOptions, error) {
+	opts := &mount.TmpfsOptions{}
+
+	switch size := v["size"].(type) {
+	case nil:
+	case int:
+		opts.SizeBytes = int64(size)
+	case string:
+		bytes, err := units.RAMInBytes(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q: %w", size, err)
+		}
+		opts.SizeBytes = bytes
+	default:
+		return nil, fmt.Errorf("invalid size %v", size)
+	}
+
+	if mode, ok := v["mode"]; ok {
+		parsed, err := strconv.ParseUint(fmt.Sprintf("%v", mode), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode %v: %w", mode, err)
+		}
+		opts.Mode = os.FileMode(parsed)
+	}
+
+	return opts, nil
+}
+
+// convertTmpfs converts the service-level tmpfs field (a path or a list of paths)
+// into tmpfs mounts. A path may carry docker run style options after a colon,
+// e.g. "/run:size=64m,mode=1777,noexec"; size and mode are parsed, other options
+// are passed through to the mount.
+func convertTmpfs(tmpfs interface{}) ([]mount.Mount, error) {
+	entries, err := convertToStringSlice(tmpfs)
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []mount.Mount
+	for _, entry := range entries {
+		target, optsStr, hasOpts := strings.Cut(entry, ":")
+		if target == "" {
+			return nil, fmt.Errorf("tmpfs entry %q is missing a path", entry)
+		}
+
+		m := mount.Mount{Type: mount.TypeTmpfs, Target: target}
+		if hasOpts && optsStr != "" {
+			opts := &mount.TmpfsOptions{}
+			for _, opt := range strings.Split(optsStr, ",") {
+				key, value, _ := strings.Cut(opt, "=")
+				switch key {
+				case "size":
+					size, err := units.RAMInBytes(value)
+					if err != nil {
+						return nil, fmt.Errorf("tmpfs %s: invalid size %q: %w", target, value, err)
+					}
+					opts.SizeBytes = size
+				case "mode":
+					mode, err := strconv.ParseUint(value, 8, 32)
+					if err != nil {
+						return nil, fmt.Errorf("tmpfs %s: invalid mode %q: %w", target, value, err)
+					}
+					opts.Mode = os.FileMode(mode)
+				case "ro":
+					m.ReadOnly = true
+				case "rw", "":
+				default:
+					if strings.Contains(opt, "=") {
+						opts.Options = append(opts.Options, []string{key, value})
+					} else {
+						opts.Options = append(opts.Options, []string{key})
+					}
+				}
+			}
+			m.TmpfsOptions = opts
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	return mounts, nil
+}
+
+// boolOption reads an optional boolean field
+func boolOption(v map[string]interface{}, key string) (bool, error) {
+	switch b := v[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	default:
+		return false, fmt.Errorf("%s must be a boolean, got %v", key, b)
+	}
+}
@@ -0,0 +1,182 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+func parseServiceVolumes(t *testing.T, volumes string) []mount.Mount {
+	t.Helper()
+	data := "services:\n  app:\n    image: alpine:3.20\n    volumes:\n" + volumes
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+	mounts, err := convertVolumes(composeFile.Services["app"].Volumes, "mystack", "")
+	if err != nil {
+		t.Fatalf("convertVolumes failed: %v", err)
+	}
+	return mounts
+}
+
+func TestConvertVolumes_LongFormBind(t *testing.T) {
+	t.Setenv("STACKMAN_WORKDIR", "/project")
+
+	mounts := parseServiceVolumes(t, `
+      - type: bind
+        source: ./config
+        target: /etc/app
+        read_only: true
+        bind:
+          propagation: rslave
+`)
+	if len(mounts) != 1 {
+		t.Fatalf("Expected 1 mount, got %d", len(mounts))
+	}
+	m := mounts[0]
+	if m.Type != mount.TypeBind || m.Source != "/project/config" || m.Target != "/etc/app" || !m.ReadOnly {
+		t.Errorf("Unexpected bind mount: %+v", m)
+	}
+	if m.BindOptions == nil || m.BindOptions.Propagation != mount.PropagationRSlave {
+		t.Errorf("Expected rslave propagation, got %+v", m.BindOptions)
+	}
+}
+
+func TestConvertVolumes_LongFormNamedVolume(t *testing.T) {
+	mounts := parseServiceVolumes(t, `
+      - type: volume
+        source: data
+        target: /var/lib/data
+        volume:
+          nocopy: true
+      - type: volume
+        target: /scratch
+`)
+	if len(mounts) != 2 {
+		t.Fatalf("Expected 2 mounts, got %d", len(mounts))
+	}
+	named := mounts[0]
+	if named.Type != mount.TypeVolume || named.Source != "mystack_data" || named.Target != "/var/lib/data" || named.ReadOnly {
+		t.Errorf("Unexpected volume mount: %+v", named)
+	}
+	if named.VolumeOptions == nil || !named.VolumeOptions.NoCopy {
+		t.Errorf("Expected nocopy volume option, got %+v", named.VolumeOptions)
+	}
+
+	// Without a source the volume is anonymous
+	if anon := mounts[1]; anon.Type != mount.TypeVolume || anon.Source != "" || anon.VolumeOptions != nil {
+		t.Errorf("Unexpected anonymous volume: %+v", anon)
+	}
+}
+
+func TestConvertVolumes_LongFormTmpfs(t *testing.T) {
+	mounts := parseServiceVolumes(t, `
+      - type: tmpfs
+        target: /tmp
+        tmpfs:
+          size: 64m
+          mode: 1777
+      - type: tmpfs
+        target: /run
+        tmpfs:
+          size: 1048576
+`)
+	if len(mounts) != 2 {
+		t.Fatalf("Expected 2 mounts, got %d", len(mounts))
+	}
+	if m := mounts[0]; m.Type != mount.TypeTmpfs || m.Target != "/tmp" || m.TmpfsOptions == nil ||
+		m.TmpfsOptions.SizeBytes != 64*1024*1024 || m.TmpfsOptions.Mode != 01777 {
+		t.Errorf("Unexpected tmpfs mount: %+v %+v", m, m.TmpfsOptions)
+	}
+	if m := mounts[1]; m.TmpfsOptions == nil || m.TmpfsOptions.SizeBytes != 1048576 {
+		t.Errorf("Expected byte size to be used as-is, got %+v", m.TmpfsOptions)
+	}
+}
+
+func TestConvertVolumes_LongFormErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry map[string]interface{}
+		want  string
+	}{
+		{"missing target", map[string]interface{}{"type": "bind", "source": "/x"}, "missing target"},
+		{"bind without source", map[string]interface{}{"type": "bind", "target": "/y"}, "missing source"},
+		{"tmpfs with source", map[string]interface{}{"type": "tmpfs", "source": "x", "target": "/y"}, "does not take a source"},
+		{"unknown type", map[string]interface{}{"type": "npipe", "target": "/y"}, "unsupported type"},
+		{"bad read_only", map[string]interface{}{"type": "bind", "source": "/x", "target": "/y", "read_only": "yes"}, "read_only must be a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convertVolumes([]interface{}{tt.entry}, "mystack", "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestConvertTmpfs(t *testing.T) {
+	tests := []struct {
+		name  string
+		tmpfs interface{}
+		want  []mount.Mount
+	}{
+		{
+			name:  "bare path",
+			tmpfs: "/tmp",
+			want:  []mount.Mount{{Type: mount.TypeTmpfs, Target: "/tmp"}},
+		},
+		{
+			name:  "options suffix",
+			tmpfs: []interface{}{"/run:size=64m,mode=1777", "/cache:noexec,uid=1000"},
+			want: []mount.Mount{
+				{Type: mount.TypeTmpfs, Target: "/run", TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 64 * 1024 * 1024, Mode: 01777}},
+				{Type: mount.TypeTmpfs, Target: "/cache", TmpfsOptions: &mount.TmpfsOptions{Options: [][]string{{"noexec"}, {"uid", "1000"}}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertTmpfs(tt.tmpfs)
+			if err != nil {
+				t.Fatalf("convertTmpfs failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertTmpfs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertTmpfs_InvalidSize(t *testing.T) {
+	if _, err := convertTmpfs("/run:size=lots"); err == nil {
+		t.Error("Expected error for invalid tmpfs size")
+	}
+}
+
+func TestConvertToSwarmSpec_TmpfsAppendedToMounts(t *testing.T) {
+	service := &Service{
+		Image:   "alpine:3.20",
+		Volumes: []interface{}{"/srv/data:/data"},
+		Tmpfs:   "/run:size=64m",
+	}
+	spec, err := ConvertToSwarmSpec("app", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	mounts := spec.TaskTemplate.ContainerSpec.Mounts
+	if len(mounts) != 2 || mounts[1].Type != mount.TypeTmpfs || mounts[1].Target != "/run" {
+		t.Errorf("Expected bind mount followed by tmpfs mount, got %+v", mounts)
+	}
+}
@@ -0,0 +1,81 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServiceWarnings returns warnings for compose options that Swarm cannot apply
+// to the given service. They are reported but never block conversion.
+func ServiceWarnings(serviceName string, service *Service) []string {
+	var warnings []string
+
+	// Swarm's ContainerSpec has no CPU pinning, so cpuset is dropped
+	if service.Cpuset != "" {
+		warnings = append(warnings, fmt.Sprintf("service %s: cpuset %q is not supported by Docker Swarm and will be ignored", serviceName, service.Cpuset))
+	}
+
+	// Swarm services have no block I/O weight or throttling
+	if settings := blkioSettings(service.BlkioConfig); len(settings) > 0 {
+		warnings = append(warnings, fmt.Sprintf("service %s: blkio_config (%s) is not supported by Docker Swarm and will be ignored", serviceName, strings.Join(settings, ", ")))
+	}
+
+	// Swarm tasks always run with the node's default runtime; runc is that default
+	if service.Runtime != "" && service.Runtime != "runc" {
+		warnings = append(warnings, fmt.Sprintf("service %s: runtime %q is not supported by Docker Swarm and will be ignored (set default-runtime in daemon.json on the target nodes instead)", serviceName, service.Runtime))
+	}
+
+	// Swarm allocates task addresses from the network's IPAM pool; a fixed address cannot be requested
+	if addresses := staticNetworkAddresses(service.Networks); len(addresses) > 0 {
+		warnings = append(warnings, fmt.Sprintf("service %s: static network addresses (%s) are not supported by Docker Swarm and will be ignored; use aliases to give the service stable names", serviceName, strings.Join(addresses, ", ")))
+	}
+
+	// Swarm publishes ports on every interface; a host IP cannot be honoured
+	for _, p := range service.Ports {
+		if spec, ok := p.(string); ok {
+			if hostIP := portHostIP(spec); hostIP != "" {
+				warnings = append(warnings, fmt.Sprintf("service %s: port %q binds host IP %s, which Docker Swarm ignores (published on all interfaces)", serviceName, spec, hostIP))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// MemoryLimitWarning returns a warning when the service sets no deploy.resources.limits.memory,
+// or "" when it does. A task without a memory limit can exhaust its node's memory.
+func MemoryLimitWarning(serviceName string, service *Service) string {
+	if service.Deploy != nil && service.Deploy.Resources != nil &&
+		service.Deploy.Resources.Limits != nil && service.Deploy.Resources.Limits.Memory != "" {
+		return ""
+	}
+	return fmt.Sprintf("service %s: no deploy.resources.limits.memory set; a memory leak can exhaust the node", serviceName)
+}
+
+// blkioSettings lists the block I/O settings present in a blkio_config block
+func blkioSettings(blkio *BlkioConfig) []string {
+	if blkio == nil {
+		return nil
+	}
+
+	var settings []string
+	if blkio.Weight != 0 {
+		settings = append(settings, "weight")
+	}
+	if len(blkio.WeightDevice) > 0 {
+		settings = append(settings, "weight_device")
+	}
+	if len(blkio.DeviceReadBps) > 0 {
+		settings = append(settings, "device_read_bps")
+	}
+	if len(blkio.DeviceReadIOps) > 0 {
+		settings = append(settings, "device_read_iops")
+	}
+	if len(blkio.DeviceWriteBps) > 0 {
+		settings = append(settings, "device_write_bps")
+	}
+	if len(blkio.DeviceWriteIOps) > 0 {
+		settings = append(settings, "device_write_iops")
+	}
+	return settings
+}
@@ -0,0 +1,103 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServiceWarnings_Cpuset(t *testing.T) {
+	service := &Service{Image: "nginx:1.25", Cpuset: "0,1"}
+
+	warnings := ServiceWarnings("web", service)
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "cpuset") || !strings.Contains(warnings[0], `"0,1"`) {
+		t.Errorf("Unexpected warning: %s", warnings[0])
+	}
+
+	// The warning must not stop conversion
+	if _, err := ConvertToSwarmSpec("web", service, "mystack", ""); err != nil {
+		t.Errorf("Expected conversion to succeed, got: %v", err)
+	}
+
+	if warnings := ServiceWarnings("web", &Service{Image: "nginx:1.25"}); len(warnings) != 0 {
+		t.Errorf("Expected no warnings without cpuset, got %v", warnings)
+	}
+}
+
+func TestServiceWarnings_BlkioConfig(t *testing.T) {
+	data := `
+services:
+  db:
+    image: postgres:16
+    blkio_config:
+      weight: 300
+      device_read_bps:
+        - path: /dev/sda
+          rate: '12mb'
+      device_write_iops:
+        - path: /dev/sda
+          rate: 120
+`
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	warnings := ServiceWarnings("db", composeFile.Services["db"])
+	want := "service db: blkio_config (weight, device_read_bps, device_write_iops) is not supported by Docker Swarm and will be ignored"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("Expected warning %q, got %v", want, warnings)
+	}
+
+	if warnings := ServiceWarnings("db", &Service{Image: "postgres:16", BlkioConfig: &BlkioConfig{}}); len(warnings) != 0 {
+		t.Errorf("Expected no warnings for an empty blkio_config, got %v", warnings)
+	}
+}
+
+func TestServiceWarnings_Runtime(t *testing.T) {
+	data := `
+services:
+  gpu:
+    image: nvidia/cuda:12.4.0-base-ubuntu22.04
+    runtime: nvidia
+`
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	composeFile, err := ParseComposeFile(path)
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	service := composeFile.Services["gpu"]
+	if service.Runtime != "nvidia" {
+		t.Fatalf("Expected runtime nvidia, got %q", service.Runtime)
+	}
+
+	warnings := ServiceWarnings("gpu", service)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `runtime "nvidia"`) {
+		t.Errorf("Expected a runtime warning, got %v", warnings)
+	}
+
+	// The runtime has no ContainerSpec field; conversion must still succeed
+	spec, err := ConvertToSwarmSpec("gpu", service, "mystack", "")
+	if err != nil {
+		t.Fatalf("Expected conversion to succeed, got: %v", err)
+	}
+	if spec.TaskTemplate.ContainerSpec.Image != service.Image {
+		t.Errorf("Expected image %s, got %s", service.Image, spec.TaskTemplate.ContainerSpec.Image)
+	}
+
+	if warnings := ServiceWarnings("gpu", &Service{Image: "nginx:1.25", Runtime: "runc"}); len(warnings) != 0 {
+		t.Errorf("Expected no warnings for the default runc runtime, got %v", warnings)
+	}
+}
@@ -0,0 +1,13 @@
+package health
+
+import "regexp"
+
+// ansiEscape matches terminal escape sequences: CSI sequences such as colors
+// (ESC [ ... m), OSC sequences such as hyperlinks and titles (ESC ] ... BEL or
+// ESC \), and two-byte escapes
+var ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// StripANSI removes terminal escape sequences from a log line
+func StripANSI(line string) string {
+	return ansiEscape.ReplaceAllString(line, "")
+}
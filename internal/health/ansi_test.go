@@ -0,0 +1,28 @@
+package health
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"plain", "GET /healthz 200\n", "GET /healthz 200\n"},
+		{"color", "\x1b[32mINFO\x1b[0m server started\n", "INFO server started\n"},
+		{"bold and 256 colors", "\x1b[1;38;5;196mERROR\x1b[m: boom", "ERROR: boom"},
+		{"cursor movement", "\x1b[2K\x1b[1Gprogress 50%", "progress 50%"},
+		{"hyperlink", "see \x1b]8;;https://example.com\x1b\\docs\x1b]8;;\x1b\\ for help", "see docs for help"},
+		{"title with bell", "\x1b]0;worker\x07ready", "ready"},
+		{"two-byte escape", "\x1bMline", "line"},
+		{"keeps other control characters", "col1\tcol2\r\n", "col1\tcol2\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripANSI(tt.line); got != tt.want {
+				t.Errorf("StripANSI(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
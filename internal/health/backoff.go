@@ -0,0 +1,62 @@
+package health
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Defaults for polling task health: tight at first for fast-starting services,
+// then backing off so large stacks don't inspect every container every few seconds
+const (
+	DefaultPollInitial = 500 * time.Millisecond
+	DefaultPollMax     = 10 * time.Second
+	DefaultPollFactor  = 2.0
+	DefaultPollJitter  = 0.1
+)
+
+// PollBackoff yields exponentially growing poll delays, capped at Max.
+// Each delay is randomized by up to ±Jitter of its value so concurrent
+// pollers don't inspect in lockstep.
+type PollBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+	Jitter  float64
+
+	current time.Duration
+	random  func() float64 // Returns values in [0, 1)
+}
+
+// NewPollBackoff returns a backoff from initial to max using the default factor and jitter
+func NewPollBackoff(initial, max time.Duration) *PollBackoff {
+	return &PollBackoff{
+		Initial: initial,
+		Max:     max,
+		Factor:  DefaultPollFactor,
+		Jitter:  DefaultPollJitter,
+		random:  rand.Float64,
+	}
+}
+
+// Next returns the delay before the next poll and advances the sequence
+func (b *PollBackoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+	} else {
+		b.current = time.Duration(float64(b.current) * b.Factor)
+	}
+	if b.current > b.Max {
+		b.current = b.Max
+	}
+
+	delay := b.current
+	if b.Jitter > 0 && b.random != nil {
+		delay += time.Duration((b.random()*2 - 1) * b.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// Reset starts the sequence over from Initial
+func (b *PollBackoff) Reset() {
+	b.current = 0
+}
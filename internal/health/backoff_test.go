@@ -0,0 +1,59 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollBackoff_Sequence(t *testing.T) {
+	b := NewPollBackoff(500*time.Millisecond, 10*time.Second)
+	b.Jitter = 0
+
+	want := []time.Duration{
+		500 * time.Millisecond,
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+	for i, w := range want {
+		if got := b.Next(); got != w {
+			t.Errorf("Next() #%d = %v, want %v", i+1, got, w)
+		}
+	}
+
+	b.Reset()
+	if got := b.Next(); got != 500*time.Millisecond {
+		t.Errorf("Next() after Reset = %v, want 500ms", got)
+	}
+}
+
+func TestPollBackoff_Jitter(t *testing.T) {
+	tests := []struct {
+		random float64
+		want   time.Duration
+	}{
+		{0, 900 * time.Millisecond},
+		{0.5, time.Second},
+		{0.75, 1050 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		b := NewPollBackoff(time.Second, 10*time.Second)
+		b.random = func() float64 { return tt.random }
+		if got := b.Next(); got != tt.want {
+			t.Errorf("Next() with random %v = %v, want %v", tt.random, got, tt.want)
+		}
+	}
+
+	// Jitter never pushes the delay outside ±10% of the capped value
+	b := NewPollBackoff(time.Second, 2*time.Second)
+	for i := 0; i < 50; i++ {
+		got := b.Next()
+		if i > 0 && (got < 1800*time.Millisecond || got > 2200*time.Millisecond) {
+			t.Fatalf("Next() #%d = %v, outside the jitter range of the 2s ceiling", i+1, got)
+		}
+	}
+}
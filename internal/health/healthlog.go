@@ -0,0 +1,58 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Default health check output limits
+const (
+	DefaultHealthLogLines = 5
+	DefaultHealthLogChars = 100
+)
+
+// HealthLogLimits bounds how much health check output is logged
+type HealthLogLimits struct {
+	Lines int // Lines kept from a failed check's output (0 = unlimited)
+	Chars int // Characters kept from a passing check's output (0 = unlimited)
+}
+
+// DefaultHealthLogLimits returns the default health check output limits
+func DefaultHealthLogLimits() HealthLogLimits {
+	return HealthLogLimits{Lines: DefaultHealthLogLines, Chars: DefaultHealthLogChars}
+}
+
+// LastHealthCheck returns the most recent health check result, or nil
+func LastHealthCheck(state *container.Health) *container.HealthcheckResult {
+	if state == nil || len(state.Log) == 0 {
+		return nil
+	}
+	return state.Log[len(state.Log)-1]
+}
+
+// FormatHealthLog returns the output of a health check result truncated to limits.
+// Failed checks keep their first limits.Lines lines; passing checks are shown on
+// one line cut to limits.Chars characters.
+func FormatHealthLog(result *container.HealthcheckResult, limits HealthLogLimits) string {
+	output := strings.TrimRight(result.Output, "\n")
+	if output == "" {
+		return ""
+	}
+
+	if result.ExitCode != 0 {
+		lines := strings.Split(output, "\n")
+		if limits.Lines > 0 && len(lines) > limits.Lines {
+			omitted := len(lines) - limits.Lines
+			lines = append(lines[:limits.Lines], fmt.Sprintf("... (%d more lines)", omitted))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	output = strings.Join(strings.Fields(output), " ")
+	if runes := []rune(output); limits.Chars > 0 && len(runes) > limits.Chars {
+		output = string(runes[:limits.Chars]) + "..."
+	}
+	return output
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestFormatHealthLog(t *testing.T) {
+	failedOutput := "line1\nline2\nline3\nline4\nline5\nline6\nline7\n"
+	passedOutput := strings.Repeat("a", 150)
+
+	tests := []struct {
+		name     string
+		result   container.HealthcheckResult
+		limits   HealthLogLimits
+		expected string
+	}{
+		{
+			name:     "failed check uses default line limit",
+			result:   container.HealthcheckResult{ExitCode: 1, Output: failedOutput},
+			limits:   DefaultHealthLogLimits(),
+			expected: "line1\nline2\nline3\nline4\nline5\n... (2 more lines)",
+		},
+		{
+			name:     "failed check uses configured line limit",
+			result:   container.HealthcheckResult{ExitCode: 1, Output: failedOutput},
+			limits:   HealthLogLimits{Lines: 2, Chars: 10},
+			expected: "line1\nline2\n... (5 more lines)",
+		},
+		{
+			name:     "failed check without line limit",
+			result:   container.HealthcheckResult{ExitCode: 1, Output: failedOutput},
+			limits:   HealthLogLimits{},
+			expected: strings.TrimRight(failedOutput, "\n"),
+		},
+		{
+			name:     "passing check uses default char limit",
+			result:   container.HealthcheckResult{Output: passedOutput},
+			limits:   DefaultHealthLogLimits(),
+			expected: strings.Repeat("a", 100) + "...",
+		},
+		{
+			name:     "passing check uses configured char limit",
+			result:   container.HealthcheckResult{Output: "ok\nready to serve"},
+			limits:   HealthLogLimits{Lines: 1, Chars: 8},
+			expected: "ok ready...",
+		},
+		{
+			name:     "passing check without char limit",
+			result:   container.HealthcheckResult{Output: passedOutput},
+			limits:   HealthLogLimits{},
+			expected: passedOutput,
+		},
+		{
+			name:     "empty output",
+			result:   container.HealthcheckResult{ExitCode: 1, Output: "\n"},
+			limits:   DefaultHealthLogLimits(),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			if got := FormatHealthLog(&result, tt.limits); got != tt.expected {
+				t.Errorf("FormatHealthLog() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLastHealthCheck(t *testing.T) {
+	if LastHealthCheck(nil) != nil {
+		t.Error("Expected nil for a container without health state")
+	}
+	if LastHealthCheck(&container.Health{}) != nil {
+		t.Error("Expected nil for an empty health log")
+	}
+
+	last := &container.HealthcheckResult{ExitCode: 1}
+	state := &container.Health{Log: []*container.HealthcheckResult{{ExitCode: 0}, last}}
+	if LastHealthCheck(state) != last {
+		t.Error("Expected the most recent health check result")
+	}
+}
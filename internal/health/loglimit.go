@@ -0,0 +1,78 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// logLimitWindow is the period MaxLinesPerSecond is counted over
+const logLimitWindow = time.Second
+
+// LogLimits bounds the log output of a single container (zero = unlimited)
+type LogLimits struct {
+	MaxLineLength     int // bytes of a line kept before it is truncated with an ellipsis
+	MaxLinesPerSecond int // lines printed per second, the rest are dropped
+}
+
+// LogLimiter applies LogLimits to the log lines of one container, so a container
+// flooding its logs cannot drown the output of the others. Dropped lines are
+// reported by a "[N lines dropped]" notice once per second while the flood lasts.
+type LogLimiter struct {
+	limits      LogLimits
+	windowStart time.Time
+	lines       int
+	dropped     int
+}
+
+// NewLogLimiter returns a limiter for limits, or nil when there are none
+func NewLogLimiter(limits LogLimits) *LogLimiter {
+	if limits.MaxLineLength <= 0 && limits.MaxLinesPerSecond <= 0 {
+		return nil
+	}
+	return &LogLimiter{limits: limits}
+}
+
+// Filter reports whether line should be printed at now, and returns it truncated
+// to the maximum line length. When a new second starts after lines were dropped,
+// notice tells how many.
+func (l *LogLimiter) Filter(line string, now time.Time) (notice, out string, keep bool) {
+	if l.limits.MaxLinesPerSecond > 0 {
+		if l.windowStart.IsZero() || now.Sub(l.windowStart) >= logLimitWindow {
+			notice = l.Flush()
+			l.windowStart = now
+			l.lines = 0
+		}
+		if l.lines >= l.limits.MaxLinesPerSecond {
+			l.dropped++
+			return notice, "", false
+		}
+		l.lines++
+	}
+	return notice, truncateLine(line, l.limits.MaxLineLength), true
+}
+
+// Flush returns the notice for lines dropped since the last one, if any
+func (l *LogLimiter) Flush() string {
+	if l.dropped == 0 {
+		return ""
+	}
+	notice := fmt.Sprintf("[%d lines dropped]", l.dropped)
+	l.dropped = 0
+	return notice
+}
+
+// truncateLine shortens line to max bytes, not counting its line ending, without
+// splitting a UTF-8 character, and marks the cut with an ellipsis
+func truncateLine(line string, max int) string {
+	text := strings.TrimRight(line, "\r\n")
+	if max <= 0 || len(text) <= max {
+		return line
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…" + line[len(text):]
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		max  int
+		want string
+	}{
+		{"unlimited", "hello world\n", 0, "hello world\n"},
+		{"short line", "hello\n", 10, "hello\n"},
+		{"newline not counted", "hello\n", 5, "hello\n"},
+		{"truncated", "hello world\n", 5, "hello…\n"},
+		{"crlf kept", "hello world\r\n", 5, "hello…\r\n"},
+		{"no newline", "hello world", 5, "hello…"},
+		{"utf-8 boundary", "héllo\n", 2, "h…\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateLine(tt.line, tt.max); got != tt.want {
+				t.Errorf("truncateLine(%q, %d) = %q, want %q", tt.line, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLogLimiter_Unlimited(t *testing.T) {
+	if l := NewLogLimiter(LogLimits{}); l != nil {
+		t.Errorf("Expected no limiter without limits, got %+v", l)
+	}
+}
+
+func TestLogLimiter_DropsExcessLines(t *testing.T) {
+	l := NewLogLimiter(LogLimits{MaxLineLength: 4, MaxLinesPerSecond: 2})
+	start := time.Unix(1700000000, 0)
+
+	type step struct {
+		at     time.Duration
+		line   string
+		notice string
+		out    string
+		keep   bool
+	}
+	steps := []step{
+		{0, "first line\n", "", "firs…\n", true},
+		{100 * time.Millisecond, "two\n", "", "two\n", true},
+		{200 * time.Millisecond, "three\n", "", "", false},
+		{900 * time.Millisecond, "four\n", "", "", false},
+		{time.Second, "five\n", "[2 lines dropped]", "five\n", true},
+		{1100 * time.Millisecond, "six\n", "", "six\n", true},
+		{1200 * time.Millisecond, "seven\n", "", "", false},
+		{3 * time.Second, "eight\n", "[1 lines dropped]", "eigh…\n", true},
+	}
+	for i, s := range steps {
+		notice, out, keep := l.Filter(s.line, start.Add(s.at))
+		if notice != s.notice || out != s.out || keep != s.keep {
+			t.Errorf("step %d: Filter(%q) = %q, %q, %v, want %q, %q, %v", i, s.line, notice, out, keep, s.notice, s.out, s.keep)
+		}
+	}
+
+	if notice := l.Flush(); notice != "" {
+		t.Errorf("Expected no pending notice, got %q", notice)
+	}
+	l.Filter("nine\n", start.Add(3100*time.Millisecond))
+	l.Filter("ten\n", start.Add(3200*time.Millisecond))
+	if notice := l.Flush(); notice != "[1 lines dropped]" {
+		t.Errorf("Flush() = %q, want the dropped line notice", notice)
+	}
+}
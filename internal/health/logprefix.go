@@ -0,0 +1,132 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultLogPrefixTemplate is the prefix printed before each streamed container log line
+const DefaultLogPrefixTemplate = "{{.Icon}} [{{.Source}}]"
+
+// LogPrefixData is the data available to a log prefix template
+type LogPrefixData struct {
+	Service string // Full service name (stack_service)
+	Stream  string // stdout or stderr
+	Task    string // Short task ID
+	TaskID  string // Full task ID
+	Slot    string // Replica slot of the task (empty for global services or when unknown)
+	Source  string // Service, slot and short task ID, e.g. mystack_web.2.a1b2c3d4e5f6
+	Icon    string // 📘 for stdout, 📕 for stderr
+}
+
+// logSource identifies the replica that produced a log line: service.slot.task,
+// or service.task when the slot is unknown
+func logSource(service, slot, task string) string {
+	if slot == "" {
+		return service + "." + task
+	}
+	return service + "." + slot + "." + task
+}
+
+// containerSlot extracts the replica slot from a swarm container name
+// ("/<service>.<slot>.<task id>"). Global service containers carry a node ID
+// instead of a slot, for which it returns "".
+func containerSlot(containerName, serviceName string) string {
+	name := strings.TrimPrefix(containerName, "/")
+	rest, ok := strings.CutPrefix(name, serviceName+".")
+	if !ok {
+		return ""
+	}
+	slot, _, ok := strings.Cut(rest, ".")
+	if !ok {
+		return ""
+	}
+	if _, err := strconv.Atoi(slot); err != nil {
+		return ""
+	}
+	return slot
+}
+
+// LineCollapser suppresses a log line identical to the one printed just before it.
+// Replicas of a service often print the same lines, so one collapser is shared by
+// all task monitors of a service. It is safe for concurrent use.
+type LineCollapser struct {
+	mu      sync.Mutex
+	last    string
+	repeats int
+}
+
+// Filter reports whether line should be printed. When a new line ends a run of
+// suppressed duplicates, notice tells how many were dropped.
+func (c *LineCollapser) Filter(stream, line string) (notice string, keep bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := stream + "\x00" + strings.TrimRight(line, "\r\n")
+	if key == c.last {
+		c.repeats++
+		return "", false
+	}
+
+	if c.repeats > 0 {
+		notice = fmt.Sprintf("previous line repeated %d more time(s)", c.repeats)
+	}
+	c.last = key
+	c.repeats = 0
+	return notice, true
+}
+
+// LogPrefix renders the prefix of streamed container log lines
+type LogPrefix struct {
+	tmpl *template.Template
+}
+
+var defaultLogPrefix = MustParseLogPrefix(DefaultLogPrefixTemplate)
+
+// ParseLogPrefix parses a Go template using the fields of LogPrefixData
+func ParseLogPrefix(text string) (*LogPrefix, error) {
+	tmpl, err := template.New("log-prefix").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log prefix template: %w", err)
+	}
+
+	// Render once so unknown fields are reported up front, not on the first log line
+	p := &LogPrefix{tmpl: tmpl}
+	if _, err := p.render(LogPrefixData{}); err != nil {
+		return nil, fmt.Errorf("invalid log prefix template: %w", err)
+	}
+	return p, nil
+}
+
+// MustParseLogPrefix is like ParseLogPrefix but panics on error
+func MustParseLogPrefix(text string) *LogPrefix {
+	p, err := ParseLogPrefix(text)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Format renders the prefix, falling back to the default template if rendering fails
+func (p *LogPrefix) Format(data LogPrefixData) string {
+	if p == nil {
+		p = defaultLogPrefix
+	}
+	prefix, err := p.render(data)
+	if err != nil {
+		prefix, _ = defaultLogPrefix.render(data)
+	}
+	return prefix
+}
+
+func (p *LogPrefix) render(data LogPrefixData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,95 @@
+package health
+
+import "testing"
+
+func TestLogStreamer_Format_DefaultPrefix(t *testing.T) {
+	s := NewLogStreamer("mystack_web", "abcdef1234567890", "", StreamOptions{})
+
+	if got, want := s.format("stdout", "hello"), "📘 [mystack_web.abcdef123456] hello\n"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+	if got, want := s.format("stderr", "oops\n"), "📕 [mystack_web.abcdef123456] oops\n"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+}
+
+func TestLogStreamer_Format_CustomTemplate(t *testing.T) {
+	prefix, err := ParseLogPrefix("{{.Service}}|{{.Stream}}|{{.Task}}:")
+	if err != nil {
+		t.Fatalf("ParseLogPrefix failed: %v", err)
+	}
+
+	s := NewLogStreamer("mystack_web", "abcdef1234567890", "", StreamOptions{Prefix: prefix})
+
+	if got, want := s.format("stderr", "boom"), "mystack_web|stderr|abcdef123456: boom\n"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+}
+
+func TestParseLogPrefix_Invalid(t *testing.T) {
+	for _, text := range []string{"{{.Service", "{{.Replica}}"} {
+		if _, err := ParseLogPrefix(text); err == nil {
+			t.Errorf("Expected ParseLogPrefix(%q) to fail", text)
+		}
+	}
+}
+
+func TestLogStreamer_Format_ReplicaSource(t *testing.T) {
+	s := NewLogStreamer("mystack_web", "abcdef1234567890", "2", StreamOptions{})
+
+	if got, want := s.format("stdout", "hello"), "📘 [mystack_web.2.abcdef123456] hello\n"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+
+	s = NewLogStreamer("mystack_web", "abcdef1234567890", "2", StreamOptions{Prefix: MustParseLogPrefix("{{.Slot}}:")})
+	if got, want := s.format("stdout", "hello"), "2: hello\n"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+}
+
+func TestLogSource(t *testing.T) {
+	tests := []struct {
+		containerName string
+		want          string
+	}{
+		{"/mystack_web.2.abcdef1234567890xyz", "mystack_web.2.abcdef123456"},
+		{"mystack_web.10.abcdef1234567890xyz", "mystack_web.10.abcdef123456"},
+		// Global services carry a node ID instead of a slot
+		{"/mystack_web.n0d3id1234567890abcdefghi.abcdef1234567890xyz", "mystack_web.abcdef123456"},
+		// Not a swarm container name
+		{"/hopeful_turing", "mystack_web.abcdef123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.containerName, func(t *testing.T) {
+			slot := containerSlot(tt.containerName, "mystack_web")
+			if got := logSource("mystack_web", slot, "abcdef123456"); got != tt.want {
+				t.Errorf("logSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineCollapser(t *testing.T) {
+	c := &LineCollapser{}
+
+	type step struct {
+		stream, line string
+		notice       string
+		keep         bool
+	}
+	steps := []step{
+		{"stdout", "starting\n", "", true},
+		{"stdout", "starting\n", "", false},
+		{"stdout", "starting", "", false},
+		{"stderr", "starting\n", "previous line repeated 2 more time(s)", true},
+		{"stdout", "ready\n", "", true},
+		{"stdout", "starting\n", "", true},
+	}
+	for i, s := range steps {
+		notice, keep := c.Filter(s.stream, s.line)
+		if notice != s.notice || keep != s.keep {
+			t.Errorf("step %d: Filter(%q, %q) = %q, %v, want %q, %v", i, s.stream, s.line, notice, keep, s.notice, s.keep)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package health
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// StreamOptions controls how streamed container log lines are filtered and printed
+type StreamOptions struct {
+	Handler    LogHandler     // receives the lines instead of Output (nil = print them)
+	Output     io.Writer      // where lines are printed, one Write per line (nil = stdout)
+	Prefix     *LogPrefix     // prefix of printed lines (nil = DefaultLogPrefixTemplate)
+	Collapser  *LineCollapser // drops identical consecutive lines (nil = print all)
+	StripColor bool           // remove ANSI escape sequences from the lines
+	Limits     LogLimits      // bounds the line length and rate (zero = unlimited)
+}
+
+// LogStreamer prints the log stream of one task's container line by line, as
+// StreamOptions asks. Apply's task monitors and the logs command both use it.
+type LogStreamer struct {
+	opts       StreamOptions
+	limiter    *LogLimiter
+	service    string
+	taskID     string
+	slot       string
+	lastStream string
+}
+
+// NewLogStreamer creates a streamer for the container of a task; slot is the
+// task's replica slot, empty for global services or when unknown
+func NewLogStreamer(service, taskID, slot string, opts StreamOptions) *LogStreamer {
+	return &LogStreamer{
+		opts:       opts,
+		limiter:    NewLogLimiter(opts.Limits),
+		service:    service,
+		taskID:     taskID,
+		slot:       slot,
+		lastStream: "stdout",
+	}
+}
+
+// Stream prints the lines read from r until it ends. Unless the container has
+// a TTY, r carries stdout and stderr multiplexed as the Docker API sends them.
+// A last line without a newline is printed when the stream ends.
+func (s *LogStreamer) Stream(r io.Reader, tty bool) error {
+	stdout := &lineWriter{emit: func(line string) { s.line("stdout", line) }}
+	stderr := &lineWriter{emit: func(line string) { s.line("stderr", line) }}
+
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, r)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, r)
+	}
+	stdout.Flush()
+	stderr.Flush()
+
+	// Report lines dropped just before the stream ended
+	if s.limiter != nil {
+		if notice := s.limiter.Flush(); notice != "" {
+			s.notice(s.lastStream, notice)
+		}
+	}
+	return err
+}
+
+// line filters one container log line and prints what is left of it
+func (s *LogStreamer) line(stream, text string) {
+	s.lastStream = stream
+	if s.opts.StripColor {
+		text = StripANSI(text)
+	}
+
+	if s.limiter != nil {
+		notice, out, keep := s.limiter.Filter(text, time.Now())
+		if notice != "" {
+			s.notice(stream, notice)
+		}
+		if !keep {
+			return
+		}
+		text = out
+	}
+
+	if s.opts.Collapser != nil {
+		notice, keep := s.opts.Collapser.Filter(stream, text)
+		if !keep {
+			return
+		}
+		if notice != "" {
+			s.notice(stream, notice)
+		}
+	}
+
+	s.write(stream, text)
+}
+
+// notice writes a line produced by stackman itself where the container's lines go
+func (s *LogStreamer) notice(stream, text string) {
+	s.write(stream, text+"\n")
+}
+
+func (s *LogStreamer) write(stream, line string) {
+	if s.opts.Handler != nil {
+		s.opts.Handler(s.service, s.taskID, stream, line)
+		return
+	}
+	out := s.opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = io.WriteString(out, s.format(stream, line))
+}
+
+// format prefixes a container log line and makes sure it ends with a newline
+func (s *LogStreamer) format(stream, line string) string {
+	icon := "📘"
+	if stream == "stderr" {
+		icon = "📕"
+	}
+
+	task := s.taskID
+	if len(task) > 12 {
+		task = task[:12]
+	}
+
+	prefix := s.opts.Prefix.Format(LogPrefixData{
+		Service: s.service,
+		Stream:  stream,
+		Task:    task,
+		TaskID:  s.taskID,
+		Slot:    s.slot,
+		Source:  logSource(s.service, s.slot, task),
+		Icon:    icon,
+	})
+
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line += "\n"
+	}
+	return prefix + " " + line
+}
+
+// lineWriter splits written data into lines, newline included
+type lineWriter struct {
+	emit func(line string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		w.emit(string(w.buf[:i+1]))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush emits a trailing line that has no newline yet
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestLogStreamer_Stream(t *testing.T) {
+	var logs bytes.Buffer
+	stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte("\x1b[32mready\x1b[0m\nfirst half "))
+	stdcopy.NewStdWriter(&logs, stdcopy.Stderr).Write([]byte("a very long warning\n"))
+	stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte("second half\nno newline"))
+
+	var out bytes.Buffer
+	s := NewLogStreamer("web", "abcdef1234567890", "1", StreamOptions{
+		Output:     &out,
+		Prefix:     MustParseLogPrefix("{{.Source}} {{.Stream}} |"),
+		StripColor: true,
+		Limits:     LogLimits{MaxLineLength: 11},
+	})
+	if err := s.Stream(&logs, false); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"web.1.abcdef123456 stdout | ready",
+		"web.1.abcdef123456 stderr | a very long…",
+		"web.1.abcdef123456 stdout | first half …",
+		"web.1.abcdef123456 stdout | no newline",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestLogStreamer_StreamTTY(t *testing.T) {
+	type line struct{ stream, text string }
+	var got []line
+	s := NewLogStreamer("web", "abcdef1234567890", "", StreamOptions{
+		Handler: func(serviceName, taskID, stream, text string) {
+			got = append(got, line{stream, text})
+		},
+	})
+
+	if err := s.Stream(strings.NewReader("one\ntwo\n"), true); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	want := []line{{"stdout", "one\n"}, {"stdout", "two\n"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
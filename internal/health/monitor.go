@@ -9,8 +9,13 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+
+	"github.com/SomeBlackMagic/stackman/internal/throttle"
 )
 
+// LogHandler receives container log lines in place of printing them to stdout
+type LogHandler func(serviceName, taskID, stream, line string)
+
 // Monitor monitors a single task's lifecycle, health, and logs
 // It automatically cleans up goroutines when task reaches terminal state
 type Monitor struct {
@@ -33,7 +38,10 @@ type Monitor struct {
 	failedChecks int    // number of failed health checks
 
 	// Configuration
-	showLogs bool // whether to stream container logs
+	showLogs   bool          // whether to stream container logs
+	showEvents bool          // whether to log task lifecycle events
+	logOptions StreamOptions // how streamed container log lines are filtered and printed
+	slot       string        // replica slot, from the container name
 
 	// Channels for coordination
 	eventChan chan Event    // receives events for this task
@@ -65,6 +73,7 @@ func NewMonitorWithLogs(client client.APIClient, taskID string, serviceID string
 		serviceID:    serviceID,
 		serviceName:  serviceName,
 		showLogs:     showLogs,
+		showEvents:   true,
 		eventChan:    make(chan Event, 10),
 		stopChan:     make(chan struct{}),
 		doneChan:     make(chan struct{}),
@@ -75,6 +84,41 @@ func NewMonitorWithLogs(client client.APIClient, taskID string, serviceID string
 	}
 }
 
+// SetLogOptions replaces how streamed container logs are filtered and printed
+func (m *Monitor) SetLogOptions(opts StreamOptions) {
+	m.logOptions = opts
+}
+
+// SetLogHandler routes streamed container logs to h instead of stdout
+func (m *Monitor) SetLogHandler(h LogHandler) {
+	m.logOptions.Handler = h
+}
+
+// SetLogCollapser collapses identical consecutive log lines through c (nil = disabled)
+func (m *Monitor) SetLogCollapser(c *LineCollapser) {
+	m.logOptions.Collapser = c
+}
+
+// SetStripColor removes ANSI escape sequences from streamed log lines
+func (m *Monitor) SetStripColor(strip bool) {
+	m.logOptions.StripColor = strip
+}
+
+// SetLogLimits bounds the line length and rate of the streamed container logs
+func (m *Monitor) SetLogLimits(limits LogLimits) {
+	m.logOptions.Limits = limits
+}
+
+// SetShowEvents enables or disables logging of task lifecycle events
+func (m *Monitor) SetShowEvents(show bool) {
+	m.showEvents = show
+}
+
+// SetLogPrefix sets the template used to prefix printed container log lines
+func (m *Monitor) SetLogPrefix(p *LogPrefix) {
+	m.logOptions.Prefix = p
+}
+
 // Start begins monitoring the task
 // This method blocks until task reaches terminal state or context is cancelled
 func (m *Monitor) Start(ctx context.Context) error {
@@ -209,6 +253,10 @@ func (m *Monitor) handleEvent(event Event) {
 		}
 	}
 
+	if !m.showEvents {
+		return
+	}
+
 	// Log important events
 	switch event.Type {
 	case EventTypeCreated:
@@ -261,7 +309,7 @@ func (m *Monitor) checkHealth(ctx context.Context) {
 	}
 
 	// Inspect container to get health status
-	containerInfo, err := m.client.ContainerInspect(ctx, containerID)
+	containerInfo, err := throttle.ContainerInspect(ctx, m.client, containerID)
 	if err != nil {
 		// Container might not exist anymore
 		return
@@ -312,8 +360,28 @@ func (m *Monitor) streamLogs(ctx context.Context) {
 		time.Sleep(100 * time.Millisecond)
 	}
 
+	// The container name carries the replica slot used in the log prefix
+	tty := false
+	if info, err := throttle.ContainerInspect(ctx, m.client, containerID); err == nil {
+		m.mu.Lock()
+		m.slot = containerSlot(info.Name, m.serviceName)
+		m.mu.Unlock()
+		tty = info.Config != nil && info.Config.Tty
+	}
+
 	log.Printf("[TaskLogs] About to start streaming logs for %s/%s (container: %s)", m.serviceName, m.shortTaskID(), containerID[:12])
 
+	// Closing the log stream is the only way to interrupt a blocked read
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	// Start streaming logs - get ALL logs, not just from now
 	options := container.LogsOptions{
 		ShowStdout: true,
@@ -332,76 +400,13 @@ func (m *Monitor) streamLogs(ctx context.Context) {
 
 	log.Printf("[TaskLogs] Successfully opened log stream for %s/%s, starting to read...", m.serviceName, m.shortTaskID())
 
-	// Docker multiplexes stdout/stderr in a special format
-	// Header: [8]byte{STREAM_TYPE, 0, 0, 0, SIZE1, SIZE2, SIZE3, SIZE4}
-	// STREAM_TYPE: 0=stdin, 1=stdout, 2=stderr
-	// SIZE: uint32 big-endian, size of frame
-
-	buf := make([]byte, 8192)
-	logsReceived := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-m.stopChan:
-			return
-		default:
-		}
-
-		// Read header (8 bytes)
-		header := make([]byte, 8)
-		n, err := logReader.Read(header)
-		if err != nil {
-			if err.Error() != "EOF" && err.Error() != "context canceled" {
-				log.Printf("[TaskLogs] Log stream error for %s: %v", m.shortTaskID(), err)
-			}
-			return
-		}
-		if n != 8 {
-			if n > 0 {
-				log.Printf("[TaskLogs] Unexpected header size for %s: %d bytes", m.shortTaskID(), n)
-			}
-			continue
-		}
-
-		// Parse frame size from header
-		frameSize := uint32(header[4])<<24 | uint32(header[5])<<16 | uint32(header[6])<<8 | uint32(header[7])
-		if frameSize == 0 {
-			continue
-		}
-
-		// Read frame data
-		if frameSize > uint32(len(buf)) {
-			buf = make([]byte, frameSize)
-		}
-
-		n, err = logReader.Read(buf[:frameSize])
-		if err != nil {
-			return
-		}
-
-		// Output log line with service/task prefix
-		streamPrefix := "📄"
-		if header[0] == 1 {
-			streamPrefix = "📘" // stdout
-		} else if header[0] == 2 {
-			streamPrefix = "📕" // stderr
-		}
-
-		logLine := string(buf[:n])
-		logsReceived++
-
-		// Use fmt.Printf to output directly to stdout (not via logger)
-		fmt.Printf("%s [%s/%s] %s", streamPrefix, m.serviceName, m.shortTaskID(), logLine)
-		if len(logLine) > 0 && logLine[len(logLine)-1] != '\n' {
-			fmt.Println() // Add newline if not present
-		}
+	m.mu.RLock()
+	slot := m.slot
+	m.mu.RUnlock()
 
-		// Log every 10 lines to show we're receiving data
-		if logsReceived%10 == 1 {
-			//TODO debug log
-			//log.Printf("[TaskLogs] Received %d log lines from %s/%s", logsReceived, m.serviceName, m.shortTaskID())
-		}
+	streamer := NewLogStreamer(m.serviceName, m.taskID, slot, m.logOptions)
+	if err := streamer.Stream(logReader, tty); err != nil && ctx.Err() == nil {
+		log.Printf("[TaskLogs] Log stream error for %s: %v", m.shortTaskID(), err)
 	}
 }
 
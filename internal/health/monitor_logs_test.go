@@ -1,7 +1,16 @@
 package health
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
 	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 func TestMonitor_LogStreaming(t *testing.T) {
@@ -31,3 +40,81 @@ func TestMonitor_LogStreaming(t *testing.T) {
 		t.Errorf("shortTaskID should be <= 12 chars, got %d", len(shortID))
 	}
 }
+
+// logsClient serves multiplexed container logs through the Docker API
+type logsClient struct {
+	client.APIClient
+	logs []byte
+}
+
+func (c *logsClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(c.logs)), nil
+}
+
+func (c *logsClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/mystack_web.2.abcdef123456"}}, nil
+}
+
+func TestMonitor_StreamLogsWithoutDockerCLI(t *testing.T) {
+	// Logs come from the API, so no docker binary is needed on PATH
+	t.Setenv("PATH", "")
+	if _, err := exec.LookPath("docker"); err == nil {
+		t.Fatal("Expected docker to be unavailable with an empty PATH")
+	}
+
+	var logs bytes.Buffer
+	stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte("listening on :80\n"))
+	stdcopy.NewStdWriter(&logs, stdcopy.Stderr).Write([]byte("warning: no config\n"))
+
+	m := NewMonitorWithLogs(&logsClient{logs: logs.Bytes()}, "task1234567890", "svc1", "mystack_web", true)
+	m.containerID = "container1234567890"
+
+	type line struct{ stream, text string }
+	var got []line
+	m.SetLogHandler(func(serviceName, taskID, stream, text string) {
+		got = append(got, line{stream, text})
+	})
+
+	m.streamLogs(context.Background())
+
+	want := []line{{"stdout", "listening on :80\n"}, {"stderr", "warning: no config\n"}}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d log lines, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if m.slot != "2" {
+		t.Errorf("Expected slot 2 from the container name, got %q", m.slot)
+	}
+}
+
+func TestMonitor_CollapsedLinesNoticeGoesToLogHandler(t *testing.T) {
+	var logs bytes.Buffer
+	for _, text := range []string{"retrying\n", "retrying\n", "retrying\n", "connected\n"} {
+		stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte(text))
+	}
+
+	m := NewMonitorWithLogs(&logsClient{logs: logs.Bytes()}, "task1234567890", "svc1", "mystack_web", true)
+	m.containerID = "container1234567890"
+	m.SetLogCollapser(&LineCollapser{})
+
+	var got []string
+	m.SetLogHandler(func(serviceName, taskID, stream, text string) {
+		got = append(got, text)
+	})
+
+	m.streamLogs(context.Background())
+
+	want := []string{"retrying\n", "previous line repeated 2 more time(s)\n", "connected\n"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d log lines, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
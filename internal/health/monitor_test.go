@@ -1,7 +1,11 @@
 package health
 
 import (
+	"bytes"
 	"context"
+	"log"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -116,6 +120,30 @@ func TestMonitor_HandleEvent(t *testing.T) {
 	}
 }
 
+func TestMonitor_HandleEvent_EventsDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	monitor := NewMonitor(nil, "task123", "service456", "mystack_web")
+	monitor.SetShowEvents(false)
+	monitor.handleEvent(Event{Type: EventTypeHealthy, TaskID: "task123"})
+
+	// State is still tracked, only the lifecycle log line is suppressed
+	if monitor.healthStatus != "healthy" {
+		t.Errorf("Expected health status 'healthy', got '%s'", monitor.healthStatus)
+	}
+	if strings.Contains(buf.String(), "is healthy") {
+		t.Errorf("Expected no lifecycle output, got %q", buf.String())
+	}
+
+	monitor.SetShowEvents(true)
+	monitor.handleEvent(Event{Type: EventTypeHealthy, TaskID: "task123"})
+	if !strings.Contains(buf.String(), "is healthy") {
+		t.Errorf("Expected lifecycle output once enabled, got %q", buf.String())
+	}
+}
+
 func TestMonitor_GetState(t *testing.T) {
 	monitor := NewMonitor(nil, "task123", "service456", "mystack_web")
 
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// pendingStates are the task states between scheduling and running, in lifecycle order
+var pendingStates = []swarm.TaskState{
+	swarm.TaskStateNew,
+	swarm.TaskStatePending,
+	swarm.TaskStateAssigned,
+	swarm.TaskStateAccepted,
+	swarm.TaskStateReady,
+	swarm.TaskStatePreparing,
+	swarm.TaskStateStarting,
+}
+
+// SummarizePendingTasks counts tasks that should be running but are still in a
+// pre-running state, and reports how long the oldest of each has been there.
+// It returns "" when no task is pending, e.g. "2 preparing (1m30s), 1 assigned (5s)".
+func SummarizePendingTasks(tasks []swarm.Task, now time.Time) string {
+	counts := make(map[swarm.TaskState]int)
+	longest := make(map[swarm.TaskState]time.Duration)
+
+	for _, task := range tasks {
+		if task.DesiredState != swarm.TaskStateRunning {
+			continue
+		}
+		state := task.Status.State
+		counts[state]++
+
+		// Status.Timestamp is when the task entered its current state
+		if !task.Status.Timestamp.IsZero() {
+			if age := now.Sub(task.Status.Timestamp); age > longest[state] {
+				longest[state] = age
+			}
+		}
+	}
+
+	var parts []string
+	// Report the states furthest along first: those are the ones that look stuck
+	for i := len(pendingStates) - 1; i >= 0; i-- {
+		state := pendingStates[i]
+		if counts[state] == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (%s)", counts[state], state, longest[state].Round(time.Second)))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// imagePullErrors are fragments of the task errors Swarm reports when a node
+// cannot pull or find the service image
+var imagePullErrors = []string{
+	"no such image",
+	"pull access denied",
+	"manifest unknown",
+	"repository does not exist",
+	"failed to resolve reference",
+	"error pulling image",
+}
+
+// ImagePullFailure returns the reason a failed or rejected task could not get
+// its image, or "" when the task did not fail on the image pull
+func ImagePullFailure(task swarm.Task) string {
+	switch task.Status.State {
+	case swarm.TaskStateFailed, swarm.TaskStateRejected:
+	default:
+		return ""
+	}
+
+	for _, reason := range []string{task.Status.Err, task.Status.Message} {
+		lower := strings.ToLower(reason)
+		for _, fragment := range imagePullErrors {
+			if strings.Contains(lower, fragment) {
+				return reason
+			}
+		}
+	}
+	return ""
+}
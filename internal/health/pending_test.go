@@ -0,0 +1,83 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func pendingTask(state swarm.TaskState, since time.Time) swarm.Task {
+	return swarm.Task{
+		DesiredState: swarm.TaskStateRunning,
+		Status:       swarm.TaskStatus{State: state, Timestamp: since},
+	}
+}
+
+func TestSummarizePendingTasks(t *testing.T) {
+	now := time.Now()
+
+	tasks := []swarm.Task{
+		pendingTask(swarm.TaskStatePreparing, now.Add(-90*time.Second)),
+		pendingTask(swarm.TaskStatePreparing, now.Add(-10*time.Second)),
+		pendingTask(swarm.TaskStateAssigned, now.Add(-5*time.Second)),
+		pendingTask(swarm.TaskStateRunning, now.Add(-time.Minute)),
+		// Tasks being shut down are not pending
+		{DesiredState: swarm.TaskStateShutdown, Status: swarm.TaskStatus{State: swarm.TaskStatePreparing}},
+	}
+
+	got := SummarizePendingTasks(tasks, now)
+	want := "2 preparing (1m30s), 1 assigned (5s)"
+	if got != want {
+		t.Errorf("SummarizePendingTasks() = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizePendingTasks_NonePending(t *testing.T) {
+	tasks := []swarm.Task{pendingTask(swarm.TaskStateRunning, time.Now())}
+
+	if got := SummarizePendingTasks(tasks, time.Now()); got != "" {
+		t.Errorf("Expected empty summary, got %q", got)
+	}
+}
+
+func TestImagePullFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status swarm.TaskStatus
+		want   string
+	}{
+		{
+			name:   "missing tag",
+			status: swarm.TaskStatus{State: swarm.TaskStateRejected, Err: "No such image: nginx:does-not-exist"},
+			want:   "No such image: nginx:does-not-exist",
+		},
+		{
+			name:   "private repository",
+			status: swarm.TaskStatus{State: swarm.TaskStateFailed, Err: "pull access denied for acme/app, repository does not exist or may require 'docker login'"},
+			want:   "pull access denied for acme/app, repository does not exist or may require 'docker login'",
+		},
+		{
+			name:   "reason in message",
+			status: swarm.TaskStatus{State: swarm.TaskStateRejected, Message: "manifest unknown: manifest unknown"},
+			want:   "manifest unknown: manifest unknown",
+		},
+		{
+			name:   "crashing container",
+			status: swarm.TaskStatus{State: swarm.TaskStateFailed, Err: "task: non-zero exit (1)"},
+		},
+		{
+			name:   "still preparing",
+			status: swarm.TaskStatus{State: swarm.TaskStatePreparing, Err: "No such image: stale"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := swarm.Task{DesiredState: swarm.TaskStateRunning, Status: tt.status}
+			if got := ImagePullFailure(task); got != tt.want {
+				t.Errorf("ImagePullFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
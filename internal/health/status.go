@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// TaskLister is the subset of the Docker client needed to compute service status
+type TaskLister interface {
+	TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error)
+}
+
+// ServiceStatus is a point-in-time summary of a service and its current tasks
+type ServiceStatus struct {
+	ServiceID    string       `json:"serviceId"`
+	ServiceName  string       `json:"serviceName"`
+	Image        string       `json:"image"`
+	Mode         string       `json:"mode"`
+	DesiredTasks int          `json:"desiredTasks"`
+	RunningTasks int          `json:"runningTasks"`
+	Pending      string       `json:"pending,omitempty"`
+	Tasks        []TaskStatus `json:"tasks"`
+}
+
+// TaskStatus describes the most recent task of a replica slot (or node, for global services)
+type TaskStatus struct {
+	ID           string    `json:"id"`
+	Slot         int       `json:"slot,omitempty"`
+	NodeID       string    `json:"nodeId,omitempty"`
+	State        string    `json:"state"`
+	DesiredState string    `json:"desiredState"`
+	Message      string    `json:"message,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// IsFailed reports whether the task ended in failed or rejected state
+func (t TaskStatus) IsFailed() bool {
+	return t.State == string(swarm.TaskStateFailed) || t.State == string(swarm.TaskStateRejected)
+}
+
+// FailedTasks returns the tasks in failed or rejected state
+func (s *ServiceStatus) FailedTasks() []TaskStatus {
+	var failed []TaskStatus
+	for _, task := range s.Tasks {
+		if task.IsFailed() {
+			failed = append(failed, task)
+		}
+	}
+	return failed
+}
+
+// GetServiceStatus lists the tasks of a service and summarizes replicas and task states.
+// Only the newest task of each slot is reported, so old failures that were
+// already replaced by a healthy task don't show up.
+func GetServiceStatus(ctx context.Context, cli TaskLister, service swarm.Service) (*ServiceStatus, error) {
+	tasks, err := cli.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("service", service.ID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks for service %s: %w", service.Spec.Name, err)
+	}
+
+	status := &ServiceStatus{
+		ServiceID:   service.ID,
+		ServiceName: service.Spec.Name,
+		Mode:        "replicated",
+	}
+	if service.Spec.TaskTemplate.ContainerSpec != nil {
+		status.Image = service.Spec.TaskTemplate.ContainerSpec.Image
+	}
+
+	// Keep the newest task per slot (replicated) or node (global)
+	latest := make(map[string]swarm.Task)
+	for _, task := range tasks {
+		key := task.NodeID
+		if task.Slot != 0 {
+			key = strconv.Itoa(task.Slot)
+		}
+		if prev, ok := latest[key]; !ok || task.Meta.CreatedAt.After(prev.Meta.CreatedAt) {
+			latest[key] = task
+		}
+	}
+
+	current := make([]swarm.Task, 0, len(latest))
+	for _, task := range latest {
+		current = append(current, task)
+	}
+	status.Pending = SummarizePendingTasks(current, time.Now())
+
+	globalDesired := 0
+	for _, task := range latest {
+		if task.DesiredState == swarm.TaskStateRunning {
+			globalDesired++
+			if task.Status.State == swarm.TaskStateRunning {
+				status.RunningTasks++
+			}
+		}
+
+		status.Tasks = append(status.Tasks, TaskStatus{
+			ID:           task.ID,
+			Slot:         task.Slot,
+			NodeID:       task.NodeID,
+			State:        string(task.Status.State),
+			DesiredState: string(task.DesiredState),
+			Message:      task.Status.Message,
+			Error:        task.Status.Err,
+			UpdatedAt:    task.Meta.UpdatedAt,
+		})
+	}
+
+	sort.Slice(status.Tasks, func(i, j int) bool {
+		if status.Tasks[i].Slot != status.Tasks[j].Slot {
+			return status.Tasks[i].Slot < status.Tasks[j].Slot
+		}
+		return status.Tasks[i].NodeID < status.Tasks[j].NodeID
+	})
+
+	switch {
+	case service.Spec.Mode.Global != nil:
+		status.Mode = "global"
+		status.DesiredTasks = globalDesired
+	case service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil:
+		status.DesiredTasks = int(*service.Spec.Mode.Replicated.Replicas)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+type fakeTaskLister struct {
+	tasks []swarm.Task
+}
+
+func (f *fakeTaskLister) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	return f.tasks, nil
+}
+
+func newTask(id string, slot int, created time.Time, state, desired swarm.TaskState, errMsg string) swarm.Task {
+	return swarm.Task{
+		ID:           id,
+		Meta:         swarm.Meta{CreatedAt: created, UpdatedAt: created},
+		Slot:         slot,
+		DesiredState: desired,
+		Status:       swarm.TaskStatus{State: state, Err: errMsg},
+	}
+}
+
+func TestGetServiceStatus_Replicated(t *testing.T) {
+	now := time.Now()
+	replicas := uint64(3)
+	service := swarm.Service{
+		ID: "svc1",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "mystack_web"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.25"},
+			},
+			Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+		},
+	}
+
+	lister := &fakeTaskLister{tasks: []swarm.Task{
+		// Slot 1: old failure replaced by a running task
+		newTask("t1-old", 1, now.Add(-time.Minute), swarm.TaskStateFailed, swarm.TaskStateShutdown, "exit 1"),
+		newTask("t1", 1, now, swarm.TaskStateRunning, swarm.TaskStateRunning, ""),
+		newTask("t2", 2, now, swarm.TaskStateRunning, swarm.TaskStateRunning, ""),
+		// Slot 3: currently rejected
+		newTask("t3", 3, now, swarm.TaskStateRejected, swarm.TaskStateRunning, "no suitable node"),
+	}}
+
+	status, err := GetServiceStatus(context.Background(), lister, service)
+	if err != nil {
+		t.Fatalf("GetServiceStatus failed: %v", err)
+	}
+
+	if status.Image != "nginx:1.25" {
+		t.Errorf("Expected image nginx:1.25, got %s", status.Image)
+	}
+	if status.DesiredTasks != 3 || status.RunningTasks != 2 {
+		t.Errorf("Expected 2/3 running, got %d/%d", status.RunningTasks, status.DesiredTasks)
+	}
+	if len(status.Tasks) != 3 {
+		t.Fatalf("Expected 3 tasks (newest per slot), got %d", len(status.Tasks))
+	}
+
+	failed := status.FailedTasks()
+	if len(failed) != 1 || failed[0].ID != "t3" || failed[0].Error != "no suitable node" {
+		t.Errorf("Expected only t3 to be reported as failed, got %+v", failed)
+	}
+}
+
+func TestGetServiceStatus_Global(t *testing.T) {
+	now := time.Now()
+	service := swarm.Service{
+		ID: "svc2",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "mystack_agent"},
+			Mode:        swarm.ServiceMode{Global: &swarm.GlobalService{}},
+		},
+	}
+
+	nodeA := newTask("a", 0, now, swarm.TaskStateRunning, swarm.TaskStateRunning, "")
+	nodeA.NodeID = "node-a"
+	nodeB := newTask("b", 0, now, swarm.TaskStatePreparing, swarm.TaskStateRunning, "")
+	nodeB.NodeID = "node-b"
+
+	status, err := GetServiceStatus(context.Background(), &fakeTaskLister{tasks: []swarm.Task{nodeA, nodeB}}, service)
+	if err != nil {
+		t.Fatalf("GetServiceStatus failed: %v", err)
+	}
+
+	if status.Mode != "global" {
+		t.Errorf("Expected mode global, got %s", status.Mode)
+	}
+	if status.DesiredTasks != 2 || status.RunningTasks != 1 {
+		t.Errorf("Expected 1/2 running, got %d/%d", status.RunningTasks, status.DesiredTasks)
+	}
+	// The preparing task on node-b is reported separately from running ones
+	if !strings.HasPrefix(status.Pending, "1 preparing (") {
+		t.Errorf("Expected 1 preparing task in pending summary, got %q", status.Pending)
+	}
+}
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthTimeoutLabel overrides the health wait timeout for a single service
+const HealthTimeoutLabel = "stackman.health_timeout"
+
+// ParseHealthTimeout reads the health timeout override from service labels.
+// Returns 0 when the label is not set.
+func ParseHealthTimeout(labels map[string]string) (time.Duration, error) {
+	value, ok := labels[HealthTimeoutLabel]
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", HealthTimeoutLabel, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", HealthTimeoutLabel, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,42 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHealthTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "not set", labels: map[string]string{"other": "1m"}, want: 0},
+		{name: "nil labels", labels: nil, want: 0},
+		{name: "minutes", labels: map[string]string{HealthTimeoutLabel: "15m"}, want: 15 * time.Minute},
+		{name: "compound", labels: map[string]string{HealthTimeoutLabel: "1m30s"}, want: 90 * time.Second},
+		{name: "missing unit", labels: map[string]string{HealthTimeoutLabel: "120"}, wantErr: true},
+		{name: "garbage", labels: map[string]string{HealthTimeoutLabel: "soon"}, wantErr: true},
+		{name: "zero", labels: map[string]string{HealthTimeoutLabel: "0s"}, wantErr: true},
+		{name: "negative", labels: map[string]string{HealthTimeoutLabel: "-5m"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHealthTimeout(tt.labels)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -63,6 +63,12 @@ type taskState struct {
 	lastSeen     time.Time
 }
 
+// Backoff between reconnect attempts when the Docker event stream drops
+var (
+	eventReconnectBackoff    = time.Second
+	maxEventReconnectBackoff = 30 * time.Second
+)
+
 // NewWatcher creates a new task event watcher for entire stack
 func NewWatcher(client client.APIClient, stackName string) *Watcher {
 	return &Watcher{
@@ -123,27 +129,63 @@ func (w *Watcher) Start(ctx context.Context) error {
 	eventFilter.Add("type", "service")
 	eventFilter.Add("type", "container")
 
-	eventsChan, errChan := w.client.Events(ctx, events.ListOptions{
-		Filters: eventFilter,
-	})
-
 	log.Printf("[TaskWatcher] Started watching events for stack: %s", w.stackName)
 
+	// A dropped stream (daemon restart, network blip) is re-subscribed with the
+	// same filters. Task state lives on the watcher, so it survives reconnects.
+	backoff := eventReconnectBackoff
 	for {
+		received, err := w.consumeEvents(ctx, eventFilter)
+		if ctx.Err() != nil {
+			log.Printf("[TaskWatcher] Context cancelled, stopping watcher")
+			w.shutdown()
+			return ctx.Err()
+		}
+
+		if received {
+			backoff = eventReconnectBackoff
+		}
+		log.Printf("[TaskWatcher] Event stream dropped: %v; reconnecting in %v", err, backoff)
+
 		select {
 		case <-ctx.Done():
 			log.Printf("[TaskWatcher] Context cancelled, stopping watcher")
 			w.shutdown()
 			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxEventReconnectBackoff {
+			backoff = maxEventReconnectBackoff
+		}
+	}
+}
+
+// consumeEvents subscribes to Docker events and handles them until the stream
+// fails or ctx is done. It reports whether any event was received.
+func (w *Watcher) consumeEvents(ctx context.Context, eventFilter filters.Args) (bool, error) {
+	eventsChan, errChan := w.client.Events(ctx, events.ListOptions{
+		Filters: eventFilter,
+	})
+
+	received := false
+	for {
+		select {
+		case <-ctx.Done():
+			return received, ctx.Err()
 
 		case err := <-errChan:
-			if err != nil {
-				log.Printf("[TaskWatcher] Error receiving events: %v", err)
-				w.shutdown()
-				return err
+			if err == nil {
+				err = fmt.Errorf("event stream closed")
 			}
+			return received, err
 
-		case dockerEvent := <-eventsChan:
+		case dockerEvent, ok := <-eventsChan:
+			if !ok {
+				return received, fmt.Errorf("event stream closed")
+			}
+			received = true
 			w.handleDockerEvent(ctx, dockerEvent)
 		}
 	}
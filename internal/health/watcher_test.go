@@ -2,11 +2,19 @@ package health
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
 )
 
 func TestNewWatcher(t *testing.T) {
@@ -367,3 +375,101 @@ func TestWatcher_HandleContainerHealthEvent(t *testing.T) {
 		t.Error("Timeout waiting for health event")
 	}
 }
+
+// flakyEventsClient fails the first event subscription and serves events on the next one
+type flakyEventsClient struct {
+	client.APIClient
+
+	mu            sync.Mutex
+	subscriptions int
+	filters       []filters.Args
+}
+
+func (c *flakyEventsClient) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	c.mu.Lock()
+	c.subscriptions++
+	attempt := c.subscriptions
+	c.filters = append(c.filters, options.Filters)
+	c.mu.Unlock()
+
+	msgs := make(chan events.Message, 1)
+	errs := make(chan error, 1)
+	if attempt == 1 {
+		errs <- errors.New("unexpected EOF")
+		return msgs, errs
+	}
+	msgs <- events.Message{
+		Type:   "container",
+		Action: "start",
+		Actor: events.Actor{
+			ID: "container1234567890",
+			Attributes: map[string]string{
+				"com.docker.swarm.task.id":      "task1234567890",
+				"com.docker.swarm.service.id":   "service1",
+				"com.docker.swarm.service.name": "teststack_web",
+			},
+		},
+	}
+	return msgs, errs
+}
+
+func (c *flakyEventsClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	return nil, nil
+}
+
+func (c *flakyEventsClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	return nil, nil
+}
+
+func (c *flakyEventsClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	return nil, nil
+}
+
+func TestWatcher_ReconnectsAfterEventStreamError(t *testing.T) {
+	defer func(backoff time.Duration) { eventReconnectBackoff = backoff }(eventReconnectBackoff)
+	eventReconnectBackoff = time.Millisecond
+
+	cli := &flakyEventsClient{}
+	watcher := NewWatcher(cli, "teststack")
+	// State collected before the drop must survive the reconnect
+	watcher.existingTasks["old-task"] = true
+	sub := watcher.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Start(ctx) }()
+
+	select {
+	case event := <-sub:
+		if event.Type != EventTypeStarted || event.TaskID != "task1234567890" {
+			t.Errorf("Unexpected event after reconnect: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event after the stream recovered")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected watcher to stop with context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher did not stop after cancel")
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.subscriptions < 2 {
+		t.Fatalf("Expected a re-subscription, got %d subscription(s)", cli.subscriptions)
+	}
+	got, want := cli.filters[1].Get("type"), cli.filters[0].Get("type")
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the same filters on reconnect, got %v want %v", got, want)
+	}
+	if !watcher.existingTasks["old-task"] {
+		t.Error("Expected existing task state to be preserved")
+	}
+}
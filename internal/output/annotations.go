@@ -0,0 +1,85 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Annotation formats understood by CI systems
+const (
+	AnnotationGitHub = "github" // GitHub Actions workflow commands (::warning file=...::)
+	AnnotationJSON   = "json"   // one JSON object per line, for GitLab code quality and similar
+)
+
+// Annotation levels
+const (
+	AnnotationWarning = "warning"
+	AnnotationError   = "error"
+)
+
+// Annotation is a warning or error tied to a file position
+type Annotation struct {
+	Level   string `json:"level"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Annotator writes annotations in a CI-specific format. It is safe for concurrent use.
+type Annotator struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// NewAnnotator creates an annotator for the given format
+func NewAnnotator(w io.Writer, format string) (*Annotator, error) {
+	switch format {
+	case AnnotationGitHub, AnnotationJSON:
+	default:
+		return nil, fmt.Errorf("unsupported annotation format %q (supported: %s, %s)", format, AnnotationGitHub, AnnotationJSON)
+	}
+	return &Annotator{w: w, format: format}, nil
+}
+
+// Annotate writes a single annotation
+func (a *Annotator) Annotate(an Annotation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.format == AnnotationJSON {
+		json.NewEncoder(a.w).Encode(an)
+		return
+	}
+	fmt.Fprintln(a.w, githubAnnotation(an))
+}
+
+// githubAnnotation formats an annotation as a GitHub Actions workflow command
+func githubAnnotation(an Annotation) string {
+	var props []string
+	if an.File != "" {
+		props = append(props, "file="+escapeGitHubProperty(an.File))
+	}
+	if an.Line > 0 {
+		props = append(props, fmt.Sprintf("line=%d", an.Line))
+	}
+
+	cmd := "::" + an.Level
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	return cmd + "::" + escapeGitHubData(an.Message)
+}
+
+// escapeGitHubData escapes a workflow command message
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a workflow command property value
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAnnotator_GitHub(t *testing.T) {
+	var buf bytes.Buffer
+	a, err := NewAnnotator(&buf, AnnotationGitHub)
+	if err != nil {
+		t.Fatalf("NewAnnotator failed: %v", err)
+	}
+
+	a.Annotate(Annotation{Level: AnnotationWarning, File: "deploy/stack.yml", Line: 12, Message: "service web: 100% cpu\nsecond line"})
+	a.Annotate(Annotation{Level: AnnotationError, File: "C:stack,1.yml", Message: "failed"})
+
+	want := "::warning file=deploy/stack.yml,line=12::service web: 100%25 cpu%0Asecond line\n" +
+		"::error file=C%3Astack%2C1.yml::failed\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\ngot:  %q\nwant: %q", buf.String(), want)
+	}
+}
+
+func TestAnnotator_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	a, err := NewAnnotator(&buf, AnnotationJSON)
+	if err != nil {
+		t.Fatalf("NewAnnotator failed: %v", err)
+	}
+
+	a.Annotate(Annotation{Level: AnnotationWarning, File: "stack.yml", Line: 3, Message: "careful"})
+
+	want := `{"level":"warning","file":"stack.yml","line":3,"message":"careful"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\ngot:  %q\nwant: %q", buf.String(), want)
+	}
+}
+
+func TestNewAnnotator_UnknownFormat(t *testing.T) {
+	if _, err := NewAnnotator(&bytes.Buffer{}, "teamcity"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types emitted during apply
+const (
+	EventPlan          = "plan"           // plan computed
+	EventStackDeployed = "stack_deployed" // all service create/update calls were accepted
+	EventServiceUpdate = "service_update" // service update started, completed or failed
+	EventTaskState     = "task_state"     // task lifecycle transition
+	EventHealth        = "health"         // task health status during the health wait
+	EventProgress      = "progress"       // healthy tasks out of desired tasks, once per health poll
+	EventLog           = "log"            // container log line
+	EventResult        = "result"         // final result
+)
+
+// Event is a single apply lifecycle event.
+// Message is the human-readable line printed by the text sink.
+type Event struct {
+	Time     time.Time   `json:"time"`
+	Type     string      `json:"type"`
+	Stack    string      `json:"stack,omitempty"`
+	Service  string      `json:"service,omitempty"`
+	Task     string      `json:"task,omitempty"`
+	State    string      `json:"state,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	ExitCode *int        `json:"exitCode,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// ResultData is the Data of a result event
+type ResultData struct {
+	DeployID string `json:"deployId,omitempty"`
+}
+
+// Emitter receives apply lifecycle events
+type Emitter interface {
+	Emit(e Event)
+}
+
+// TextEmitter prints event messages through the standard logger,
+// keeping the interactive output unchanged
+type TextEmitter struct{}
+
+// Emit logs the event message
+func (TextEmitter) Emit(e Event) {
+	if e.Message != "" {
+		log.Print(e.Message)
+	}
+}
+
+// JSONEmitter writes one JSON object per line. It is safe for concurrent use.
+type JSONEmitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONEmitter creates an emitter writing newline-delimited JSON to w
+func NewJSONEmitter(w io.Writer) *JSONEmitter {
+	return &JSONEmitter{enc: json.NewEncoder(w)}
+}
+
+// Emit writes the event, stamping the current time when it has none
+func (j *JSONEmitter) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(e); err != nil {
+		log.Printf("WARNING: failed to write event: %v", err)
+	}
+}
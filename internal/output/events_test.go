@@ -0,0 +1,96 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONEmitter_StreamIsParseable(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewJSONEmitter(&buf)
+
+	exitCode := 0
+	emitter.Emit(Event{Type: EventPlan, Stack: "demo", Message: "Plan: 1 to create", Data: map[string]int{"create": 1}})
+	emitter.Emit(Event{Type: EventServiceUpdate, Stack: "demo", Service: "demo_web", State: "started"})
+
+	// Task monitors emit concurrently
+	var wg sync.WaitGroup
+	for _, state := range []string{"task_created", "task_running", "task_healthy"} {
+		wg.Add(1)
+		go func(state string) {
+			defer wg.Done()
+			emitter.Emit(Event{Type: EventTaskState, Service: "demo_web", Task: "abc123", State: state})
+		}(state)
+	}
+	wg.Wait()
+
+	emitter.Emit(Event{Type: EventHealth, Service: "demo_web", Task: "abc123", State: "healthy"})
+	emitter.Emit(Event{Type: EventResult, Stack: "demo", State: "success", ExitCode: &exitCode})
+
+	var events []Event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line is not valid JSON: %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 7 {
+		t.Fatalf("Expected 7 events, got %d", len(events))
+	}
+	if events[0].Type != EventPlan || events[1].Type != EventServiceUpdate {
+		t.Errorf("Unexpected leading events: %+v", events[:2])
+	}
+	for _, e := range events[2:5] {
+		if e.Type != EventTaskState || e.Task != "abc123" {
+			t.Errorf("Expected task state event, got %+v", e)
+		}
+	}
+	for i, e := range events {
+		if e.Time.IsZero() {
+			t.Errorf("event %d has no timestamp", i)
+		}
+	}
+
+	last := events[len(events)-1]
+	if last.Type != EventResult || last.ExitCode == nil || *last.ExitCode != 0 || last.State != "success" {
+		t.Errorf("Unexpected result event: %+v", last)
+	}
+}
+
+func TestJSONEmitter_KeepsExplicitTime(t *testing.T) {
+	var buf bytes.Buffer
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	NewJSONEmitter(&buf).Emit(Event{Type: EventHealth, Time: at})
+
+	if !strings.Contains(buf.String(), `"time":"2025-01-02T03:04:05Z"`) {
+		t.Errorf("Expected explicit time to be kept, got %s", buf.String())
+	}
+}
+
+func TestTextEmitter_LogsMessage(t *testing.T) {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	TextEmitter{}.Emit(Event{Type: EventHealth, Message: "[HealthCheck] ✅ Task abc (web) is healthy"})
+	TextEmitter{}.Emit(Event{Type: EventResult})
+
+	if buf.String() != "[HealthCheck] ✅ Task abc (web) is healthy\n" {
+		t.Errorf("Unexpected text output: %q", buf.String())
+	}
+}
@@ -0,0 +1,184 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC notification methods emitted during apply
+const (
+	MethodDeployProgress = "deploy/progress"
+	MethodServiceLog     = "service/log"
+	MethodDeployError    = "deploy/error"
+	MethodDeployDone     = "deploy/done"
+)
+
+// Notification is a JSON-RPC 2.0 notification (a request without an id)
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// ProgressParams carries a human-readable progress message
+type ProgressParams struct {
+	Message string `json:"message"`
+}
+
+// LogParams carries a single container log line
+type LogParams struct {
+	Service string `json:"service"`
+	Task    string `json:"task"`
+	Stream  string `json:"stream"`
+	Line    string `json:"line"`
+}
+
+// ErrorParams carries a deployment error
+type ErrorParams struct {
+	Message string `json:"message"`
+}
+
+// DoneParams carries the final deployment result
+type DoneParams struct {
+	Stack    string `json:"stack"`
+	DeployID string `json:"deployId,omitempty"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+// JSONRPCWriter writes newline-delimited JSON-RPC notifications.
+// It is safe for concurrent use.
+type JSONRPCWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONRPCWriter creates a writer emitting notifications to w
+func NewJSONRPCWriter(w io.Writer) *JSONRPCWriter {
+	return &JSONRPCWriter{enc: json.NewEncoder(w)}
+}
+
+// Notify emits a single notification
+func (w *JSONRPCWriter) Notify(method string, params interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(Notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// Progress emits a deploy/progress notification
+func (w *JSONRPCWriter) Progress(message string) error {
+	return w.Notify(MethodDeployProgress, ProgressParams{Message: message})
+}
+
+// Log emits a service/log notification
+func (w *JSONRPCWriter) Log(service, task, stream, line string) error {
+	return w.Notify(MethodServiceLog, LogParams{
+		Service: service,
+		Task:    task,
+		Stream:  stream,
+		Line:    strings.TrimRight(line, "\r\n"),
+	})
+}
+
+// Error emits a deploy/error notification
+func (w *JSONRPCWriter) Error(err error) error {
+	return w.Notify(MethodDeployError, ErrorParams{Message: err.Error()})
+}
+
+// Done emits the final deploy/done notification
+func (w *JSONRPCWriter) Done(stack, deployID string, err error) error {
+	params := DoneParams{
+		Stack:    stack,
+		DeployID: deployID,
+		Success:  err == nil,
+	}
+	if err != nil {
+		params.Error = err.Error()
+	}
+	return w.Notify(MethodDeployDone, params)
+}
+
+// JSONRPCEmitter emits apply lifecycle events as JSON-RPC notifications: log
+// events become service/log, the result deploy/error and deploy/done, and other
+// events with a message deploy/progress
+type JSONRPCEmitter struct {
+	rpc *JSONRPCWriter
+}
+
+// NewJSONRPCEmitter creates an emitter writing notifications through rpc
+func NewJSONRPCEmitter(rpc *JSONRPCWriter) *JSONRPCEmitter {
+	return &JSONRPCEmitter{rpc: rpc}
+}
+
+// Emit writes the notification for the event
+func (j *JSONRPCEmitter) Emit(e Event) {
+	var err error
+	switch e.Type {
+	case EventLog:
+		err = j.rpc.Log(e.Service, e.Task, e.State, e.Message)
+	case EventResult:
+		var deployErr error
+		if e.Error != "" {
+			deployErr = errors.New(e.Error)
+			if err = j.rpc.Error(deployErr); err != nil {
+				break
+			}
+		}
+		var deployID string
+		if data, ok := e.Data.(ResultData); ok {
+			deployID = data.DeployID
+		}
+		err = j.rpc.Done(e.Stack, deployID, deployErr)
+	default:
+		if e.Message != "" {
+			err = j.rpc.Progress(e.Message)
+		}
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to write notification: %v", err)
+	}
+}
+
+// ProgressWriter returns an io.Writer that turns each written line into a
+// deploy/progress notification. It is meant to be passed to log.SetOutput.
+func (w *JSONRPCWriter) ProgressWriter() io.Writer {
+	return &progressWriter{rpc: w}
+}
+
+type progressWriter struct {
+	rpc *JSONRPCWriter
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf.Write(data)
+	for {
+		line, err := p.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			p.buf.Reset()
+			p.buf.WriteString(line)
+			break
+		}
+		if msg := strings.TrimRight(line, "\r\n"); msg != "" {
+			if err := p.rpc.Progress(msg); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(data), nil
+}
@@ -0,0 +1,127 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+)
+
+func TestJSONRPCWriter_SimpleDeploy(t *testing.T) {
+	var buf bytes.Buffer
+	rpc := NewJSONRPCWriter(&buf)
+
+	// Simulate a simple deploy: progress via the logger, one log line, then done
+	logger := log.New(rpc.ProgressWriter(), "", 0)
+	logger.Printf("Deploying stack: %s", "demo")
+	rpc.Log("demo_web", "abc123def456", "stdout", "listening on :80\n")
+	rpc.Error(errors.New("task failed"))
+	rpc.Done("demo", "deploy-1", nil)
+
+	expectedMethods := []string{MethodDeployProgress, MethodServiceLog, MethodDeployError, MethodDeployDone}
+
+	scanner := bufio.NewScanner(&buf)
+	var got []map[string]interface{}
+	for scanner.Scan() {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("line is not valid JSON: %q: %v", scanner.Text(), err)
+		}
+		got = append(got, msg)
+	}
+
+	if len(got) != len(expectedMethods) {
+		t.Fatalf("Expected %d notifications, got %d", len(expectedMethods), len(got))
+	}
+
+	for i, msg := range got {
+		if msg["jsonrpc"] != "2.0" {
+			t.Errorf("notification %d: jsonrpc = %v, want 2.0", i, msg["jsonrpc"])
+		}
+		if msg["method"] != expectedMethods[i] {
+			t.Errorf("notification %d: method = %v, want %s", i, msg["method"], expectedMethods[i])
+		}
+		if _, hasID := msg["id"]; hasID {
+			t.Errorf("notification %d must not carry an id", i)
+		}
+		if _, ok := msg["params"].(map[string]interface{}); !ok {
+			t.Errorf("notification %d: params should be an object, got %T", i, msg["params"])
+		}
+	}
+
+	if got[0]["params"].(map[string]interface{})["message"] != "Deploying stack: demo" {
+		t.Errorf("Unexpected progress params: %v", got[0]["params"])
+	}
+	if got[1]["params"].(map[string]interface{})["line"] != "listening on :80" {
+		t.Errorf("Expected trailing newline to be trimmed, got %v", got[1]["params"])
+	}
+	done := got[3]["params"].(map[string]interface{})
+	if done["success"] != true || done["stack"] != "demo" || done["deployId"] != "deploy-1" {
+		t.Errorf("Unexpected done params: %v", done)
+	}
+}
+
+func TestProgressWriter_SplitsLines(t *testing.T) {
+	var buf bytes.Buffer
+	rpc := NewJSONRPCWriter(&buf)
+	w := rpc.ProgressWriter()
+
+	fmt.Fprint(w, "first\nsec")
+	fmt.Fprint(w, "ond\n\n")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d: %s", len(lines), buf.String())
+	}
+
+	var n Notification
+	json.Unmarshal(lines[1], &n)
+	params := n.Params.(map[string]interface{})
+	if params["message"] != "second" {
+		t.Errorf("Expected buffered partial line to be joined, got %v", params["message"])
+	}
+}
+
+func TestJSONRPCEmitter_MapsEvents(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewJSONRPCEmitter(NewJSONRPCWriter(&buf))
+
+	exitCode := 1
+	emitter.Emit(Event{Type: EventPlan, Stack: "demo", Message: "Plan: 1 to create"})
+	emitter.Emit(Event{Type: EventStackDeployed, Stack: "demo"})
+	emitter.Emit(Event{Type: EventLog, Service: "demo_web", Task: "abc123", State: "stderr", Message: "boom"})
+	emitter.Emit(Event{Type: EventResult, Stack: "demo", State: "failed", Error: "task failed", ExitCode: &exitCode, Data: ResultData{DeployID: "deploy-1"}})
+
+	var got []Notification
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var n Notification
+		if err := json.Unmarshal(scanner.Bytes(), &n); err != nil {
+			t.Fatalf("line is not valid JSON: %q: %v", scanner.Text(), err)
+		}
+		got = append(got, n)
+	}
+
+	// The message-less stack_deployed event has nothing to report
+	wantMethods := []string{MethodDeployProgress, MethodServiceLog, MethodDeployError, MethodDeployDone}
+	if len(got) != len(wantMethods) {
+		t.Fatalf("Expected %d notifications, got %d: %s", len(wantMethods), len(got), buf.String())
+	}
+	for i, n := range got {
+		if n.Method != wantMethods[i] {
+			t.Errorf("notification %d: method = %s, want %s", i, n.Method, wantMethods[i])
+		}
+	}
+
+	logParams := got[1].Params.(map[string]interface{})
+	if logParams["service"] != "demo_web" || logParams["stream"] != "stderr" || logParams["line"] != "boom" {
+		t.Errorf("Unexpected log params: %v", logParams)
+	}
+	done := got[3].Params.(map[string]interface{})
+	if done["success"] != false || done["deployId"] != "deploy-1" || done["error"] != "task failed" {
+		t.Errorf("Unexpected done params: %v", done)
+	}
+}
@@ -0,0 +1,263 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JUnitRecorder forwards events to another emitter and records each service's
+// deploy as a JUnit test case of a suite named after the stack. The report is
+// written when the result event arrives. It is safe for concurrent use.
+type JUnitRecorder struct {
+	mu       sync.Mutex
+	w        io.Writer
+	next     Emitter
+	stack    string
+	started  time.Time
+	services map[string]*junitService
+	order    []string
+	written  bool
+}
+
+// junitService is the recorded outcome of one service deploy
+type junitService struct {
+	started time.Time
+	ended   time.Time
+	failure string
+	health  map[string]string // Last health state per task
+}
+
+// NewJUnitRecorder creates a recorder writing the report to w and passing events on to next
+func NewJUnitRecorder(w io.Writer, stack string, next Emitter) *JUnitRecorder {
+	return &JUnitRecorder{
+		w:        w,
+		next:     next,
+		stack:    stack,
+		started:  time.Now(),
+		services: make(map[string]*junitService),
+	}
+}
+
+// Emit records the event and forwards it
+func (r *JUnitRecorder) Emit(e Event) {
+	r.next.Emit(e)
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e.Type {
+	case EventServiceUpdate:
+		svc := r.service(e.Service, e.Time)
+		switch e.State {
+		case "completed":
+			svc.ended = e.Time
+		case "failed":
+			svc.ended = e.Time
+			svc.failure = e.Message
+		}
+	case EventHealth:
+		if e.Service != "" && e.Task != "" {
+			r.service(e.Service, e.Time).health[e.Task] = e.State
+		}
+	case EventResult:
+		r.finish(e)
+	}
+}
+
+// service returns the record of a service, starting it at t on first sight
+func (r *JUnitRecorder) service(name string, t time.Time) *junitService {
+	svc, ok := r.services[name]
+	if !ok {
+		svc = &junitService{started: t, health: make(map[string]string)}
+		r.services[name] = svc
+		r.order = append(r.order, name)
+	}
+	return svc
+}
+
+// finish marks the services the result error blames, then writes the report once
+func (r *JUnitRecorder) finish(result Event) {
+	if r.written {
+		return
+	}
+	r.written = true
+
+	if result.Error != "" {
+		blamed := false
+		for _, name := range r.order {
+			svc := r.services[name]
+			if svc.failure == "" && mentionsService(result.Error, name) {
+				svc.failure = result.Error
+				blamed = true
+			}
+		}
+		// Without a named service, every service that did not get healthy shares the error
+		for _, name := range r.order {
+			svc := r.services[name]
+			if svc.failure == "" && !blamed && !svc.healthy() {
+				svc.failure = result.Error
+			}
+		}
+	}
+
+	if err := r.write(result); err != nil {
+		log.Printf("WARNING: failed to write JUnit report: %v", err)
+	}
+}
+
+// healthy reports whether every task seen during the health wait ended healthy or running
+func (s *junitService) healthy() bool {
+	if len(s.health) == 0 {
+		return false
+	}
+	for _, state := range s.health {
+		if state != "healthy" && state != "running" {
+			return false
+		}
+	}
+	return true
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// write renders the recorded services as one test suite. A deploy that fails
+// before any service is rolled out is reported as a single "deploy" test case.
+func (r *JUnitRecorder) write(result Event) error {
+	ended := result.Time
+	suite := junitTestSuite{
+		Name:      r.stack,
+		Time:      junitSeconds(ended.Sub(r.started)),
+		Timestamp: r.started.UTC().Format(time.RFC3339),
+	}
+
+	for _, name := range r.order {
+		svc := r.services[name]
+		end := svc.ended
+		if end.IsZero() || end.Before(svc.started) {
+			end = ended
+		}
+		tc := junitTestCase{
+			Name:      name,
+			Classname: r.stack,
+			Time:      junitSeconds(end.Sub(svc.started)),
+			SystemOut: svc.healthSummary(),
+		}
+		if svc.failure != "" {
+			tc.Failure = &junitFailure{Message: firstLine(svc.failure), Text: svc.failure}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if len(suite.Cases) == 0 {
+		tc := junitTestCase{Name: "deploy", Classname: r.stack, Time: suite.Time}
+		if result.Error != "" {
+			tc.Failure = &junitFailure{Message: firstLine(result.Error), Text: result.Error}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suite.Tests = len(suite.Cases)
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	if _, err := io.WriteString(r.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(r.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(r.w, "\n")
+	return err
+}
+
+// healthSummary lists the last health state of each task, sorted by task ID
+func (s *junitService) healthSummary() string {
+	tasks := make([]string, 0, len(s.health))
+	for task := range s.health {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	var b strings.Builder
+	for _, task := range tasks {
+		fmt.Fprintf(&b, "task %s: %s\n", task, s.health[task])
+	}
+	return b.String()
+}
+
+// junitSeconds formats a duration the way JUnit reports expect
+func junitSeconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// mentionsService reports whether msg names the service as "service <name>",
+// so "service web" does not match an error about "service web-admin"
+func mentionsService(msg, name string) bool {
+	needle := "service " + name
+	for i := strings.Index(msg, needle); i >= 0; {
+		end := i + len(needle)
+		if end == len(msg) || !isServiceNameChar(msg[end]) {
+			return true
+		}
+		next := strings.Index(msg[end:], needle)
+		if next < 0 {
+			break
+		}
+		i = end + next
+	}
+	return false
+}
+
+func isServiceNameChar(c byte) bool {
+	return c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+// recordingEmitter keeps the events passed on by a wrapping emitter
+type recordingEmitter struct {
+	events []Event
+}
+
+func (r *recordingEmitter) Emit(e Event) {
+	r.events = append(r.events, e)
+}
+
+func parseJUnit(t *testing.T, data []byte) junitTestSuite {
+	t.Helper()
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Report is not valid XML: %v\n%s", err, data)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("Expected one test suite, got %d", len(report.Suites))
+	}
+	return report.Suites[0]
+}
+
+func TestJUnitRecorder_OneCasePerService(t *testing.T) {
+	var buf bytes.Buffer
+	next := &recordingEmitter{}
+	recorder := NewJUnitRecorder(&buf, "shop", next)
+
+	for _, name := range []string{"shop_web", "shop_api", "shop_worker", "shop_web-admin"} {
+		recorder.Emit(Event{Type: EventServiceUpdate, Stack: "shop", Service: name, State: "started"})
+	}
+	recorder.Emit(Event{Type: EventServiceUpdate, Service: "shop_web", State: "completed"})
+	recorder.Emit(Event{Type: EventServiceUpdate, Service: "shop_api", State: "completed"})
+	recorder.Emit(Event{Type: EventServiceUpdate, Service: "shop_web-admin", State: "completed"})
+	recorder.Emit(Event{Type: EventServiceUpdate, Service: "shop_worker", State: "failed", Message: "service shop_worker update failed: paused"})
+	recorder.Emit(Event{Type: EventHealth, Service: "shop_web", Task: "task1", State: "healthy"})
+	recorder.Emit(Event{Type: EventHealth, Service: "shop_web-admin", Task: "task3", State: "healthy"})
+	recorder.Emit(Event{Type: EventHealth, Service: "shop_api", Task: "task2", State: "starting"})
+	recorder.Emit(Event{Type: EventHealth, Service: "shop_api", Task: "task2", State: "unhealthy"})
+	recorder.Emit(Event{Type: EventResult, Stack: "shop", State: "failed", Error: "service shop_api did not become healthy within 1m0s"})
+
+	if len(next.events) != 13 {
+		t.Errorf("Expected all 13 events to be forwarded, got %d", len(next.events))
+	}
+
+	suite := parseJUnit(t, buf.Bytes())
+	if suite.Name != "shop" || suite.Tests != 4 || suite.Failures != 2 {
+		t.Errorf("Expected suite shop with 4 tests and 2 failures, got %s with %d tests and %d failures", suite.Name, suite.Tests, suite.Failures)
+	}
+
+	want := map[string]string{
+		"shop_web":       "",
+		"shop_web-admin": "",
+		"shop_api":       "service shop_api did not become healthy within 1m0s",
+		"shop_worker":    "service shop_worker update failed: paused",
+	}
+	if len(suite.Cases) != len(want) {
+		t.Fatalf("Expected %d test cases, got %d", len(want), len(suite.Cases))
+	}
+	for _, tc := range suite.Cases {
+		failure, ok := want[tc.Name]
+		if !ok {
+			t.Errorf("Unexpected test case %s", tc.Name)
+			continue
+		}
+		switch {
+		case failure == "" && tc.Failure != nil:
+			t.Errorf("Expected %s to pass, got failure %q", tc.Name, tc.Failure.Message)
+		case failure != "" && (tc.Failure == nil || tc.Failure.Message != failure):
+			t.Errorf("Expected %s to fail with %q, got %+v", tc.Name, failure, tc.Failure)
+		}
+	}
+	if suite.Cases[1].Name != "shop_api" || suite.Cases[1].SystemOut != "task task2: unhealthy\n" {
+		t.Errorf("Expected the last api health state in system-out, got %+v", suite.Cases[1])
+	}
+
+	// The report is written once, on the first result
+	size := buf.Len()
+	recorder.Emit(Event{Type: EventResult, Stack: "shop", State: "failed", Error: "again"})
+	if buf.Len() != size {
+		t.Error("Expected the report to be written only once")
+	}
+}
+
+func TestJUnitRecorder_FailureBeforeRollout(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewJUnitRecorder(&buf, "shop", &recordingEmitter{})
+	recorder.Emit(Event{Type: EventResult, Stack: "shop", State: "failed", Error: "failed to deploy stack: no such image"})
+
+	suite := parseJUnit(t, buf.Bytes())
+	if len(suite.Cases) != 1 || suite.Cases[0].Name != "deploy" || suite.Cases[0].Failure == nil {
+		t.Fatalf("Expected a single failed deploy test case, got %+v", suite.Cases)
+	}
+
+	buf.Reset()
+	recorder = NewJUnitRecorder(&buf, "shop", &recordingEmitter{})
+	recorder.Emit(Event{Type: EventResult, Stack: "shop", State: "success"})
+	suite = parseJUnit(t, buf.Bytes())
+	if suite.Tests != 1 || suite.Failures != 0 {
+		t.Errorf("Expected a single passing deploy test case, got %d tests and %d failures", suite.Tests, suite.Failures)
+	}
+}
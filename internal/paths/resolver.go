@@ -14,7 +14,16 @@ type Resolver struct {
 // NewResolver creates a new path resolver
 // It uses STACKMAN_WORKDIR environment variable or current working directory
 func NewResolver() (*Resolver, error) {
+	return NewResolverFor("")
+}
+
+// NewResolverFor creates a path resolver for a compose file located in dir.
+// STACKMAN_WORKDIR still takes precedence; an empty dir falls back to the current directory.
+func NewResolverFor(dir string) (*Resolver, error) {
 	basePath := os.Getenv("STACKMAN_WORKDIR")
+	if basePath == "" {
+		basePath = dir
+	}
 	if basePath == "" {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -90,3 +90,26 @@ func TestNewResolver(t *testing.T) {
 		t.Errorf("NewResolver() basePath = %q, want %q", r2.BasePath(), cwd)
 	}
 }
+
+func TestNewResolverFor(t *testing.T) {
+	t.Setenv("STACKMAN_WORKDIR", "")
+
+	dir := t.TempDir()
+	r, err := NewResolverFor(dir)
+	if err != nil {
+		t.Fatalf("NewResolverFor() error = %v", err)
+	}
+	if r.BasePath() != dir {
+		t.Errorf("NewResolverFor() basePath = %q, want %q", r.BasePath(), dir)
+	}
+
+	// STACKMAN_WORKDIR takes precedence over the given directory
+	t.Setenv("STACKMAN_WORKDIR", "/test/path")
+	r, err = NewResolverFor(dir)
+	if err != nil {
+		t.Fatalf("NewResolverFor() error = %v", err)
+	}
+	if r.BasePath() != "/test/path" {
+		t.Errorf("NewResolverFor() basePath = %q, want %q", r.BasePath(), "/test/path")
+	}
+}
@@ -0,0 +1,258 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// deployIDLabel changes on every deployment and is not part of the service definition
+const deployIDLabel = "com.stackman.deploy.id"
+
+// SpecHashLabel records the hash of the spec a service was last deployed with. It
+// covers the fields compareServices doesn't inspect, such as healthchecks.
+const SpecHashLabel = "com.stackman.spec.hash"
+
+// networkIndex resolves network attachments for comparison.
+// Swarm stores attachments by network ID while the converted spec uses names.
+type networkIndex struct {
+	names    map[string]string // network ID -> full name, for stack networks
+	external map[string]bool   // desired targets that refer to external networks
+}
+
+// compareServices compares the running service with the spec converted from the
+// compose file and returns the fields that differ. An empty result means the
+// service is up to date.
+func compareServices(current *swarm.Service, desired *swarm.ServiceSpec, networks networkIndex) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, before, after []string) {
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, FieldChange{
+				Field:  field,
+				Before: strings.Join(before, "\n"),
+				After:  strings.Join(after, "\n"),
+			})
+		}
+	}
+
+	currentContainer := current.Spec.TaskTemplate.ContainerSpec
+	if currentContainer == nil {
+		currentContainer = &swarm.ContainerSpec{}
+	}
+	desiredContainer := desired.TaskTemplate.ContainerSpec
+	if desiredContainer == nil {
+		desiredContainer = &swarm.ContainerSpec{}
+	}
+
+	// Compare image
+	if !sameImage(currentContainer.Image, desiredContainer.Image) {
+		changes = append(changes, FieldChange{Field: "image", Before: currentContainer.Image, After: desiredContainer.Image})
+	}
+
+	// Compare mode and replicas
+	currentMode, currentReplicas := serviceMode(current.Spec.Mode)
+	desiredMode, desiredReplicas := serviceMode(desired.Mode)
+	if currentMode != desiredMode {
+		changes = append(changes, FieldChange{Field: "mode", Before: currentMode, After: desiredMode})
+	} else if currentReplicas != desiredReplicas {
+		changes = append(changes, FieldChange{
+			Field:  "replicas",
+			Before: strconv.FormatUint(currentReplicas, 10),
+			After:  strconv.FormatUint(desiredReplicas, 10),
+		})
+	}
+
+	add("env", sortedCopy(currentContainer.Env), sortedCopy(desiredContainer.Env))
+	add("entrypoint", nonNil(currentContainer.Command), nonNil(desiredContainer.Command))
+	add("command", nonNil(currentContainer.Args), nonNil(desiredContainer.Args))
+	add("mounts", mountStrings(currentContainer.Mounts), mountStrings(desiredContainer.Mounts))
+	add("labels", labelStrings(current.Spec.Labels), labelStrings(desired.Labels))
+	add("container_labels", labelStrings(currentContainer.Labels), labelStrings(desiredContainer.Labels))
+	add("resources", resourceStrings(current.Spec.TaskTemplate.Resources), resourceStrings(desired.TaskTemplate.Resources))
+	add("ports", portStrings(current.Spec.EndpointSpec), portStrings(desired.EndpointSpec))
+	add("endpoint_mode", []string{endpointMode(current.Spec.EndpointSpec)}, []string{endpointMode(desired.EndpointSpec)})
+
+	currentNetworks, desiredNetworks := networkTargets(current.Spec.TaskTemplate.Networks, desired.TaskTemplate.Networks, networks)
+	add("networks", currentNetworks, desiredNetworks)
+
+	return changes
+}
+
+// ServiceChanges returns the fields in which a running service differs from the
+// spec it would be updated to. networkNames maps network IDs to names; the desired
+// spec must attach to networks by their actual names.
+func ServiceChanges(current *swarm.Service, desired *swarm.ServiceSpec, networkNames map[string]string) []FieldChange {
+	return compareServices(current, desired, networkIndex{names: networkNames})
+}
+
+// ServiceSpecHash hashes a service spec for SpecHashLabel. Set it before the
+// per-deploy ID labels are added, so the hash only changes with the definition.
+func ServiceSpecHash(spec *swarm.ServiceSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash service spec: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sameImage reports whether two image references point at the same image.
+// When both carry a digest the digests decide, so a retagged image is a change
+// even if the tag is unchanged. Otherwise the digest Swarm may have added to
+// the running image is ignored and the references are compared by name and tag.
+func sameImage(current, desired string) bool {
+	currentRef, currentDigest, _ := strings.Cut(current, "@")
+	desiredRef, desiredDigest, _ := strings.Cut(desired, "@")
+
+	if currentDigest != "" && desiredDigest != "" {
+		return currentDigest == desiredDigest
+	}
+	return currentRef == desiredRef
+}
+
+// serviceMode returns the mode name and replica count; Swarm defaults to one replica
+func serviceMode(mode swarm.ServiceMode) (string, uint64) {
+	if mode.Global != nil {
+		return "global", 0
+	}
+	if mode.Replicated != nil && mode.Replicated.Replicas != nil {
+		return "replicated", *mode.Replicated.Replicas
+	}
+	return "replicated", 1
+}
+
+// sortedCopy returns a sorted copy so ordering differences don't count as changes
+func sortedCopy(values []string) []string {
+	result := append([]string{}, values...)
+	sort.Strings(result)
+	return result
+}
+
+// nonNil treats a nil slice like an empty one
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// mountStrings renders mounts as sorted "type source:target[:ro]" entries
+func mountStrings(mounts []mount.Mount) []string {
+	result := make([]string, 0, len(mounts))
+	for _, m := range mounts {
+		entry := fmt.Sprintf("%s %s:%s", m.Type, m.Source, m.Target)
+		if m.ReadOnly {
+			entry += ":ro"
+		}
+		result = append(result, entry)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// labelStrings renders labels as sorted key=value entries, without the labels stackman
+// maintains itself
+func labelStrings(labels map[string]string) []string {
+	result := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if k == deployIDLabel || k == SpecHashLabel {
+			continue
+		}
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// resourceStrings renders resource limits and reservations
+func resourceStrings(resources *swarm.ResourceRequirements) []string {
+	result := []string{}
+	if resources == nil {
+		return result
+	}
+	if l := resources.Limits; l != nil && (l.NanoCPUs != 0 || l.MemoryBytes != 0 || l.Pids != 0) {
+		result = append(result, fmt.Sprintf("limits: cpus=%d memory=%d pids=%d", l.NanoCPUs, l.MemoryBytes, l.Pids))
+	}
+	if r := resources.Reservations; r != nil && (r.NanoCPUs != 0 || r.MemoryBytes != 0 || len(r.GenericResources) > 0) {
+		entry := fmt.Sprintf("reservations: cpus=%d memory=%d", r.NanoCPUs, r.MemoryBytes)
+		for _, g := range r.GenericResources {
+			switch {
+			case g.DiscreteResourceSpec != nil:
+				entry += fmt.Sprintf(" %s=%d", g.DiscreteResourceSpec.Kind, g.DiscreteResourceSpec.Value)
+			case g.NamedResourceSpec != nil:
+				entry += fmt.Sprintf(" %s=%s", g.NamedResourceSpec.Kind, g.NamedResourceSpec.Value)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// portStrings renders published ports as sorted "published:target/protocol (mode)" entries
+func portStrings(endpoint *swarm.EndpointSpec) []string {
+	result := []string{}
+	if endpoint == nil {
+		return result
+	}
+	for _, p := range endpoint.Ports {
+		mode := p.PublishMode
+		if mode == "" {
+			mode = swarm.PortConfigPublishModeIngress
+		}
+		result = append(result, fmt.Sprintf("%d:%d/%s (%s)", p.PublishedPort, p.TargetPort, p.Protocol, mode))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// endpointMode returns the resolution mode, defaulting to vip like Swarm
+func endpointMode(endpoint *swarm.EndpointSpec) string {
+	if endpoint == nil || endpoint.Mode == "" {
+		return string(swarm.ResolutionModeVIP)
+	}
+	return string(endpoint.Mode)
+}
+
+// withAliases appends the sorted aliases of a network attachment to its name
+func withAliases(name string, aliases []string) string {
+	if len(aliases) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (aliases: %s)", name, strings.Join(sortedCopy(aliases), ", "))
+}
+
+// networkTargets returns comparable network lists for both specs.
+// Current attachments are mapped from IDs back to names. External networks
+// can't be resolved by name on the current side, so they are only counted.
+func networkTargets(current, desired []swarm.NetworkAttachmentConfig, networks networkIndex) ([]string, []string) {
+	var currentNames, desiredNames []string
+	currentExternal, desiredExternal := 0, 0
+	for _, n := range current {
+		if name, ok := networks.names[n.Target]; ok {
+			currentNames = append(currentNames, withAliases(name, n.Aliases))
+		} else {
+			currentExternal++
+		}
+	}
+	for _, n := range desired {
+		if networks.external[n.Target] {
+			desiredExternal++
+		} else {
+			desiredNames = append(desiredNames, withAliases(n.Target, n.Aliases))
+		}
+	}
+
+	if currentExternal != desiredExternal {
+		currentNames = append(currentNames, fmt.Sprintf("(%d external)", currentExternal))
+		desiredNames = append(desiredNames, fmt.Sprintf("(%d external)", desiredExternal))
+	}
+	return sortedCopy(currentNames), sortedCopy(desiredNames)
+}
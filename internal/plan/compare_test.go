@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestCompareServices_SingleFieldChange(t *testing.T) {
+	base := func() *compose.Service {
+		replicas := 2
+		return &compose.Service{
+			Image:       "nginx:1.25",
+			Command:     "nginx -g 'daemon off;'",
+			Environment: []interface{}{"A=1", "B=2"},
+			Labels:      map[string]string{"team": "web"},
+			Volumes:     []interface{}{"data:/var/lib/data"},
+			Networks:    []interface{}{"frontend"},
+			Deploy:      &compose.DeployConfig{Replicas: &replicas, Labels: map[string]string{"tier": "front"}},
+		}
+	}
+	networks := networkIndex{names: map[string]string{"net1": "mystack_frontend", "net2": "mystack_backend"}}
+
+	// The running service is what the deployer created from the base definition
+	runningSpec, err := compose.ConvertToSwarmSpec("web", base(), "mystack", "")
+	if err != nil {
+		t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+	}
+	runningSpec.Labels[deployIDLabel] = "previous"
+	runningSpec.Labels[SpecHashLabel] = "previous-hash"
+	runningSpec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "net1"}}
+	running := &swarm.Service{Spec: *runningSpec}
+
+	tests := []struct {
+		name   string
+		modify func(svc *compose.Service)
+		field  string
+	}{
+		{name: "no change", modify: func(svc *compose.Service) {}},
+		{name: "env order ignored", modify: func(svc *compose.Service) { svc.Environment = []interface{}{"B=2", "A=1"} }},
+		{name: "image", modify: func(svc *compose.Service) { svc.Image = "nginx:1.26" }, field: "image"},
+		{name: "replicas", modify: func(svc *compose.Service) { r := 3; svc.Deploy.Replicas = &r }, field: "replicas"},
+		{name: "scaled to zero", modify: func(svc *compose.Service) { r := 0; svc.Deploy.Replicas = &r }, field: "replicas"},
+		{name: "default replicas", modify: func(svc *compose.Service) { svc.Deploy.Replicas = nil }, field: "replicas"},
+		{name: "env", modify: func(svc *compose.Service) { svc.Environment = []interface{}{"A=1", "B=3"} }, field: "env"},
+		{name: "command", modify: func(svc *compose.Service) { svc.Command = "nginx" }, field: "command"},
+		{name: "mounts", modify: func(svc *compose.Service) { svc.Volumes = []interface{}{"data:/data"} }, field: "mounts"},
+		{name: "labels", modify: func(svc *compose.Service) { svc.Deploy.Labels["tier"] = "back" }, field: "labels"},
+		{name: "container labels", modify: func(svc *compose.Service) { svc.Labels["team"] = "api" }, field: "container_labels"},
+		{name: "networks", modify: func(svc *compose.Service) { svc.Networks = []interface{}{"backend"} }, field: "networks"},
+		{
+			name: "network aliases",
+			modify: func(svc *compose.Service) {
+				svc.Networks = map[string]interface{}{"frontend": map[string]interface{}{"aliases": []interface{}{"www"}}}
+			},
+			field: "networks",
+		},
+		{
+			name: "resources",
+			modify: func(svc *compose.Service) {
+				svc.Deploy.Resources = &compose.Resources{Limits: &compose.ResourceLimit{Memory: "256M"}}
+			},
+			field: "resources",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := base()
+			tt.modify(svc)
+			desired, err := compose.ConvertToSwarmSpec("web", svc, "mystack", "")
+			if err != nil {
+				t.Fatalf("ConvertToSwarmSpec failed: %v", err)
+			}
+
+			changes := compareServices(running, desired, networks)
+			if tt.field == "" {
+				if len(changes) != 0 {
+					t.Errorf("Expected no changes, got %+v", changes)
+				}
+				return
+			}
+			if len(changes) != 1 || changes[0].Field != tt.field {
+				t.Errorf("Expected a single %q change, got %+v", tt.field, changes)
+			}
+		})
+	}
+}
+
+func TestCompareServices_ExternalNetworksCounted(t *testing.T) {
+	current := &swarm.Service{Spec: swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "nginx"},
+			Networks:      []swarm.NetworkAttachmentConfig{{Target: "ext-id"}},
+		},
+	}}
+	desired := &swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "nginx"},
+			Networks:      []swarm.NetworkAttachmentConfig{{Target: "mystack_proxy"}},
+		},
+	}
+	networks := networkIndex{external: map[string]bool{"mystack_proxy": true}}
+
+	if changes := compareServices(current, desired, networks); len(changes) != 0 {
+		t.Errorf("Expected external network attachment to match, got %+v", changes)
+	}
+}
@@ -7,6 +7,16 @@ import (
 
 // FormatDiff formats the plan as a human-readable diff
 func FormatDiff(plan *Plan) string {
+	return formatDiff(plan, false)
+}
+
+// FormatDetailedDiff formats the plan like FormatDiff and adds the before/after
+// values of changed fields under each updated service
+func FormatDetailedDiff(plan *Plan) string {
+	return formatDiff(plan, true)
+}
+
+func formatDiff(plan *Plan, withContext bool) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("Stack: %s\n", plan.StackName))
@@ -121,6 +131,9 @@ func FormatDiff(plan *Plan) string {
 						sb.WriteString(fmt.Sprintf(" (changes: %s)", strings.Join(svc.Changes, ", ")))
 					}
 					sb.WriteString("\n")
+					if withContext && svc.Action == ActionUpdate {
+						writeFieldChanges(&sb, svc.Details)
+					}
 					hasChanges = true
 				}
 			}
@@ -159,6 +172,30 @@ func FormatDiff(plan *Plan) string {
 	return sb.String()
 }
 
+// writeFieldChanges renders before/after values as a compact unified diff
+func writeFieldChanges(sb *strings.Builder, details []FieldChange) {
+	for _, detail := range details {
+		if detail.Before == "" && detail.After == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("      %s:\n", detail.Field))
+		for _, line := range splitValue(detail.Before) {
+			sb.WriteString(fmt.Sprintf("        - %s\n", line))
+		}
+		for _, line := range splitValue(detail.After) {
+			sb.WriteString(fmt.Sprintf("        + %s\n", line))
+		}
+	}
+}
+
+// splitValue splits a multi-valued field into lines, dropping the empty value
+func splitValue(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
 // actionSymbol returns a visual symbol for the action type
 func actionSymbol(action ActionType) string {
 	switch action {
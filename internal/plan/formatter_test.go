@@ -0,0 +1,67 @@
+package plan
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestFormatDetailedDiff_ShowsBeforeAfter(t *testing.T) {
+	replicas := uint64(2)
+	current := &CurrentState{
+		Services: map[string]swarm.Service{
+			"web": {
+				ID: "service123",
+				Spec: swarm.ServiceSpec{
+					Annotations: swarm.Annotations{
+						Name:   "mystack_web",
+						Labels: map[string]string{"com.docker.stack.namespace": "mystack"},
+					},
+					TaskTemplate: swarm.TaskSpec{
+						ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.24", Labels: map[string]string{"com.docker.stack.namespace": "mystack"}},
+						Networks:      []swarm.NetworkAttachmentConfig{{Target: "net123"}},
+					},
+					Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+				},
+			},
+		},
+		Networks: map[string]swarm.Network{
+			"default": {ID: "net123", Spec: swarm.NetworkSpec{Annotations: swarm.Annotations{Name: "mystack_default"}}},
+		},
+	}
+
+	desiredReplicas := 4
+	desired := &DesiredState{
+		Services: map[string]*compose.Service{
+			"web": {
+				Image:  "nginx:1.25",
+				Deploy: &compose.DeployConfig{Replicas: &desiredReplicas},
+			},
+		},
+	}
+
+	plan, err := NewPlanner(nil, "mystack").CreatePlan(context.Background(), current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+
+	detailed := FormatDetailedDiff(plan)
+	for _, want := range []string{
+		"~ update web (changes: image, replicas)",
+		"      image:\n        - nginx:1.24\n        + nginx:1.25\n",
+		"      replicas:\n        - 2\n        + 4\n",
+	} {
+		if !strings.Contains(detailed, want) {
+			t.Errorf("Expected detailed diff to contain %q, got:\n%s", want, detailed)
+		}
+	}
+
+	// The plain diff keeps only the change names
+	if plain := FormatDiff(plan); strings.Contains(plain, "nginx:1.24") {
+		t.Errorf("Expected plain diff without before/after values, got:\n%s", plain)
+	}
+}
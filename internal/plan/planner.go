@@ -2,6 +2,9 @@ package plan
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
@@ -36,13 +39,25 @@ func (p *Planner) CreatePlan(ctx context.Context, current *CurrentState, desired
 	plan.Volumes = p.planVolumes(current, desired)
 
 	// Plan config changes
-	plan.Configs = p.planConfigs(current, desired)
+	configs, rotatedConfigs, err := p.planConfigs(current, desired)
+	if err != nil {
+		return nil, err
+	}
+	plan.Configs = configs
 
 	// Plan secret changes
-	plan.Secrets = p.planSecrets(current, desired)
+	secrets, rotatedSecrets, err := p.planSecrets(current, desired)
+	if err != nil {
+		return nil, err
+	}
+	plan.Secrets = secrets
 
 	// Plan service changes
-	plan.Services = p.planServices(current, desired)
+	services, err := p.planServices(current, desired, rotatedSecrets, rotatedConfigs)
+	if err != nil {
+		return nil, err
+	}
+	plan.Services = services
 
 	return plan, nil
 }
@@ -53,6 +68,11 @@ func (p *Planner) planNetworks(current *CurrentState, desired *DesiredState) []N
 
 	// Check for creates and updates
 	for name, desiredNet := range desired.Networks {
+		// External networks are not managed by the stack
+		if desiredNet != nil && compose.IsExternal(desiredNet.External) {
+			continue
+		}
+
 		if currentNet, exists := current.Networks[name]; exists {
 			// Network exists - check if update needed
 			// For now, we don't update networks (would require recreate)
@@ -130,28 +150,55 @@ func (p *Planner) planVolumes(current *CurrentState, desired *DesiredState) []Vo
 	return actions
 }
 
-// planConfigs determines config changes
-func (p *Planner) planConfigs(current *CurrentState, desired *DesiredState) []ConfigAction {
+// planConfigs determines config changes.
+// Configs are immutable, so a content change is planned as a rotation: create the
+// new version, update referencing services, then delete the old version.
+// The returned set holds the names of rotated configs.
+func (p *Planner) planConfigs(current *CurrentState, desired *DesiredState) ([]ConfigAction, map[string]bool, error) {
 	var actions []ConfigAction
+	rotated := make(map[string]bool)
 
 	// Check for creates and updates
 	for name, desiredCfg := range desired.Configs {
-		if currentCfg, exists := current.Configs[name]; exists {
-			// Config exists - configs are immutable, so we'd need to create new version
-			// For now, treat as no change
+		// External configs are not managed by the stack
+		if desiredCfg == nil || compose.IsExternal(desiredCfg.External) {
+			continue
+		}
+
+		data, err := compose.ReadResourceFile(desiredCfg.File, desired.ComposeDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("config %s: %w", name, err)
+		}
+		hash := compose.ContentHash(data)
+
+		currentCfg, exists := current.Configs[name]
+		if exists && currentCfg.Spec.Labels[compose.ContentHashLabel] == hash {
 			actions = append(actions, ConfigAction{
 				Name:     name,
 				Action:   ActionNone,
 				ConfigID: currentCfg.ID,
 				Labels:   currentCfg.Spec.Labels,
+				Hash:     hash,
 			})
-		} else {
-			// Config doesn't exist - create
+			continue
+		}
+
+		// Config doesn't exist or its content changed - create a new version
+		actions = append(actions, ConfigAction{
+			Name:   name,
+			Action: ActionCreate,
+			Labels: desiredCfg.Labels,
+			Data:   data,
+			Hash:   hash,
+		})
+
+		if exists {
+			rotated[name] = true
 			actions = append(actions, ConfigAction{
-				Name:   name,
-				Action: ActionCreate,
-				Labels: desiredCfg.Labels,
-				// Data will be read from File later
+				Name:     name,
+				Action:   ActionDelete,
+				ConfigID: currentCfg.ID,
+				Hash:     currentCfg.Spec.Labels[compose.ContentHashLabel],
 			})
 		}
 	}
@@ -167,31 +214,57 @@ func (p *Planner) planConfigs(current *CurrentState, desired *DesiredState) []Co
 		}
 	}
 
-	return actions
+	return actions, rotated, nil
 }
 
-// planSecrets determines secret changes
-func (p *Planner) planSecrets(current *CurrentState, desired *DesiredState) []SecretAction {
+// planSecrets determines secret changes.
+// Like configs, a changed secret is rotated: create new, update services, delete old.
+// The returned set holds the names of rotated secrets.
+func (p *Planner) planSecrets(current *CurrentState, desired *DesiredState) ([]SecretAction, map[string]bool, error) {
 	var actions []SecretAction
+	rotated := make(map[string]bool)
 
 	// Check for creates and updates
 	for name, desiredSec := range desired.Secrets {
-		if currentSec, exists := current.Secrets[name]; exists {
-			// Secret exists - secrets are immutable, so we'd need to create new version
-			// For now, treat as no change
+		// External secrets are not managed by the stack
+		if desiredSec == nil || compose.IsExternal(desiredSec.External) {
+			continue
+		}
+
+		data, err := compose.ReadResourceFile(desiredSec.File, desired.ComposeDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+		hash := compose.ContentHash(data)
+
+		currentSec, exists := current.Secrets[name]
+		if exists && currentSec.Spec.Labels[compose.ContentHashLabel] == hash {
 			actions = append(actions, SecretAction{
 				Name:     name,
 				Action:   ActionNone,
 				SecretID: currentSec.ID,
 				Labels:   currentSec.Spec.Labels,
+				Hash:     hash,
 			})
-		} else {
-			// Secret doesn't exist - create
+			continue
+		}
+
+		// Secret doesn't exist or its content changed - create a new version
+		actions = append(actions, SecretAction{
+			Name:   name,
+			Action: ActionCreate,
+			Labels: desiredSec.Labels,
+			Data:   data,
+			Hash:   hash,
+		})
+
+		if exists {
+			rotated[name] = true
 			actions = append(actions, SecretAction{
-				Name:   name,
-				Action: ActionCreate,
-				Labels: desiredSec.Labels,
-				// Data will be read from File later
+				Name:     name,
+				Action:   ActionDelete,
+				SecretID: currentSec.ID,
+				Hash:     currentSec.Spec.Labels[compose.ContentHashLabel],
 			})
 		}
 	}
@@ -207,18 +280,43 @@ func (p *Planner) planSecrets(current *CurrentState, desired *DesiredState) []Se
 		}
 	}
 
-	return actions
+	return actions, rotated, nil
 }
 
 // planServices determines service changes
-func (p *Planner) planServices(current *CurrentState, desired *DesiredState) []ServiceAction {
+// Services referencing a rotated secret or config are updated to the new version.
+func (p *Planner) planServices(current *CurrentState, desired *DesiredState, rotatedSecrets, rotatedConfigs map[string]bool) ([]ServiceAction, error) {
 	var actions []ServiceAction
+	networks := p.networkIndex(current, desired)
 
 	// Check for creates and updates
 	for name, desiredSvc := range desired.Services {
+		desiredSpec, err := p.desiredServiceSpec(name, desiredSvc, desired)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
 		if currentSvc, exists := current.Services[name]; exists {
 			// Service exists - check if update needed
-			changes := compareServices(&currentSvc, desiredSvc)
+			details := compareServices(&currentSvc, desiredSpec, networks)
+			var changes []string
+			for _, detail := range details {
+				changes = append(changes, detail.Field)
+			}
+			changes = append(changes, rotationChanges(desiredSvc, rotatedSecrets, rotatedConfigs)...)
+
+			// Like the deployer, update a service whose other fields changed,
+			// such as its healthcheck, when the spec hash no longer matches
+			if len(changes) == 0 {
+				hash, err := p.deployedSpecHash(desiredSpec, &currentSvc, current, desired)
+				if err != nil {
+					return nil, fmt.Errorf("service %s: %w", name, err)
+				}
+				if currentSvc.Spec.Labels[SpecHashLabel] != hash {
+					changes = append(changes, "configuration")
+				}
+			}
+
 			action := ActionNone
 			if len(changes) > 0 {
 				action = ActionUpdate
@@ -229,66 +327,195 @@ func (p *Planner) planServices(current *CurrentState, desired *DesiredState) []S
 				Action:      action,
 				ServiceID:   currentSvc.ID,
 				CurrentSpec: &currentSvc.Spec,
+				DesiredSpec: desiredSpec,
 				CurrentMeta: &currentSvc.Meta,
 				Changes:     changes,
+				Details:     details,
 			})
 		} else {
 			// Service doesn't exist - create
 			actions = append(actions, ServiceAction{
-				Name:   name,
-				Action: ActionCreate,
+				Name:        name,
+				Action:      ActionCreate,
+				DesiredSpec: desiredSpec,
 			})
 		}
 	}
 
 	// Check for deletes (orphaned services)
 	for name, currentSvc := range current.Services {
-		if _, exists := desired.Services[name]; !exists {
+		if _, exists := desired.Services[name]; !exists && !desired.DisabledServices[name] {
 			actions = append(actions, ServiceAction{
-				Name:      name,
-				Action:    ActionDelete,
-				ServiceID: currentSvc.ID,
+				Name:        name,
+				Action:      ActionDelete,
+				ServiceID:   currentSvc.ID,
+				CurrentSpec: &currentSvc.Spec,
 			})
 		}
 	}
 
-	return actions
+	return actions, nil
 }
 
-// compareServices compares current and desired service specs and returns list of changes
-func compareServices(current *swarm.Service, desired *compose.Service) []string {
-	var changes []string
+// desiredServiceSpec converts a compose service the same way the deployer does,
+// so the result can be compared with the running spec
+func (p *Planner) desiredServiceSpec(name string, svc *compose.Service, desired *DesiredState) (*swarm.ServiceSpec, error) {
+	spec, err := compose.ConvertToSwarmSpec(name, svc, p.stackName, desired.ComposeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinned, ok := desired.PinnedImages[name]; ok {
+		spec.TaskTemplate.ContainerSpec.Image = pinned
+	}
+
+	compose.ApplyDefaultRestartCondition(spec, desired.DefaultRestartCondition)
+
+	// Services without networks are attached to the stack's default network
+	if svc.Networks == nil {
+		spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{
+			{Target: fmt.Sprintf("%s_default", p.stackName)},
+		}
+	}
+
+	return spec, nil
+}
+
+// deployedSpecHash hashes desiredSpec as the deployer does before updating the
+// service: with secrets, configs and networks resolved to the swarm objects the
+// update would reference. Only called when no secret or config is rotated.
+func (p *Planner) deployedSpecHash(desiredSpec *swarm.ServiceSpec, currentSvc *swarm.Service, current *CurrentState, desired *DesiredState) (string, error) {
+	spec := *desiredSpec
+	spec.TaskTemplate.Networks = slices.Clone(desiredSpec.TaskTemplate.Networks)
+	if desiredSpec.TaskTemplate.ContainerSpec != nil {
+		container := *desiredSpec.TaskTemplate.ContainerSpec
+		container.Secrets = p.resolveSecretReferences(container.Secrets, currentSvc, current, desired)
+		container.Configs = p.resolveConfigReferences(container.Configs, currentSvc, current, desired)
+		spec.TaskTemplate.ContainerSpec = &container
+	}
+
+	for i, attachment := range spec.TaskTemplate.Networks {
+		name, ok := strings.CutPrefix(attachment.Target, p.stackName+"_")
+		if !ok {
+			continue
+		}
+		if net := desired.Networks[name]; net != nil && compose.IsExternal(net.External) {
+			spec.TaskTemplate.Networks[i].Target = externalResourceName(name, net.Name, net.External)
+		}
+	}
+
+	if spec.Labels == nil {
+		spec.Labels = make(map[string]string)
+	}
+	return ServiceSpecHash(&spec)
+}
+
+// resolveSecretReferences returns copies of refs pointing at the secrets the
+// deployer would use. External secrets keep the ID the service already holds.
+func (p *Planner) resolveSecretReferences(refs []*swarm.SecretReference, currentSvc *swarm.Service, current *CurrentState, desired *DesiredState) []*swarm.SecretReference {
+	if refs == nil {
+		return nil
+	}
+	var running []*swarm.SecretReference
+	if container := currentSvc.Spec.TaskTemplate.ContainerSpec; container != nil {
+		running = container.Secrets
+	}
 
-	// For MVP, we'll do a simple comparison
-	// In full implementation, this would compare all fields
-
-	// Compare image
-	if current.Spec.TaskTemplate.ContainerSpec != nil {
-		currentImage := current.Spec.TaskTemplate.ContainerSpec.Image
-		// Strip digest from current image for comparison
-		// Docker adds @sha256:... to images, but compose files don't have it
-		// This is a simplified comparison
-		if currentImage != desired.Image {
-			changes = append(changes, "image")
+	resolved := make([]*swarm.SecretReference, len(refs))
+	for i, ref := range refs {
+		r := *ref
+		name := strings.TrimPrefix(ref.SecretName, p.stackName+"_")
+		if sec := desired.Secrets[name]; sec != nil && compose.IsExternal(sec.External) {
+			r.SecretName = externalResourceName(name, sec.Name, sec.External)
+			for _, existing := range running {
+				if existing.SecretName == r.SecretName {
+					r.SecretID = existing.SecretID
+				}
+			}
+		} else if currentSec, ok := current.Secrets[name]; ok {
+			r.SecretID = currentSec.ID
+			r.SecretName = currentSec.Spec.Name
 		}
+		resolved[i] = &r
+	}
+	return resolved
+}
+
+// resolveConfigReferences is resolveSecretReferences for configs
+func (p *Planner) resolveConfigReferences(refs []*swarm.ConfigReference, currentSvc *swarm.Service, current *CurrentState, desired *DesiredState) []*swarm.ConfigReference {
+	if refs == nil {
+		return nil
+	}
+	var running []*swarm.ConfigReference
+	if container := currentSvc.Spec.TaskTemplate.ContainerSpec; container != nil {
+		running = container.Configs
 	}
 
-	// Compare replicas
-	if current.Spec.Mode.Replicated != nil && current.Spec.Mode.Replicated.Replicas != nil {
-		currentReplicas := *current.Spec.Mode.Replicated.Replicas
-		if desired.Deploy != nil && desired.Deploy.Replicas != nil {
-			desiredReplicas := uint64(*desired.Deploy.Replicas)
-			if desiredReplicas != currentReplicas {
-				changes = append(changes, "replicas")
+	resolved := make([]*swarm.ConfigReference, len(refs))
+	for i, ref := range refs {
+		r := *ref
+		name := strings.TrimPrefix(ref.ConfigName, p.stackName+"_")
+		if cfg := desired.Configs[name]; cfg != nil && compose.IsExternal(cfg.External) {
+			r.ConfigName = externalResourceName(name, cfg.Name, cfg.External)
+			for _, existing := range running {
+				if existing.ConfigName == r.ConfigName {
+					r.ConfigID = existing.ConfigID
+				}
 			}
+		} else if currentCfg, ok := current.Configs[name]; ok {
+			r.ConfigID = currentCfg.ID
+			r.ConfigName = currentCfg.Spec.Name
+		}
+		resolved[i] = &r
+	}
+	return resolved
+}
+
+// externalResourceName returns the swarm name of an external network, secret or config
+func externalResourceName(key, name string, external interface{}) string {
+	if extName := compose.ExternalName(external); extName != "" {
+		return extName
+	}
+	if name != "" {
+		return name
+	}
+	return key
+}
+
+// networkIndex builds the lookups used to compare network attachments
+func (p *Planner) networkIndex(current *CurrentState, desired *DesiredState) networkIndex {
+	index := networkIndex{
+		names:    make(map[string]string, len(current.Networks)),
+		external: make(map[string]bool),
+	}
+	for _, net := range current.Networks {
+		index.names[net.ID] = net.Spec.Name
+	}
+	for name, net := range desired.Networks {
+		if net != nil && compose.IsExternal(net.External) {
+			index.external[fmt.Sprintf("%s_%s", p.stackName, name)] = true
 		}
 	}
+	return index
+}
 
-	// More comparisons would go here...
-	// For now, if we have any uncertainty, mark as changed
-	if len(changes) == 0 && desired != nil {
-		// Conservative approach: assume service needs update if we can't verify it's the same
-		changes = append(changes, "configuration")
+// rotationChanges lists the rotated secrets and configs referenced by a service
+func rotationChanges(svc *compose.Service, rotatedSecrets, rotatedConfigs map[string]bool) []string {
+	var changes []string
+
+	// Invalid entries are reported by the converter at deploy time
+	secrets, _ := compose.ReferenceSources(svc.Secrets)
+	for _, name := range secrets {
+		if rotatedSecrets[name] {
+			changes = append(changes, "secret "+name)
+		}
+	}
+
+	configs, _ := compose.ReferenceSources(svc.Configs)
+	for _, name := range configs {
+		if rotatedConfigs[name] {
+			changes = append(changes, "config "+name)
+		}
 	}
 
 	return changes
@@ -2,6 +2,9 @@ package plan
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/docker/docker/api/types/swarm"
@@ -89,10 +92,18 @@ func TestCreatePlan_NoChanges(t *testing.T) {
 				Spec: swarm.ServiceSpec{
 					Annotations: swarm.Annotations{
 						Name: "test-stack_web",
+						Labels: map[string]string{
+							"com.docker.stack.namespace": "test-stack",
+							"com.stackman.deploy.id":     "previous-deploy",
+						},
 					},
 					TaskTemplate: swarm.TaskSpec{
 						ContainerSpec: &swarm.ContainerSpec{
-							Image: "nginx:1.21",
+							Image:  "nginx:1.21@sha256:abc",
+							Labels: map[string]string{"com.docker.stack.namespace": "test-stack"},
+						},
+						Networks: []swarm.NetworkAttachmentConfig{
+							{Target: "net123"},
 						},
 					},
 					Mode: swarm.ServiceMode{
@@ -122,7 +133,8 @@ func TestCreatePlan_NoChanges(t *testing.T) {
 	desired := &DesiredState{
 		Services: map[string]*compose.Service{
 			"web": {
-				Image: "nginx:1.21",
+				Image:    "nginx:1.21",
+				Networks: []interface{}{"frontend"},
 				Deploy: &compose.DeployConfig{
 					Replicas: &desiredReplicas,
 				},
@@ -139,20 +151,87 @@ func TestCreatePlan_NoChanges(t *testing.T) {
 	}
 
 	planner := NewPlanner(nil, "test-stack")
+	labelDeployedHash(t, planner, "web", current, desired)
+	plan, err := planner.CreatePlan(context.Background(), current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+
+	if !plan.IsEmpty() {
+		t.Errorf("Expected empty plan, got:\n%s", FormatDiff(plan))
+	}
+	if len(plan.Services) != 1 || plan.Services[0].Action != ActionNone {
+		t.Fatalf("Expected web to have action none, got %+v", plan.Services)
+	}
+	if len(plan.Services[0].Changes) != 0 {
+		t.Errorf("Expected no changes, got %v", plan.Services[0].Changes)
+	}
+}
+
+func TestCreatePlan_HealthcheckOnlyChange(t *testing.T) {
+	current := &CurrentState{
+		Services: map[string]swarm.Service{
+			"web": {
+				ID: "service123",
+				Spec: swarm.ServiceSpec{
+					Annotations: swarm.Annotations{
+						Name:   "test-stack_web",
+						Labels: map[string]string{"com.docker.stack.namespace": "test-stack"},
+					},
+					TaskTemplate: swarm.TaskSpec{
+						ContainerSpec: &swarm.ContainerSpec{
+							Image:  "nginx:1.21",
+							Labels: map[string]string{"com.docker.stack.namespace": "test-stack"},
+						},
+						Networks: []swarm.NetworkAttachmentConfig{{Target: "net123"}},
+					},
+				},
+			},
+		},
+		Networks: map[string]swarm.Network{
+			"default": {ID: "net123", Spec: swarm.NetworkSpec{Annotations: swarm.Annotations{Name: "test-stack_default"}}},
+		},
+	}
+	desired := &DesiredState{
+		Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.21"},
+		},
+	}
+
+	planner := NewPlanner(nil, "test-stack")
+	labelDeployedHash(t, planner, "web", current, desired)
+	desired.Services["web"].HealthCheck = &compose.HealthCheck{Test: []string{"CMD", "curl", "-f", "http://localhost"}}
+
 	plan, err := planner.CreatePlan(context.Background(), current, desired)
 	if err != nil {
 		t.Fatalf("CreatePlan failed: %v", err)
 	}
+	if len(plan.Services) != 1 || plan.Services[0].Action != ActionUpdate {
+		t.Fatalf("Expected web to be updated, got %+v", plan.Services)
+	}
+	if got := plan.Services[0].Changes; len(got) != 1 || got[0] != "configuration" {
+		t.Errorf("Expected a configuration change, got %v", got)
+	}
+	if len(plan.Services[0].Details) != 0 {
+		t.Errorf("Expected no compared field to differ, got %+v", plan.Services[0].Details)
+	}
+}
 
-	// Note: In current implementation, compareServices is conservative and marks services as changed
-	// This is acceptable for MVP - we can refine comparison logic later
-	if plan.IsEmpty() {
-		// If implementation improves to detect no changes, this is good
-		t.Logf("Plan correctly detected no changes")
-	} else {
-		// Conservative approach - marks as update even if same
-		t.Logf("Plan conservatively marks service as changed (acceptable for MVP)")
+// labelDeployedHash records on the current service the spec hash the deployer
+// would have labelled it with when deploying desired
+func labelDeployedHash(t *testing.T, planner *Planner, name string, current *CurrentState, desired *DesiredState) {
+	t.Helper()
+	svc := current.Services[name]
+	spec, err := planner.desiredServiceSpec(name, desired.Services[name], desired)
+	if err != nil {
+		t.Fatalf("desiredServiceSpec failed: %v", err)
+	}
+	hash, err := planner.deployedSpecHash(spec, &svc, current, desired)
+	if err != nil {
+		t.Fatalf("deployedSpecHash failed: %v", err)
 	}
+	svc.Spec.Labels[SpecHashLabel] = hash
+	current.Services[name] = svc
 }
 
 func TestCreatePlan_DeleteOrphaned(t *testing.T) {
@@ -225,6 +304,54 @@ func TestCreatePlan_DeleteOrphaned(t *testing.T) {
 	}
 }
 
+func TestCreatePlan_DisabledServiceNotOrphaned(t *testing.T) {
+	current := &CurrentState{
+		Services: map[string]swarm.Service{
+			"debug": {ID: "service456", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "test-stack_debug"}}},
+		},
+		Networks: make(map[string]swarm.Network),
+		Volumes:  make(map[string]struct{}),
+		Configs:  make(map[string]swarm.Config),
+		Secrets:  make(map[string]swarm.Secret),
+	}
+	desired := BuildDesiredState(&compose.ComposeFile{DisabledServices: []string{"debug"}})
+
+	plan, err := NewPlanner(nil, "test-stack").CreatePlan(context.Background(), current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	if orphans := plan.Orphans(); len(orphans) != 0 {
+		t.Errorf("Expected a service of an inactive profile not to be orphaned, got %v", orphans)
+	}
+}
+
+func TestPlan_Orphans(t *testing.T) {
+	p := &Plan{
+		Networks: []NetworkAction{
+			{Name: "front", Action: ActionNone},
+			{Name: "legacy", Action: ActionDelete},
+			{Name: "default", Action: ActionDelete},
+		},
+		Volumes: []VolumeAction{{Name: "cache", Action: ActionDelete}},
+		Configs: []ConfigAction{
+			// Rotation: the old version is replaced, not orphaned
+			{Name: "app_conf", Action: ActionCreate},
+			{Name: "app_conf", Action: ActionDelete},
+			{Name: "old_conf", Action: ActionDelete},
+		},
+		Secrets: []SecretAction{{Name: "token", Action: ActionDelete}},
+		Services: []ServiceAction{
+			{Name: "web", Action: ActionUpdate},
+			{Name: "old-service", Action: ActionDelete},
+		},
+	}
+
+	want := []string{"config old_conf", "network legacy", "secret token", "service old-service", "volume cache"}
+	if got := p.Orphans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Orphans() = %v, want %v", got, want)
+	}
+}
+
 func TestPlan_IsEmpty(t *testing.T) {
 	// Test IsEmpty with empty plan
 	emptyPlan := &Plan{
@@ -299,3 +426,178 @@ func TestBuildDesiredState(t *testing.T) {
 		t.Errorf("Expected 1 volume, got %d", len(state.Volumes))
 	}
 }
+
+func TestCreatePlan_SecretRelativeToComposeDir(t *testing.T) {
+	composeDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", "")
+	t.Chdir(t.TempDir())
+
+	if err := os.WriteFile(filepath.Join(composeDir, "db_password.txt"), []byte("s3cret"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	current := &CurrentState{
+		Services: make(map[string]swarm.Service),
+		Networks: make(map[string]swarm.Network),
+		Volumes:  make(map[string]struct{}),
+		Configs:  make(map[string]swarm.Config),
+		Secrets:  make(map[string]swarm.Secret),
+	}
+	desired := BuildDesiredState(&compose.ComposeFile{
+		Secrets: map[string]*compose.Secret{"db_password": {File: "./db_password.txt"}},
+		Dir:     composeDir,
+	})
+
+	plan, err := NewPlanner(nil, "test-stack").CreatePlan(context.Background(), current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	if len(plan.Secrets) != 1 || plan.Secrets[0].Hash != compose.ContentHash([]byte("s3cret")) {
+		t.Errorf("Expected the secret to be read next to the compose file, got %+v", plan.Secrets)
+	}
+}
+
+func TestCreatePlan_ConfigRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "nginx.conf"), []byte("worker_processes 2;"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		currentData   string
+		expectRotated bool
+	}{
+		{"changed file", "worker_processes 1;", true},
+		{"unchanged file", "worker_processes 2;", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currentHash := compose.ContentHash([]byte(tt.currentData))
+			current := &CurrentState{
+				Services: map[string]swarm.Service{
+					"web": {ID: "svc1", Spec: swarm.ServiceSpec{
+						TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.25"}},
+					}},
+				},
+				Networks: make(map[string]swarm.Network),
+				Volumes:  make(map[string]struct{}),
+				Configs: map[string]swarm.Config{
+					"nginx": {ID: "cfg_old", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{
+						Name:   compose.VersionedName("test-stack", "nginx", currentHash),
+						Labels: map[string]string{compose.ContentHashLabel: currentHash},
+					}}},
+				},
+				Secrets: make(map[string]swarm.Secret),
+			}
+
+			desired := &DesiredState{
+				Services: map[string]*compose.Service{
+					"web": {Image: "nginx:1.25", Configs: []interface{}{"nginx"}},
+				},
+				Networks: make(map[string]*compose.Network),
+				Volumes:  make(map[string]*compose.Volume),
+				Configs: map[string]*compose.Config{
+					"nginx": {File: "nginx.conf"},
+				},
+				Secrets: make(map[string]*compose.Secret),
+			}
+
+			plan, err := NewPlanner(nil, "test-stack").CreatePlan(context.Background(), current, desired)
+			if err != nil {
+				t.Fatalf("CreatePlan failed: %v", err)
+			}
+
+			var creates, deletes int
+			for _, cfg := range plan.Configs {
+				switch cfg.Action {
+				case ActionCreate:
+					creates++
+					if cfg.Hash != compose.ContentHash([]byte("worker_processes 2;")) {
+						t.Errorf("Create action has wrong hash: %s", cfg.Hash)
+					}
+				case ActionDelete:
+					deletes++
+					if cfg.ConfigID != "cfg_old" {
+						t.Errorf("Expected old config cfg_old to be deleted, got %s", cfg.ConfigID)
+					}
+				}
+			}
+
+			rotationChange := false
+			for _, change := range plan.Services[0].Changes {
+				if change == "config nginx" {
+					rotationChange = true
+				}
+			}
+
+			if tt.expectRotated {
+				if creates != 1 || deletes != 1 {
+					t.Errorf("Expected create+delete rotation, got %d creates and %d deletes", creates, deletes)
+				}
+				if !rotationChange || plan.Services[0].Action != ActionUpdate {
+					t.Errorf("Expected service web to be updated for rotated config, got %+v", plan.Services[0])
+				}
+			} else {
+				if creates != 0 || deletes != 0 {
+					t.Errorf("Expected no rotation, got %d creates and %d deletes", creates, deletes)
+				}
+				if rotationChange {
+					t.Error("Service should not be updated for an unchanged config")
+				}
+			}
+		})
+	}
+}
+
+func TestSameImage(t *testing.T) {
+	tests := []struct {
+		current, desired string
+		same             bool
+	}{
+		{"nginx:1.25", "nginx:1.25", true},
+		{"nginx:1.25@sha256:aaa", "nginx:1.25", true},
+		{"nginx:1.25", "nginx:1.26", false},
+		{"nginx:1.25@sha256:aaa", "nginx:1.25@sha256:aaa", true},
+		// Same tag, retagged upstream: only the digest tells them apart
+		{"nginx:stable@sha256:aaa", "nginx:stable@sha256:bbb", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameImage(tt.current, tt.desired); got != tt.same {
+			t.Errorf("sameImage(%q, %q) = %v, want %v", tt.current, tt.desired, got, tt.same)
+		}
+	}
+}
+
+func TestCreatePlan_PinnedImageComparedByDigest(t *testing.T) {
+	current := &CurrentState{
+		Services: map[string]swarm.Service{
+			"web": {
+				ID: "service123",
+				Spec: swarm.ServiceSpec{
+					TaskTemplate: swarm.TaskSpec{
+						ContainerSpec: &swarm.ContainerSpec{Image: "nginx:stable@sha256:aaa"},
+					},
+				},
+			},
+		},
+	}
+	desired := &DesiredState{
+		Services:     map[string]*compose.Service{"web": {Image: "nginx:stable"}},
+		PinnedImages: map[string]string{"web": "nginx:stable@sha256:bbb"},
+	}
+
+	plan, err := NewPlanner(nil, "mystack").CreatePlan(context.Background(), current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+
+	details := plan.Services[0].Details
+	if len(details) == 0 || details[0].Field != "image" || details[0].After != "nginx:stable@sha256:bbb" {
+		t.Errorf("Expected image change to the new digest, got %+v", details)
+	}
+}
@@ -12,6 +12,13 @@ func BuildDesiredState(composeFile *compose.ComposeFile) *DesiredState {
 		Volumes:  make(map[string]*compose.Volume),
 		Configs:  make(map[string]*compose.Config),
 		Secrets:  make(map[string]*compose.Secret),
+
+		ComposeDir:       composeFile.Dir,
+		DisabledServices: make(map[string]bool, len(composeFile.DisabledServices)),
+	}
+
+	for _, name := range composeFile.DisabledServices {
+		state.DisabledServices[name] = true
 	}
 
 	// Copy services
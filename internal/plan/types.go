@@ -1,6 +1,8 @@
 package plan
 
 import (
+	"sort"
+
 	"github.com/SomeBlackMagic/stackman/internal/compose"
 
 	"github.com/docker/docker/api/types/swarm"
@@ -18,70 +20,81 @@ const (
 
 // ServiceAction represents a planned change to a service
 type ServiceAction struct {
-	Name        string
-	Action      ActionType
-	CurrentSpec *swarm.ServiceSpec
-	DesiredSpec *swarm.ServiceSpec
-	CurrentMeta *swarm.Meta
-	ServiceID   string
-	Changes     []string // Human-readable list of changes
+	Name        string             `json:"name"`
+	Action      ActionType         `json:"action"`
+	CurrentSpec *swarm.ServiceSpec `json:"-"`
+	DesiredSpec *swarm.ServiceSpec `json:"-"`
+	CurrentMeta *swarm.Meta        `json:"-"`
+	ServiceID   string             `json:"serviceId,omitempty"`
+	Changes     []string           `json:"changes,omitempty"` // Human-readable list of changes
+	Details     []FieldChange      `json:"details,omitempty"` // Before/after values of changed fields
+}
+
+// FieldChange holds the before/after value of a changed service field.
+// Multi-valued fields use one line per value.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
 }
 
 // NetworkAction represents a planned change to a network
 type NetworkAction struct {
-	Name      string
-	Action    ActionType
-	NetworkID string
-	Driver    string
-	Labels    map[string]string
+	Name      string            `json:"name"`
+	Action    ActionType        `json:"action"`
+	NetworkID string            `json:"networkId,omitempty"`
+	Driver    string            `json:"driver,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
 // VolumeAction represents a planned change to a volume
 type VolumeAction struct {
-	Name     string
-	Action   ActionType
-	VolumeID string
-	Driver   string
-	Labels   map[string]string
+	Name     string            `json:"name"`
+	Action   ActionType        `json:"action"`
+	VolumeID string            `json:"volumeId,omitempty"`
+	Driver   string            `json:"driver,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
 }
 
 // SecretAction represents a planned change to a secret
 type SecretAction struct {
-	Name     string
-	Action   ActionType
-	SecretID string
-	Labels   map[string]string
-	Data     []byte // Only set for create/update
+	Name     string            `json:"name"`
+	Action   ActionType        `json:"action"`
+	SecretID string            `json:"secretId,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Data     []byte            `json:"-"`              // Only set for create/update
+	Hash     string            `json:"hash,omitempty"` // SHA-256 of the secret data, used for rotation
 }
 
 // ConfigAction represents a planned change to a config
 type ConfigAction struct {
-	Name     string
-	Action   ActionType
-	ConfigID string
-	Labels   map[string]string
-	Data     []byte // Only set for create/update
+	Name     string            `json:"name"`
+	Action   ActionType        `json:"action"`
+	ConfigID string            `json:"configId,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Data     []byte            `json:"-"`              // Only set for create/update
+	Hash     string            `json:"hash,omitempty"` // SHA-256 of the config data, used for rotation
 }
 
 // Plan represents the full deployment plan
 type Plan struct {
-	StackName string
+	StackName string `json:"stack"`
 
 	// Resources (applied first, in order)
-	Networks []NetworkAction
-	Volumes  []VolumeAction
-	Configs  []ConfigAction
-	Secrets  []SecretAction
+	Networks []NetworkAction `json:"networks"`
+	Volumes  []VolumeAction  `json:"volumes"`
+	Configs  []ConfigAction  `json:"configs"`
+	Secrets  []SecretAction  `json:"secrets"`
 
 	// Services (applied after resources)
-	Services []ServiceAction
+	Services []ServiceAction `json:"services"`
 
 	// Cleanup (applied last, only with --prune)
-	OrphanedServices []string
-	OrphanedNetworks []string
-	OrphanedVolumes  []string
-	OrphanedConfigs  []string
-	OrphanedSecrets  []string
+	OrphanedServices []string `json:"orphanedServices,omitempty"`
+	OrphanedNetworks []string `json:"orphanedNetworks,omitempty"`
+	OrphanedVolumes  []string `json:"orphanedVolumes,omitempty"`
+	OrphanedConfigs  []string `json:"orphanedConfigs,omitempty"`
+	OrphanedSecrets  []string `json:"orphanedSecrets,omitempty"`
 }
 
 // IsEmpty returns true if the plan has no changes
@@ -128,6 +141,57 @@ func (p *Plan) IsEmpty() bool {
 		len(p.OrphanedConfigs) == 0 && len(p.OrphanedSecrets) == 0
 }
 
+// Orphans lists the live resources the compose file no longer declares, as
+// "<kind> <name>" sorted by kind and name. The old version of a rotated config
+// or secret is replaced rather than orphaned, and the stack's default network
+// is implicit, so neither is listed.
+func (p *Plan) Orphans() []string {
+	var orphans []string
+	for _, net := range p.Networks {
+		if net.Action == ActionDelete && net.Name != "default" {
+			orphans = append(orphans, "network "+net.Name)
+		}
+	}
+	for _, vol := range p.Volumes {
+		if vol.Action == ActionDelete {
+			orphans = append(orphans, "volume "+vol.Name)
+		}
+	}
+
+	createdConfigs := make(map[string]bool)
+	for _, cfg := range p.Configs {
+		if cfg.Action == ActionCreate {
+			createdConfigs[cfg.Name] = true
+		}
+	}
+	for _, cfg := range p.Configs {
+		if cfg.Action == ActionDelete && !createdConfigs[cfg.Name] {
+			orphans = append(orphans, "config "+cfg.Name)
+		}
+	}
+
+	createdSecrets := make(map[string]bool)
+	for _, sec := range p.Secrets {
+		if sec.Action == ActionCreate {
+			createdSecrets[sec.Name] = true
+		}
+	}
+	for _, sec := range p.Secrets {
+		if sec.Action == ActionDelete && !createdSecrets[sec.Name] {
+			orphans = append(orphans, "secret "+sec.Name)
+		}
+	}
+
+	for _, svc := range p.Services {
+		if svc.Action == ActionDelete {
+			orphans = append(orphans, "service "+svc.Name)
+		}
+	}
+
+	sort.Strings(orphans)
+	return orphans
+}
+
 // CurrentState represents the current state of the stack in Swarm
 type CurrentState struct {
 	Services map[string]swarm.Service
@@ -144,4 +208,19 @@ type DesiredState struct {
 	Volumes  map[string]*compose.Volume
 	Configs  map[string]*compose.Config
 	Secrets  map[string]*compose.Secret
+
+	// ComposeDir is the compose file directory, used to resolve relative bind sources
+	ComposeDir string
+
+	// PinnedImages holds digest-pinned image references by service name
+	// when the deployment pins digests; images are then compared by digest
+	PinnedImages map[string]string
+
+	// DefaultRestartCondition is the restart condition the deployment gives
+	// services without one (empty = Swarm default)
+	DefaultRestartCondition string
+
+	// DisabledServices holds the services left out by the active profiles,
+	// which are kept rather than deleted when already deployed
+	DisabledServices map[string]bool
 }
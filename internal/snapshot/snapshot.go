@@ -21,24 +21,31 @@ func CreateSnapshot(ctx context.Context, stackDeployer *swarm.StackDeployer) *sw
 	return snapshot
 }
 
-// rollback restores the stack to a previous snapshot state
-func Rollback(ctx context.Context, stackDeployer *swarm.StackDeployer, snapshot *swarm.StackSnapshot) {
+// rollback restores the stack to a previous snapshot state.
+// The rollback gets its own timeout and is not cancelled with ctx, since ctx
+// usually belongs to the deployment that just failed or was interrupted.
+func Rollback(ctx context.Context, stackDeployer *swarm.StackDeployer, snapshot *swarm.StackSnapshot, timeout time.Duration) error {
 	if snapshot == nil {
 		log.Println("No snapshot available, cannot rollback")
-		return
+		return fmt.Errorf("no snapshot available")
 	}
 
-	fmt.Println("Starting rollback to previous state...")
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	log.Println("Starting rollback to previous state...")
 
 	// Create new context with timeout for rollback
-	rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	rollbackCtx, rollbackCancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
 	defer rollbackCancel()
 
 	if err := stackDeployer.Rollback(rollbackCtx, snapshot); err != nil {
 		log.Printf("Rollback failed: %v", err)
 		log.Println("Manual intervention may be required")
-		return
+		return err
 	}
 
-	fmt.Println("Rollback completed successfully")
+	log.Println("Rollback completed successfully")
+	return nil
 }
@@ -0,0 +1,218 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+// DefaultRetain is the number of snapshots kept per stack
+const DefaultRetain = 10
+
+// idLayout formats snapshot IDs from their creation time (UTC). The fraction is
+// left out for whole seconds, so IDs of older snapshots still parse.
+const idLayout = "20060102T150405.999999999Z"
+
+// Store persists stack snapshots as JSON files in Dir/<stack>/<id>.json
+type Store struct {
+	Dir    string
+	Retain int
+}
+
+// Info describes a persisted snapshot without loading it
+type Info struct {
+	ID        string
+	CreatedAt time.Time
+	Path      string
+}
+
+// DefaultDir returns STACKMAN_SNAPSHOT_DIR, or ~/.stackman/snapshots when unset
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("STACKMAN_SNAPSHOT_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine snapshot directory: %w", err)
+	}
+	return filepath.Join(home, ".stackman", "snapshots"), nil
+}
+
+// NewStore creates a store rooted at dir that keeps DefaultRetain snapshots per stack
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, Retain: DefaultRetain}
+}
+
+// Save writes the snapshot and prunes the oldest ones beyond Retain.
+// It returns the snapshot ID.
+func (s *Store) Save(snap *swarm.StackSnapshot) (string, error) {
+	stackDir := filepath.Join(s.Dir, snap.StackName)
+	if err := os.MkdirAll(stackDir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	id := snap.CreatedAt.UTC().Format(idLayout)
+	path := filepath.Join(stackDir, id+".json")
+
+	// Write to a temp file first so a crash never leaves a truncated snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := s.prune(snap.StackName); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// List returns the snapshots of a stack, newest first
+func (s *Store) List(stackName string) ([]Info, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, stackName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var infos []Info
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		createdAt, err := time.Parse(idLayout, id)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, Info{
+			ID:        id,
+			CreatedAt: createdAt,
+			Path:      filepath.Join(s.Dir, stackName, entry.Name()),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+	return infos, nil
+}
+
+// Load reads a snapshot by ID, or by timestamp (RFC3339 or the ID format),
+// in which case the newest snapshot taken at or before that time is used.
+// An empty ref loads the latest snapshot.
+func (s *Store) Load(stackName, ref string) (*swarm.StackSnapshot, error) {
+	infos, err := s.List(stackName)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("no snapshots found for stack %s in %s (a snapshot is saved by every successful apply)", stackName, s.Dir)
+	}
+
+	if ref == "" {
+		return readSnapshot(infos[0].Path)
+	}
+
+	for _, info := range infos {
+		if info.ID == ref {
+			return readSnapshot(info.Path)
+		}
+	}
+
+	at, err := parseTimestamp(ref)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %q not found for stack %s (use 'stackman rollback -n %s --list')", ref, stackName, stackName)
+	}
+	for _, info := range infos {
+		if !info.CreatedAt.After(at) {
+			return readSnapshot(info.Path)
+		}
+	}
+	return nil, fmt.Errorf("no snapshot of stack %s was taken at or before %s", stackName, at.Format(time.RFC3339))
+}
+
+// ReferencedResources returns the IDs of the secrets and configs used by the
+// services of the stack's saved snapshots, which a rollback to them needs
+func (s *Store) ReferencedResources(stackName string) (map[string]bool, error) {
+	infos, err := s.List(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for _, info := range infos {
+		snap, err := readSnapshot(info.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range snap.Services {
+			spec := svc.Service.Spec.TaskTemplate.ContainerSpec
+			if spec == nil {
+				continue
+			}
+			for _, ref := range spec.Secrets {
+				ids[ref.SecretID] = true
+			}
+			for _, ref := range spec.Configs {
+				ids[ref.ConfigID] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// prune removes the oldest snapshots beyond Retain
+func (s *Store) prune(stackName string) error {
+	if s.Retain <= 0 {
+		return nil
+	}
+
+	infos, err := s.List(stackName)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos[min(s.Retain, len(infos)):] {
+		if err := os.Remove(info.Path); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s: %w", info.ID, err)
+		}
+	}
+	return nil
+}
+
+// readSnapshot decodes a snapshot file
+func readSnapshot(path string) (*swarm.StackSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap swarm.StackSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// parseTimestamp accepts RFC3339 or the snapshot ID layout
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(idLayout, value)
+}
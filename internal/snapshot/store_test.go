@@ -0,0 +1,282 @@
+package snapshot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerswarm "github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/swarm"
+)
+
+func testSnapshot(createdAt time.Time, image string) *swarm.StackSnapshot {
+	return &swarm.StackSnapshot{
+		StackName:   "mystack",
+		CreatedAt:   createdAt,
+		ExistingIDs: map[string]bool{"svc1": true},
+		Services: map[string]swarm.ServiceSnapshot{
+			"svc1": {Service: dockerswarm.Service{
+				ID: "svc1",
+				Spec: dockerswarm.ServiceSpec{
+					Annotations: dockerswarm.Annotations{Name: "mystack_web"},
+					TaskTemplate: dockerswarm.TaskSpec{
+						ContainerSpec: &dockerswarm.ContainerSpec{Image: image},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func TestStore_ListNewestFirstAndPrune(t *testing.T) {
+	store := NewStore(t.TempDir())
+	store.Retain = 2
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, image := range []string{"web:1", "web:2", "web:3"} {
+		if _, err := store.Save(testSnapshot(base.Add(time.Duration(i)*time.Hour), image)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	infos, err := store.List("mystack")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 retained snapshots, got %d", len(infos))
+	}
+	if infos[0].ID != "20261001T140000Z" || infos[1].ID != "20261001T130000Z" {
+		t.Errorf("Expected newest first, got %s, %s", infos[0].ID, infos[1].ID)
+	}
+
+	if infos, _ := store.List("otherstack"); len(infos) != 0 {
+		t.Errorf("Expected no snapshots for unknown stack, got %d", len(infos))
+	}
+}
+
+func TestStore_Load(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, image := range []string{"web:1", "web:2", "web:3"} {
+		if _, err := store.Save(testSnapshot(base.Add(time.Duration(i)*time.Hour), image)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		ref   string
+		image string
+	}{
+		{"20261001T120000Z", "web:1"},
+		{"20261001T130000Z", "web:2"},
+		// Timestamps pick the newest snapshot at or before them
+		{"2026-10-01T13:30:00Z", "web:2"},
+		{"2026-10-01T16:00:00+02:00", "web:3"},
+	}
+
+	for _, tt := range tests {
+		snap, err := store.Load("mystack", tt.ref)
+		if err != nil {
+			t.Fatalf("Load(%q) failed: %v", tt.ref, err)
+		}
+		if got := snap.Services["svc1"].Service.Spec.TaskTemplate.ContainerSpec.Image; got != tt.image {
+			t.Errorf("Load(%q): expected image %s, got %s", tt.ref, tt.image, got)
+		}
+	}
+
+	for _, ref := range []string{"2026-10-01T11:00:00Z", "bogus"} {
+		if _, err := store.Load("mystack", ref); err == nil {
+			t.Errorf("Expected Load(%q) to fail", ref)
+		}
+	}
+}
+
+// updateRecorder records the specs sent to ServiceUpdate
+type updateRecorder struct {
+	*swarm.MockDockerClient
+	updated map[string]dockerswarm.ServiceSpec
+}
+
+func (r *updateRecorder) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]dockerswarm.Service, error) {
+	return []dockerswarm.Service{{ID: "svc1", Spec: dockerswarm.ServiceSpec{
+		Annotations: dockerswarm.Annotations{Name: "mystack_web"},
+		TaskTemplate: dockerswarm.TaskSpec{
+			ContainerSpec: &dockerswarm.ContainerSpec{Image: "web:3"},
+		},
+	}}}, nil
+}
+
+func (r *updateRecorder) ServiceUpdate(ctx context.Context, serviceID string, version dockerswarm.Version, service dockerswarm.ServiceSpec, options types.ServiceUpdateOptions) (dockerswarm.ServiceUpdateResponse, error) {
+	r.updated[serviceID] = service
+	return dockerswarm.ServiceUpdateResponse{}, nil
+}
+
+func TestRollback_ToNonLatestSnapshot(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, image := range []string{"web:1", "web:2", "web:3"} {
+		if _, err := store.Save(testSnapshot(base.Add(time.Duration(i)*time.Hour), image)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	snap, err := store.Load("mystack", "20261001T120000Z")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cli := &updateRecorder{MockDockerClient: &swarm.MockDockerClient{}, updated: make(map[string]dockerswarm.ServiceSpec)}
+	if err := Rollback(context.Background(), swarm.NewStackDeployer(cli, "mystack", 3), snap, time.Minute); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	spec, ok := cli.updated["svc1"]
+	if !ok {
+		t.Fatal("Expected svc1 to be updated")
+	}
+	if image := spec.TaskTemplate.ContainerSpec.Image; image != "web:1" {
+		t.Errorf("Expected svc1 restored to web:1, got %s", image)
+	}
+}
+
+func TestStore_SaveLoadRoundTrip(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	replicas := uint64(3)
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	snap := &swarm.StackSnapshot{
+		StackName:   "mystack",
+		CreatedAt:   createdAt,
+		ExistingIDs: map[string]bool{"svc1": true},
+		Services: map[string]swarm.ServiceSnapshot{
+			"svc1": {
+				Service: dockerswarm.Service{
+					ID:   "svc1",
+					Meta: dockerswarm.Meta{Version: dockerswarm.Version{Index: 42}},
+					Spec: dockerswarm.ServiceSpec{
+						Annotations: dockerswarm.Annotations{
+							Name:   "mystack_web",
+							Labels: map[string]string{"com.docker.stack.namespace": "mystack"},
+						},
+						TaskTemplate: dockerswarm.TaskSpec{
+							ContainerSpec: &dockerswarm.ContainerSpec{
+								Image: "web:1",
+								Env:   []string{"MODE=prod"},
+								Args:  []string{"--port", "80"},
+							},
+							Networks: []dockerswarm.NetworkAttachmentConfig{{Target: "net1", Aliases: []string{"web"}}},
+						},
+						Mode: dockerswarm.ServiceMode{Replicated: &dockerswarm.ReplicatedService{Replicas: &replicas}},
+						EndpointSpec: &dockerswarm.EndpointSpec{
+							Ports: []dockerswarm.PortConfig{{Protocol: dockerswarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080}},
+						},
+					},
+				},
+				Tasks: []dockerswarm.Task{{ID: "task1", ServiceID: "svc1", Slot: 1}},
+			},
+		},
+		Resources: swarm.ResourceSnapshot{
+			Networks: map[string]string{"mystack_default": "net1"},
+			Volumes:  map[string]string{},
+		},
+	}
+
+	id, err := store.Save(snap)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := store.Load("mystack", id)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected CreatedAt %s, got %s", createdAt, loaded.CreatedAt)
+	}
+	loaded.CreatedAt = snap.CreatedAt
+
+	if !reflect.DeepEqual(loaded, snap) {
+		t.Errorf("Snapshot did not round-trip:\ngot  %+v\nwant %+v", loaded, snap)
+	}
+}
+
+func TestStore_LoadLatest(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Load("mystack", ""); err == nil || !strings.Contains(err.Error(), "no snapshots found") {
+		t.Errorf("Expected a no-snapshots error, got %v", err)
+	}
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, image := range []string{"web:1", "web:2", "web:3"} {
+		if _, err := store.Save(testSnapshot(base.Add(time.Duration(i)*time.Hour), image)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	snap, err := store.Load("mystack", "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := snap.Services["svc1"].Service.Spec.TaskTemplate.ContainerSpec.Image; got != "web:3" {
+		t.Errorf("Expected the latest snapshot (web:3), got %s", got)
+	}
+}
+
+func TestStore_SubSecondSnapshotsKeptApart(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, image := range []string{"web:1", "web:2"} {
+		if _, err := store.Save(testSnapshot(base.Add(time.Duration(i)*250*time.Millisecond), image)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	infos, err := store.List("mystack")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(infos) != 2 || infos[0].ID != "20261001T120000.25Z" || infos[1].ID != "20261001T120000Z" {
+		t.Fatalf("Expected two snapshots within the same second, newest first, got %+v", infos)
+	}
+
+	snap, err := store.Load("mystack", infos[0].ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if image := snap.Services["svc1"].Service.Spec.TaskTemplate.ContainerSpec.Image; image != "web:2" {
+		t.Errorf("Expected the newer snapshot (web:2), got %s", image)
+	}
+}
+
+func TestStore_ReferencedResources(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"secret-v1", "secret-v2"} {
+		snap := testSnapshot(base.Add(time.Duration(i)*time.Hour), "web:1")
+		spec := snap.Services["svc1"].Service.Spec.TaskTemplate.ContainerSpec
+		spec.Secrets = []*dockerswarm.SecretReference{{SecretID: id}}
+		spec.Configs = []*dockerswarm.ConfigReference{{ConfigID: "config-v1"}}
+		if _, err := store.Save(snap); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	ids, err := store.ReferencedResources("mystack")
+	if err != nil {
+		t.Fatalf("ReferencedResources failed: %v", err)
+	}
+	want := map[string]bool{"secret-v1": true, "secret-v2": true, "config-v1": true}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ReferencedResources() = %v, want %v", ids, want)
+	}
+}
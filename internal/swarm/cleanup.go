@@ -4,17 +4,33 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
 
 	"github.com/SomeBlackMagic/stackman/internal/compose"
 )
 
-// removeObsoleteServices removes services that exist in the stack but not in the compose file
-func (d *StackDeployer) removeObsoleteServices(ctx context.Context, services map[string]*compose.Service) error {
+// serviceRemovalPollInterval is how often removed services are inspected until they are gone
+var serviceRemovalPollInterval = 500 * time.Millisecond
+
+// serviceRemovalTimeout bounds the wait for removed services to disappear
+var serviceRemovalTimeout = 2 * time.Minute
+
+// networkReleasePollInterval is how often tasks are listed while waiting for a network to be released
+var networkReleasePollInterval = time.Second
+
+// removeObsoleteServices removes services that exist in the stack but not in the compose file.
+// Services left out by inactive profiles are still declared and are kept.
+func (d *StackDeployer) removeObsoleteServices(ctx context.Context, composeFile *compose.ComposeFile) error {
 	// Get current services in stack
 	currentServices, err := d.GetStackServices(ctx)
 	if err != nil {
@@ -28,15 +44,17 @@ func (d *StackDeployer) removeObsoleteServices(ctx context.Context, services map
 
 	// Build map of desired service names
 	desiredServices := make(map[string]bool)
-	for name := range services {
-		fullName := fmt.Sprintf("%s_%s", d.stackName, name)
-		desiredServices[fullName] = true
+	for name := range composeFile.Services {
+		desiredServices[fmt.Sprintf("%s_%s", d.stackName, name)] = true
+	}
+	for _, name := range composeFile.DisabledServices {
+		desiredServices[fmt.Sprintf("%s_%s", d.stackName, name)] = true
 	}
 
-	// Find services to remove
+	// Find services to remove; services outside ServiceFilter are never pruned
 	var servicesToRemove []swarm.Service
 	for _, svc := range currentServices {
-		if !desiredServices[svc.Spec.Name] {
+		if !desiredServices[svc.Spec.Name] && d.runningServiceSelected(svc) {
 			servicesToRemove = append(servicesToRemove, svc)
 		}
 	}
@@ -47,11 +65,15 @@ func (d *StackDeployer) removeObsoleteServices(ctx context.Context, services map
 		return nil
 	}
 
+	sort.Slice(servicesToRemove, func(i, j int) bool {
+		return servicesToRemove[i].Spec.Name < servicesToRemove[j].Spec.Name
+	})
+
 	// Remove obsolete services
 	log.Printf("Found %d obsolete service(s) to remove", len(servicesToRemove))
 	for _, svc := range servicesToRemove {
 		log.Printf("Removing obsolete service: %s", svc.Spec.Name)
-		if err := d.cli.ServiceRemove(ctx, svc.ID); err != nil {
+		if err := d.cli.ServiceRemove(d.mutationContext(ctx), svc.ID); err != nil {
 			return fmt.Errorf("failed to remove service %s: %w", svc.Spec.Name, err)
 		}
 		log.Printf("Service %s marked for removal", svc.Spec.Name)
@@ -67,28 +89,127 @@ func (d *StackDeployer) removeObsoleteServices(ctx context.Context, services map
 	return nil
 }
 
-// waitForServicesRemoval waits for services to be completely removed
+// pruneOrphanedResources removes the stack's networks, volumes, secrets and configs
+// that the compose file no longer declares. The default network is implicit and
+// kept, and previous versions of declared secrets and configs are left to
+// RemoveRotatedResources, since rolled-back services may still need them.
+func (d *StackDeployer) pruneOrphanedResources(ctx context.Context, composeFile *compose.ComposeFile) error {
+	// Services outside the filter may still use them
+	if d.ServiceFilter != nil {
+		log.Printf("Filter %s: keeping orphaned networks, volumes, secrets and configs of stack %s", d.ServiceFilter, d.stackName)
+		return nil
+	}
+
+	stackFilter := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", d.stackName)),
+	)
+
+	// Remove networks
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	for _, net := range networks {
+		name := d.stackScopedName(net.Name)
+		if _, declared := composeFile.Networks[name]; declared || name == "default" {
+			continue
+		}
+
+		// Tasks of removed services keep their network attachments until torn down
+		if err := d.waitForNetworkRelease(ctx, net); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		log.Printf("Removing orphaned network: %s", net.Name)
+		if err := d.cli.NetworkRemove(d.mutationContext(ctx), net.ID); err != nil {
+			log.Printf("Warning: failed to remove network %s: %v", net.Name, err)
+		}
+	}
+
+	// Remove volumes
+	volumes, err := d.cli.VolumeList(ctx, volume.ListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	for _, vol := range volumes.Volumes {
+		if _, declared := composeFile.Volumes[d.stackScopedName(vol.Name)]; declared {
+			continue
+		}
+
+		log.Printf("Removing orphaned volume: %s", vol.Name)
+		if err := d.cli.VolumeRemove(d.mutationContext(ctx), vol.Name, false); err != nil {
+			log.Printf("Warning: failed to remove volume %s: %v", vol.Name, err)
+		}
+	}
+
+	// Remove secrets
+	secrets, err := d.cli.SecretList(ctx, swarm.SecretListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	for _, secret := range secrets {
+		if _, declared := composeFile.Secrets[resourceName(secret.Spec.Annotations, d.stackName)]; declared {
+			continue
+		}
+
+		log.Printf("Removing orphaned secret: %s", secret.Spec.Name)
+		if err := d.cli.SecretRemove(d.mutationContext(ctx), secret.ID); err != nil {
+			log.Printf("Warning: failed to remove secret %s: %v", secret.Spec.Name, err)
+		}
+	}
+
+	// Remove configs
+	configs, err := d.cli.ConfigList(ctx, swarm.ConfigListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	for _, cfg := range configs {
+		if _, declared := composeFile.Configs[resourceName(cfg.Spec.Annotations, d.stackName)]; declared {
+			continue
+		}
+
+		log.Printf("Removing orphaned config: %s", cfg.Spec.Name)
+		if err := d.cli.ConfigRemove(d.mutationContext(ctx), cfg.ID); err != nil {
+			log.Printf("Warning: failed to remove config %s: %v", cfg.Spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// stackScopedName strips the "<stack>_" prefix from the name of a stack resource
+func (d *StackDeployer) stackScopedName(name string) string {
+	return strings.TrimPrefix(name, d.stackName+"_")
+}
+
+// waitForServicesRemoval polls until the services are gone, for at most serviceRemovalTimeout
 func (d *StackDeployer) waitForServicesRemoval(ctx context.Context, services []swarm.Service) error {
+	ctx, cancel := context.WithTimeout(ctx, serviceRemovalTimeout)
+	defer cancel()
+
 	for _, svc := range services {
 		log.Printf("Waiting for service %s to be removed...", svc.Spec.Name)
 
 		for {
+			_, _, err := d.cli.ServiceInspectWithRaw(ctx, svc.ID, types.ServiceInspectOptions{})
+			if client.IsErrNotFound(err) {
+				log.Printf("Service %s has been removed", svc.Spec.Name)
+				break
+			}
+			// Other errors don't tell whether the service is gone; keep polling
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Warning: error inspecting service %s: %v", svc.Spec.Name, err)
+			}
+
 			select {
 			case <-ctx.Done():
 				return fmt.Errorf("timeout waiting for service %s removal: %w", svc.Spec.Name, ctx.Err())
-			default:
-				// Check if service still exists
-				_, _, err := d.cli.ServiceInspectWithRaw(ctx, svc.ID, types.ServiceInspectOptions{})
-				if err != nil {
-					// Service not found - it's been removed
-					log.Printf("Service %s has been removed", svc.Spec.Name)
-					break
-				}
-
-				// Service still exists, wait a bit
-				continue
+			case <-time.After(serviceRemovalPollInterval):
 			}
-			break
 		}
 	}
 
@@ -104,6 +225,7 @@ func (d *StackDeployer) RemoveStack(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to list services: %w", err)
 	}
+	services = d.filterRunningServices(services)
 
 	for _, svc := range services {
 		log.Printf("Removing service: %s", svc.Spec.Name)
@@ -112,80 +234,191 @@ func (d *StackDeployer) RemoveStack(ctx context.Context) error {
 		}
 	}
 
+	// Networks, secrets and configs stay in use until their services are gone
+	if err := d.waitForServicesRemoval(ctx, services); err != nil {
+		return fmt.Errorf("failed to wait for service removal: %w", err)
+	}
+
+	// Other services may still use the stack's networks, secrets and configs
+	if d.ServiceFilter != nil {
+		log.Printf("Filter %s: keeping networks, secrets and configs of stack %s", d.ServiceFilter, d.stackName)
+		return nil
+	}
+
+	stackFilter := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", d.stackName)),
+	)
+
 	// Remove networks
-	networks, err := d.cli.NetworkList(ctx, network.ListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", d.stackName)),
-		),
-	})
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{Filters: stackFilter})
 	if err != nil {
 		return fmt.Errorf("failed to list networks: %w", err)
 	}
 
 	for _, net := range networks {
+		// Tasks of removed services keep their network attachments until torn down
+		if err := d.waitForNetworkRelease(ctx, net); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
 		log.Printf("Removing network: %s", net.Name)
 		if err := d.cli.NetworkRemove(ctx, net.ID); err != nil {
 			log.Printf("Warning: failed to remove network %s: %v", net.Name, err)
 		}
 	}
 
+	// Remove secrets
+	secrets, err := d.cli.SecretList(ctx, swarm.SecretListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	for _, secret := range secrets {
+		log.Printf("Removing secret: %s", secret.Spec.Name)
+		if err := d.cli.SecretRemove(ctx, secret.ID); err != nil {
+			log.Printf("Warning: failed to remove secret %s: %v", secret.Spec.Name, err)
+		}
+	}
+
+	// Remove configs
+	configs, err := d.cli.ConfigList(ctx, swarm.ConfigListOptions{Filters: stackFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	for _, cfg := range configs {
+		log.Printf("Removing config: %s", cfg.Spec.Name)
+		if err := d.cli.ConfigRemove(ctx, cfg.ID); err != nil {
+			log.Printf("Warning: failed to remove config %s: %v", cfg.Spec.Name, err)
+		}
+	}
+
 	log.Printf("Stack %s removed", d.stackName)
 	return nil
 }
 
-// RemoveExitedContainers removes all exited containers from the stack
+// waitForNetworkRelease polls until no task is attached to the network, for at most PruneWait
+func (d *StackDeployer) waitForNetworkRelease(ctx context.Context, net network.Summary) error {
+	if d.PruneWait <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.PruneWait)
+	defer cancel()
+
+	var reported string
+	for {
+		holders, err := d.networkTasks(ctx, net.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list tasks using network %s: %w", net.Name, err)
+		}
+		if len(holders) == 0 {
+			return nil
+		}
+
+		list := strings.Join(holders, ", ")
+		if list != reported {
+			log.Printf("Waiting for network %s to be released by task(s): %s", net.Name, list)
+			reported = list
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("network %s still in use by task(s) %s: %w", net.Name, list, ctx.Err())
+		case <-time.After(networkReleasePollInterval):
+		}
+	}
+}
+
+// networkTasks describes the tasks still attached to a network. Tasks that
+// reached a terminal state are only retained history: their container is gone
+// and holds no endpoint, so they don't keep the network in use.
+func (d *StackDeployer) networkTasks(ctx context.Context, networkID string) ([]string, error) {
+	tasks, err := d.cli.TaskList(ctx, types.TaskListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []string
+	for _, task := range tasks {
+		if taskTerminated(task.Status.State) {
+			continue
+		}
+		for _, attachment := range task.NetworksAttachments {
+			if attachment.Network.ID == networkID {
+				holders = append(holders, fmt.Sprintf("%s (%s)", shortTaskID(task.ID), task.Status.State))
+				break
+			}
+		}
+	}
+	return holders, nil
+}
+
+// taskTerminated reports whether a task in state has stopped for good
+func taskTerminated(state swarm.TaskState) bool {
+	switch state {
+	case swarm.TaskStateComplete, swarm.TaskStateShutdown, swarm.TaskStateFailed,
+		swarm.TaskStateRejected, swarm.TaskStateRemove, swarm.TaskStateOrphaned:
+		return true
+	}
+	return false
+}
+
+// shortTaskID truncates a task ID for logging
+func shortTaskID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// RemoveExitedContainers removes exited task containers of the stack's services.
+// A container counts as part of the stack only when its service ID belongs to a
+// service carrying the stack namespace label: a "<stack>_" name prefix alone
+// would also match the services of a stack named "<stack>_<suffix>".
 func (d *StackDeployer) RemoveExitedContainers(ctx context.Context) error {
-	//log.Printf("Removing exited containers from stack: %s", d.stackName)
-	//
-	//// List all exited swarm task containers
-	//containerFilters := filters.NewArgs(
-	//	filters.Arg("label", "com.docker.swarm.task"),
-	//	filters.Arg("status", "exited"),
-	//)
-	//
-	//containers, err := d.cli.ContainerList(ctx, container.ListOptions{
-	//	All:     true,
-	//	Filters: containerFilters,
-	//})
-	//if err != nil {
-	//	return fmt.Errorf("failed to list exited containers: %w", err)
-	//}
-	//
-	//// Filter containers by stack name using service name prefix
-	//var stackContainers []types.Container
-	//stackPrefix := d.stackName + "_"
-	//for _, cont := range containers {
-	//	if serviceName, ok := cont.Labels["com.docker.swarm.service.name"]; ok {
-	//		if len(serviceName) > len(stackPrefix) && serviceName[:len(stackPrefix)] == stackPrefix {
-	//			stackContainers = append(stackContainers, cont)
-	//		}
-	//	}
-	//}
-	//
-	//if len(stackContainers) == 0 {
-	//	log.Printf("No exited containers found for stack: %s", d.stackName)
-	//	return nil
-	//}
-	//
-	//log.Printf("Found %d exited container(s) to remove", len(stackContainers))
-	//
-	//// Remove each exited container
-	//for _, cont := range stackContainers {
-	//	containerName := cont.Names[0]
-	//	if len(containerName) > 0 && containerName[0] == '/' {
-	//		containerName = containerName[1:] // Remove leading slash
-	//	}
-	//
-	//	log.Printf("Removing exited container: %s (ID: %s)", containerName, cont.ID[:12])
-	//	if err := d.cli.ContainerRemove(ctx, cont.ID, container.RemoveOptions{
-	//		Force: true,
-	//	}); err != nil {
-	//		log.Printf("Warning: failed to remove container %s: %v", containerName, err)
-	//	} else {
-	//		log.Printf("Container %s removed successfully", containerName)
-	//	}
-	//}
-	//
-	//log.Printf("Finished removing exited containers from stack: %s", d.stackName)
+	services, err := d.GetStackServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list stack services: %w", err)
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	stackServices := make(map[string]bool, len(services))
+	for _, svc := range services {
+		stackServices[svc.ID] = true
+	}
+
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.swarm.task"),
+			filters.Arg("status", "exited"),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list exited containers: %w", err)
+	}
+
+	removed := 0
+	for _, cont := range containers {
+		if cont.State != "exited" || !stackServices[cont.Labels["com.docker.swarm.service.id"]] {
+			continue
+		}
+
+		name := shortTaskID(cont.ID)
+		if len(cont.Names) > 0 {
+			name = strings.TrimPrefix(cont.Names[0], "/")
+		}
+		if err := d.cli.ContainerRemove(ctx, cont.ID, container.RemoveOptions{}); err != nil {
+			log.Printf("WARNING: failed to remove exited container %s: %v", name, err)
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d exited container(s) of stack %s", removed, d.stackName)
+	}
 	return nil
 }
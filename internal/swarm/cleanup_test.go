@@ -0,0 +1,425 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestRemoveStack_RemovesSecretsAndConfigs(t *testing.T) {
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}},
+		},
+		secrets: []swarm.Secret{
+			{ID: "secret1", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "mystack_db_password_1a2b3c4d"}}},
+		},
+		configs: []swarm.Config{
+			{ID: "config1", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "mystack_nginx_5e6f7a8b"}}},
+			{ID: "config2", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "mystack_app_9c0d1e2f"}}},
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	if err := deployer.RemoveStack(context.Background()); err != nil {
+		t.Fatalf("RemoveStack failed: %v", err)
+	}
+
+	if len(mockCli.removedServices) != 1 || mockCli.removedServices[0] != "svc1" {
+		t.Errorf("Expected svc1 to be removed, got %v", mockCli.removedServices)
+	}
+	if len(mockCli.removedSecrets) != 1 || mockCli.removedSecrets[0] != "secret1" {
+		t.Errorf("Expected secret1 to be removed, got %v", mockCli.removedSecrets)
+	}
+	if len(mockCli.removedConfigs) != 2 {
+		t.Errorf("Expected 2 configs to be removed, got %v", mockCli.removedConfigs)
+	}
+}
+
+func TestDeploy_KeepsObsoleteServicesWithoutPrune(t *testing.T) {
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "svc-old", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_old"}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{"web": {Image: "nginx:1.25"}},
+	}
+	// Only the removal calls matter here
+	_, _ = deployer.Deploy(context.Background(), composeFile, "deploy-1")
+
+	if len(mockCli.removedServices) != 0 {
+		t.Errorf("Expected obsolete services to be kept without Prune, got %v removed", mockCli.removedServices)
+	}
+}
+
+func TestDeploy_PruneKeepsServicesOfInactiveProfiles(t *testing.T) {
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "svc-debug", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_debug"}}},
+			{ID: "svc-old", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_old"}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.Prune = true
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web":   {Image: "nginx:1.25"},
+			"debug": {Image: "busybox:1.36", Profiles: []string{"debug"}},
+		},
+	}
+	if _, err := compose.ApplyProfiles(composeFile, nil); err != nil {
+		t.Fatalf("ApplyProfiles failed: %v", err)
+	}
+	// Only the removal calls matter here
+	_, _ = deployer.Deploy(context.Background(), composeFile, "deploy-1")
+
+	if len(mockCli.removedServices) != 1 || mockCli.removedServices[0] != "svc-old" {
+		t.Errorf("Expected only the undeclared service to be removed, got %v", mockCli.removedServices)
+	}
+}
+
+func TestPruneOrphanedResources(t *testing.T) {
+	mockCli := &MockDockerClient{
+		networks: []network.Summary{
+			{ID: "net-default", Name: "mystack_default"},
+			{ID: "net-front", Name: "mystack_front"},
+			{ID: "net-old", Name: "mystack_old"},
+		},
+		volumes: []volume.Volume{
+			{Name: "mystack_data"},
+			{Name: "mystack_cache"},
+		},
+		secrets: []swarm.Secret{
+			{ID: "secret-db", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+				Name:   "mystack_db_password_1a2b3c4d",
+				Labels: map[string]string{compose.ResourceNameLabel: "db_password"},
+			}}},
+			{ID: "secret-old", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+				Name:   "mystack_api_key_5e6f7a8b",
+				Labels: map[string]string{compose.ResourceNameLabel: "api_key"},
+			}}},
+		},
+		configs: []swarm.Config{
+			{ID: "config-old", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{
+				Name:   "mystack_nginx_9c0d1e2f",
+				Labels: map[string]string{compose.ResourceNameLabel: "nginx"},
+			}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+
+	composeFile := &compose.ComposeFile{
+		Networks: map[string]*compose.Network{"front": {}},
+		Volumes:  map[string]*compose.Volume{"data": {}},
+		Secrets:  map[string]*compose.Secret{"db_password": {File: "db_password.txt"}},
+	}
+	if err := deployer.pruneOrphanedResources(context.Background(), composeFile); err != nil {
+		t.Fatalf("pruneOrphanedResources failed: %v", err)
+	}
+
+	checks := []struct {
+		kind    string
+		removed []string
+		want    string
+	}{
+		{"network", mockCli.removedNetworks, "net-old"},
+		{"volume", mockCli.removedVolumes, "mystack_cache"},
+		{"secret", mockCli.removedSecrets, "secret-old"},
+		{"config", mockCli.removedConfigs, "config-old"},
+	}
+	for _, c := range checks {
+		if len(c.removed) != 1 || c.removed[0] != c.want {
+			t.Errorf("Expected only orphaned %s %s to be removed, got %v", c.kind, c.want, c.removed)
+		}
+	}
+}
+
+func TestPruneOrphanedResources_ServiceFilterKeepsResources(t *testing.T) {
+	mockCli := &MockDockerClient{
+		networks: []network.Summary{{ID: "net-old", Name: "mystack_old"}},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.ServiceFilter = &compose.LabelFilter{Key: "tier", AnyValue: true}
+
+	if err := deployer.pruneOrphanedResources(context.Background(), &compose.ComposeFile{}); err != nil {
+		t.Fatalf("pruneOrphanedResources failed: %v", err)
+	}
+	if len(mockCli.removedNetworks) != 0 {
+		t.Errorf("Expected networks to be kept under a service filter, got %v removed", mockCli.removedNetworks)
+	}
+}
+
+// lingeringTaskClient reports a task attached to a network for the first few TaskList calls
+type lingeringTaskClient struct {
+	*MockDockerClient
+	lingerCalls int
+	taskLists   int
+}
+
+func (c *lingeringTaskClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	c.taskLists++
+	if c.taskLists > c.lingerCalls {
+		return nil, nil
+	}
+	return []swarm.Task{{
+		// The task of a removed service whose container is still stopping
+		ID:           "task1234567890ab",
+		DesiredState: swarm.TaskStateShutdown,
+		Status:       swarm.TaskStatus{State: swarm.TaskStateRunning},
+		NetworksAttachments: []swarm.NetworkAttachment{
+			{Network: swarm.Network{ID: "net1"}},
+		},
+	}}, nil
+}
+
+func TestRemoveStack_WaitsForTasksToReleaseNetwork(t *testing.T) {
+	oldInterval := networkReleasePollInterval
+	networkReleasePollInterval = time.Millisecond
+	defer func() { networkReleasePollInterval = oldInterval }()
+
+	cli := &lingeringTaskClient{
+		MockDockerClient: &MockDockerClient{
+			networks: []network.Summary{{ID: "net1", Name: "mystack_default"}},
+		},
+		lingerCalls: 2,
+	}
+
+	deployer := NewStackDeployer(cli, "mystack", 3)
+	deployer.PruneWait = time.Second
+	if err := deployer.RemoveStack(context.Background()); err != nil {
+		t.Fatalf("RemoveStack failed: %v", err)
+	}
+
+	if cli.taskLists != 3 {
+		t.Errorf("Expected tasks to be polled until released (3 calls), got %d", cli.taskLists)
+	}
+	if len(cli.removedNetworks) != 1 || cli.removedNetworks[0] != "net1" {
+		t.Errorf("Expected net1 to be removed, got %v", cli.removedNetworks)
+	}
+}
+
+func TestWaitForNetworkRelease_Timeout(t *testing.T) {
+	oldInterval := networkReleasePollInterval
+	networkReleasePollInterval = time.Millisecond
+	defer func() { networkReleasePollInterval = oldInterval }()
+
+	cli := &lingeringTaskClient{MockDockerClient: &MockDockerClient{}, lingerCalls: 1 << 30}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+	deployer.PruneWait = 20 * time.Millisecond
+
+	err := deployer.waitForNetworkRelease(context.Background(), network.Summary{ID: "net1", Name: "mystack_default"})
+	if err == nil {
+		t.Fatal("Expected a timeout while the task holds the network")
+	}
+	if !strings.Contains(err.Error(), "task12345678 (running)") {
+		t.Errorf("Expected the holding task in the error, got: %v", err)
+	}
+}
+
+func TestPruneOrphanedResources_WaitsForTasksToReleaseNetwork(t *testing.T) {
+	oldInterval := networkReleasePollInterval
+	networkReleasePollInterval = time.Millisecond
+	defer func() { networkReleasePollInterval = oldInterval }()
+
+	cli := &lingeringTaskClient{
+		MockDockerClient: &MockDockerClient{
+			networks: []network.Summary{{ID: "net1", Name: "mystack_old", Labels: map[string]string{"com.docker.stack.namespace": "mystack"}}},
+		},
+		lingerCalls: 2,
+	}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+	deployer.PruneWait = time.Second
+
+	if err := deployer.pruneOrphanedResources(context.Background(), &compose.ComposeFile{}); err != nil {
+		t.Fatalf("pruneOrphanedResources failed: %v", err)
+	}
+	if cli.taskLists != 3 {
+		t.Errorf("Expected tasks to be polled until released (3 calls), got %d", cli.taskLists)
+	}
+	if len(cli.removedNetworks) != 1 || cli.removedNetworks[0] != "net1" {
+		t.Errorf("Expected net1 to be removed, got %v", cli.removedNetworks)
+	}
+}
+
+func TestPruneOrphanedResources_RetainedTaskHistoryDoesNotHoldNetwork(t *testing.T) {
+	oldInterval := networkReleasePollInterval
+	networkReleasePollInterval = time.Millisecond
+	defer func() { networkReleasePollInterval = oldInterval }()
+
+	// An updated service still has shutdown and failed tasks attached to the dropped network
+	attached := []swarm.NetworkAttachment{{Network: swarm.Network{ID: "net-old"}}}
+	mockCli := &MockDockerClient{
+		networks: []network.Summary{{ID: "net-old", Name: "mystack_old", Labels: map[string]string{"com.docker.stack.namespace": "mystack"}}},
+		tasks: []swarm.Task{
+			{ID: "old-task-1", DesiredState: swarm.TaskStateShutdown, Status: swarm.TaskStatus{State: swarm.TaskStateShutdown}, NetworksAttachments: attached},
+			{ID: "old-task-2", DesiredState: swarm.TaskStateShutdown, Status: swarm.TaskStatus{State: swarm.TaskStateFailed}, NetworksAttachments: attached},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.PruneWait = 10 * time.Second
+
+	start := time.Now()
+	if err := deployer.pruneOrphanedResources(context.Background(), &compose.ComposeFile{}); err != nil {
+		t.Fatalf("pruneOrphanedResources failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected terminated tasks not to be waited for, took %v", elapsed)
+	}
+	if len(mockCli.removedNetworks) != 1 || mockCli.removedNetworks[0] != "net-old" {
+		t.Errorf("Expected net-old to be removed, got %v", mockCli.removedNetworks)
+	}
+}
+
+// disappearingServiceClient reports a service as present for the first few inspects.
+// Like the real client, inspects fail once ctx is done.
+type disappearingServiceClient struct {
+	*MockDockerClient
+	presentInspects int
+	inspects        int
+	inspectErr      error // returned while the service is present, if set
+}
+
+func (c *disappearingServiceClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (swarm.Service, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return swarm.Service{}, nil, err
+	}
+	c.inspects++
+	if c.inspects > c.presentInspects {
+		return swarm.Service{}, nil, fmt.Errorf("service %s not found: %w", serviceID, cerrdefs.ErrNotFound)
+	}
+	if c.inspectErr != nil {
+		return swarm.Service{}, nil, c.inspectErr
+	}
+	return swarm.Service{ID: serviceID}, nil, nil
+}
+
+func TestWaitForServicesRemoval_PollsUntilGone(t *testing.T) {
+	oldInterval := serviceRemovalPollInterval
+	serviceRemovalPollInterval = time.Millisecond
+	defer func() { serviceRemovalPollInterval = oldInterval }()
+
+	cli := &disappearingServiceClient{MockDockerClient: &MockDockerClient{}, presentInspects: 2}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+
+	services := []swarm.Service{{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}}
+	if err := deployer.waitForServicesRemoval(context.Background(), services); err != nil {
+		t.Fatalf("waitForServicesRemoval failed: %v", err)
+	}
+	if cli.inspects != 3 {
+		t.Errorf("Expected 3 inspects (present, present, gone), got %d", cli.inspects)
+	}
+}
+
+func TestWaitForServicesRemoval_Timeout(t *testing.T) {
+	oldInterval, oldTimeout := serviceRemovalPollInterval, serviceRemovalTimeout
+	serviceRemovalPollInterval = time.Millisecond
+	serviceRemovalTimeout = 20 * time.Millisecond
+	defer func() { serviceRemovalPollInterval, serviceRemovalTimeout = oldInterval, oldTimeout }()
+
+	cli := &disappearingServiceClient{MockDockerClient: &MockDockerClient{}, presentInspects: 1 << 30}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+
+	services := []swarm.Service{{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}}
+	err := deployer.waitForServicesRemoval(context.Background(), services)
+	if err == nil || !strings.Contains(err.Error(), "mystack_web") {
+		t.Errorf("Expected a timeout naming the service, got %v", err)
+	}
+	// Polling sleeps between inspects instead of spinning
+	if cli.inspects > 100 {
+		t.Errorf("Expected paced polling, got %d inspects in 20ms", cli.inspects)
+	}
+}
+
+func TestWaitForServicesRemoval_InspectErrorIsNotRemoval(t *testing.T) {
+	oldInterval := serviceRemovalPollInterval
+	serviceRemovalPollInterval = time.Millisecond
+	defer func() { serviceRemovalPollInterval = oldInterval }()
+
+	cli := &disappearingServiceClient{
+		MockDockerClient: &MockDockerClient{},
+		presentInspects:  2,
+		inspectErr:       fmt.Errorf("Error response from daemon: %w", cerrdefs.ErrUnavailable),
+	}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+
+	services := []swarm.Service{{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}}
+	if err := deployer.waitForServicesRemoval(context.Background(), services); err != nil {
+		t.Fatalf("waitForServicesRemoval failed: %v", err)
+	}
+	if cli.inspects != 3 {
+		t.Errorf("Expected polling to go on past transient errors until not found, got %d inspects", cli.inspects)
+	}
+}
+
+func TestRemoveExitedContainers_OnlyStackServices(t *testing.T) {
+	taskContainer := func(id, serviceID, serviceName, state string) types.Container {
+		return types.Container{
+			ID:    id,
+			Names: []string{"/" + serviceName + ".1." + id},
+			State: state,
+			Labels: map[string]string{
+				"com.docker.swarm.task":         "",
+				"com.docker.swarm.service.id":   serviceID,
+				"com.docker.swarm.service.name": serviceName,
+			},
+		}
+	}
+
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "svc-web", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}},
+		},
+		containers: []types.Container{
+			taskContainer("exited-web", "svc-web", "mystack_web", "exited"),
+			taskContainer("running-web", "svc-web", "mystack_web", "running"),
+			// A stack named mystack_prod shares the "mystack_" name prefix
+			taskContainer("exited-prod", "svc-prod", "mystack_prod_web", "exited"),
+			taskContainer("exited-other", "svc-other", "otherstack_web", "exited"),
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	if err := deployer.RemoveExitedContainers(context.Background()); err != nil {
+		t.Fatalf("RemoveExitedContainers failed: %v", err)
+	}
+
+	if len(mockCli.removedContainers) != 1 || mockCli.removedContainers[0] != "exited-web" {
+		t.Errorf("Expected only exited-web to be removed, got %v", mockCli.removedContainers)
+	}
+}
+
+func TestDeploy_KeepExitedContainers(t *testing.T) {
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "svc-web", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}},
+		},
+		containers: []types.Container{{
+			ID:     "exited-web",
+			State:  "exited",
+			Labels: map[string]string{"com.docker.swarm.service.id": "svc-web"},
+		}},
+	}
+
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.KeepExitedContainers = true
+	if _, err := deployer.Deploy(context.Background(), &compose.ComposeFile{Services: map[string]*compose.Service{}}, "deploy-1"); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if len(mockCli.removedContainers) != 0 {
+		t.Errorf("Expected exited containers to be kept, got %v removed", mockCli.removedContainers)
+	}
+}
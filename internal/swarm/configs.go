@@ -0,0 +1,148 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// deployConfigs makes sure every config declared in the compose file exists in the swarm.
+// File-based configs are created as <stack>_<name>_<hash8>; external configs are referenced by name.
+func (d *StackDeployer) deployConfigs(ctx context.Context, configs map[string]*compose.Config) error {
+	d.configs = make(map[string]swarmObject, len(configs))
+
+	for _, name := range sortedKeys(configs) {
+		cfg := configs[name]
+		if cfg == nil {
+			return fmt.Errorf("config %s has no definition", name)
+		}
+		key := fmt.Sprintf("%s_%s", d.stackName, name)
+
+		if compose.IsExternal(cfg.External) {
+			externalName := configName(name, cfg)
+			existing, err := d.findConfig(ctx, externalName)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				return fmt.Errorf("external config %s not found", externalName)
+			}
+			log.Printf("Using external config: %s", externalName)
+			d.configs[key] = swarmObject{ID: existing.ID, Name: externalName}
+			continue
+		}
+
+		data, err := readResourceFile("config", name, cfg.File, d.composeDir)
+		if err != nil {
+			return err
+		}
+		hash := compose.ContentHash(data)
+
+		annotations := stackResourceAnnotations(d.stackName, name, cfg.Name, hash, cfg.Labels)
+		fullName := annotations.Name
+
+		existing, err := d.findConfig(ctx, fullName)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			log.Printf("Config %s already exists", fullName)
+			d.configs[key] = swarmObject{ID: existing.ID, Name: fullName}
+			continue
+		}
+
+		response, err := d.cli.ConfigCreate(ctx, swarm.ConfigSpec{
+			Annotations: annotations,
+			Data:        data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create config %s: %w", fullName, err)
+		}
+
+		log.Printf("Created config: %s", fullName)
+		d.configs[key] = swarmObject{ID: response.ID, Name: fullName}
+	}
+
+	return nil
+}
+
+// findConfig returns the config with exactly the given name, or nil if it does not exist
+func (d *StackDeployer) findConfig(ctx context.Context, name string) (*swarm.Config, error) {
+	configs, err := d.cli.ConfigList(ctx, swarm.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	// The name filter matches prefixes, so look for an exact match
+	for i := range configs {
+		if configs[i].Spec.Name == name {
+			return &configs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// configName returns the swarm name of an external config
+func configName(name string, cfg *compose.Config) string {
+	if extName := compose.ExternalName(cfg.External); extName != "" {
+		return extName
+	}
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return name
+}
+
+// resolveConfigReferences fills in config IDs and swarm names on converted config references
+func (d *StackDeployer) resolveConfigReferences(refs []*swarm.ConfigReference) error {
+	for _, ref := range refs {
+		obj, ok := d.configs[ref.ConfigName]
+		if !ok {
+			return fmt.Errorf("config %s is not defined in the compose file", ref.ConfigName)
+		}
+		ref.ConfigID = obj.ID
+		ref.ConfigName = obj.Name
+	}
+	return nil
+}
+
+// removeRotatedConfigs removes previous versions of the configs deployed in this run
+func (d *StackDeployer) removeRotatedConfigs(ctx context.Context) {
+	configs, err := d.cli.ConfigList(ctx, swarm.ConfigListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", d.stackName)),
+		),
+	})
+	if err != nil {
+		log.Printf("WARNING: failed to list configs for cleanup: %v", err)
+		return
+	}
+
+	for _, cfg := range configs {
+		name, ok := cfg.Spec.Labels[compose.ResourceNameLabel]
+		if !ok {
+			continue
+		}
+		current, deployed := d.configs[fmt.Sprintf("%s_%s", d.stackName, name)]
+		if !deployed || current.ID == cfg.ID {
+			continue
+		}
+		if d.RetainedResources[cfg.ID] {
+			log.Printf("Keeping old config version %s: a saved snapshot still uses it", cfg.Spec.Name)
+			continue
+		}
+
+		if err := d.cli.ConfigRemove(ctx, cfg.ID); err != nil {
+			log.Printf("WARNING: failed to remove old config version %s: %v", cfg.Spec.Name, err)
+			continue
+		}
+		log.Printf("Removed old config version: %s", cfg.Spec.Name)
+	}
+}
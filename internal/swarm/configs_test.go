@@ -0,0 +1,79 @@
+package swarm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestDeployConfigs(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "nginx.conf"), []byte("worker_processes 1;"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	mockCli := &MockDockerClient{
+		configs: []swarm.Config{
+			{ID: "shared_id", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "shared_settings"}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	configs := map[string]*compose.Config{
+		"nginx_conf": {File: "nginx.conf"},
+		"settings":   {External: true, Name: "shared_settings"},
+	}
+
+	if err := deployer.deployConfigs(context.Background(), configs); err != nil {
+		t.Fatalf("deployConfigs failed: %v", err)
+	}
+
+	// Only the file-based config is created; the external one is referenced as-is
+	if len(mockCli.createdConfigs) != 1 {
+		t.Fatalf("Expected 1 config created, got %d", len(mockCli.createdConfigs))
+	}
+	created := mockCli.createdConfigs[0]
+	expectedName := compose.VersionedName("test", "nginx_conf", compose.ContentHash([]byte("worker_processes 1;")))
+	if created.Name != expectedName {
+		t.Errorf("Expected config name %s, got %s", expectedName, created.Name)
+	}
+	if string(created.Data) != "worker_processes 1;" {
+		t.Errorf("Expected config data from file, got %q", string(created.Data))
+	}
+	if created.Labels["com.docker.stack.namespace"] != "test" {
+		t.Errorf("Expected stack namespace label, got %v", created.Labels)
+	}
+
+	refs := []*swarm.ConfigReference{
+		{ConfigName: "test_nginx_conf", File: &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx/nginx.conf"}},
+		{ConfigName: "test_settings", File: &swarm.ConfigReferenceFileTarget{Name: "settings"}},
+	}
+	if err := deployer.resolveConfigReferences(refs); err != nil {
+		t.Fatalf("resolveConfigReferences failed: %v", err)
+	}
+	if refs[0].ConfigID != "config_1" || refs[0].ConfigName != expectedName {
+		t.Errorf("Unexpected resolved reference: %+v", refs[0])
+	}
+	if refs[1].ConfigID != "shared_id" || refs[1].ConfigName != "shared_settings" {
+		t.Errorf("Expected external config shared_settings, got %+v", refs[1])
+	}
+}
+
+func TestDeployConfigs_ExternalNotFound(t *testing.T) {
+	deployer := NewStackDeployer(&MockDockerClient{}, "test", 3)
+
+	configs := map[string]*compose.Config{
+		"settings": {External: true},
+	}
+
+	if err := deployer.deployConfigs(context.Background(), configs); err == nil {
+		t.Error("Expected error for missing external config")
+	}
+}
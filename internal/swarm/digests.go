@@ -0,0 +1,55 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// ResolveImageDigests looks up the registry digest of every service image and
+// returns the pinned references (image@sha256:...) keyed by service name.
+// Images that already carry a digest are kept as they are.
+func ResolveImageDigests(ctx context.Context, cli DockerClient, services map[string]*compose.Service) (map[string]string, error) {
+	pinned := make(map[string]string, len(services))
+
+	for _, name := range sortedKeys(services) {
+		svc := services[name]
+		if svc.Image == "" {
+			continue
+		}
+		if strings.Contains(svc.Image, "@") {
+			pinned[name] = svc.Image
+			continue
+		}
+
+		info, err := cli.DistributionInspect(ctx, svc.Image, getRegistryAuth(svc.Image))
+		if err != nil {
+			return nil, fmt.Errorf("service %s: failed to resolve digest of %s: %w", name, svc.Image, err)
+		}
+		if info.Descriptor.Digest == "" {
+			return nil, fmt.Errorf("service %s: registry returned no digest for %s", name, svc.Image)
+		}
+
+		pinned[name] = svc.Image + "@" + info.Descriptor.Digest.String()
+	}
+
+	return pinned, nil
+}
+
+// pinImageDigests resolves image digests for the deployment so services run
+// exactly the image that was current at deploy time
+func (d *StackDeployer) pinImageDigests(ctx context.Context, services map[string]*compose.Service) error {
+	pinned, err := ResolveImageDigests(ctx, d.cli, services)
+	if err != nil {
+		return err
+	}
+
+	for name, image := range pinned {
+		log.Printf("Pinned image for service %s: %s", name, image)
+	}
+	d.pinnedImages = pinned
+	return nil
+}
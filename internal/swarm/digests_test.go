@@ -0,0 +1,71 @@
+package swarm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+const testDigest = "sha256:4c0e7bd1e0a5b4e6a8f3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1"
+
+func TestResolveImageDigests(t *testing.T) {
+	mockCli := &MockDockerClient{
+		registryDigests: map[string]string{"nginx:1.25": testDigest},
+	}
+
+	services := map[string]*compose.Service{
+		"web":    {Image: "nginx:1.25"},
+		"pinned": {Image: "redis:7@sha256:aaaa"},
+		"build":  {},
+	}
+
+	pinned, err := ResolveImageDigests(context.Background(), mockCli, services)
+	if err != nil {
+		t.Fatalf("ResolveImageDigests failed: %v", err)
+	}
+
+	if got := pinned["web"]; got != "nginx:1.25@"+testDigest {
+		t.Errorf("Expected web pinned to digest, got %q", got)
+	}
+	if got := pinned["pinned"]; got != "redis:7@sha256:aaaa" {
+		t.Errorf("Expected already pinned image kept, got %q", got)
+	}
+	if _, ok := pinned["build"]; ok {
+		t.Error("Expected service without image to be skipped")
+	}
+}
+
+func TestResolveImageDigests_LookupFails(t *testing.T) {
+	services := map[string]*compose.Service{"web": {Image: "private/app:1.0"}}
+
+	_, err := ResolveImageDigests(context.Background(), &MockDockerClient{}, services)
+	if err == nil || !strings.Contains(err.Error(), "private/app:1.0") {
+		t.Errorf("Expected digest lookup error naming the image, got %v", err)
+	}
+}
+
+func TestDeployService_UsesPinnedImage(t *testing.T) {
+	mockCli := &MockDockerClient{
+		registryDigests: map[string]string{"nginx:1.25": testDigest},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.PinDigests = true
+
+	services := map[string]*compose.Service{"web": {Image: "nginx:1.25"}}
+	if err := deployer.pinImageDigests(context.Background(), services); err != nil {
+		t.Fatalf("pinImageDigests failed: %v", err)
+	}
+
+	// The mock doesn't register created services, so the post-create inspect fails;
+	// only the spec sent to ServiceCreate matters here
+	_, _ = deployer.deployService(context.Background(), "web", services["web"], "deploy-1")
+
+	if len(mockCli.createdServices) != 1 {
+		t.Fatalf("Expected 1 created service, got %d", len(mockCli.createdServices))
+	}
+	if image := mockCli.createdServices[0].Spec.TaskTemplate.ContainerSpec.Image; image != "nginx:1.25@"+testDigest {
+		t.Errorf("Expected service created with pinned image, got %s", image)
+	}
+}
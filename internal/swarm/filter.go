@@ -0,0 +1,40 @@
+package swarm
+
+import (
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// selectedServices returns the compose services matching ServiceFilter (all when unset)
+func (d *StackDeployer) selectedServices(services map[string]*compose.Service) map[string]*compose.Service {
+	if d.ServiceFilter == nil {
+		return services
+	}
+	selected := make(map[string]*compose.Service, len(services))
+	for name, service := range services {
+		if service != nil && d.ServiceFilter.MatchService(service) {
+			selected[name] = service
+		}
+	}
+	return selected
+}
+
+// runningServiceSelected reports whether a deployed service matches ServiceFilter
+func (d *StackDeployer) runningServiceSelected(svc swarm.Service) bool {
+	return d.ServiceFilter == nil || d.ServiceFilter.MatchServiceSpec(&svc.Spec)
+}
+
+// filterRunningServices returns the deployed services matching ServiceFilter
+func (d *StackDeployer) filterRunningServices(services []swarm.Service) []swarm.Service {
+	if d.ServiceFilter == nil {
+		return services
+	}
+	var selected []swarm.Service
+	for _, svc := range services {
+		if d.runningServiceSelected(svc) {
+			selected = append(selected, svc)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,134 @@
+package swarm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// labeledService returns a deployed service with the given container labels
+func labeledService(id, name string, labels map[string]string) swarm.Service {
+	return swarm.Service{
+		ID: id,
+		Spec: swarm.ServiceSpec{
+			Annotations:  swarm.Annotations{Name: name, Labels: map[string]string{"com.docker.stack.namespace": "mystack"}},
+			TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Labels: labels}},
+		},
+	}
+}
+
+// nameFilteringClient honors the name filter of ServiceList, which the mock ignores
+type nameFilteringClient struct {
+	MockDockerClient
+}
+
+func (c *nameFilteringClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	names := options.Filters.Get("name")
+	if len(names) == 0 {
+		return c.services, nil
+	}
+	var matched []swarm.Service
+	for _, svc := range c.services {
+		if svc.Spec.Name == names[0] {
+			matched = append(matched, svc)
+		}
+	}
+	return matched, nil
+}
+
+func TestDeploy_ServiceFilter(t *testing.T) {
+	mockCli := &nameFilteringClient{MockDockerClient{
+		services: []swarm.Service{
+			labeledService("old-backend", "mystack_old_worker", map[string]string{"tier": "backend"}),
+			labeledService("old-frontend", "mystack_old_web", map[string]string{"tier": "frontend"}),
+		},
+	}}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	filter, err := compose.ParseLabelFilter("label=tier=backend")
+	if err != nil {
+		t.Fatalf("ParseLabelFilter failed: %v", err)
+	}
+	deployer.ServiceFilter = filter
+	deployer.Prune = true
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"api": {Image: "api:1", Labels: map[string]string{"tier": "backend"}, DependsOn: []interface{}{"db"}},
+			"db":  {Image: "postgres:16", Deploy: &compose.DeployConfig{Labels: map[string]string{"tier": "backend"}}},
+			"web": {Image: "nginx:1.25", Labels: map[string]string{"tier": "frontend"}, DependsOn: []interface{}{"api"}},
+		},
+	}
+
+	// The mock doesn't register created services, so post-create inspects fail;
+	// only the calls made matter here
+	_, _ = deployer.Deploy(context.Background(), composeFile, "deploy-1")
+
+	var created []string
+	for _, svc := range mockCli.createdServices {
+		created = append(created, svc.Spec.Name)
+	}
+	if !reflect.DeepEqual(created, []string{"mystack_db", "mystack_api"}) {
+		t.Errorf("Expected only the backend services to be created in dependency order, got %v", created)
+	}
+	for _, image := range mockCli.pulledImages {
+		if image == "nginx:1.25" {
+			t.Errorf("Expected the filtered-out web image not to be pulled, got %v", mockCli.pulledImages)
+		}
+	}
+	if len(mockCli.removedServices) != 1 || mockCli.removedServices[0] != "old-backend" {
+		t.Errorf("Expected only the obsolete backend service to be pruned, got %v", mockCli.removedServices)
+	}
+}
+
+func TestDeployServices_ServiceFilterSkipsUnselected(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.ServiceFilter = &compose.LabelFilter{Key: "tier", AnyValue: true}
+
+	services := map[string]*compose.Service{
+		"api":   {Image: "api:1", Labels: map[string]string{"tier": "backend"}, DependsOn: []interface{}{"db"}},
+		"db":    {Image: "postgres:16"},
+		"cache": {Image: "redis:7", Labels: map[string]string{"tier": "cache"}},
+	}
+
+	// api depends on the unselected db, which must not be deployed
+	_, _ = deployer.deployServices(context.Background(), services, "deploy-1")
+
+	got := make(map[string]bool)
+	for _, svc := range mockCli.createdServices {
+		got[svc.Spec.Name] = true
+	}
+	if got["mystack_db"] {
+		t.Error("Expected the unselected db service to be skipped")
+	}
+	if !got["mystack_cache"] {
+		t.Errorf("Expected the selected cache service to be created, got %v", got)
+	}
+}
+
+func TestRemoveStack_ServiceFilter(t *testing.T) {
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			labeledService("svc-backend", "mystack_api", map[string]string{"tier": "backend"}),
+			labeledService("svc-frontend", "mystack_web", map[string]string{"tier": "frontend"}),
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.ServiceFilter = &compose.LabelFilter{Key: "tier", Value: "backend"}
+
+	if err := deployer.RemoveStack(context.Background()); err != nil {
+		t.Fatalf("RemoveStack failed: %v", err)
+	}
+
+	if len(mockCli.removedServices) != 1 || mockCli.removedServices[0] != "svc-backend" {
+		t.Errorf("Expected only the backend service to be removed, got %v", mockCli.removedServices)
+	}
+	if len(mockCli.removedNetworks) != 0 {
+		t.Errorf("Expected networks to be kept, got %v", mockCli.removedNetworks)
+	}
+}
@@ -1,45 +1,75 @@
 package swarm
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/api/types/registry"
 	"golang.org/x/net/context"
 
 	"github.com/SomeBlackMagic/stackman/internal/compose"
 )
 
 func (d *StackDeployer) pullImages(ctx context.Context, services map[string]*compose.Service) error {
-	for name, svc := range services {
+	if err := d.checkNeverPulledImages(ctx, services); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(services) {
+		svc := services[name]
 		if svc.Image == "" {
 			log.Printf("Service %s has no image specified, skipping pull", name)
 			continue
 		}
 
+		policy := d.pullPolicy(svc)
+		switch policy {
+		case "", compose.PullPolicyAlways:
+		case compose.PullPolicyMissing, "if_not_present":
+			if _, err := d.cli.ImageInspect(ctx, svc.Image); err == nil {
+				log.Printf("Image %s for service %s is present locally, skipping pull (pull_policy: %s)", svc.Image, name, policy)
+				continue
+			}
+		case compose.PullPolicyNever, compose.PullPolicyBuild:
+			log.Printf("Skipping pull of %s for service %s (pull_policy: %s)", svc.Image, name, policy)
+			continue
+		default:
+			return fmt.Errorf("service %s: unsupported pull_policy %q", name, policy)
+		}
+
 		log.Printf("Pulling image for service %s: %s", name, svc.Image)
 
-		// Pull image with credentials from Docker config (~/.docker/config.json)
-		pullOpts := image.PullOptions{
-			RegistryAuth: getRegistryAuth(svc.Image),
+		// Retry with backoff so a single flaky registry response doesn't fail the deploy
+		var err error
+		maxRetries := d.PullRetries
+		if maxRetries <= 0 {
+			maxRetries = 1
 		}
-
-		out, err := d.cli.ImagePull(ctx, svc.Image, pullOpts)
-		if err != nil {
-			return fmt.Errorf("failed to pull image %s: %w", svc.Image, err)
+		for retry := 0; retry < maxRetries; retry++ {
+			err = d.pullImage(ctx, svc.Image)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			if retry < maxRetries-1 {
+				waitTime := time.Duration(retry+1) * time.Second
+				log.Printf("failed to pull image %s (attempt %d/%d): %v, retrying in %v",
+					svc.Image, retry+1, maxRetries, err, waitTime)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(waitTime):
+				}
+			}
 		}
-		defer out.Close()
-
-		// Parse and log pull progress
-		if err := d.logPullProgress(out); err != nil {
-			return fmt.Errorf("failed to read pull progress for %s: %w", svc.Image, err)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
 		}
 
 		log.Printf("Successfully pulled image: %s", svc.Image)
@@ -48,90 +78,104 @@ func (d *StackDeployer) pullImages(ctx context.Context, services map[string]*com
 	return nil
 }
 
-func getRegistryAuth(imageName string) string {
-	// Extract registry from image name
-	registryURL := extractRegistry(imageName)
-	if registryURL == "" {
-		return ""
-	}
-
-	// Read Docker config
-	// Check for DOCKER_CONFIG_PATH env variable first, then fall back to default
-	configPath := filepath.Join(os.Getenv("HOME"), ".docker", "config.json")
-	if dockerConfigPath := os.Getenv("DOCKER_CONFIG_PATH"); dockerConfigPath != "" {
-		configPath = filepath.Join(dockerConfigPath, "config.json")
-		log.Printf("Using Docker config from DOCKER_CONFIG_PATH: %s", configPath)
-	}
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		log.Printf("Warning: could not read Docker config from %s: %v", configPath, err)
-		return ""
+// pullPolicy returns the service pull_policy, falling back to the deployer default
+func (d *StackDeployer) pullPolicy(svc *compose.Service) string {
+	if svc.PullPolicy != "" {
+		return svc.PullPolicy
 	}
+	return d.PullPolicy
+}
 
-	var config struct {
-		Auths map[string]struct {
-			Auth string `json:"auth"`
-		} `json:"auths"`
+// checkNeverPulledImages fails before any pull when an image that must not be
+// pulled (pull_policy never, e.g. side-loaded in air-gapped setups) is missing.
+// The Swarm API doesn't expose the image store of other nodes, so presence is
+// checked on the manager stackman talks to; all missing images are reported at once.
+func (d *StackDeployer) checkNeverPulledImages(ctx context.Context, services map[string]*compose.Service) error {
+	var missing []string
+	for _, name := range sortedKeys(services) {
+		svc := services[name]
+		if svc.Image == "" || d.pullPolicy(svc) != compose.PullPolicyNever {
+			continue
+		}
+		if _, err := d.cli.ImageInspect(ctx, svc.Image); err != nil {
+			missing = append(missing, fmt.Sprintf("%s (%s)", name, svc.Image))
+		}
 	}
 
-	if err := json.Unmarshal(data, &config); err != nil {
-		log.Printf("Warning: could not parse Docker config: %v", err)
-		return ""
+	if len(missing) > 0 {
+		return fmt.Errorf("pull policy never: image not present on the manager for service %s; load the image or use pull policy missing", strings.Join(missing, ", "))
 	}
+	return nil
+}
 
-	// Try to find auth for this registry
-	if auth, ok := config.Auths[registryURL]; ok && auth.Auth != "" {
-		// Auth is already base64 encoded username:password
-		// We need to decode it, then encode as registry.AuthConfig
-		authBytes, err := base64.StdEncoding.DecodeString(auth.Auth)
-		if err != nil {
-			log.Printf("Warning: could not decode auth: %v", err)
-			return ""
-		}
-
-		parts := strings.SplitN(string(authBytes), ":", 2)
-		if len(parts) != 2 {
-			log.Printf("Warning: invalid auth format")
-			return ""
+// checkImageAge warns about services whose image was built longer ago than MaxImageAge
+func (d *StackDeployer) checkImageAge(ctx context.Context, services map[string]*compose.Service) {
+	for _, name := range sortedKeys(services) {
+		svc := services[name]
+		if svc.Image == "" {
+			continue
 		}
 
-		authConfig := registry.AuthConfig{
-			Username:      parts[0],
-			Password:      parts[1],
-			ServerAddress: registryURL,
+		inspect, err := d.cli.ImageInspect(ctx, svc.Image)
+		if err != nil {
+			log.Printf("Cannot inspect image %s for service %s, skipping age check: %v", svc.Image, name, err)
+			continue
 		}
 
-		encodedJSON, err := json.Marshal(authConfig)
+		created, err := time.Parse(time.RFC3339Nano, inspect.Created)
 		if err != nil {
-			log.Printf("Warning: could not encode auth config: %v", err)
-			return ""
+			log.Printf("Image %s has no valid creation date, skipping age check", svc.Image)
+			continue
 		}
 
-		return base64.URLEncoding.EncodeToString(encodedJSON)
+		age := time.Since(created)
+		if age > d.MaxImageAge {
+			d.warn(name, "service %s: image %s was created %d days ago (max age %d days)",
+				name, svc.Image, int(age.Hours()/24), int(d.MaxImageAge.Hours()/24))
+		}
 	}
-
-	return ""
 }
 
-func extractRegistry(imageName string) string {
-	// Examples:
-	// gitlab.opscore.org:5001/core/devops/domain-router:v0.1.0-alfa1 -> gitlab.opscore.org:5001
-	// docker.io/library/nginx:latest -> docker.io
-	// nginx:latest -> "" (Docker Hub, default)
+// pullImage pulls a single image, abandoning the pull if it exceeds PullTimeout
+func (d *StackDeployer) pullImage(ctx context.Context, imageName string) error {
+	pullCtx := ctx
+	if d.PullTimeout > 0 {
+		var cancel context.CancelFunc
+		pullCtx, cancel = context.WithTimeout(ctx, d.PullTimeout)
+		defer cancel()
+	}
 
-	parts := strings.Split(imageName, "/")
-	if len(parts) < 2 {
-		return "" // No registry specified, use default
+	// Pull image with credentials from Docker config (~/.docker/config.json)
+	pullOpts := image.PullOptions{
+		RegistryAuth: getRegistryAuth(imageName),
 	}
 
-	firstPart := parts[0]
-	// Check if first part looks like a registry (contains . or :)
-	if strings.Contains(firstPart, ".") || strings.Contains(firstPart, ":") {
-		return firstPart
+	out, err := d.cli.ImagePull(pullCtx, imageName, pullOpts)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
 	}
+	defer out.Close()
 
-	return ""
+	// Read progress in background so a stalled stream can't block past the deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- d.logPullProgress(out)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to read pull progress for %s: %w", imageName, err)
+		}
+		return nil
+	case <-pullCtx.Done():
+		// Closing the stream unblocks the progress reader
+		out.Close()
+		if ctx.Err() == nil {
+			return fmt.Errorf("pull of image %s timed out after %v (registry not responding?)", imageName, d.PullTimeout)
+		}
+		return fmt.Errorf("pull of image %s cancelled: %w", imageName, ctx.Err())
+	}
 }
 
 func (d *StackDeployer) logPullProgress(reader io.ReadCloser) error {
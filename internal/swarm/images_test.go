@@ -0,0 +1,265 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// blockingReader blocks on Read until it is closed
+type blockingReader struct {
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newBlockingReader() *blockingReader {
+	return &blockingReader{closed: make(chan struct{})}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.closed
+	return 0, io.EOF
+}
+
+func (r *blockingReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+func TestPullImages_TimeoutAbandonsStuckPull(t *testing.T) {
+	reader := newBlockingReader()
+	mockCli := &MockDockerClient{
+		imagePullFunc: func(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+			return reader, nil
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullTimeout = 200 * time.Millisecond
+	deployer.PullRetries = 1
+
+	services := map[string]*compose.Service{
+		"web": {Image: "registry.invalid/web:1.0"},
+	}
+
+	start := time.Now()
+	err := deployer.pullImages(context.Background(), services)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("Expected pull to fail with timeout, got nil")
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "registry.invalid/web:1.0") {
+		t.Errorf("Expected error to name the image, got: %v", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Pull was not abandoned promptly, took %v", elapsed)
+	}
+
+	select {
+	case <-reader.closed:
+	default:
+		t.Error("Expected stuck pull stream to be closed")
+	}
+}
+
+func TestPullImages_RetriesAfterTimeout(t *testing.T) {
+	attempts := 0
+	mockCli := &MockDockerClient{
+		imagePullFunc: func(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+			attempts++
+			if attempts == 1 {
+				return newBlockingReader(), nil
+			}
+			return io.NopCloser(strings.NewReader(`{"status":"Pull complete","id":"abc"}`)), nil
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullTimeout = 100 * time.Millisecond
+	deployer.PullRetries = 2
+
+	services := map[string]*compose.Service{
+		"web": {Image: "nginx:1.25"},
+	}
+
+	if err := deployer.pullImages(context.Background(), services); err != nil {
+		t.Fatalf("Expected pull to succeed on retry, got: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("Expected 2 pull attempts, got %d", attempts)
+	}
+}
+
+func TestPullImages_CancelDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	mockCli := &MockDockerClient{
+		imagePullFunc: func(context.Context, string, image.PullOptions) (io.ReadCloser, error) {
+			attempts++
+			// Cancel once the deployer starts waiting before the next attempt
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return nil, errors.New("registry unavailable")
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullRetries = 5
+
+	services := map[string]*compose.Service{
+		"web": {Image: "nginx:1.25"},
+	}
+
+	start := time.Now()
+	err := deployer.pullImages(ctx, services)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Backoff was not interrupted by the cancellation, took %v", elapsed)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no pull after the cancellation, got %d attempts", attempts)
+	}
+}
+
+func TestPullImages_PerServicePullPolicy(t *testing.T) {
+	mockCli := &MockDockerClient{
+		localImages: map[string]bool{
+			"cached/app:1.0": true,
+			"local/app:dev":  true,
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullPolicy = compose.PullPolicyAlways
+
+	services := map[string]*compose.Service{
+		"upstream":   {Image: "nginx:1.25"},
+		"local":      {Image: "local/app:dev", PullPolicy: compose.PullPolicyNever},
+		"built":      {Image: "local/built:dev", PullPolicy: compose.PullPolicyBuild},
+		"cached":     {Image: "cached/app:1.0", PullPolicy: compose.PullPolicyMissing},
+		"not_cached": {Image: "remote/app:1.0", PullPolicy: compose.PullPolicyMissing},
+	}
+
+	if err := deployer.pullImages(context.Background(), services); err != nil {
+		t.Fatalf("pullImages failed: %v", err)
+	}
+
+	pulled := make(map[string]bool)
+	for _, img := range mockCli.pulledImages {
+		pulled[img] = true
+	}
+
+	expected := map[string]bool{
+		"nginx:1.25":      true,
+		"local/app:dev":   false,
+		"local/built:dev": false,
+		"cached/app:1.0":  false,
+		"remote/app:1.0":  true,
+	}
+	for img, shouldPull := range expected {
+		if pulled[img] != shouldPull {
+			t.Errorf("image %s: pulled = %v, want %v", img, pulled[img], shouldPull)
+		}
+	}
+}
+
+func TestPullImages_GlobalPolicyOverriddenByService(t *testing.T) {
+	mockCli := &MockDockerClient{localImages: map[string]bool{"nginx:1.25": true}}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullPolicy = compose.PullPolicyNever
+
+	services := map[string]*compose.Service{
+		"web": {Image: "nginx:1.25"},
+		"api": {Image: "api:2.0", PullPolicy: compose.PullPolicyAlways},
+	}
+
+	if err := deployer.pullImages(context.Background(), services); err != nil {
+		t.Fatalf("pullImages failed: %v", err)
+	}
+
+	if len(mockCli.pulledImages) != 1 || mockCli.pulledImages[0] != "api:2.0" {
+		t.Errorf("Expected only api:2.0 to be pulled, got %v", mockCli.pulledImages)
+	}
+}
+
+func TestPullImages_NeverPolicyMissingImage(t *testing.T) {
+	mockCli := &MockDockerClient{localImages: map[string]bool{"nginx:1.25": true}}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.PullPolicy = compose.PullPolicyNever
+
+	services := map[string]*compose.Service{
+		"web":    {Image: "nginx:1.25"},
+		"api":    {Image: "registry.local/api:2.0"},
+		"worker": {Image: "registry.local/worker:2.0"},
+		"proxy":  {Image: "traefik:3.0", PullPolicy: compose.PullPolicyAlways},
+	}
+
+	err := deployer.pullImages(context.Background(), services)
+	if err == nil {
+		t.Fatal("Expected the pre-flight check to fail for images missing on the manager")
+	}
+	if !strings.Contains(err.Error(), "api (registry.local/api:2.0), worker (registry.local/worker:2.0)") {
+		t.Errorf("Expected both missing images in the error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "nginx") || strings.Contains(err.Error(), "traefik") {
+		t.Errorf("Expected present and pulled images not to be reported, got %v", err)
+	}
+	// The check runs before anything is pulled
+	if len(mockCli.pulledImages) != 0 {
+		t.Errorf("Expected no pulls after a failed pre-flight check, got %v", mockCli.pulledImages)
+	}
+}
+
+func TestPullImages_InvalidPullPolicy(t *testing.T) {
+	deployer := NewStackDeployer(&MockDockerClient{}, "test", 3)
+
+	services := map[string]*compose.Service{
+		"web": {Image: "nginx:1.25", PullPolicy: "sometimes"},
+	}
+
+	if err := deployer.pullImages(context.Background(), services); err == nil {
+		t.Error("Expected error for unsupported pull_policy")
+	}
+}
+
+func TestCheckImageAge_WarnsOnOldImage(t *testing.T) {
+	mockCli := &MockDockerClient{
+		imageCreated: map[string]time.Time{
+			"legacy/base:1.0": time.Now().Add(-200 * 24 * time.Hour),
+			"fresh/app:2.0":   time.Now().Add(-2 * 24 * time.Hour),
+		},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.MaxImageAge = 90 * 24 * time.Hour
+
+	services := map[string]*compose.Service{
+		"legacy": {Image: "legacy/base:1.0"},
+		"fresh":  {Image: "fresh/app:2.0"},
+		"absent": {Image: "missing/app:1.0"},
+	}
+
+	deployer.checkImageAge(context.Background(), services)
+
+	warnings := deployer.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "legacy/base:1.0") || !strings.Contains(warnings[0], "200 days") {
+		t.Errorf("Unexpected warning: %s", warnings[0])
+	}
+}
@@ -0,0 +1,308 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// ManagerHostsEnv lists additional manager endpoints (comma-separated) to fail over to
+const ManagerHostsEnv = "STACKMAN_MANAGER_HOSTS"
+
+// ErrManagerUnavailable is returned when the connected manager lost leadership
+// or is no longer a manager, and no other manager could take over
+var ErrManagerUnavailable = errors.New("swarm manager unavailable")
+
+// managerUnavailableMessages are the daemon and swarmkit errors that mean the
+// manager can't serve requests until leadership is restored. Retrying the same
+// manager won't help, unlike a timeout.
+var managerUnavailableMessages = []string{
+	"the swarm does not have a leader",
+	"this node is not a swarm manager",
+	"no elected cluster leader",
+	"not the leader",
+	"lost leadership",
+	"raft: stopped",
+}
+
+// IsManagerUnavailable reports whether err means the manager lost leadership
+// or swarm membership, as opposed to a transient failure such as a timeout
+func IsManagerUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrManagerUnavailable) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range managerUnavailableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// managerError explains a manager-unavailable error and how to recover
+func managerError(err error) error {
+	if errors.Is(err, ErrManagerUnavailable) {
+		return err
+	}
+	return fmt.Errorf("%w: %v (the manager may have lost leadership; reconnect to a healthy manager via DOCKER_HOST or list fallbacks in %s)",
+		ErrManagerUnavailable, err, ManagerHostsEnv)
+}
+
+// ManagerHosts returns the fallback manager endpoints from STACKMAN_MANAGER_HOSTS
+func ManagerHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv(ManagerHostsEnv), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// NewManagerClient returns cli, or when fallback manager hosts are configured a
+// FailoverClient that tries them in order after cli
+func NewManagerClient(cli DockerClient) (DockerClient, error) {
+	hosts := ManagerHosts()
+	if len(hosts) == 0 {
+		return cli, nil
+	}
+
+	clients := []DockerClient{cli}
+	for _, host := range hosts {
+		c, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(host), client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, fmt.Errorf("docker client for manager %s: %w", host, err)
+		}
+		clients = append(clients, c)
+	}
+	return NewFailoverClient(clients...), nil
+}
+
+// FailoverClient forwards calls to the current manager and switches to the next
+// one when a call fails because the manager is unavailable. The failed call is
+// retried on the new manager; other errors are returned unchanged.
+// Container, volume and image calls always go to the first client, the local
+// daemon, since those objects are node-local and another manager doesn't know them.
+type FailoverClient struct {
+	mu      sync.Mutex
+	clients []DockerClient
+	current int
+}
+
+// NewFailoverClient creates a client failing over between the given managers in order
+func NewFailoverClient(clients ...DockerClient) *FailoverClient {
+	return &FailoverClient{clients: clients}
+}
+
+// call runs fn against the current manager, failing over while managers report unavailable
+func (f *FailoverClient) call(fn func(c DockerClient) error) error {
+	f.mu.Lock()
+	start := f.current
+	f.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < len(f.clients); attempt++ {
+		idx := (start + attempt) % len(f.clients)
+		err = fn(f.clients[idx])
+		if !IsManagerUnavailable(err) {
+			f.mu.Lock()
+			f.current = idx
+			f.mu.Unlock()
+			return err
+		}
+		if attempt < len(f.clients)-1 {
+			log.Printf("[Failover] Manager %d/%d unavailable: %v, trying next manager", idx+1, len(f.clients), err)
+		}
+	}
+	return managerError(err)
+}
+
+func (f *FailoverClient) ServiceList(ctx context.Context, options types.ServiceListOptions) (result []swarm.Service, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.ServiceList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) ServiceCreate(ctx context.Context, service swarm.ServiceSpec, options types.ServiceCreateOptions) (result swarm.ServiceCreateResponse, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.ServiceCreate(ctx, service, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec, options types.ServiceUpdateOptions) (result swarm.ServiceUpdateResponse, err error) {
+	err = f.call(func(c DockerClient) error {
+		result, err = c.ServiceUpdate(ctx, serviceID, version, service, options)
+		return err
+	})
+	return result, err
+}
+
+func (f *FailoverClient) ServiceRemove(ctx context.Context, serviceID string) error {
+	return f.call(func(c DockerClient) error { return c.ServiceRemove(ctx, serviceID) })
+}
+
+func (f *FailoverClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (result swarm.Service, raw []byte, err error) {
+	err = f.call(func(c DockerClient) error {
+		result, raw, err = c.ServiceInspectWithRaw(ctx, serviceID, options)
+		return err
+	})
+	return result, raw, err
+}
+
+func (f *FailoverClient) TaskList(ctx context.Context, options types.TaskListOptions) (result []swarm.Task, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.TaskList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) NodeList(ctx context.Context, options types.NodeListOptions) (result []swarm.Node, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.NodeList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	return f.clients[0].ContainerList(ctx, options)
+}
+
+func (f *FailoverClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	return f.clients[0].ContainerInspect(ctx, containerID)
+}
+
+func (f *FailoverClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	return f.clients[0].ContainerRemove(ctx, containerID, options)
+}
+
+func (f *FailoverClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (result network.CreateResponse, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.NetworkCreate(ctx, name, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) NetworkList(ctx context.Context, options network.ListOptions) (result []network.Summary, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.NetworkList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (result network.Inspect, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.NetworkInspect(ctx, networkID, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	return f.clients[0].VolumeCreate(ctx, options)
+}
+
+func (f *FailoverClient) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	return f.clients[0].VolumeList(ctx, options)
+}
+
+func (f *FailoverClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	return f.clients[0].VolumeInspect(ctx, volumeID)
+}
+
+func (f *FailoverClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	return f.clients[0].VolumeRemove(ctx, volumeID, force)
+}
+
+func (f *FailoverClient) NetworkRemove(ctx context.Context, networkID string) error {
+	return f.call(func(c DockerClient) error { return c.NetworkRemove(ctx, networkID) })
+}
+
+func (f *FailoverClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	return f.clients[0].ImagePull(ctx, refStr, options)
+}
+
+func (f *FailoverClient) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	return f.clients[0].ImageInspect(ctx, imageID, inspectOpts...)
+}
+
+func (f *FailoverClient) DistributionInspect(ctx context.Context, imageRef, encodedRegistryAuth string) (result registry.DistributionInspect, err error) {
+	err = f.call(func(c DockerClient) error {
+		result, err = c.DistributionInspect(ctx, imageRef, encodedRegistryAuth)
+		return err
+	})
+	return result, err
+}
+
+func (f *FailoverClient) SecretList(ctx context.Context, options swarm.SecretListOptions) (result []swarm.Secret, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.SecretList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) SecretCreate(ctx context.Context, secret swarm.SecretSpec) (result swarm.SecretCreateResponse, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.SecretCreate(ctx, secret); return err })
+	return result, err
+}
+
+func (f *FailoverClient) SecretRemove(ctx context.Context, id string) error {
+	return f.call(func(c DockerClient) error { return c.SecretRemove(ctx, id) })
+}
+
+func (f *FailoverClient) ConfigList(ctx context.Context, options swarm.ConfigListOptions) (result []swarm.Config, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.ConfigList(ctx, options); return err })
+	return result, err
+}
+
+func (f *FailoverClient) ConfigCreate(ctx context.Context, config swarm.ConfigSpec) (result swarm.ConfigCreateResponse, err error) {
+	err = f.call(func(c DockerClient) error { result, err = c.ConfigCreate(ctx, config); return err })
+	return result, err
+}
+
+func (f *FailoverClient) ConfigRemove(ctx context.Context, id string) error {
+	return f.call(func(c DockerClient) error { return c.ConfigRemove(ctx, id) })
+}
+
+// Close closes all manager clients
+func (f *FailoverClient) Close() error {
+	var errs []error
+	for _, c := range f.clients {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClusterClient is the full Docker API client of the local daemon, with the
+// swarm-level reads used while waiting for a deploy (services, tasks, nodes)
+// sent through managers, typically a FailoverClient. Container calls, logs
+// and events stay on the local daemon.
+type ClusterClient struct {
+	client.APIClient
+	managers DockerClient
+}
+
+// NewClusterClient creates a ClusterClient reading cluster state through managers
+func NewClusterClient(local client.APIClient, managers DockerClient) *ClusterClient {
+	return &ClusterClient{APIClient: local, managers: managers}
+}
+
+func (c *ClusterClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	return c.managers.ServiceList(ctx, options)
+}
+
+func (c *ClusterClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (swarm.Service, []byte, error) {
+	return c.managers.ServiceInspectWithRaw(ctx, serviceID, options)
+}
+
+func (c *ClusterClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	return c.managers.TaskList(ctx, options)
+}
+
+func (c *ClusterClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	return c.managers.NodeList(ctx, options)
+}
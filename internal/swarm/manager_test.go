@@ -0,0 +1,243 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// timeoutError mimics a net.Error timeout from the HTTP transport
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestIsManagerUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"no leader", errors.New("Error response from daemon: rpc error: code = Unknown desc = The swarm does not have a leader. It's possible that too few managers are online."), true},
+		{"not a manager", errors.New("Error response from daemon: This node is not a swarm manager. Worker nodes can't be used to view or modify cluster state."), true},
+		{"raft leader", errors.New("rpc error: code = Unavailable desc = raft: no elected cluster leader"), true},
+		{"wrapped", fmt.Errorf("failed to list tasks: %w", errors.New("node is not the leader")), true},
+		{"sentinel", managerError(errors.New("lost leadership")), true},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"network timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, false},
+		{"client timeout", errors.New("Get \"http://docker/v1.47/tasks\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)"), false},
+		{"not found", errors.New("service not found: web"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsManagerUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsManagerUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedManager returns a fixed ServiceList error and counts calls
+type scriptedManager struct {
+	*MockDockerClient
+	err   error
+	calls int
+}
+
+func (m *scriptedManager) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.MockDockerClient.ServiceList(ctx, options)
+}
+
+func TestFailoverClient_SwitchesOnLeadershipLoss(t *testing.T) {
+	lost := &scriptedManager{MockDockerClient: &MockDockerClient{}, err: errors.New("The swarm does not have a leader")}
+	healthy := &scriptedManager{MockDockerClient: &MockDockerClient{services: []swarm.Service{{ID: "svc1"}}}}
+
+	f := NewFailoverClient(lost, healthy)
+	services, err := f.ServiceList(context.Background(), types.ServiceListOptions{})
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if len(services) != 1 || services[0].ID != "svc1" {
+		t.Errorf("Expected services from the healthy manager, got %+v", services)
+	}
+
+	// Later calls stay on the manager that answered
+	if _, err := f.ServiceList(context.Background(), types.ServiceListOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lost.calls != 1 || healthy.calls != 2 {
+		t.Errorf("Expected calls lost=1 healthy=2, got lost=%d healthy=%d", lost.calls, healthy.calls)
+	}
+}
+
+func TestFailoverClient_TimeoutIsNotFailover(t *testing.T) {
+	slow := &scriptedManager{MockDockerClient: &MockDockerClient{}, err: context.DeadlineExceeded}
+	other := &scriptedManager{MockDockerClient: &MockDockerClient{}}
+
+	_, err := NewFailoverClient(slow, other).ServiceList(context.Background(), types.ServiceListOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the timeout to be returned unchanged, got %v", err)
+	}
+	if errors.Is(err, ErrManagerUnavailable) {
+		t.Errorf("Timeout must not be reported as manager unavailable")
+	}
+	if other.calls != 0 {
+		t.Errorf("Expected no failover on a timeout, got %d calls to the other manager", other.calls)
+	}
+}
+
+func TestFailoverClient_AllManagersUnavailable(t *testing.T) {
+	a := &scriptedManager{MockDockerClient: &MockDockerClient{}, err: errors.New("This node is not a swarm manager")}
+	b := &scriptedManager{MockDockerClient: &MockDockerClient{}, err: errors.New("The swarm does not have a leader")}
+
+	_, err := NewFailoverClient(a, b).ServiceList(context.Background(), types.ServiceListOptions{})
+	if !errors.Is(err, ErrManagerUnavailable) {
+		t.Errorf("Expected ErrManagerUnavailable, got %v", err)
+	}
+}
+
+func TestFailoverClient_ContainerCallsStayLocal(t *testing.T) {
+	local := &scriptedManager{MockDockerClient: &MockDockerClient{}, err: errors.New("The swarm does not have a leader")}
+	other := &scriptedManager{MockDockerClient: &MockDockerClient{}}
+
+	f := NewFailoverClient(local, other)
+	if _, err := f.ServiceList(context.Background(), types.ServiceListOptions{}); err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if err := f.ContainerRemove(context.Background(), "c1", container.RemoveOptions{}); err != nil {
+		t.Fatalf("ContainerRemove failed: %v", err)
+	}
+	if len(local.removedContainers) != 1 || len(other.removedContainers) != 0 {
+		t.Errorf("Expected the container to be removed on the local daemon, got local=%v other=%v",
+			local.removedContainers, other.removedContainers)
+	}
+}
+
+// leaderlessDaemon fails volume and image calls with a manager-unavailable error
+// and counts them
+type leaderlessDaemon struct {
+	*MockDockerClient
+	err   error
+	calls int
+}
+
+func (d *leaderlessDaemon) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	d.calls++
+	return volume.Volume{}, d.err
+}
+
+func (d *leaderlessDaemon) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	d.calls++
+	return volume.ListResponse{}, d.err
+}
+
+func (d *leaderlessDaemon) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	d.calls++
+	return volume.Volume{}, d.err
+}
+
+func (d *leaderlessDaemon) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	d.calls++
+	return d.err
+}
+
+func (d *leaderlessDaemon) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	d.calls++
+	return nil, d.err
+}
+
+func (d *leaderlessDaemon) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	d.calls++
+	return image.InspectResponse{}, d.err
+}
+
+func TestFailoverClient_VolumeAndImageCallsStayLocal(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(f *FailoverClient) error
+	}{
+		{"VolumeCreate", func(f *FailoverClient) error {
+			_, err := f.VolumeCreate(ctx, volume.CreateOptions{Name: "data"})
+			return err
+		}},
+		{"VolumeList", func(f *FailoverClient) error { _, err := f.VolumeList(ctx, volume.ListOptions{}); return err }},
+		{"VolumeInspect", func(f *FailoverClient) error { _, err := f.VolumeInspect(ctx, "data"); return err }},
+		{"VolumeRemove", func(f *FailoverClient) error { return f.VolumeRemove(ctx, "data", false) }},
+		{"ImagePull", func(f *FailoverClient) error {
+			_, err := f.ImagePull(ctx, "nginx:1.25", image.PullOptions{})
+			return err
+		}},
+		{"ImageInspect", func(f *FailoverClient) error { _, err := f.ImageInspect(ctx, "nginx:1.25"); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := &leaderlessDaemon{MockDockerClient: &MockDockerClient{}, err: errors.New("The swarm does not have a leader")}
+			other := &leaderlessDaemon{MockDockerClient: &MockDockerClient{}}
+
+			if err := tt.call(NewFailoverClient(local, other)); err == nil {
+				t.Fatal("Expected the local daemon's error to be returned")
+			}
+			if local.calls != 1 || other.calls != 0 {
+				t.Errorf("Expected one call on the local daemon and none on another manager, got local=%d other=%d",
+					local.calls, other.calls)
+			}
+		})
+	}
+}
+
+// localDaemon is the part of client.APIClient the ClusterClient test calls
+type localDaemon struct {
+	client.APIClient
+	inspected []string
+}
+
+func (d *localDaemon) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	d.inspected = append(d.inspected, containerID)
+	return types.ContainerJSON{}, nil
+}
+
+func TestClusterClient(t *testing.T) {
+	local := &localDaemon{}
+	managers := &scriptedManager{MockDockerClient: &MockDockerClient{services: []swarm.Service{{ID: "svc1"}}}}
+
+	c := NewClusterClient(local, managers)
+	services, err := c.ServiceList(context.Background(), types.ServiceListOptions{})
+	if err != nil || len(services) != 1 || managers.calls != 1 {
+		t.Errorf("Expected services to be listed through the managers, got %+v, %v (%d calls)", services, err, managers.calls)
+	}
+	if _, err := c.ContainerInspect(context.Background(), "c1"); err != nil {
+		t.Fatalf("ContainerInspect failed: %v", err)
+	}
+	if len(local.inspected) != 1 {
+		t.Errorf("Expected the container to be inspected on the local daemon, got %v", local.inspected)
+	}
+}
+
+func TestManagerHosts(t *testing.T) {
+	t.Setenv(ManagerHostsEnv, " tcp://m2:2376, ,tcp://m3:2376")
+	hosts := ManagerHosts()
+	if len(hosts) != 2 || hosts[0] != "tcp://m2:2376" || hosts[1] != "tcp://m3:2376" {
+		t.Errorf("Unexpected hosts: %v", hosts)
+	}
+}
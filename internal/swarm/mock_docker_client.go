@@ -4,25 +4,62 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // MockDockerClient implements DockerClient interface for testing
 type MockDockerClient struct {
-	services        []swarm.Service
-	tasks           []swarm.Task
-	containers      []types.Container
-	networks        []network.Summary
-	volumes         []volume.Volume
-	removedServices []string
-	updatedServices []string
-	createdServices []swarm.Service
+	services          []swarm.Service
+	tasks             []swarm.Task
+	nodes             []swarm.Node
+	containers        []types.Container
+	removedContainers []string
+	networks          []network.Summary
+	volumes           []volume.Volume
+	removedVolumes    []string
+	removedServices   []string
+	updatedServices   []string
+	updatedSpecs      map[string]swarm.ServiceSpec
+	createdServices   []swarm.Service
+	createdNetworks   []string
+	networkOptions    map[string]network.CreateOptions
+	removedNetworks   []string
+	secrets           []swarm.Secret
+	createdSecrets    []swarm.SecretSpec
+	removedSecrets    []string
+	configs           []swarm.Config
+	createdConfigs    []swarm.ConfigSpec
+	removedConfigs    []string
+
+	// imagePullFunc overrides ImagePull behaviour when set
+	imagePullFunc func(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+	pulledImages  []string
+	localImages   map[string]bool
+	imageCreated  map[string]time.Time
+	// registryDigests maps image references to the digest DistributionInspect returns
+	registryDigests map[string]string
+}
+
+// MutationCount returns how many calls changed cluster state (creating, updating
+// or removing services, networks, volumes, secrets, configs or containers)
+func (m *MockDockerClient) MutationCount() int {
+	return len(m.createdServices) + len(m.updatedServices) + len(m.removedServices) +
+		len(m.createdNetworks) + len(m.removedNetworks) + len(m.removedVolumes) +
+		len(m.createdSecrets) + len(m.removedSecrets) +
+		len(m.createdConfigs) + len(m.removedConfigs) + len(m.removedContainers)
 }
 
 func (m *MockDockerClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
@@ -40,11 +77,21 @@ func (m *MockDockerClient) ServiceCreate(ctx context.Context, service swarm.Serv
 
 func (m *MockDockerClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec, options types.ServiceUpdateOptions) (swarm.ServiceUpdateResponse, error) {
 	m.updatedServices = append(m.updatedServices, serviceID)
+	if m.updatedSpecs == nil {
+		m.updatedSpecs = make(map[string]swarm.ServiceSpec)
+	}
+	m.updatedSpecs[serviceID] = service
 	return swarm.ServiceUpdateResponse{}, nil
 }
 
 func (m *MockDockerClient) ServiceRemove(ctx context.Context, serviceID string) error {
 	m.removedServices = append(m.removedServices, serviceID)
+	for i, svc := range m.services {
+		if svc.ID == serviceID {
+			m.services = append(m.services[:i], m.services[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
@@ -54,13 +101,22 @@ func (m *MockDockerClient) ServiceInspectWithRaw(ctx context.Context, serviceID
 			return svc, nil, nil
 		}
 	}
-	return swarm.Service{}, nil, fmt.Errorf("service not found: %s", serviceID)
+	for _, svc := range m.createdServices {
+		if svc.ID == serviceID {
+			return svc, nil, nil
+		}
+	}
+	return swarm.Service{}, nil, fmt.Errorf("service %s not found: %w", serviceID, cerrdefs.ErrNotFound)
 }
 
 func (m *MockDockerClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
 	return m.tasks, nil
 }
 
+func (m *MockDockerClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	return m.nodes, nil
+}
+
 func (m *MockDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
 	return m.containers, nil
 }
@@ -70,10 +126,16 @@ func (m *MockDockerClient) ContainerInspect(ctx context.Context, containerID str
 }
 
 func (m *MockDockerClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	m.removedContainers = append(m.removedContainers, containerID)
 	return nil
 }
 
 func (m *MockDockerClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	m.createdNetworks = append(m.createdNetworks, name)
+	if m.networkOptions == nil {
+		m.networkOptions = make(map[string]network.CreateOptions)
+	}
+	m.networkOptions[name] = options
 	return network.CreateResponse{ID: "network_" + name}, nil
 }
 
@@ -82,7 +144,12 @@ func (m *MockDockerClient) NetworkList(ctx context.Context, options network.List
 }
 
 func (m *MockDockerClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
-	return network.Inspect{}, nil
+	for _, net := range m.networks {
+		if net.ID == networkID || net.Name == networkID {
+			return network.Inspect{ID: net.ID, Name: net.Name, Driver: net.Driver, Labels: net.Labels}, nil
+		}
+	}
+	return network.Inspect{}, fmt.Errorf("network not found: %s", networkID)
 }
 
 func (m *MockDockerClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
@@ -101,12 +168,71 @@ func (m *MockDockerClient) VolumeInspect(ctx context.Context, volumeID string) (
 	return volume.Volume{}, nil
 }
 
+func (m *MockDockerClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	m.removedVolumes = append(m.removedVolumes, volumeID)
+	return nil
+}
+
 func (m *MockDockerClient) NetworkRemove(ctx context.Context, networkID string) error {
+	m.removedNetworks = append(m.removedNetworks, networkID)
 	return nil
 }
 
 func (m *MockDockerClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
-	return io.NopCloser(nil), nil
+	m.pulledImages = append(m.pulledImages, refStr)
+	if m.imagePullFunc != nil {
+		return m.imagePullFunc(ctx, refStr, options)
+	}
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (m *MockDockerClient) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	if created, ok := m.imageCreated[imageID]; ok {
+		return image.InspectResponse{ID: imageID, Created: created.Format(time.RFC3339Nano)}, nil
+	}
+	if m.localImages[imageID] {
+		return image.InspectResponse{ID: imageID}, nil
+	}
+	return image.InspectResponse{}, fmt.Errorf("no such image: %s", imageID)
+}
+
+func (m *MockDockerClient) DistributionInspect(ctx context.Context, imageRef, encodedRegistryAuth string) (registry.DistributionInspect, error) {
+	if dgst, ok := m.registryDigests[imageRef]; ok {
+		return registry.DistributionInspect{Descriptor: ocispec.Descriptor{Digest: digest.Digest(dgst)}}, nil
+	}
+	return registry.DistributionInspect{}, fmt.Errorf("manifest unknown: %s", imageRef)
+}
+
+func (m *MockDockerClient) SecretList(ctx context.Context, options swarm.SecretListOptions) ([]swarm.Secret, error) {
+	return m.secrets, nil
+}
+
+func (m *MockDockerClient) SecretCreate(ctx context.Context, secret swarm.SecretSpec) (swarm.SecretCreateResponse, error) {
+	m.createdSecrets = append(m.createdSecrets, secret)
+	id := fmt.Sprintf("secret_%d", len(m.createdSecrets))
+	m.secrets = append(m.secrets, swarm.Secret{ID: id, Spec: secret})
+	return swarm.SecretCreateResponse{ID: id}, nil
+}
+
+func (m *MockDockerClient) SecretRemove(ctx context.Context, id string) error {
+	m.removedSecrets = append(m.removedSecrets, id)
+	return nil
+}
+
+func (m *MockDockerClient) ConfigList(ctx context.Context, options swarm.ConfigListOptions) ([]swarm.Config, error) {
+	return m.configs, nil
+}
+
+func (m *MockDockerClient) ConfigCreate(ctx context.Context, config swarm.ConfigSpec) (swarm.ConfigCreateResponse, error) {
+	m.createdConfigs = append(m.createdConfigs, config)
+	id := fmt.Sprintf("config_%d", len(m.createdConfigs))
+	m.configs = append(m.configs, swarm.Config{ID: id, Spec: config})
+	return swarm.ConfigCreateResponse{ID: id}, nil
+}
+
+func (m *MockDockerClient) ConfigRemove(ctx context.Context, id string) error {
+	m.removedConfigs = append(m.removedConfigs, id)
+	return nil
 }
 
 func (m *MockDockerClient) Close() error {
@@ -6,19 +6,36 @@ import (
 	"log"
 
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
 
 	"github.com/SomeBlackMagic/stackman/internal/compose"
 )
 
 func (d *StackDeployer) createNetworks(ctx context.Context, networks map[string]*compose.Network) error {
+	d.networks = make(map[string]string, len(networks))
+
 	if len(networks) == 0 {
 		// Create default network for the stack
 		return d.ensureDefaultNetwork(ctx)
 	}
 
-	for name, netConfig := range networks {
+	for _, name := range sortedKeys(networks) {
+		netConfig := networks[name]
 		fullName := fmt.Sprintf("%s_%s", d.stackName, name)
 
+		// External networks are attached by their real name and never created
+		if netConfig != nil && compose.IsExternal(netConfig.External) {
+			externalName := externalNetworkName(name, netConfig)
+			if _, err := d.cli.NetworkInspect(ctx, externalName, network.InspectOptions{}); err != nil {
+				return fmt.Errorf("external network %s not found: %w", externalName, err)
+			}
+			log.Printf("Using external network: %s", externalName)
+			d.networks[fullName] = externalName
+			continue
+		}
+
+		d.networks[fullName] = fullName
+
 		// Check if network already exists
 		_, err := d.cli.NetworkInspect(ctx, fullName, network.InspectOptions{})
 		if err == nil {
@@ -26,31 +43,7 @@ func (d *StackDeployer) createNetworks(ctx context.Context, networks map[string]
 			continue
 		}
 
-		// Create network
-		driver := "overlay"
-		if netConfig != nil && netConfig.Driver != "" {
-			driver = netConfig.Driver
-		}
-
-		labels := map[string]string{
-			"com.docker.stack.namespace": d.stackName,
-		}
-		if netConfig != nil && netConfig.Labels != nil {
-			for k, v := range netConfig.Labels {
-				labels[k] = v
-			}
-		}
-
-		opts := network.CreateOptions{
-			Driver:     driver,
-			Labels:     labels,
-			Attachable: netConfig != nil && netConfig.Attachable,
-		}
-
-		if netConfig != nil && netConfig.DriverOpts != nil {
-			opts.Options = netConfig.DriverOpts
-		}
-
+		opts := networkCreateOptions(d.stackName, netConfig)
 		_, err = d.cli.NetworkCreate(ctx, fullName, opts)
 		if err != nil {
 			return fmt.Errorf("failed to create network %s: %w", fullName, err)
@@ -62,6 +55,71 @@ func (d *StackDeployer) createNetworks(ctx context.Context, networks map[string]
 	return nil
 }
 
+// networkCreateOptions builds the create options of a stack network from its compose definition.
+// Networks default to the overlay driver and always carry the stack namespace label.
+func networkCreateOptions(stackName string, netConfig *compose.Network) network.CreateOptions {
+	opts := network.CreateOptions{
+		Driver: "overlay",
+		Labels: map[string]string{
+			"com.docker.stack.namespace": stackName,
+		},
+	}
+	if netConfig == nil {
+		return opts
+	}
+
+	if netConfig.Driver != "" {
+		opts.Driver = netConfig.Driver
+	}
+	for k, v := range netConfig.Labels {
+		opts.Labels[k] = v
+	}
+	opts.Options = netConfig.DriverOpts
+	opts.Attachable = netConfig.Attachable
+	opts.Internal = netConfig.Internal
+	if netConfig.EnableIPv6 {
+		enableIPv6 := true
+		opts.EnableIPv6 = &enableIPv6
+	}
+
+	if ipam := netConfig.IPAM; ipam != nil {
+		opts.IPAM = &network.IPAM{
+			Driver:  ipam.Driver,
+			Options: ipam.Options,
+		}
+		for _, pool := range ipam.Config {
+			opts.IPAM.Config = append(opts.IPAM.Config, network.IPAMConfig{
+				Subnet:     pool.Subnet,
+				IPRange:    pool.IPRange,
+				Gateway:    pool.Gateway,
+				AuxAddress: pool.AuxAddress,
+			})
+		}
+	}
+
+	return opts
+}
+
+// externalNetworkName returns the swarm name of an external network
+func externalNetworkName(name string, netConfig *compose.Network) string {
+	if extName := compose.ExternalName(netConfig.External); extName != "" {
+		return extName
+	}
+	if netConfig.Name != "" {
+		return netConfig.Name
+	}
+	return name
+}
+
+// resolveNetworkAttachments rewrites stack-scoped attachment targets to actual network names
+func (d *StackDeployer) resolveNetworkAttachments(attachments []swarm.NetworkAttachmentConfig) {
+	for i := range attachments {
+		if actual, ok := d.networks[attachments[i].Target]; ok {
+			attachments[i].Target = actual
+		}
+	}
+}
+
 func (d *StackDeployer) ensureDefaultNetwork(ctx context.Context) error {
 	networkName := fmt.Sprintf("%s_default", d.stackName)
 
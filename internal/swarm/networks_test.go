@@ -0,0 +1,202 @@
+package swarm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"gopkg.in/yaml.v3"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestCreateNetworks_External(t *testing.T) {
+	tests := []struct {
+		name           string
+		netConfig      *compose.Network
+		expectedTarget string
+	}{
+		{
+			name:           "external bool",
+			netConfig:      &compose.Network{External: true},
+			expectedTarget: "shared",
+		},
+		{
+			name:           "external with name",
+			netConfig:      &compose.Network{External: map[string]interface{}{"name": "traefik_public"}},
+			expectedTarget: "traefik_public",
+		},
+		{
+			name:           "external bool with top-level name",
+			netConfig:      &compose.Network{External: true, Name: "traefik_public"},
+			expectedTarget: "traefik_public",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCli := &MockDockerClient{
+				networks: []network.Summary{
+					{ID: "net1", Name: "shared", Driver: "overlay"},
+					{ID: "net2", Name: "traefik_public", Driver: "overlay"},
+				},
+			}
+			deployer := NewStackDeployer(mockCli, "test", 3)
+
+			networks := map[string]*compose.Network{"shared": tt.netConfig}
+			if err := deployer.createNetworks(context.Background(), networks); err != nil {
+				t.Fatalf("createNetworks failed: %v", err)
+			}
+
+			if len(mockCli.createdNetworks) != 0 {
+				t.Errorf("External network must not be created, got %v", mockCli.createdNetworks)
+			}
+
+			attachments := []swarm.NetworkAttachmentConfig{{Target: "test_shared"}}
+			deployer.resolveNetworkAttachments(attachments)
+			if attachments[0].Target != tt.expectedTarget {
+				t.Errorf("Target = %s, want %s", attachments[0].Target, tt.expectedTarget)
+			}
+		})
+	}
+}
+
+func TestCreateNetworks_ExternalMissing(t *testing.T) {
+	deployer := NewStackDeployer(&MockDockerClient{}, "test", 3)
+
+	networks := map[string]*compose.Network{"shared": {External: true}}
+	if err := deployer.createNetworks(context.Background(), networks); err == nil {
+		t.Error("Expected error for missing external network")
+	}
+}
+
+func TestCreateNetworks_StackNetwork(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	networks := map[string]*compose.Network{"backend": {Driver: "overlay"}}
+	if err := deployer.createNetworks(context.Background(), networks); err != nil {
+		t.Fatalf("createNetworks failed: %v", err)
+	}
+
+	if len(mockCli.createdNetworks) != 1 || mockCli.createdNetworks[0] != "test_backend" {
+		t.Errorf("Expected test_backend to be created, got %v", mockCli.createdNetworks)
+	}
+
+	attachments := []swarm.NetworkAttachmentConfig{{Target: "test_backend"}}
+	deployer.resolveNetworkAttachments(attachments)
+	if attachments[0].Target != "test_backend" {
+		t.Errorf("Target = %s, want test_backend", attachments[0].Target)
+	}
+}
+
+func TestCreateNetworks_Options(t *testing.T) {
+	data := `
+services:
+  api:
+    image: api:1.0
+networks:
+  secure:
+    driver_opts:
+      encrypted: "true"
+    attachable: true
+    internal: true
+    enable_ipv6: true
+    labels:
+      team: payments
+    ipam:
+      driver: default
+      config:
+        - subnet: 10.20.0.0/24
+          ip_range: 10.20.0.128/25
+          gateway: 10.20.0.1
+          aux_addresses:
+            router: 10.20.0.2
+  plain:
+`
+	var file compose.ComposeFile
+	if err := yaml.Unmarshal([]byte(data), &file); err != nil {
+		t.Fatalf("Failed to parse compose file: %v", err)
+	}
+
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	if err := deployer.createNetworks(context.Background(), file.Networks); err != nil {
+		t.Fatalf("createNetworks failed: %v", err)
+	}
+
+	enableIPv6 := true
+	want := network.CreateOptions{
+		Driver:     "overlay",
+		EnableIPv6: &enableIPv6,
+		Internal:   true,
+		Attachable: true,
+		Options:    map[string]string{"encrypted": "true"},
+		Labels:     map[string]string{"com.docker.stack.namespace": "test", "team": "payments"},
+		IPAM: &network.IPAM{
+			Driver: "default",
+			Config: []network.IPAMConfig{{
+				Subnet:     "10.20.0.0/24",
+				IPRange:    "10.20.0.128/25",
+				Gateway:    "10.20.0.1",
+				AuxAddress: map[string]string{"router": "10.20.0.2"},
+			}},
+		},
+	}
+	if got := mockCli.networkOptions["test_secure"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("test_secure create options = %+v, want %+v", got, want)
+	}
+
+	plain := network.CreateOptions{
+		Driver: "overlay",
+		Labels: map[string]string{"com.docker.stack.namespace": "test"},
+	}
+	if got := mockCli.networkOptions["test_plain"]; !reflect.DeepEqual(got, plain) {
+		t.Errorf("test_plain create options = %+v, want %+v", got, plain)
+	}
+}
+
+func TestDeploy_UndeclaredNetworkRejected(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.25", Networks: []interface{}{"frontend", "backend"}},
+		},
+		Networks: map[string]*compose.Network{"frontend": {}},
+	}
+
+	_, err := deployer.Deploy(context.Background(), composeFile, "deploy-1")
+	if err == nil || !strings.Contains(err.Error(), "service web: network backend is not declared") {
+		t.Fatalf("Expected the undeclared network to be rejected, got %v", err)
+	}
+	if len(mockCli.createdNetworks) != 0 || len(mockCli.createdServices) != 0 {
+		t.Errorf("Expected nothing created, got networks %v and %d service(s)", mockCli.createdNetworks, len(mockCli.createdServices))
+	}
+}
+
+func TestDeploy_DefaultNetworkAlongsideDeclared(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web":    {Image: "nginx:1.25", Networks: []interface{}{"frontend"}},
+			"worker": {Image: "worker:1.0"},
+		},
+		Networks: map[string]*compose.Network{"frontend": {}},
+	}
+
+	if _, err := deployer.Deploy(context.Background(), composeFile, "deploy-1"); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	created := strings.Join(mockCli.createdNetworks, ",")
+	if created != "test_frontend,test_default" {
+		t.Errorf("Expected test_frontend and test_default to be created, got %v", mockCli.createdNetworks)
+	}
+}
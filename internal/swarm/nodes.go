@@ -0,0 +1,82 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// GlobalTaskTarget returns how many tasks a global service should run: one per
+// ready, active node that satisfies the service's placement constraints
+func GlobalTaskTarget(ctx context.Context, cli DockerClient, placement *swarm.Placement) (int, error) {
+	nodes, err := cli.NodeList(ctx, types.NodeListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	var constraints []string
+	if placement != nil {
+		constraints = placement.Constraints
+	}
+
+	count := 0
+	for _, node := range nodes {
+		if node.Status.State != swarm.NodeStateReady || node.Spec.Availability != swarm.NodeAvailabilityActive {
+			continue
+		}
+		if nodeMatchesConstraints(node, constraints) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// nodeMatchesConstraints evaluates placement constraints (attr==value, attr!=value)
+// against a node. Attributes stackman doesn't know are treated as satisfied, so
+// the target errs towards what the scheduler may place rather than failing the wait.
+func nodeMatchesConstraints(node swarm.Node, constraints []string) bool {
+	for _, constraint := range constraints {
+		op := "=="
+		key, want, ok := strings.Cut(constraint, op)
+		if !ok {
+			op = "!="
+			if key, want, ok = strings.Cut(constraint, op); !ok {
+				continue
+			}
+		}
+		key, want = strings.TrimSpace(key), strings.TrimSpace(want)
+
+		actual, known := nodeAttribute(node, key)
+		if !known {
+			continue
+		}
+		if (actual == want) != (op == "==") {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeAttribute returns the value of a placement constraint attribute for a node
+func nodeAttribute(node swarm.Node, key string) (string, bool) {
+	switch {
+	case key == "node.id":
+		return node.ID, true
+	case key == "node.hostname":
+		return node.Description.Hostname, true
+	case key == "node.role":
+		return string(node.Spec.Role), true
+	case key == "node.platform.os":
+		return node.Description.Platform.OS, true
+	case key == "node.platform.arch":
+		return node.Description.Platform.Architecture, true
+	case strings.HasPrefix(key, "node.labels."):
+		return node.Spec.Labels[strings.TrimPrefix(key, "node.labels.")], true
+	case strings.HasPrefix(key, "engine.labels."):
+		return node.Description.Engine.Labels[strings.TrimPrefix(key, "engine.labels.")], true
+	}
+	return "", false
+}
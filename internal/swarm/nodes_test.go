@@ -0,0 +1,53 @@
+package swarm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func testNode(id string, role swarm.NodeRole, labels map[string]string, state swarm.NodeState, availability swarm.NodeAvailability) swarm.Node {
+	return swarm.Node{
+		ID:          id,
+		Spec:        swarm.NodeSpec{Role: role, Availability: availability, Annotations: swarm.Annotations{Labels: labels}},
+		Description: swarm.NodeDescription{Hostname: id + ".local", Platform: swarm.Platform{OS: "linux", Architecture: "x86_64"}},
+		Status:      swarm.NodeStatus{State: state},
+	}
+}
+
+func TestGlobalTaskTarget(t *testing.T) {
+	mockCli := &MockDockerClient{nodes: []swarm.Node{
+		testNode("manager1", swarm.NodeRoleManager, nil, swarm.NodeStateReady, swarm.NodeAvailabilityActive),
+		testNode("worker1", swarm.NodeRoleWorker, map[string]string{"zone": "eu-1"}, swarm.NodeStateReady, swarm.NodeAvailabilityActive),
+		testNode("worker2", swarm.NodeRoleWorker, map[string]string{"zone": "eu-2"}, swarm.NodeStateReady, swarm.NodeAvailabilityActive),
+		testNode("worker3", swarm.NodeRoleWorker, map[string]string{"zone": "eu-1"}, swarm.NodeStateDown, swarm.NodeAvailabilityActive),
+		testNode("worker4", swarm.NodeRoleWorker, map[string]string{"zone": "eu-1"}, swarm.NodeStateReady, swarm.NodeAvailabilityDrain),
+	}}
+
+	tests := []struct {
+		name        string
+		constraints []string
+		want        int
+	}{
+		{"no constraints", nil, 3},
+		{"workers only", []string{"node.role == worker"}, 2},
+		{"not a manager", []string{"node.role!=manager"}, 2},
+		{"label", []string{"node.labels.zone==eu-1"}, 1},
+		{"combined", []string{"node.role==worker", "node.labels.zone!=eu-1"}, 1},
+		{"hostname", []string{"node.hostname==manager1.local"}, 1},
+		{"unknown attribute", []string{"node.custom==x"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GlobalTaskTarget(context.Background(), mockCli, &swarm.Placement{Constraints: tt.constraints})
+			if err != nil {
+				t.Fatalf("GlobalTaskTarget failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GlobalTaskTarget(%v) = %d, want %d", tt.constraints, got, tt.want)
+			}
+		})
+	}
+}
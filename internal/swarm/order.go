@@ -0,0 +1,123 @@
+package swarm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// deploymentLevels groups services by depends_on so that every service comes
+// after all of its dependencies. Services within one level are independent of
+// each other and may be deployed concurrently.
+func deploymentLevels(services map[string]*compose.Service) ([][]string, error) {
+	deps := make(map[string][]string, len(services))
+	for name, svc := range services {
+		names, err := compose.DependsOnServices(svc.DependsOn)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		for _, dep := range names {
+			if _, ok := services[dep]; !ok {
+				return nil, fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+		}
+		deps[name] = names
+	}
+
+	if cycle := findDependencyCycle(deps); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	// A service's level is one more than the deepest of its dependencies
+	levelOf := make(map[string]int, len(deps))
+	var depth func(name string) int
+	depth = func(name string) int {
+		if level, ok := levelOf[name]; ok {
+			return level
+		}
+		level := 0
+		for _, dep := range deps[name] {
+			if l := depth(dep) + 1; l > level {
+				level = l
+			}
+		}
+		levelOf[name] = level
+		return level
+	}
+
+	var levels [][]string
+	for name := range deps {
+		level := depth(name)
+		for len(levels) <= level {
+			levels = append(levels, nil)
+		}
+		levels[level] = append(levels[level], name)
+	}
+	for _, level := range levels {
+		sort.Strings(level)
+	}
+
+	return levels, nil
+}
+
+// findDependencyCycle returns the first cycle found as a path that starts and
+// ends with the same service, or nil if the graph is acyclic
+func findDependencyCycle(deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	names := sortedKeys(deps)
+
+	state := make(map[string]int, len(deps))
+	var stack []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range deps[name] {
+			switch state[dep] {
+			case visiting:
+				// Cut the stack at the first occurrence of dep
+				for i, n := range stack {
+					if n == dep {
+						cycle := append([]string{}, stack[i:]...)
+						return append(cycle, dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in alphabetical order, so map-driven steps
+// run (and log) in the same order on every deploy
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
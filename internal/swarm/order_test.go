@@ -0,0 +1,161 @@
+package swarm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestDeploymentLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		services map[string]*compose.Service
+		expected [][]string
+	}{
+		{
+			name: "no dependencies",
+			services: map[string]*compose.Service{
+				"web": {}, "api": {},
+			},
+			expected: [][]string{{"api", "web"}},
+		},
+		{
+			name: "list form",
+			services: map[string]*compose.Service{
+				"web":   {DependsOn: []interface{}{"api"}},
+				"api":   {DependsOn: []interface{}{"db", "cache"}},
+				"db":    {},
+				"cache": {},
+			},
+			expected: [][]string{{"cache", "db"}, {"api"}, {"web"}},
+		},
+		{
+			name: "map form with conditions",
+			services: map[string]*compose.Service{
+				"web": {DependsOn: map[string]interface{}{
+					"db":     map[string]interface{}{"condition": "service_healthy"},
+					"worker": map[string]interface{}{"condition": "service_started"},
+				}},
+				"worker": {DependsOn: map[string]interface{}{
+					"db": map[string]interface{}{"condition": "service_healthy"},
+				}},
+				"db": {},
+			},
+			expected: [][]string{{"db"}, {"worker"}, {"web"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, err := deploymentLevels(tt.services)
+			if err != nil {
+				t.Fatalf("deploymentLevels() error = %v", err)
+			}
+			if !reflect.DeepEqual(levels, tt.expected) {
+				t.Errorf("deploymentLevels() = %v, want %v", levels, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeploymentLevels_Cycle(t *testing.T) {
+	services := map[string]*compose.Service{
+		"a": {DependsOn: []interface{}{"b"}},
+		"b": {DependsOn: []interface{}{"c"}},
+		"c": {DependsOn: []interface{}{"a"}},
+		"d": {},
+	}
+
+	_, err := deploymentLevels(services)
+	if err == nil {
+		t.Fatal("Expected cycle error, got nil")
+	}
+	if !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("Expected cycle path in error, got: %v", err)
+	}
+}
+
+func TestDeploymentLevels_UndefinedDependency(t *testing.T) {
+	services := map[string]*compose.Service{
+		"web": {DependsOn: []interface{}{"db"}},
+	}
+
+	_, err := deploymentLevels(services)
+	if err == nil || !strings.Contains(err.Error(), "undefined service db") {
+		t.Errorf("Expected undefined dependency error, got: %v", err)
+	}
+}
+
+func TestDeployServices_DependencyOrder(t *testing.T) {
+	cli := &concurrencyRecordingClient{}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+	deployer.Parallel = 4
+
+	services := map[string]*compose.Service{
+		"web": {Image: "web:1.0", DependsOn: []interface{}{"api"}},
+		"api": {Image: "api:1.0", DependsOn: map[string]interface{}{"db": nil}},
+		"db":  {Image: "postgres:16"},
+	}
+
+	if _, err := deployer.deployServices(context.Background(), services, "deploy-1"); err != nil {
+		t.Fatalf("deployServices failed: %v", err)
+	}
+
+	expected := []string{"mystack_db", "mystack_api", "mystack_web"}
+	if !reflect.DeepEqual(cli.created, expected) {
+		t.Errorf("Expected creation order %v, got %v", expected, cli.created)
+	}
+	if cli.maxFlight != 1 {
+		t.Errorf("Expected dependent services not to deploy concurrently, max in flight %d", cli.maxFlight)
+	}
+}
+
+func TestDeploy_StableOrderAcrossRuns(t *testing.T) {
+	newComposeFile := func() *compose.ComposeFile {
+		return &compose.ComposeFile{
+			Services: map[string]*compose.Service{
+				"web":    {Image: "web:1.0"},
+				"api":    {Image: "api:1.0"},
+				"worker": {Image: "worker:1.0"},
+				"cache":  {Image: "redis:7"},
+				"db":     {Image: "postgres:16"},
+			},
+			Networks: map[string]*compose.Network{
+				"front": {},
+				"back":  {},
+			},
+		}
+	}
+
+	deploy := func() (services, images, networks []string) {
+		cli := &MockDockerClient{}
+		if _, err := NewStackDeployer(cli, "mystack", 3).Deploy(context.Background(), newComposeFile(), "deploy-1"); err != nil {
+			t.Fatalf("Deploy failed: %v", err)
+		}
+		for _, svc := range cli.createdServices {
+			services = append(services, svc.Spec.Name)
+		}
+		return services, cli.pulledImages, cli.createdNetworks
+	}
+
+	firstServices, firstImages, firstNetworks := deploy()
+	for run := 0; run < 5; run++ {
+		services, images, networks := deploy()
+		if !reflect.DeepEqual(services, firstServices) || !reflect.DeepEqual(images, firstImages) || !reflect.DeepEqual(networks, firstNetworks) {
+			t.Fatalf("Deploy order changed between runs:\nfirst %v %v %v\nrun   %v %v %v",
+				firstServices, firstImages, firstNetworks, services, images, networks)
+		}
+	}
+
+	expected := []string{"mystack_api", "mystack_cache", "mystack_db", "mystack_web", "mystack_worker"}
+	if !reflect.DeepEqual(firstServices, expected) {
+		t.Errorf("Expected alphabetical deploy order %v, got %v", expected, firstServices)
+	}
+	// The services attach to the implicit default network, created after the declared ones
+	if !reflect.DeepEqual(firstNetworks, []string{"mystack_back", "mystack_front", "mystack_default"}) {
+		t.Errorf("Expected networks created alphabetically, then the default one, got %v", firstNetworks)
+	}
+}
@@ -0,0 +1,213 @@
+package swarm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// dockerHubAuthKey is the key Docker uses for Docker Hub in config.json
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// dockerConfigFile is the subset of ~/.docker/config.json used for registry auth
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// getRegistryAuth returns the base64 X-Registry-Auth value for the registry of
+// imageName, or "" when no credentials are configured (e.g. public images)
+func getRegistryAuth(imageName string) string {
+	registryHost := extractRegistry(imageName)
+
+	configPath := dockerConfigPath()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: could not read Docker config from %s: %v", configPath, err)
+		}
+		return ""
+	}
+
+	var config dockerConfigFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Warning: could not parse Docker config: %v", err)
+		return ""
+	}
+
+	authConfig, found, err := lookupRegistryAuth(&config, registryHost)
+	if err != nil {
+		log.Printf("Warning: could not load credentials for %s: %v", registryDisplayName(registryHost), err)
+		return ""
+	}
+	if !found {
+		return ""
+	}
+
+	encoded, err := registry.EncodeAuthConfig(authConfig)
+	if err != nil {
+		log.Printf("Warning: could not encode auth config: %v", err)
+		return ""
+	}
+	return encoded
+}
+
+// dockerConfigPath returns the config.json location: DOCKER_CONFIG_PATH, then
+// DOCKER_CONFIG (as the docker CLI uses it), then ~/.docker
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG_PATH"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".docker", "config.json")
+}
+
+// lookupRegistryAuth finds credentials for a registry host ("" = Docker Hub).
+// Per-registry credHelpers win over the global credsStore, which wins over auths.
+func lookupRegistryAuth(config *dockerConfigFile, registryHost string) (registry.AuthConfig, bool, error) {
+	serverAddress := registryHost
+	if registryHost == "" {
+		serverAddress = dockerHubAuthKey
+	}
+
+	helper := config.CredHelpers[registryHost]
+	if registryHost == "" {
+		helper = config.CredHelpers[dockerHubAuthKey]
+	}
+	if helper == "" {
+		helper = config.CredsStore
+	}
+	if helper != "" {
+		authConfig, found, err := credentialHelperAuth(helper, serverAddress)
+		if err != nil {
+			// A missing or broken helper shouldn't hide credentials stored in auths
+			log.Printf("Warning: %v", err)
+		} else if found {
+			return authConfig, true, nil
+		}
+	}
+
+	for key, entry := range config.Auths {
+		if normalizeRegistryKey(key) != normalizeRegistryKey(serverAddress) {
+			continue
+		}
+
+		authConfig := registry.AuthConfig{
+			ServerAddress: serverAddress,
+			IdentityToken: entry.IdentityToken,
+		}
+		if entry.Auth != "" {
+			// auth is base64 "username:password"
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return registry.AuthConfig{}, false, fmt.Errorf("invalid auth entry: %w", err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return registry.AuthConfig{}, false, fmt.Errorf("invalid auth entry: expected username:password")
+			}
+			authConfig.Username = username
+			authConfig.Password = password
+		}
+		if authConfig.Username == "" && authConfig.IdentityToken == "" {
+			continue
+		}
+		return authConfig, true, nil
+	}
+
+	return registry.AuthConfig{}, false, nil
+}
+
+// credentialHelperAuth runs docker-credential-<helper> get for the server address
+func credentialHelperAuth(helper, serverAddress string) (registry.AuthConfig, bool, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		// Helpers report unknown servers this way; it just means no credentials
+		if strings.Contains(output, "credentials not found") {
+			return registry.AuthConfig{}, false, nil
+		}
+		return registry.AuthConfig{}, false, fmt.Errorf("credential helper %s failed: %w: %s", helper, err, output)
+	}
+
+	var creds struct {
+		ServerURL string `json:"ServerURL"`
+		Username  string `json:"Username"`
+		Secret    string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return registry.AuthConfig{}, false, fmt.Errorf("credential helper %s returned invalid output: %w", helper, err)
+	}
+
+	authConfig := registry.AuthConfig{ServerAddress: serverAddress}
+	// "<token>" marks an identity token instead of a password
+	if creds.Username == "<token>" {
+		authConfig.IdentityToken = creds.Secret
+	} else {
+		authConfig.Username = creds.Username
+		authConfig.Password = creds.Secret
+	}
+	return authConfig, true, nil
+}
+
+// normalizeRegistryKey strips scheme and path so "https://host/v1/" matches "host"
+func normalizeRegistryKey(key string) string {
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	host, _, _ := strings.Cut(key, "/")
+	if host == "docker.io" || host == "registry-1.docker.io" {
+		return "index.docker.io"
+	}
+	return host
+}
+
+// registryDisplayName names a registry host for log messages
+func registryDisplayName(registryHost string) string {
+	if registryHost == "" {
+		return "Docker Hub"
+	}
+	return registryHost
+}
+
+func extractRegistry(imageName string) string {
+	// Examples:
+	// gitlab.opscore.org:5001/core/devops/domain-router:v0.1.0-alfa1 -> gitlab.opscore.org:5001
+	// docker.io/library/nginx:latest -> "" (Docker Hub)
+	// localhost/app:dev -> localhost
+	// nginx:latest -> "" (Docker Hub, default)
+
+	parts := strings.Split(imageName, "/")
+	if len(parts) < 2 {
+		return "" // No registry specified, use default
+	}
+
+	firstPart := parts[0]
+	if normalizeRegistryKey(firstPart) == "index.docker.io" {
+		return ""
+	}
+	// Check if first part looks like a registry (contains . or :, or is localhost)
+	if strings.Contains(firstPart, ".") || strings.Contains(firstPart, ":") || firstPart == "localhost" {
+		return firstPart
+	}
+
+	return ""
+}
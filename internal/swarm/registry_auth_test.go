@@ -0,0 +1,122 @@
+package swarm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// decodeRegistryAuth decodes an X-Registry-Auth value
+func decodeRegistryAuth(t *testing.T, encoded string) registry.AuthConfig {
+	t.Helper()
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Invalid base64 auth %q: %v", encoded, err)
+	}
+	var authConfig registry.AuthConfig
+	if err := json.Unmarshal(data, &authConfig); err != nil {
+		t.Fatalf("Invalid auth JSON: %v", err)
+	}
+	return authConfig
+}
+
+// installCredentialHelperStub puts a docker-credential-stackman-stub script on PATH
+func installCredentialHelperStub(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper stub is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+read server
+if [ "$server" = "helper.example.com" ]; then
+  echo '{"ServerURL":"helper.example.com","Username":"robot","Secret":"from-helper"}'
+  exit 0
+fi
+echo "credentials not found in native keychain"
+exit 1
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker-credential-stackman-stub"), []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write helper stub: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGetRegistryAuth(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG_PATH", "")
+	t.Setenv("DOCKER_CONFIG", "testdata/dockerconfig")
+	installCredentialHelperStub(t)
+
+	tests := []struct {
+		image    string
+		username string
+		password string
+		server   string
+	}{
+		{"registry.example.com/team/app:1.0", "deploy", "s3cret", "registry.example.com"},
+		{"registry.example.com:443/team/app:1.0", "", "", ""},
+		{"helper.example.com/app:2.0", "robot", "from-helper", "helper.example.com"},
+		{"nginx:1.25", "hubuser", "hubpass", dockerHubAuthKey},
+		{"docker.io/library/nginx:1.25", "hubuser", "hubpass", dockerHubAuthKey},
+		{"unknown.example.com/app:1.0", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			encoded := getRegistryAuth(tt.image)
+			if tt.username == "" {
+				if encoded != "" {
+					t.Errorf("Expected no credentials, got %+v", decodeRegistryAuth(t, encoded))
+				}
+				return
+			}
+
+			authConfig := decodeRegistryAuth(t, encoded)
+			if authConfig.Username != tt.username || authConfig.Password != tt.password {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.username, tt.password, authConfig.Username, authConfig.Password)
+			}
+			if authConfig.ServerAddress != tt.server {
+				t.Errorf("Expected server %s, got %s", tt.server, authConfig.ServerAddress)
+			}
+		})
+	}
+}
+
+func TestGetRegistryAuth_CredsStoreFallsBackToAuths(t *testing.T) {
+	dir := t.TempDir()
+	config := `{
+  "auths": {"registry.example.com": {"auth": "ZGVwbG95OnMzY3JldA=="}},
+  "credsStore": "stackman-stub"
+}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG_PATH", dir)
+	installCredentialHelperStub(t)
+
+	// The store knows nothing about this registry, so the auths entry is used
+	authConfig := decodeRegistryAuth(t, getRegistryAuth("registry.example.com/app:1.0"))
+	if authConfig.Username != "deploy" {
+		t.Errorf("Expected auths fallback, got %+v", authConfig)
+	}
+
+	// The store answers for its own registry
+	authConfig = decodeRegistryAuth(t, getRegistryAuth("helper.example.com/app:1.0"))
+	if authConfig.Username != "robot" || authConfig.Password != "from-helper" {
+		t.Errorf("Expected helper credentials, got %+v", authConfig)
+	}
+}
+
+func TestGetRegistryAuth_NoConfig(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG_PATH", t.TempDir())
+
+	if auth := getRegistryAuth("registry.example.com/app:1.0"); auth != "" {
+		t.Errorf("Expected empty auth without config.json, got %q", auth)
+	}
+}
@@ -0,0 +1,146 @@
+package swarm
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// RenderedStack is the swarm view of a compose file: the specs apply would send
+// to the daemon, keyed by their swarm names
+type RenderedStack struct {
+	Services map[string]swarm.ServiceSpec     `json:"services"`
+	Networks map[string]network.CreateOptions `json:"networks,omitempty"`
+	Secrets  map[string]swarm.SecretSpec      `json:"secrets,omitempty"`
+	Configs  map[string]swarm.ConfigSpec      `json:"configs,omitempty"`
+}
+
+// RenderStack converts a compose file to swarm specs the way Deploy does, without
+// contacting the daemon. External networks, secrets and configs are referenced by
+// name only, so references carry no IDs; the deployment ID label is left out.
+// Secret data is never included.
+func RenderStack(stackName string, composeFile *compose.ComposeFile) (*RenderedStack, error) {
+	rendered := &RenderedStack{
+		Services: make(map[string]swarm.ServiceSpec, len(composeFile.Services)),
+		Networks: make(map[string]network.CreateOptions),
+		Secrets:  make(map[string]swarm.SecretSpec),
+		Configs:  make(map[string]swarm.ConfigSpec),
+	}
+
+	// Stack-scoped names of networks, secrets and configs, mapped to their swarm names
+	networks := make(map[string]string)
+	for _, name := range sortedKeys(composeFile.Networks) {
+		netConfig := composeFile.Networks[name]
+		fullName := fmt.Sprintf("%s_%s", stackName, name)
+		if netConfig != nil && compose.IsExternal(netConfig.External) {
+			networks[fullName] = externalNetworkName(name, netConfig)
+			continue
+		}
+		networks[fullName] = fullName
+		rendered.Networks[fullName] = networkCreateOptions(stackName, netConfig)
+	}
+	if _, declared := composeFile.Networks["default"]; !declared && compose.UsesDefaultNetwork(composeFile.Services) {
+		rendered.Networks[stackName+"_default"] = networkCreateOptions(stackName, nil)
+	}
+
+	secrets := make(map[string]string)
+	for _, name := range sortedKeys(composeFile.Secrets) {
+		secret := composeFile.Secrets[name]
+		if secret == nil {
+			return nil, fmt.Errorf("secret %s has no definition", name)
+		}
+		key := fmt.Sprintf("%s_%s", stackName, name)
+		if compose.IsExternal(secret.External) {
+			secrets[key] = secretName(name, secret)
+			continue
+		}
+		data, err := readResourceFile("secret", name, secret.File, composeFile.Dir)
+		if err != nil {
+			return nil, err
+		}
+		annotations := stackResourceAnnotations(stackName, name, secret.Name, compose.ContentHash(data), secret.Labels)
+		secrets[key] = annotations.Name
+		rendered.Secrets[annotations.Name] = swarm.SecretSpec{Annotations: annotations}
+	}
+
+	configs := make(map[string]string)
+	for _, name := range sortedKeys(composeFile.Configs) {
+		cfg := composeFile.Configs[name]
+		if cfg == nil {
+			return nil, fmt.Errorf("config %s has no definition", name)
+		}
+		key := fmt.Sprintf("%s_%s", stackName, name)
+		if compose.IsExternal(cfg.External) {
+			configs[key] = configName(name, cfg)
+			continue
+		}
+		data, err := readResourceFile("config", name, cfg.File, composeFile.Dir)
+		if err != nil {
+			return nil, err
+		}
+		annotations := stackResourceAnnotations(stackName, name, cfg.Name, compose.ContentHash(data), cfg.Labels)
+		configs[key] = annotations.Name
+		rendered.Configs[annotations.Name] = swarm.ConfigSpec{Annotations: annotations, Data: data}
+	}
+
+	for _, name := range sortedKeys(composeFile.Services) {
+		service := composeFile.Services[name]
+		if service == nil {
+			continue
+		}
+		spec, err := compose.ConvertToSwarmSpec(name, service, stackName, composeFile.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		for _, ref := range spec.TaskTemplate.ContainerSpec.Secrets {
+			actual, ok := secrets[ref.SecretName]
+			if !ok {
+				return nil, fmt.Errorf("service %s: secret %s is not defined in the compose file", name, ref.SecretName)
+			}
+			ref.SecretName = actual
+		}
+		for _, ref := range spec.TaskTemplate.ContainerSpec.Configs {
+			actual, ok := configs[ref.ConfigName]
+			if !ok {
+				return nil, fmt.Errorf("service %s: config %s is not defined in the compose file", name, ref.ConfigName)
+			}
+			ref.ConfigName = actual
+		}
+
+		for i := range spec.TaskTemplate.Networks {
+			if actual, ok := networks[spec.TaskTemplate.Networks[i].Target]; ok {
+				spec.TaskTemplate.Networks[i].Target = actual
+			}
+		}
+		if service.Networks == nil {
+			spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: stackName + "_default"}}
+		}
+
+		rendered.Services[spec.Name] = *spec
+	}
+
+	return rendered, nil
+}
+
+// stackResourceAnnotations names and labels a file-based secret or config.
+// An explicit name pins the resource; otherwise the name follows the content hash.
+func stackResourceAnnotations(stackName, name, explicitName, hash string, extraLabels map[string]string) swarm.Annotations {
+	fullName := explicitName
+	if fullName == "" {
+		fullName = compose.VersionedName(stackName, name, hash)
+	}
+
+	labels := map[string]string{
+		"com.docker.stack.namespace": stackName,
+		compose.ResourceNameLabel:    name,
+		compose.ContentHashLabel:     hash,
+	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	return swarm.Annotations{Name: fullName, Labels: labels}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/docker/docker/client"
 )
 
-// ServiceSnapshot stores the state of a service before deployment
+// ServiceSnapshot stores the state of a service before deployment.
+// Service carries the complete spec and version; rollback re-applies the spec,
+// rolled out start-first and paused on failure.
 type ServiceSnapshot struct {
 	Service swarm.Service
 	Tasks   []swarm.Task
@@ -76,6 +78,11 @@ func (d *StackDeployer) CreateSnapshot(ctx context.Context) (*StackSnapshot, err
 			if err == nil {
 				break
 			}
+			// Another attempt against a manager without a leader won't succeed
+			if IsManagerUnavailable(err) {
+				err = managerError(err)
+				break
+			}
 			if retry < maxRetries-1 {
 				waitTime := time.Duration(retry+1) * time.Second
 				log.Printf("failed to list tasks for service %s (attempt %d/%d): %v, retrying in %v",
@@ -143,26 +150,34 @@ func (d *StackDeployer) Rollback(ctx context.Context, snapshot *StackSnapshot) e
 	for serviceID, snap := range snapshot.Services {
 		serviceName := snap.Service.Spec.Name
 
-		// Check if service still exists
+		// Restore the complete service spec from snapshot (image, env, replicas,
+		// resources, ...) so nothing stays half-reverted
+		rollbackSpec := snap.Service.Spec
+
+		// Service was removed during the failed deploy - recreate it
 		current, exists := currentByID[serviceID]
 		if !exists {
-			log.Printf("Service %s no longer exists, skipping rollback", serviceName)
+			log.Printf("Service %s no longer exists, recreating it from snapshot", serviceName)
+			if _, err := d.cli.ServiceCreate(ctx, rollbackSpec, types.ServiceCreateOptions{}); err != nil {
+				return fmt.Errorf("rollback failed to recreate service %s: %w", serviceName, err)
+			}
+			updatedServices = append(updatedServices, serviceName)
 			continue
 		}
 
 		log.Printf("Rolling back service: %s to version %d", serviceName, snap.Service.Version.Index)
 
-		// Restore service spec from snapshot
-		rollbackSpec := snap.Service.Spec
-
-		// Ensure update config for start-first behavior (seamless rollback)
-		if rollbackSpec.UpdateConfig == nil {
-			rollbackSpec.UpdateConfig = &swarm.UpdateConfig{}
+		// Ensure update config for start-first behavior (seamless rollback).
+		// Work on a copy so the snapshot itself keeps the original settings.
+		updateConfig := swarm.UpdateConfig{}
+		if rollbackSpec.UpdateConfig != nil {
+			updateConfig = *rollbackSpec.UpdateConfig
 		}
 		// Set start-first order: new container starts before old one stops
-		rollbackSpec.UpdateConfig.Order = swarm.UpdateOrderStartFirst
+		updateConfig.Order = swarm.UpdateOrderStartFirst
 		// Set failure action to pause (safer for rollback)
-		rollbackSpec.UpdateConfig.FailureAction = swarm.UpdateFailureActionPause
+		updateConfig.FailureAction = swarm.UpdateFailureActionPause
+		rollbackSpec.UpdateConfig = &updateConfig
 
 		// If update is paused, log it
 		if current.UpdateStatus != nil && current.UpdateStatus.State == swarm.UpdateStatePaused {
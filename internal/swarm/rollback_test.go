@@ -2,6 +2,7 @@ package swarm
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -274,3 +275,107 @@ func TestRollback_RemoveNewAndRestoreOld(t *testing.T) {
 		t.Errorf("Expected existing_service updated, got %s", mockCli.updatedServices[0])
 	}
 }
+
+func TestRollback_RestoresFullSpec(t *testing.T) {
+	oldReplicas := uint64(3)
+	newReplicas := uint64(5)
+
+	oldSpec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name:   "test_web",
+			Labels: map[string]string{"com.docker.stack.namespace": "test"},
+		},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "nginx:1.24",
+				Env:   []string{"MODE=stable"},
+			},
+			Resources: &swarm.ResourceRequirements{
+				Limits: &swarm.Limit{MemoryBytes: 256 * 1024 * 1024},
+			},
+		},
+		Mode:         swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &oldReplicas}},
+		UpdateConfig: &swarm.UpdateConfig{Order: swarm.UpdateOrderStopFirst, FailureAction: swarm.UpdateFailureActionRollback},
+	}
+
+	newSpec := oldSpec
+	newSpec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{Image: "nginx:1.25", Env: []string{"MODE=canary"}}
+	newSpec.TaskTemplate.Resources = &swarm.ResourceRequirements{Limits: &swarm.Limit{MemoryBytes: 512 * 1024 * 1024}}
+	newSpec.Mode = swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &newReplicas}}
+
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "service1", Meta: swarm.Meta{Version: swarm.Version{Index: 12}}, Spec: newSpec},
+			{ID: "service_new", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "test_cache"}}},
+		},
+	}
+
+	snapshot := &StackSnapshot{
+		StackName: "test",
+		Services: map[string]ServiceSnapshot{
+			"service1": {Service: swarm.Service{ID: "service1", Meta: swarm.Meta{Version: swarm.Version{Index: 11}}, Spec: oldSpec}},
+		},
+		ExistingIDs: map[string]bool{"service1": true},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	if err := deployer.Rollback(context.Background(), snapshot); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	restored, ok := mockCli.updatedSpecs["service1"]
+	if !ok {
+		t.Fatal("Expected service1 to be updated")
+	}
+	// The rollback itself rolls out start-first and pauses on failure
+	wantSpec := oldSpec
+	wantSpec.UpdateConfig = &swarm.UpdateConfig{Order: swarm.UpdateOrderStartFirst, FailureAction: swarm.UpdateFailureActionPause}
+	if !reflect.DeepEqual(restored, wantSpec) {
+		t.Errorf("Restored spec differs from snapshot:\n got: %+v\nwant: %+v", restored, wantSpec)
+	}
+	if oldSpec.UpdateConfig.Order != swarm.UpdateOrderStopFirst || oldSpec.UpdateConfig.FailureAction != swarm.UpdateFailureActionRollback {
+		t.Errorf("Rollback modified the snapshot's update config: %+v", oldSpec.UpdateConfig)
+	}
+
+	if len(mockCli.removedServices) != 1 || mockCli.removedServices[0] != "service_new" {
+		t.Errorf("Expected service_new to be removed, got %v", mockCli.removedServices)
+	}
+}
+
+func TestRollback_RecreatesRemovedService(t *testing.T) {
+	oldSpec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name:   "test_worker",
+			Labels: map[string]string{"com.docker.stack.namespace": "test"},
+		},
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "worker:1.0"}},
+	}
+
+	// The service was removed as obsolete during the failed deploy
+	mockCli := &MockDockerClient{
+		services: []swarm.Service{
+			{ID: "service_web", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "test_web"}}},
+		},
+	}
+
+	snapshot := &StackSnapshot{
+		StackName: "test",
+		Services: map[string]ServiceSnapshot{
+			"service_worker": {Service: swarm.Service{ID: "service_worker", Spec: oldSpec}},
+			"service_web":    {Service: swarm.Service{ID: "service_web", Spec: mockCli.services[0].Spec}},
+		},
+		ExistingIDs: map[string]bool{"service_worker": true, "service_web": true},
+	}
+
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	if err := deployer.Rollback(context.Background(), snapshot); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if len(mockCli.createdServices) != 1 {
+		t.Fatalf("Expected removed service to be recreated, got %d creates", len(mockCli.createdServices))
+	}
+	if !reflect.DeepEqual(mockCli.createdServices[0].Spec, oldSpec) {
+		t.Errorf("Recreated spec differs from snapshot: %+v", mockCli.createdServices[0].Spec)
+	}
+}
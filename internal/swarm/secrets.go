@@ -0,0 +1,170 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// swarmObject identifies a secret or config that exists in the swarm
+type swarmObject struct {
+	ID   string
+	Name string
+}
+
+// deploySecrets makes sure every secret declared in the compose file exists in the swarm.
+// File-based secrets are created as <stack>_<name>_<hash8>, so a content change yields a
+// new secret that services are switched to; external secrets must already exist.
+// Resolved IDs are recorded so service specs can reference them.
+func (d *StackDeployer) deploySecrets(ctx context.Context, secrets map[string]*compose.Secret) error {
+	d.secrets = make(map[string]swarmObject, len(secrets))
+
+	for _, name := range sortedKeys(secrets) {
+		secret := secrets[name]
+		if secret == nil {
+			return fmt.Errorf("secret %s has no definition", name)
+		}
+		key := fmt.Sprintf("%s_%s", d.stackName, name)
+
+		if compose.IsExternal(secret.External) {
+			externalName := secretName(name, secret)
+			existing, err := d.findSecret(ctx, externalName)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				return fmt.Errorf("external secret %s not found", externalName)
+			}
+			log.Printf("Using external secret: %s", externalName)
+			d.secrets[key] = swarmObject{ID: existing.ID, Name: externalName}
+			continue
+		}
+
+		data, err := readResourceFile("secret", name, secret.File, d.composeDir)
+		if err != nil {
+			return err
+		}
+		hash := compose.ContentHash(data)
+
+		annotations := stackResourceAnnotations(d.stackName, name, secret.Name, hash, secret.Labels)
+		fullName := annotations.Name
+
+		existing, err := d.findSecret(ctx, fullName)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			log.Printf("Secret %s already exists", fullName)
+			d.secrets[key] = swarmObject{ID: existing.ID, Name: fullName}
+			continue
+		}
+
+		response, err := d.cli.SecretCreate(ctx, swarm.SecretSpec{
+			Annotations: annotations,
+			Data:        data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create secret %s: %w", fullName, err)
+		}
+
+		log.Printf("Created secret: %s", fullName)
+		d.secrets[key] = swarmObject{ID: response.ID, Name: fullName}
+	}
+
+	return nil
+}
+
+// findSecret returns the secret with exactly the given name, or nil if it does not exist
+func (d *StackDeployer) findSecret(ctx context.Context, name string) (*swarm.Secret, error) {
+	secrets, err := d.cli.SecretList(ctx, swarm.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	// The name filter matches prefixes, so look for an exact match
+	for i := range secrets {
+		if secrets[i].Spec.Name == name {
+			return &secrets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// secretName returns the swarm name of an external secret
+func secretName(name string, secret *compose.Secret) string {
+	if extName := compose.ExternalName(secret.External); extName != "" {
+		return extName
+	}
+	if secret.Name != "" {
+		return secret.Name
+	}
+	return name
+}
+
+// readResourceFile reads the content of a file-based secret or config, resolving
+// a relative file against the compose file directory dir
+func readResourceFile(kind, name, file, dir string) ([]byte, error) {
+	if file == "" {
+		return nil, fmt.Errorf("%s %s: file is required unless it is external", kind, name)
+	}
+
+	data, err := compose.ReadResourceFile(file, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", kind, name, err)
+	}
+	return data, nil
+}
+
+// resolveSecretReferences fills in secret IDs and swarm names on converted secret references
+func (d *StackDeployer) resolveSecretReferences(refs []*swarm.SecretReference) error {
+	for _, ref := range refs {
+		obj, ok := d.secrets[ref.SecretName]
+		if !ok {
+			return fmt.Errorf("secret %s is not defined in the compose file", ref.SecretName)
+		}
+		ref.SecretID = obj.ID
+		ref.SecretName = obj.Name
+	}
+	return nil
+}
+
+// removeRotatedSecrets removes previous versions of the secrets deployed in this run
+func (d *StackDeployer) removeRotatedSecrets(ctx context.Context) {
+	secrets, err := d.cli.SecretList(ctx, swarm.SecretListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("com.docker.stack.namespace=%s", d.stackName)),
+		),
+	})
+	if err != nil {
+		log.Printf("WARNING: failed to list secrets for cleanup: %v", err)
+		return
+	}
+
+	for _, secret := range secrets {
+		name, ok := secret.Spec.Labels[compose.ResourceNameLabel]
+		if !ok {
+			continue
+		}
+		current, deployed := d.secrets[fmt.Sprintf("%s_%s", d.stackName, name)]
+		if !deployed || current.ID == secret.ID {
+			continue
+		}
+		if d.RetainedResources[secret.ID] {
+			log.Printf("Keeping old secret version %s: a saved snapshot still uses it", secret.Spec.Name)
+			continue
+		}
+
+		if err := d.cli.SecretRemove(ctx, secret.ID); err != nil {
+			log.Printf("WARNING: failed to remove old secret version %s: %v", secret.Spec.Name, err)
+			continue
+		}
+		log.Printf("Removed old secret version: %s", secret.Spec.Name)
+	}
+}
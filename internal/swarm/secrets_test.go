@@ -0,0 +1,295 @@
+package swarm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestDeploySecrets_CreatesFromFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "db_password.txt"), []byte("s3cret"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	secrets := map[string]*compose.Secret{
+		"db_password": {
+			File:   "./db_password.txt",
+			Labels: map[string]string{"tier": "db"},
+		},
+	}
+
+	if err := deployer.deploySecrets(context.Background(), secrets); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+
+	if len(mockCli.createdSecrets) != 1 {
+		t.Fatalf("Expected 1 secret created, got %d", len(mockCli.createdSecrets))
+	}
+
+	created := mockCli.createdSecrets[0]
+	hash := compose.ContentHash([]byte("s3cret"))
+	if created.Name != "test_db_password_"+hash[:8] {
+		t.Errorf("Expected secret name test_db_password_%s, got %s", hash[:8], created.Name)
+	}
+	if created.Labels[compose.ContentHashLabel] != hash {
+		t.Errorf("Expected content hash label %s, got %v", hash, created.Labels)
+	}
+	if string(created.Data) != "s3cret" {
+		t.Errorf("Expected secret data from file, got %q", string(created.Data))
+	}
+	if created.Labels["com.docker.stack.namespace"] != "test" {
+		t.Errorf("Expected stack namespace label, got %v", created.Labels)
+	}
+	if created.Labels["tier"] != "db" {
+		t.Errorf("Expected custom label to be kept, got %v", created.Labels)
+	}
+
+	obj, ok := deployer.secrets["test_db_password"]
+	if !ok || obj.ID != "secret_1" {
+		t.Errorf("Expected resolved secret ID secret_1, got %+v", obj)
+	}
+}
+
+func TestDeploySecrets_RelativeToComposeDir(t *testing.T) {
+	composeDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", "")
+	t.Chdir(t.TempDir())
+
+	if err := os.WriteFile(filepath.Join(composeDir, "db_password.txt"), []byte("s3cret"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.composeDir = composeDir
+
+	secrets := map[string]*compose.Secret{"db_password": {File: "./db_password.txt"}}
+	if err := deployer.deploySecrets(context.Background(), secrets); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+	if len(mockCli.createdSecrets) != 1 || string(mockCli.createdSecrets[0].Data) != "s3cret" {
+		t.Errorf("Expected the secret to be read next to the compose file, got %+v", mockCli.createdSecrets)
+	}
+}
+
+func TestDeploySecrets_ReusesExisting(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "api_key.txt"), []byte("key"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+	existingName := compose.VersionedName("test", "api_key", compose.ContentHash([]byte("key")))
+
+	mockCli := &MockDockerClient{
+		secrets: []swarm.Secret{
+			{ID: "existing", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: existingName}}},
+			{ID: "external", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "shared_cert"}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	secrets := map[string]*compose.Secret{
+		"api_key": {File: "./api_key.txt"},
+		"cert":    {External: map[string]interface{}{"name": "shared_cert"}},
+	}
+
+	if err := deployer.deploySecrets(context.Background(), secrets); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+
+	if len(mockCli.createdSecrets) != 0 {
+		t.Errorf("Expected no secrets created, got %d", len(mockCli.createdSecrets))
+	}
+	if deployer.secrets["test_api_key"].ID != "existing" {
+		t.Errorf("Expected existing secret to be reused, got %+v", deployer.secrets["test_api_key"])
+	}
+	if obj := deployer.secrets["test_cert"]; obj.ID != "external" || obj.Name != "shared_cert" {
+		t.Errorf("Expected external secret shared_cert, got %+v", obj)
+	}
+}
+
+func TestDeploySecrets_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	tests := []struct {
+		name    string
+		secrets map[string]*compose.Secret
+	}{
+		{"missing file", map[string]*compose.Secret{"s": {File: "./missing.txt"}}},
+		{"no file", map[string]*compose.Secret{"s": {}}},
+		{"external not found", map[string]*compose.Secret{"s": {External: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployer := NewStackDeployer(&MockDockerClient{}, "test", 3)
+			if err := deployer.deploySecrets(context.Background(), tt.secrets); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestResolveSecretReferences(t *testing.T) {
+	deployer := NewStackDeployer(&MockDockerClient{}, "test", 3)
+	deployer.secrets = map[string]swarmObject{
+		"test_db_password": {ID: "id1", Name: "test_db_password"},
+	}
+
+	refs := []*swarm.SecretReference{
+		{SecretName: "test_db_password", File: &swarm.SecretReferenceFileTarget{Name: "db_password"}},
+	}
+	if err := deployer.resolveSecretReferences(refs); err != nil {
+		t.Fatalf("resolveSecretReferences failed: %v", err)
+	}
+	if refs[0].SecretID != "id1" {
+		t.Errorf("Expected SecretID id1, got %s", refs[0].SecretID)
+	}
+
+	unknown := []*swarm.SecretReference{{SecretName: "test_unknown"}}
+	if err := deployer.resolveSecretReferences(unknown); err == nil {
+		t.Error("Expected error for undeclared secret")
+	}
+}
+
+func TestDeploySecrets_RotatesOnContentChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "db_password.txt"), []byte("new-password"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	oldHash := compose.ContentHash([]byte("old-password"))
+	mockCli := &MockDockerClient{
+		secrets: []swarm.Secret{
+			{
+				ID: "old_id",
+				Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+					Name: compose.VersionedName("test", "db_password", oldHash),
+					Labels: map[string]string{
+						"com.docker.stack.namespace": "test",
+						compose.ResourceNameLabel:    "db_password",
+						compose.ContentHashLabel:     oldHash,
+					},
+				}},
+			},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	secrets := map[string]*compose.Secret{
+		"db_password": {File: "db_password.txt"},
+	}
+	if err := deployer.deploySecrets(context.Background(), secrets); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+
+	// A new version is created and services are pointed at it
+	if len(mockCli.createdSecrets) != 1 {
+		t.Fatalf("Expected a new secret version, got %d created", len(mockCli.createdSecrets))
+	}
+	newName := compose.VersionedName("test", "db_password", compose.ContentHash([]byte("new-password")))
+	refs := []*swarm.SecretReference{{SecretName: "test_db_password"}}
+	if err := deployer.resolveSecretReferences(refs); err != nil {
+		t.Fatalf("resolveSecretReferences failed: %v", err)
+	}
+	if refs[0].SecretName != newName || refs[0].SecretID == "old_id" {
+		t.Errorf("Expected reference to new version %s, got %+v", newName, refs[0])
+	}
+
+	// The old version is removed only on explicit cleanup
+	if len(mockCli.removedSecrets) != 0 {
+		t.Fatalf("Old secret removed before cleanup: %v", mockCli.removedSecrets)
+	}
+	deployer.RemoveRotatedResources(context.Background())
+	if len(mockCli.removedSecrets) != 1 || mockCli.removedSecrets[0] != "old_id" {
+		t.Errorf("Expected old_id to be removed, got %v", mockCli.removedSecrets)
+	}
+}
+
+func TestRemoveRotatedResources_KeepsVersionsOfSavedSnapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("STACKMAN_WORKDIR", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "db_password.txt"), []byte("password-3"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	version := func(id, content string) swarm.Secret {
+		hash := compose.ContentHash([]byte(content))
+		return swarm.Secret{ID: id, Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+			Name: compose.VersionedName("test", "db_password", hash),
+			Labels: map[string]string{
+				"com.docker.stack.namespace": "test",
+				compose.ResourceNameLabel:    "db_password",
+				compose.ContentHashLabel:     hash,
+			},
+		}}}
+	}
+	// Two earlier rotations: v1 is used by an older snapshot, v2 by the previous one,
+	// v0 by none that is still retained
+	mockCli := &MockDockerClient{
+		secrets: []swarm.Secret{version("v0", "password-0"), version("v1", "password-1"), version("v2", "password-2")},
+		services: []swarm.Service{{
+			ID:   "svc1",
+			Meta: swarm.Meta{Version: swarm.Version{Index: 12}},
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "test_web"}},
+		}},
+	}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	if err := deployer.deploySecrets(context.Background(), map[string]*compose.Secret{
+		"db_password": {File: "db_password.txt"},
+	}); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+	deployer.RetainedResources = map[string]bool{"v1": true, "v2": true}
+	deployer.RemoveRotatedResources(context.Background())
+
+	if len(mockCli.removedSecrets) != 1 || mockCli.removedSecrets[0] != "v0" {
+		t.Fatalf("Expected only the unreferenced version v0 to be removed, got %v", mockCli.removedSecrets)
+	}
+
+	// Restoring the older snapshot points the service back at a version that still exists
+	older := &StackSnapshot{
+		StackName:   "test",
+		ExistingIDs: map[string]bool{"svc1": true},
+		Services: map[string]ServiceSnapshot{"svc1": {Service: swarm.Service{
+			ID: "svc1",
+			Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{Name: "test_web"},
+				TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{
+					Image:   "web:1",
+					Secrets: []*swarm.SecretReference{{SecretID: "v1", SecretName: version("v1", "password-1").Spec.Name}},
+				}},
+			},
+		}}},
+	}
+	if err := deployer.Rollback(context.Background(), older); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	restored := mockCli.updatedSpecs["svc1"].TaskTemplate.ContainerSpec.Secrets
+	if len(restored) != 1 || restored[0].SecretID != "v1" {
+		t.Fatalf("Expected the restored service to use secret v1, got %+v", restored)
+	}
+	for _, id := range mockCli.removedSecrets {
+		if id == restored[0].SecretID {
+			t.Errorf("Restored service references removed secret %s", id)
+		}
+	}
+}
@@ -2,51 +2,190 @@ package swarm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/swarm"
 
 	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/plan"
+	"github.com/SomeBlackMagic/stackman/internal/throttle"
 )
 
+// deployServices creates or updates services in depends_on order. Services of
+// the same dependency level are deployed using up to Parallel workers.
+// Services outside ServiceFilter keep their place in the order but are skipped.
 func (d *StackDeployer) deployServices(ctx context.Context, services map[string]*compose.Service, deployID string) (*DeploymentResult, error) {
+	levels, err := deploymentLevels(services)
+	if err != nil {
+		return nil, err
+	}
+	if d.ServiceFilter != nil {
+		selected := d.selectedServices(services)
+		var filtered [][]string
+		for _, names := range levels {
+			var keep []string
+			for _, name := range names {
+				if selected[name] != nil {
+					keep = append(keep, name)
+				}
+			}
+			if len(keep) > 0 {
+				filtered = append(filtered, keep)
+			}
+		}
+		levels = filtered
+	}
+
 	result := &DeploymentResult{
 		UpdatedServices: make([]ServiceUpdateResult, 0, len(services)),
 	}
 
-	for name, svc := range services {
-		updateResult, err := d.deployService(ctx, name, svc, deployID)
+	for i, names := range levels {
+		if len(levels) > 1 {
+			log.Printf("Deploying dependency level %d/%d: %v", i+1, len(levels), names)
+		}
+
+		results, err := d.deployLevel(ctx, names, services, deployID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to deploy service %s: %w", name, err)
+			return nil, err
 		}
 
-		// Only add to results if service was actually changed
-		if updateResult != nil && updateResult.Changed {
-			result.UpdatedServices = append(result.UpdatedServices, *updateResult)
+		for _, updateResult := range results {
+			// Only add to results if service was actually changed
+			if updateResult != nil && updateResult.Changed {
+				result.UpdatedServices = append(result.UpdatedServices, *updateResult)
+			}
 		}
 	}
 
 	return result, nil
 }
 
+// deployLevel deploys independent services using up to Parallel workers.
+// After the first failure no new deployments are started, but in-flight ones
+// are allowed to finish; all failures are returned together.
+func (d *StackDeployer) deployLevel(ctx context.Context, names []string, services map[string]*compose.Service, deployID string) ([]*ServiceUpdateResult, error) {
+	workers := d.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	results := make([]*ServiceUpdateResult, len(names))
+	var (
+		mu     sync.Mutex
+		errs   []error
+		failed bool
+		wg     sync.WaitGroup
+	)
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				skip := failed
+				mu.Unlock()
+				if skip {
+					continue
+				}
+
+				name := names[i]
+				updateResult, err := d.deployService(ctx, name, services[name], deployID)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to deploy service %s: %w", name, err))
+					failed = true
+				} else {
+					results[i] = updateResult
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return results, nil
+}
+
+// mutationContext returns the context for service create, update and remove calls.
+// A detached deploy exits without watching the rollout, so a cancellation must not
+// abort a call the daemon may already be applying; later calls still see ctx cancelled.
+func (d *StackDeployer) mutationContext(ctx context.Context) context.Context {
+	if d.Detach {
+		return context.WithoutCancel(ctx)
+	}
+	return ctx
+}
+
 func (d *StackDeployer) deployService(ctx context.Context, serviceName string, service *compose.Service, deployID string) (*ServiceUpdateResult, error) {
 	fullName := fmt.Sprintf("%s_%s", d.stackName, serviceName)
 
 	// Convert compose service to swarm spec
-	spec, err := compose.ConvertToSwarmSpec(serviceName, service, d.stackName)
+	spec, err := compose.ConvertToSwarmSpec(serviceName, service, d.stackName, d.composeDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert service spec: %w", err)
 	}
 
+	if pinned, ok := d.pinnedImages[serviceName]; ok {
+		spec.TaskTemplate.ContainerSpec.Image = pinned
+	}
+
+	compose.ApplyDefaultRestartCondition(spec, d.DefaultRestartCondition)
+
+	// Point secret references at the secrets deployed for this stack
+	if err := d.resolveSecretReferences(spec.TaskTemplate.ContainerSpec.Secrets); err != nil {
+		return nil, err
+	}
+	if err := d.resolveConfigReferences(spec.TaskTemplate.ContainerSpec.Configs); err != nil {
+		return nil, err
+	}
+
+	// Point network attachments at the actual (possibly external) networks
+	d.resolveNetworkAttachments(spec.TaskTemplate.Networks)
+
+	// Attach to default network if no networks specified
+	if service.Networks == nil {
+		defaultNetwork := fmt.Sprintf("%s_default", d.stackName)
+		spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{
+			{Target: defaultNetwork},
+		}
+	}
+
 	// Initialize labels map if nil
 	if spec.Labels == nil {
 		spec.Labels = make(map[string]string)
 	}
 
+	// Hash the definition before the deployment ID is added, so unchanged services can be skipped
+	specHash, err := plan.ServiceSpecHash(spec)
+	if err != nil {
+		return nil, err
+	}
+	spec.Labels[plan.SpecHashLabel] = specHash
+
 	// Add deployment ID label to service spec
 	spec.Labels["com.stackman.deploy.id"] = deployID
 
@@ -58,14 +197,6 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 	}
 	spec.TaskTemplate.ContainerSpec.Labels["com.stackman.deploy.id"] = deployID
 
-	// Attach to default network if no networks specified
-	if service.Networks == nil {
-		defaultNetwork := fmt.Sprintf("%s_default", d.stackName)
-		spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{
-			{Target: defaultNetwork},
-		}
-	}
-
 	// Check if a service exists
 	existingServices, err := d.cli.ServiceList(ctx, swarm.ServiceListOptions{
 		Filters: filters.NewArgs(
@@ -84,6 +215,17 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 	if len(existingServices) > 0 {
 		// Update existing service
 		existing := existingServices[0]
+
+		// An update always rolls the tasks, if only for the new deployment ID
+		unchanged, err := d.serviceUnchanged(ctx, &existing, spec)
+		if err != nil {
+			return nil, err
+		}
+		if unchanged {
+			log.Printf("Service %s: no changes detected, skipping update", fullName)
+			return nil, nil
+		}
+
 		log.Printf("Updating service: %s", fullName)
 
 		// NOTE: We intentionally use the NEW deployID for updates
@@ -104,6 +246,11 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 			if err == nil {
 				break
 			}
+			// Another attempt against a manager without a leader won't succeed
+			if IsManagerUnavailable(err) {
+				err = managerError(err)
+				break
+			}
 			if retry < maxRetries-1 {
 				waitTime := time.Duration(retry+1) * time.Second
 				log.Printf("failed to list old tasks (attempt %d/%d): %v, retrying in %v", retry+1, maxRetries, err, waitTime)
@@ -114,15 +261,7 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 			return nil, fmt.Errorf("failed to list old tasks: %w", err)
 		}
 
-		response, err := d.cli.ServiceUpdate(
-			ctx,
-			existing.ID,
-			existing.Version,
-			*spec,
-			swarm.ServiceUpdateOptions{
-				EncodedRegistryAuth: registryAuth,
-			},
-		)
+		response, err := d.updateService(ctx, existing, *spec, registryAuth)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update service: %w", err)
 		}
@@ -143,6 +282,11 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 			if err == nil {
 				break
 			}
+			// Another attempt against a manager without a leader won't succeed
+			if IsManagerUnavailable(err) {
+				err = managerError(err)
+				break
+			}
 			if retry < maxRetries-1 {
 				waitTime := time.Duration(retry+1) * time.Second
 				log.Printf("failed to list new tasks (attempt %d/%d): %v, retrying in %v", retry+1, maxRetries, err, waitTime)
@@ -196,10 +340,18 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 		// Create new service
 		log.Printf("Creating service: %s", fullName)
 
-		createResponse, err := d.cli.ServiceCreate(ctx, *spec, swarm.ServiceCreateOptions{
-			EncodedRegistryAuth: registryAuth,
+		var createResponse swarm.ServiceCreateResponse
+		err = retryMutation(ctx, "create service "+fullName, func(int) error {
+			var err error
+			createResponse, err = d.cli.ServiceCreate(d.mutationContext(ctx), *spec, swarm.ServiceCreateOptions{
+				EncodedRegistryAuth: registryAuth,
+			})
+			return err
 		})
 		if err != nil {
+			if IsManagerUnavailable(err) {
+				err = managerError(err)
+			}
 			return nil, fmt.Errorf("failed to create service: %w", err)
 		}
 		log.Printf("Service %s created", fullName)
@@ -222,6 +374,109 @@ func (d *StackDeployer) deployService(ctx context.Context, serviceName string, s
 	}
 }
 
+// UpdateService updates existing to spec the way Deploy does: with the registry
+// credentials of the image, so the nodes can pull it, and retrying version
+// conflicts and transient daemon errors
+func (d *StackDeployer) UpdateService(ctx context.Context, existing swarm.Service, spec swarm.ServiceSpec) (swarm.ServiceUpdateResponse, error) {
+	var registryAuth string
+	if spec.TaskTemplate.ContainerSpec != nil {
+		registryAuth = getRegistryAuth(spec.TaskTemplate.ContainerSpec.Image)
+	}
+	return d.updateService(ctx, existing, spec, registryAuth)
+}
+
+func (d *StackDeployer) updateService(ctx context.Context, existing swarm.Service, spec swarm.ServiceSpec, registryAuth string) (swarm.ServiceUpdateResponse, error) {
+	var response swarm.ServiceUpdateResponse
+	version := existing.Version
+	err := retryMutation(ctx, "update service "+spec.Name, func(attempt int) error {
+		if attempt > 0 {
+			// A conflicting update bumped the version; retry against the current one
+			current, _, err := d.cli.ServiceInspectWithRaw(ctx, existing.ID, swarm.ServiceInspectOptions{})
+			if err != nil {
+				return err
+			}
+			version = current.Version
+		}
+		var err error
+		response, err = d.cli.ServiceUpdate(
+			d.mutationContext(ctx),
+			existing.ID,
+			version,
+			spec,
+			swarm.ServiceUpdateOptions{
+				EncodedRegistryAuth: registryAuth,
+			},
+		)
+		return err
+	})
+	if IsManagerUnavailable(err) {
+		err = managerError(err)
+	}
+	return response, err
+}
+
+// serviceUnchanged reports whether updating existing to spec would change nothing
+// but the deployment ID: the compared fields match and the rest of the definition
+// hashes the same as the spec existing was deployed with.
+func (d *StackDeployer) serviceUnchanged(ctx context.Context, existing *swarm.Service, spec *swarm.ServiceSpec) (bool, error) {
+	if existing.Spec.Labels[plan.SpecHashLabel] != spec.Labels[plan.SpecHashLabel] {
+		return false, nil
+	}
+
+	// The running spec attaches networks by ID
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list networks: %w", err)
+	}
+	networkNames := make(map[string]string, len(networks))
+	for _, n := range networks {
+		networkNames[n.ID] = n.Name
+	}
+
+	return len(plan.ServiceChanges(existing, spec, networkNames)) == 0, nil
+}
+
+// mutationAttempts bounds how often a service create or update is tried
+var mutationAttempts = 3
+
+// mutationRetryDelay is the wait before the first retry; it doubles with every further retry
+var mutationRetryDelay = time.Second
+
+// retryMutation calls fn until it succeeds, fails with an error that another
+// attempt can't fix, or mutationAttempts are used up. fn gets the attempt number
+// starting at 0, so retries can refresh state the failed attempt was rejected for.
+func retryMutation(ctx context.Context, what string, fn func(attempt int) error) error {
+	delay := mutationRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt == mutationAttempts-1 || !isRetryableMutationError(err) {
+			return err
+		}
+
+		log.Printf("failed to %s (attempt %d/%d): %v, retrying in %v", what, attempt+1, mutationAttempts, err, delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isRetryableMutationError reports whether a failed service create or update may
+// succeed when tried again: version conflicts with a concurrent update and transient
+// daemon errors. Rejected specs, missing objects and cancellations are final, and so
+// is a manager without a leader, which the caller reports with recovery advice.
+func isRetryableMutationError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || IsManagerUnavailable(err) {
+		return false
+	}
+	if strings.Contains(strings.ToLower(err.Error()), "update out of sequence") {
+		return true
+	}
+	return cerrdefs.IsInternal(err) || cerrdefs.IsUnavailable(err)
+}
+
 // GetStackServices returns all services in the stack
 func (d *StackDeployer) GetStackServices(ctx context.Context) ([]swarm.Service, error) {
 	return d.cli.ServiceList(ctx, swarm.ServiceListOptions{
@@ -274,6 +529,11 @@ func (d *StackDeployer) waitForServiceUpdate(ctx context.Context, serviceID stri
 			if err == nil {
 				break
 			}
+			// Another attempt against a manager without a leader won't succeed
+			if IsManagerUnavailable(err) {
+				err = managerError(err)
+				break
+			}
 			if retry < maxRetries-1 {
 				waitTime := time.Duration(retry+1) * time.Second
 				log.Printf("failed to list tasks (attempt %d/%d): %v, retrying in %v",
@@ -380,7 +640,7 @@ func (d *StackDeployer) waitForServiceUpdate(ctx context.Context, serviceID stri
 					// Check container health if task has a container
 					if task.Status.ContainerStatus.ContainerID != "" {
 						containerID := task.Status.ContainerStatus.ContainerID
-						inspect, err := d.cli.ContainerInspect(ctx, containerID)
+						inspect, err := throttle.ContainerInspect(ctx, d.cli, containerID)
 						if err == nil {
 							// If container has health check, wait for it to be healthy
 							if inspect.State.Health != nil {
@@ -0,0 +1,549 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+	"github.com/SomeBlackMagic/stackman/internal/plan"
+)
+
+// concurrencyRecordingClient records how many ServiceCreate calls run at the same time
+type concurrencyRecordingClient struct {
+	MockDockerClient
+
+	mu        sync.Mutex
+	inFlight  int
+	maxFlight int
+	created   []string
+	failFor   string
+}
+
+func (c *concurrencyRecordingClient) ServiceCreate(ctx context.Context, service swarm.ServiceSpec, options types.ServiceCreateOptions) (swarm.ServiceCreateResponse, error) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxFlight {
+		c.maxFlight = c.inFlight
+	}
+	c.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	if service.Name == c.failFor {
+		return swarm.ServiceCreateResponse{}, fmt.Errorf("no such image")
+	}
+	c.created = append(c.created, service.Name)
+	return swarm.ServiceCreateResponse{ID: "id_" + service.Name}, nil
+}
+
+func (c *concurrencyRecordingClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (swarm.Service, []byte, error) {
+	return swarm.Service{ID: serviceID}, nil, nil
+}
+
+func testServices(n int) map[string]*compose.Service {
+	services := make(map[string]*compose.Service)
+	for i := 0; i < n; i++ {
+		services[fmt.Sprintf("svc%02d", i)] = &compose.Service{Image: "nginx:1.25"}
+	}
+	return services
+}
+
+func TestDeployServices_RespectsParallel(t *testing.T) {
+	tests := []struct {
+		parallel int
+		want     int
+	}{
+		{parallel: 1, want: 1},
+		{parallel: 3, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("parallel=%d", tt.parallel), func(t *testing.T) {
+			cli := &concurrencyRecordingClient{}
+			deployer := NewStackDeployer(cli, "mystack", 3)
+			deployer.Parallel = tt.parallel
+
+			result, err := deployer.deployServices(context.Background(), testServices(6), "deploy-1")
+			if err != nil {
+				t.Fatalf("deployServices failed: %v", err)
+			}
+
+			if cli.maxFlight != tt.want {
+				t.Errorf("Expected max %d concurrent deploys, got %d", tt.want, cli.maxFlight)
+			}
+			if len(result.UpdatedServices) != 6 {
+				t.Errorf("Expected 6 updated services, got %d", len(result.UpdatedServices))
+			}
+		})
+	}
+}
+
+func TestDeployServices_AggregatesErrors(t *testing.T) {
+	cli := &concurrencyRecordingClient{failFor: "mystack_svc00"}
+	deployer := NewStackDeployer(cli, "mystack", 3)
+	deployer.Parallel = 3
+
+	_, err := deployer.deployServices(context.Background(), testServices(3), "deploy-1")
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "svc00") {
+		t.Errorf("Expected error to name the failed service, got: %v", err)
+	}
+
+	// The other two were already in flight and must have completed
+	if len(cli.created) != 2 {
+		t.Errorf("Expected in-flight deploys to finish, created: %v", cli.created)
+	}
+}
+
+func TestCheckServiceWarnings_Cpuset(t *testing.T) {
+	deployer := NewStackDeployer(&MockDockerClient{}, "mystack", 3)
+
+	deployer.checkServiceWarnings(map[string]*compose.Service{
+		"web":    {Image: "nginx:1.25", Cpuset: "0,1"},
+		"worker": {Image: "worker:1.0"},
+	})
+
+	warnings := deployer.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "service web: cpuset") {
+		t.Errorf("Expected cpuset warning for web, got %v", warnings)
+	}
+}
+
+func TestDeployService_DefaultRestartCondition(t *testing.T) {
+	maxAttempts := 5
+	services := map[string]*compose.Service{
+		"job": {Image: "app:1"},
+		"web": {Image: "nginx:1.25", Deploy: &compose.DeployConfig{
+			RestartPolicy: &compose.RestartPolicy{Condition: "any"},
+		}},
+		"worker": {Image: "app:1", Deploy: &compose.DeployConfig{
+			RestartPolicy: &compose.RestartPolicy{MaxAttempts: &maxAttempts},
+		}},
+	}
+	want := map[string]swarm.RestartPolicyCondition{
+		"mystack_job":    swarm.RestartPolicyConditionOnFailure,
+		"mystack_web":    swarm.RestartPolicyConditionAny,
+		"mystack_worker": swarm.RestartPolicyConditionOnFailure,
+	}
+
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.DefaultRestartCondition = "on-failure"
+
+	for _, name := range []string{"job", "web", "worker"} {
+		// The mock doesn't register created services, so the post-create inspect fails;
+		// only the spec sent to ServiceCreate matters here
+		_, _ = deployer.deployService(context.Background(), name, services[name], "deploy-1")
+	}
+
+	if len(mockCli.createdServices) != len(want) {
+		t.Fatalf("Expected %d created services, got %d", len(want), len(mockCli.createdServices))
+	}
+	for _, svc := range mockCli.createdServices {
+		policy := svc.Spec.TaskTemplate.RestartPolicy
+		if policy == nil {
+			t.Errorf("Expected restart policy on %s", svc.Spec.Name)
+			continue
+		}
+		if policy.Condition != want[svc.Spec.Name] {
+			t.Errorf("Expected %s restart condition %q, got %q", svc.Spec.Name, want[svc.Spec.Name], policy.Condition)
+		}
+		if svc.Spec.Name == "mystack_worker" && (policy.MaxAttempts == nil || *policy.MaxAttempts != 5) {
+			t.Errorf("Expected worker max attempts to be kept, got %v", policy.MaxAttempts)
+		}
+	}
+}
+
+func TestDeployServices_UnchangedServiceNotUpdated(t *testing.T) {
+	services := map[string]*compose.Service{
+		"web": {Image: "nginx:1.25", Environment: []interface{}{"MODE=prod"}},
+	}
+
+	// Deploy once, then keep the created service the way Swarm stores it:
+	// network attachments refer to network IDs
+	mockCli := &MockDockerClient{
+		networks: []network.Summary{{ID: "net-default", Name: "mystack_default"}},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	if _, err := deployer.deployServices(context.Background(), services, "deploy-1"); err != nil {
+		t.Fatalf("First deploy failed: %v", err)
+	}
+	if len(mockCli.createdServices) != 1 {
+		t.Fatalf("Expected the service to be created, got %d", len(mockCli.createdServices))
+	}
+	deployed := mockCli.createdServices[0]
+	deployed.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "net-default"}}
+	mockCli.services = []swarm.Service{deployed}
+
+	result, err := deployer.deployServices(context.Background(), services, "deploy-2")
+	if err != nil {
+		t.Fatalf("Second deploy failed: %v", err)
+	}
+	if len(mockCli.updatedServices) != 0 {
+		t.Errorf("Expected no ServiceUpdate calls for an unchanged stack, got %v", mockCli.updatedServices)
+	}
+	if len(result.UpdatedServices) != 0 {
+		t.Errorf("Expected no updated services, got %+v", result.UpdatedServices)
+	}
+
+	// A field compareServices doesn't inspect still counts as a change
+	services["web"].HealthCheck = &compose.HealthCheck{Test: []string{"CMD", "true"}}
+	if _, err := deployer.deployServices(context.Background(), services, "deploy-3"); err != nil {
+		t.Fatalf("Third deploy failed: %v", err)
+	}
+	if len(mockCli.updatedServices) != 1 {
+		t.Fatalf("Expected the changed service to be updated once, got %v", mockCli.updatedServices)
+	}
+	if got := mockCli.updatedSpecs[deployed.ID].TaskTemplate.ContainerSpec.Labels["com.stackman.deploy.id"]; got != "deploy-3" {
+		t.Errorf("Expected the update to carry the new deploy ID, got %q", got)
+	}
+}
+
+func TestDeployServices_PlanAgreesWithDeployer(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{"db_password.txt": "s3cret", "app.conf": "debug=false"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {
+				Image:       "nginx:1.25",
+				Networks:    []interface{}{"front", "shared"},
+				Secrets:     []interface{}{"db_password", "api_key"},
+				Configs:     []interface{}{"app_conf"},
+				HealthCheck: &compose.HealthCheck{Test: []string{"CMD", "true"}},
+			},
+		},
+		Networks: map[string]*compose.Network{
+			"front":  {Driver: "overlay"},
+			"shared": {External: map[string]interface{}{"name": "shared-net"}},
+		},
+		Secrets: map[string]*compose.Secret{
+			"db_password": {File: "db_password.txt"},
+			"api_key":     {External: true},
+		},
+		Configs: map[string]*compose.Config{
+			"app_conf": {File: "app.conf"},
+		},
+		Dir: dir,
+	}
+
+	mockCli := &MockDockerClient{
+		networks: []network.Summary{{ID: "net-front", Name: "mystack_front"}, {ID: "net-shared", Name: "shared-net"}},
+		secrets:  []swarm.Secret{{ID: "secret-ext", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "api_key"}}}},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.DefaultRestartCondition = "on-failure"
+	deployer.composeDir = dir
+	ctx := context.Background()
+	if err := deployer.deploySecrets(ctx, composeFile.Secrets); err != nil {
+		t.Fatalf("deploySecrets failed: %v", err)
+	}
+	if err := deployer.deployConfigs(ctx, composeFile.Configs); err != nil {
+		t.Fatalf("deployConfigs failed: %v", err)
+	}
+	if err := deployer.createNetworks(ctx, composeFile.Networks); err != nil {
+		t.Fatalf("createNetworks failed: %v", err)
+	}
+	if _, err := deployer.deployServices(ctx, composeFile.Services, "deploy-1"); err != nil {
+		t.Fatalf("deployServices failed: %v", err)
+	}
+	if len(mockCli.createdServices) != 1 {
+		t.Fatalf("Expected the service to be created, got %d", len(mockCli.createdServices))
+	}
+	deployed := mockCli.createdServices[0]
+	deployed.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "net-front"}, {Target: "net-shared"}}
+
+	current := &plan.CurrentState{
+		Services: map[string]swarm.Service{"web": deployed},
+		Networks: map[string]swarm.Network{
+			"front": {ID: "net-front", Spec: swarm.NetworkSpec{Annotations: swarm.Annotations{Name: "mystack_front"}}},
+		},
+		Secrets: make(map[string]swarm.Secret),
+		Configs: make(map[string]swarm.Config),
+	}
+	for _, sec := range mockCli.secrets {
+		if name, ok := sec.Spec.Labels[compose.ResourceNameLabel]; ok {
+			current.Secrets[name] = sec
+		}
+	}
+	for _, cfg := range mockCli.configs {
+		if name, ok := cfg.Spec.Labels[compose.ResourceNameLabel]; ok {
+			current.Configs[name] = cfg
+		}
+	}
+
+	desired := plan.BuildDesiredState(composeFile)
+	desired.DefaultRestartCondition = "on-failure"
+	deployPlan, err := plan.NewPlanner(nil, "mystack").CreatePlan(ctx, current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	if len(deployPlan.Services) != 1 || deployPlan.Services[0].Action != plan.ActionNone {
+		t.Fatalf("Expected the just-deployed service to be unchanged, got %+v", deployPlan.Services)
+	}
+
+	// The deployer would roll the service without the default restart condition
+	desired.DefaultRestartCondition = ""
+	deployPlan, err = plan.NewPlanner(nil, "mystack").CreatePlan(ctx, current, desired)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	if got := deployPlan.Services[0].Changes; len(got) != 1 || got[0] != "configuration" {
+		t.Errorf("Expected a configuration change, got %v", got)
+	}
+}
+
+func TestDeploy_BlkioConfigFailsWithFailOnWarning(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.FailOnWarning = true
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"db": {Image: "postgres:16", BlkioConfig: &compose.BlkioConfig{Weight: 300}},
+		},
+	}
+
+	_, err := deployer.Deploy(context.Background(), composeFile, "deploy-1")
+	if err == nil || !strings.Contains(err.Error(), "--fail-on-warning") {
+		t.Fatalf("Expected deploy to abort on the blkio_config warning, got %v", err)
+	}
+	if len(mockCli.createdServices) != 0 {
+		t.Errorf("Expected no services created, got %d", len(mockCli.createdServices))
+	}
+}
+
+func TestDeploy_WarnMissingMemoryLimit(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+	deployer.WarnMissingMemoryLimit = true
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {Image: "nginx:1.25"},
+			"api": {Image: "app:1", Deploy: &compose.DeployConfig{
+				Resources: &compose.Resources{Limits: &compose.ResourceLimit{Memory: "256M"}},
+			}},
+		},
+	}
+
+	if _, err := deployer.Deploy(context.Background(), composeFile, "deploy-1"); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	warnings := deployer.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "service web: no deploy.resources.limits.memory") {
+		t.Errorf("Expected one memory limit warning for web, got %v", warnings)
+	}
+
+	// Under -fail-on-warning the missing limit aborts the deploy
+	deployer = NewStackDeployer(&MockDockerClient{}, "mystack", 3)
+	deployer.WarnMissingMemoryLimit = true
+	deployer.FailOnWarning = true
+	if _, err := deployer.Deploy(context.Background(), composeFile, "deploy-1"); err == nil {
+		t.Error("Expected deploy to abort on the memory limit warning")
+	}
+}
+
+// blockingUpdateClient holds ServiceUpdate until released and records the state of its context
+type blockingUpdateClient struct {
+	MockDockerClient
+
+	started chan struct{}
+	release chan struct{}
+	ctxErr  error
+}
+
+func (c *blockingUpdateClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec, options types.ServiceUpdateOptions) (swarm.ServiceUpdateResponse, error) {
+	close(c.started)
+	<-c.release
+	c.ctxErr = ctx.Err()
+	return c.MockDockerClient.ServiceUpdate(ctx, serviceID, version, service, options)
+}
+
+func TestDeployService_DetachDoesNotCancelInFlightUpdate(t *testing.T) {
+	for _, detach := range []bool{true, false} {
+		mockCli := &blockingUpdateClient{
+			MockDockerClient: MockDockerClient{
+				services: []swarm.Service{{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}},
+			},
+			started: make(chan struct{}),
+			release: make(chan struct{}),
+		}
+		deployer := NewStackDeployer(mockCli, "mystack", 3)
+		deployer.Detach = detach
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = deployer.deployService(ctx, "web", &compose.Service{Image: "nginx:1.25"}, "deploy-1")
+		}()
+
+		// Cancel the deploy while the update is in flight
+		<-mockCli.started
+		cancel()
+		close(mockCli.release)
+		<-done
+
+		if len(mockCli.updatedServices) != 1 {
+			t.Fatalf("detach=%v: expected the update to be sent, got %v", detach, mockCli.updatedServices)
+		}
+		if detach && mockCli.ctxErr != nil {
+			t.Errorf("Expected a detached update to keep its context, got %v", mockCli.ctxErr)
+		}
+		if !detach && mockCli.ctxErr == nil {
+			t.Error("Expected an attached update to see the cancellation")
+		}
+	}
+}
+
+// outOfSequenceClient rejects service updates against a stale version
+type outOfSequenceClient struct {
+	MockDockerClient
+
+	current  swarm.Version
+	updates  []swarm.Version
+	auths    []string
+	fatalErr error
+}
+
+func (c *outOfSequenceClient) ServiceInspectWithRaw(ctx context.Context, serviceID string, options types.ServiceInspectOptions) (swarm.Service, []byte, error) {
+	return swarm.Service{ID: serviceID, Meta: swarm.Meta{Version: c.current}}, nil, nil
+}
+
+func (c *outOfSequenceClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec, options types.ServiceUpdateOptions) (swarm.ServiceUpdateResponse, error) {
+	c.updates = append(c.updates, version)
+	c.auths = append(c.auths, options.EncodedRegistryAuth)
+	if c.fatalErr != nil {
+		return swarm.ServiceUpdateResponse{}, c.fatalErr
+	}
+	if version != c.current {
+		return swarm.ServiceUpdateResponse{}, fmt.Errorf("Error response from daemon: rpc error: code = Unknown desc = update out of sequence")
+	}
+	return c.MockDockerClient.ServiceUpdate(ctx, serviceID, version, service, options)
+}
+
+func TestDeployService_RetriesOutOfSequenceUpdate(t *testing.T) {
+	defer func(delay time.Duration) { mutationRetryDelay = delay }(mutationRetryDelay)
+	mutationRetryDelay = time.Millisecond
+
+	// The listed version is already stale: another update bumped it to 8
+	mockCli := &outOfSequenceClient{
+		MockDockerClient: MockDockerClient{
+			services: []swarm.Service{{ID: "svc1", Meta: swarm.Meta{Version: swarm.Version{Index: 7}}, Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}},
+		},
+		current: swarm.Version{Index: 8},
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+
+	if _, err := deployer.deployService(context.Background(), "web", &compose.Service{Image: "nginx:1.25"}, "deploy-1"); err != nil {
+		t.Fatalf("deployService failed: %v", err)
+	}
+
+	want := []swarm.Version{{Index: 7}, {Index: 8}}
+	if fmt.Sprint(mockCli.updates) != fmt.Sprint(want) {
+		t.Errorf("Expected updates against versions %v, got %v", want, mockCli.updates)
+	}
+	if len(mockCli.updatedServices) != 1 {
+		t.Errorf("Expected the retried update to be applied once, got %v", mockCli.updatedServices)
+	}
+}
+
+func TestUpdateService_RetriesWithRegistryAuth(t *testing.T) {
+	defer func(delay time.Duration) { mutationRetryDelay = delay }(mutationRetryDelay)
+	mutationRetryDelay = time.Millisecond
+
+	dir := t.TempDir()
+	config := `{"auths": {"registry.example.com": {"auth": "ZGVwbG95OnMzY3JldA=="}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG_PATH", dir)
+
+	mockCli := &outOfSequenceClient{current: swarm.Version{Index: 8}}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+
+	existing := swarm.Service{ID: "svc1", Meta: swarm.Meta{Version: swarm.Version{Index: 7}}}
+	spec := swarm.ServiceSpec{
+		Annotations:  swarm.Annotations{Name: "mystack_web"},
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{Image: "registry.example.com/web:1.0"}},
+	}
+	if _, err := deployer.UpdateService(context.Background(), existing, spec); err != nil {
+		t.Fatalf("UpdateService failed: %v", err)
+	}
+
+	want := []swarm.Version{{Index: 7}, {Index: 8}}
+	if fmt.Sprint(mockCli.updates) != fmt.Sprint(want) {
+		t.Errorf("Expected updates against versions %v, got %v", want, mockCli.updates)
+	}
+	for i, auth := range mockCli.auths {
+		if authConfig := decodeRegistryAuth(t, auth); authConfig.Username != "deploy" {
+			t.Errorf("Update %d: expected the registry credentials, got %+v", i, authConfig)
+		}
+	}
+}
+
+func TestDeployService_InvalidSpecNotRetried(t *testing.T) {
+	defer func(delay time.Duration) { mutationRetryDelay = delay }(mutationRetryDelay)
+	mutationRetryDelay = time.Millisecond
+
+	mockCli := &outOfSequenceClient{
+		MockDockerClient: MockDockerClient{
+			services: []swarm.Service{{ID: "svc1", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "mystack_web"}}}},
+		},
+		fatalErr: fmt.Errorf("invalid mount target, must be an absolute path: data: %w", cerrdefs.ErrInvalidArgument),
+	}
+	deployer := NewStackDeployer(mockCli, "mystack", 3)
+
+	_, err := deployer.deployService(context.Background(), "web", &compose.Service{Image: "nginx:1.25"}, "deploy-1")
+	if err == nil || !strings.Contains(err.Error(), "invalid mount target") {
+		t.Fatalf("Expected the invalid spec error, got %v", err)
+	}
+	if len(mockCli.updates) != 1 {
+		t.Errorf("Expected a rejected spec to be tried once, got %d attempts", len(mockCli.updates))
+	}
+}
+
+func TestIsRetryableMutationError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"out of sequence", fmt.Errorf("rpc error: code = Unknown desc = update out of sequence"), true},
+		{"internal server error", fmt.Errorf("daemon busy: %w", cerrdefs.ErrInternal), true},
+		{"unavailable", fmt.Errorf("try again: %w", cerrdefs.ErrUnavailable), true},
+		{"invalid spec", fmt.Errorf("bad spec: %w", cerrdefs.ErrInvalidArgument), false},
+		{"not found", fmt.Errorf("no such service: %w", cerrdefs.ErrNotFound), false},
+		{"cancelled", context.Canceled, false},
+		{"no leader", fmt.Errorf("rpc error: code = Unknown desc = The swarm does not have a leader"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableMutationError(tt.err); got != tt.want {
+				t.Errorf("isRetryableMutationError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
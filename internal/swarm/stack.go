@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 
@@ -13,7 +14,43 @@ import (
 type StackDeployer struct {
 	cli                DockerClient
 	stackName          string
-	MaxFailedTaskCount int // Maximum number of failed tasks before giving up
+	MaxFailedTaskCount int           // Maximum number of failed tasks before giving up
+	PullTimeout        time.Duration // Maximum time for a single image pull (0 = no limit)
+	PullRetries        int           // Number of attempts per image pull
+	PullPolicy         string        // Default pull policy for services without pull_policy (always, missing, never)
+	Parallel           int           // Maximum number of services deployed concurrently
+
+	ValidateExternalResources bool          // Verify referenced external secrets/configs exist before deploying
+	MaxImageAge               time.Duration // Warn when an image is older than this (0 = disabled)
+	FailOnWarning             bool          // Abort before deploying services if any warning was raised
+	WarnMissingMemoryLimit    bool          // Warn about services without deploy.resources.limits.memory
+	PinDigests                bool          // Deploy images by registry digest instead of tag
+	DefaultRestartCondition   string        // Restart condition for services without one (empty = Swarm default "any")
+	PruneWait                 time.Duration // Maximum wait for tasks to release a network before removing it (0 = don't wait)
+	Detach                    bool          // Let in-flight service create/update/remove calls finish even if the deploy is cancelled
+	KeepExitedContainers      bool          // Skip removing the stack's exited task containers before deploying
+	Prune                     bool          // Remove services, networks, volumes, secrets and configs the compose file no longer declares
+
+	// ServiceFilter limits deploy, prune and removal to services carrying a compose label (nil = all services)
+	ServiceFilter *compose.LabelFilter
+
+	// RetainedResources holds the IDs of secret and config versions that saved snapshots
+	// still reference; RemoveRotatedResources keeps them so those snapshots stay restorable
+	RetainedResources map[string]bool
+
+	// OnWarning is called for every warning as it is raised; service is empty for stack-level warnings
+	OnWarning func(service, message string)
+
+	secrets map[string]swarmObject // Resolved stack secrets keyed by stack-scoped name
+	configs map[string]swarmObject // Resolved stack configs keyed by stack-scoped name
+
+	networks map[string]string // Actual network names keyed by stack-scoped name
+
+	pinnedImages map[string]string // Digest-pinned image references keyed by service name
+
+	composeDir string // Directory of the compose file being deployed
+
+	warnings []string // Non-fatal issues raised during the current Deploy
 }
 
 // ServiceUpdateResult contains information about a service deployment
@@ -40,6 +77,9 @@ func NewStackDeployer(cli DockerClient, stackName string, maxFailedTaskCount int
 		cli:                cli,
 		stackName:          stackName,
 		MaxFailedTaskCount: maxFailedTaskCount,
+		PullRetries:        3,
+		Parallel:           1,
+		PullPolicy:         compose.PullPolicyAlways,
 	}
 }
 
@@ -47,33 +87,100 @@ func NewStackDeployer(cli DockerClient, stackName string, maxFailedTaskCount int
 // Returns DeploymentResult with information about updated services, or error
 func (d *StackDeployer) Deploy(ctx context.Context, composeFile *compose.ComposeFile, deployID string) (*DeploymentResult, error) {
 	log.Printf("Starting deployment of stack: %s (DeployID: %s)", d.stackName, deployID)
+	d.warnings = nil
+	d.composeDir = composeFile.Dir
+
+	// 0. Reject ingress port collisions that Swarm would only report mid-deploy
+	if err := compose.ValidatePublishedPorts(composeFile.Services); err != nil {
+		return nil, err
+	}
+
+	// Attaching to an undeclared network only fails when the service is created
+	if err := compose.ValidateNetworks(composeFile); err != nil {
+		return nil, err
+	}
+
+	// Make sure referenced external secrets and configs exist before touching the swarm
+	if d.ValidateExternalResources {
+		if err := d.validateExternalResources(ctx, composeFile); err != nil {
+			return nil, err
+		}
+	}
 
 	// 1. Remove exited containers from previous deployments
-	if err := d.RemoveExitedContainers(ctx); err != nil {
-		return nil, fmt.Errorf("failed to remove exited containers: %w", err)
+	if !d.KeepExitedContainers {
+		if err := d.RemoveExitedContainers(ctx); err != nil {
+			return nil, fmt.Errorf("failed to remove exited containers: %w", err)
+		}
 	}
 
-	// 2. Check for obsolete services and remove them
-	if err := d.removeObsoleteServices(ctx, composeFile.Services); err != nil {
-		return nil, fmt.Errorf("failed to remove obsolete services: %w", err)
+	// 2. Remove obsolete services and orphaned resources; without Prune they are left in place
+	if d.Prune {
+		if err := d.removeObsoleteServices(ctx, composeFile); err != nil {
+			return nil, fmt.Errorf("failed to remove obsolete services: %w", err)
+		}
+		if err := d.pruneOrphanedResources(ctx, composeFile); err != nil {
+			return nil, fmt.Errorf("failed to prune orphaned resources: %w", err)
+		}
+	}
+
+	// Services outside -filter are left untouched
+	services := d.selectedServices(composeFile.Services)
+	if d.ServiceFilter != nil {
+		log.Printf("Filter %s selects %d of %d service(s): %v", d.ServiceFilter, len(services), len(composeFile.Services), sortedKeys(services))
 	}
 
 	// 3. Pull images
-	if err := d.pullImages(ctx, composeFile.Services); err != nil {
+	if err := d.pullImages(ctx, services); err != nil {
 		return nil, fmt.Errorf("failed to pull images: %w", err)
 	}
 
+	// Resolve tags to digests so a later retag can't change what runs
+	d.pinnedImages = nil
+	if d.PinDigests {
+		if err := d.pinImageDigests(ctx, services); err != nil {
+			return nil, fmt.Errorf("failed to pin image digests: %w", err)
+		}
+	}
+
+	// Report compose options Swarm cannot apply
+	d.checkServiceWarnings(services)
+
+	// Check image freshness now that images are local
+	if d.MaxImageAge > 0 {
+		d.checkImageAge(ctx, services)
+	}
+	if d.FailOnWarning && len(d.warnings) > 0 {
+		return nil, fmt.Errorf("aborting deployment: %d warning(s) raised and --fail-on-warning is set", len(d.warnings))
+	}
+
 	// 4. Create networks
 	if err := d.createNetworks(ctx, composeFile.Networks); err != nil {
 		return nil, fmt.Errorf("failed to create networks: %w", err)
 	}
+	// Declared networks replace the implicit default one, which services may still use
+	if _, declared := composeFile.Networks["default"]; len(composeFile.Networks) > 0 && !declared && compose.UsesDefaultNetwork(services) {
+		if err := d.ensureDefaultNetwork(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create networks: %w", err)
+		}
+	}
 
 	// 5. Create volumes
 	if err := d.createVolumes(ctx, composeFile.Volumes); err != nil {
 		return nil, fmt.Errorf("failed to create volumes: %w", err)
 	}
 
-	// 6. Create/update services and collect results
+	// 6. Create secrets
+	if err := d.deploySecrets(ctx, composeFile.Secrets); err != nil {
+		return nil, fmt.Errorf("failed to deploy secrets: %w", err)
+	}
+
+	// 7. Create configs
+	if err := d.deployConfigs(ctx, composeFile.Configs); err != nil {
+		return nil, fmt.Errorf("failed to deploy configs: %w", err)
+	}
+
+	// 8. Create/update services and collect results
 	result, err := d.deployServices(ctx, composeFile.Services, deployID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deploy services: %w", err)
@@ -85,3 +192,41 @@ func (d *StackDeployer) Deploy(ctx context.Context, composeFile *compose.Compose
 	log.Printf("Stack %s deployed successfully (DeployID: %s)", d.stackName, deployID)
 	return result, nil
 }
+
+// Warnings returns the warnings raised during the last Deploy
+func (d *StackDeployer) Warnings() []string {
+	return d.warnings
+}
+
+// checkServiceWarnings records compose-level warnings for all services
+func (d *StackDeployer) checkServiceWarnings(services map[string]*compose.Service) {
+	for _, name := range sortedKeys(services) {
+		for _, warning := range compose.ServiceWarnings(name, services[name]) {
+			d.warn(name, "%s", warning)
+		}
+		if d.WarnMissingMemoryLimit {
+			if warning := compose.MemoryLimitWarning(name, services[name]); warning != "" {
+				d.warn(name, "%s", warning)
+			}
+		}
+	}
+}
+
+// warn records a non-fatal deployment warning, attributed to service when non-empty
+func (d *StackDeployer) warn(service, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("WARNING: %s", msg)
+	d.warnings = append(d.warnings, msg)
+	if d.OnWarning != nil {
+		d.OnWarning(service, msg)
+	}
+}
+
+// RemoveRotatedResources removes previous versions of secrets and configs that were
+// rotated by the last Deploy. Call it only once the deployment is known to be good:
+// rolled-back service specs still reference the old versions. Versions listed in
+// RetainedResources are kept.
+func (d *StackDeployer) RemoveRotatedResources(ctx context.Context) {
+	d.removeRotatedSecrets(ctx)
+	d.removeRotatedConfigs(ctx)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/volume"
 
+	"github.com/SomeBlackMagic/stackman/internal/compose"
 	"github.com/SomeBlackMagic/stackman/internal/plan"
 )
 
@@ -106,12 +107,54 @@ func GetCurrentState(ctx context.Context, cli DockerClient, stackName string) (*
 		state.Volumes[volumeName] = struct{}{}
 	}
 
-	// TODO: Get configs when configs support is added
-	// TODO: Get secrets when secrets support is added
+	// Get secrets
+	secrets, err := cli.SecretList(ctx, swarm.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", stackLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	for _, secret := range secrets {
+		name := resourceName(secret.Spec.Annotations, stackName)
+		// Several versions can coexist after a rotation; keep the newest
+		if existing, ok := state.Secrets[name]; ok && existing.CreatedAt.After(secret.CreatedAt) {
+			continue
+		}
+		state.Secrets[name] = secret
+	}
+
+	// Get configs
+	configs, err := cli.ConfigList(ctx, swarm.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", stackLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	for _, cfg := range configs {
+		name := resourceName(cfg.Spec.Annotations, stackName)
+		if existing, ok := state.Configs[name]; ok && existing.CreatedAt.After(cfg.CreatedAt) {
+			continue
+		}
+		state.Configs[name] = cfg
+	}
 
 	return state, nil
 }
 
+// resourceName returns the compose-level name of a stack secret or config
+func resourceName(annotations swarm.Annotations, stackName string) string {
+	if name, ok := annotations.Labels[compose.ResourceNameLabel]; ok {
+		return name
+	}
+	name := annotations.Name
+	if len(name) > len(stackName)+1 && name[:len(stackName)] == stackName {
+		name = name[len(stackName)+1:]
+	}
+	return name
+}
+
 // convertIPAMConfig converts network IPAM to swarm IPAMOptions
 func convertIPAMConfig(ipam *network.IPAM) *swarm.IPAMOptions {
 	if ipam == nil {
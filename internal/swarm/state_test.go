@@ -9,8 +9,10 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
 )
 
 // mockDockerClient implements DockerClient for testing
@@ -65,6 +67,9 @@ func (m *mockStateDockerClient) ServiceInspectWithRaw(ctx context.Context, servi
 func (m *mockStateDockerClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
 	return nil, nil
 }
+func (m *mockStateDockerClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	return nil, nil
+}
 func (m *mockStateDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
 	return nil, nil
 }
@@ -83,12 +88,39 @@ func (m *mockStateDockerClient) VolumeCreate(ctx context.Context, options volume
 func (m *mockStateDockerClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
 	return volume.Volume{}, nil
 }
+func (m *mockStateDockerClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	return nil
+}
 func (m *mockStateDockerClient) NetworkRemove(ctx context.Context, networkID string) error {
 	return nil
 }
 func (m *mockStateDockerClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
 	return nil, nil
 }
+func (m *mockStateDockerClient) ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	return image.InspectResponse{}, nil
+}
+func (m *mockStateDockerClient) DistributionInspect(ctx context.Context, imageRef, encodedRegistryAuth string) (registry.DistributionInspect, error) {
+	return registry.DistributionInspect{}, nil
+}
+func (m *mockStateDockerClient) SecretList(ctx context.Context, options swarm.SecretListOptions) ([]swarm.Secret, error) {
+	return nil, nil
+}
+func (m *mockStateDockerClient) SecretCreate(ctx context.Context, secret swarm.SecretSpec) (swarm.SecretCreateResponse, error) {
+	return swarm.SecretCreateResponse{}, nil
+}
+func (m *mockStateDockerClient) SecretRemove(ctx context.Context, id string) error {
+	return nil
+}
+func (m *mockStateDockerClient) ConfigList(ctx context.Context, options swarm.ConfigListOptions) ([]swarm.Config, error) {
+	return nil, nil
+}
+func (m *mockStateDockerClient) ConfigCreate(ctx context.Context, config swarm.ConfigSpec) (swarm.ConfigCreateResponse, error) {
+	return swarm.ConfigCreateResponse{}, nil
+}
+func (m *mockStateDockerClient) ConfigRemove(ctx context.Context, id string) error {
+	return nil
+}
 func (m *mockStateDockerClient) Close() error { return nil }
 
 func TestGetCurrentState(t *testing.T) {
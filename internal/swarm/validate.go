@@ -0,0 +1,66 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+// validateExternalResources checks that every external secret and config referenced
+// by a service exists in the swarm. All missing resources are reported at once.
+func (d *StackDeployer) validateExternalResources(ctx context.Context, composeFile *compose.ComposeFile) error {
+	secretNames := make(map[string]bool)
+	configNames := make(map[string]bool)
+
+	for serviceName, service := range composeFile.Services {
+		sources, err := compose.ReferenceSources(service.Secrets)
+		if err != nil {
+			return fmt.Errorf("service %s: invalid secrets: %w", serviceName, err)
+		}
+		for _, source := range sources {
+			if secret, ok := composeFile.Secrets[source]; ok && secret != nil && compose.IsExternal(secret.External) {
+				secretNames[secretName(source, secret)] = true
+			}
+		}
+
+		sources, err = compose.ReferenceSources(service.Configs)
+		if err != nil {
+			return fmt.Errorf("service %s: invalid configs: %w", serviceName, err)
+		}
+		for _, source := range sources {
+			if cfg, ok := composeFile.Configs[source]; ok && cfg != nil && compose.IsExternal(cfg.External) {
+				configNames[configName(source, cfg)] = true
+			}
+		}
+	}
+
+	var missing []string
+	for name := range secretNames {
+		existing, err := d.findSecret(ctx, name)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			missing = append(missing, "secret "+name)
+		}
+	}
+	for name := range configNames {
+		existing, err := d.findConfig(ctx, name)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			missing = append(missing, "config "+name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing external resources: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package swarm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/SomeBlackMagic/stackman/internal/compose"
+)
+
+func TestDeploy_MissingExternalSecretAbortsBeforeCreate(t *testing.T) {
+	mockCli := &MockDockerClient{
+		configs: []swarm.Config{
+			{ID: "cfg1", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "app_settings"}}},
+		},
+	}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	deployer.ValidateExternalResources = true
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web": {
+				Image:   "nginx:1.25",
+				Secrets: []interface{}{"db_password", "api_token"},
+				Configs: []interface{}{"app_settings"},
+			},
+		},
+		Secrets: map[string]*compose.Secret{
+			"db_password": {External: true},
+			"api_token":   {External: map[string]interface{}{"name": "shared_token"}},
+		},
+		Configs: map[string]*compose.Config{
+			"app_settings": {External: true},
+		},
+	}
+
+	_, err := deployer.Deploy(context.Background(), composeFile, "deploy1")
+	if err == nil {
+		t.Fatal("Expected deploy to fail with missing external secrets")
+	}
+
+	if !strings.Contains(err.Error(), "secret db_password") || !strings.Contains(err.Error(), "secret shared_token") {
+		t.Errorf("Expected error to list all missing secrets, got: %v", err)
+	}
+	if strings.Contains(err.Error(), "app_settings") {
+		t.Errorf("Existing config should not be reported as missing: %v", err)
+	}
+
+	if len(mockCli.createdServices) != 0 {
+		t.Errorf("Expected no services created, got %d", len(mockCli.createdServices))
+	}
+	if len(mockCli.createdSecrets) != 0 || len(mockCli.createdConfigs) != 0 {
+		t.Errorf("Expected no secrets or configs created")
+	}
+}
+
+func TestDeploy_RemoteComposeFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("services:\n  web:\n    image: nginx:1.25\n    volumes:\n      - ./html:/usr/share/nginx/html\n"))
+	}))
+	defer server.Close()
+
+	// There is no local directory for a remote file, so relative paths use STACKMAN_WORKDIR
+	t.Setenv("STACKMAN_WORKDIR", "/srv/stack")
+
+	composeFile, err := compose.ParseComposeFile(server.URL + "/stack.yml")
+	if err != nil {
+		t.Fatalf("ParseComposeFile failed: %v", err)
+	}
+
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+	if _, err := deployer.Deploy(context.Background(), composeFile, "deploy1"); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	if len(mockCli.createdServices) != 1 {
+		t.Fatalf("Expected 1 service created, got %d", len(mockCli.createdServices))
+	}
+	spec := mockCli.createdServices[0].Spec
+	if spec.Name != "test_web" {
+		t.Errorf("Expected service test_web, got %s", spec.Name)
+	}
+	mounts := spec.TaskTemplate.ContainerSpec.Mounts
+	if len(mounts) != 1 || mounts[0].Source != "/srv/stack/html" {
+		t.Errorf("Expected bind source resolved against STACKMAN_WORKDIR, got %+v", mounts)
+	}
+}
+
+func TestDeploy_PortCollisionAbortsBeforeCreate(t *testing.T) {
+	mockCli := &MockDockerClient{}
+	deployer := NewStackDeployer(mockCli, "test", 3)
+
+	composeFile := &compose.ComposeFile{
+		Services: map[string]*compose.Service{
+			"web":   {Image: "nginx:1.25", Ports: []interface{}{"80:80"}},
+			"admin": {Image: "nginx:1.25", Ports: []interface{}{"80:8080"}},
+		},
+	}
+
+	_, err := deployer.Deploy(context.Background(), composeFile, "deploy1")
+	if err == nil || !strings.Contains(err.Error(), "services admin and web both publish port 80/tcp") {
+		t.Fatalf("Expected port conflict error, got %v", err)
+	}
+	if len(mockCli.createdServices) != 0 || len(mockCli.pulledImages) != 0 {
+		t.Errorf("Expected no services created or images pulled before validation")
+	}
+}
@@ -11,7 +11,8 @@ import (
 )
 
 func (d *StackDeployer) createVolumes(ctx context.Context, volumes map[string]*compose.Volume) error {
-	for name, volConfig := range volumes {
+	for _, name := range sortedKeys(volumes) {
+		volConfig := volumes[name]
 		fullName := fmt.Sprintf("%s_%s", d.stackName, name)
 
 		// Check if volume already exists
@@ -0,0 +1,47 @@
+// Package throttle limits concurrent Docker API calls across the whole process,
+// so many task monitors polling at once don't overwhelm the daemon
+package throttle
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ContainerInspector is the part of the Docker client used for inspects
+type ContainerInspector interface {
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+}
+
+// inspectSlots holds the process-wide inspect semaphore (nil = unlimited)
+var inspectSlots atomic.Pointer[chan struct{}]
+
+// SetInspectLimit sets the maximum number of concurrent ContainerInspect calls.
+// A limit of 0 or less removes the limit. Calls already holding a slot are not affected.
+func SetInspectLimit(limit int) {
+	if limit <= 0 {
+		inspectSlots.Store(nil)
+		return
+	}
+	slots := make(chan struct{}, limit)
+	inspectSlots.Store(&slots)
+}
+
+// ContainerInspect calls cli.ContainerInspect once a slot is free.
+// It gives up with the context error if ctx ends while waiting.
+func ContainerInspect(ctx context.Context, cli ContainerInspector, containerID string) (types.ContainerJSON, error) {
+	slots := inspectSlots.Load()
+	if slots == nil {
+		return cli.ContainerInspect(ctx, containerID)
+	}
+
+	select {
+	case *slots <- struct{}{}:
+	case <-ctx.Done():
+		return types.ContainerJSON{}, ctx.Err()
+	}
+	defer func() { <-*slots }()
+
+	return cli.ContainerInspect(ctx, containerID)
+}
@@ -0,0 +1,95 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// countingInspector records the highest number of concurrent inspects
+type countingInspector struct {
+	active atomic.Int32
+	peak   atomic.Int32
+	calls  atomic.Int32
+}
+
+func (c *countingInspector) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	n := c.active.Add(1)
+	defer c.active.Add(-1)
+	c.calls.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return types.ContainerJSON{}, nil
+}
+
+func TestContainerInspect_LimitNeverExceeded(t *testing.T) {
+	SetInspectLimit(3)
+	defer SetInspectLimit(0)
+
+	cli := &countingInspector{}
+	var wg sync.WaitGroup
+	// Simulate many task monitors polling health at the same time
+	for monitor := 0; monitor < 20; monitor++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if _, err := ContainerInspect(context.Background(), cli, "container"); err != nil {
+					t.Errorf("ContainerInspect failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := cli.peak.Load(); peak > 3 {
+		t.Errorf("Expected at most 3 concurrent inspects, saw %d", peak)
+	}
+	if calls := cli.calls.Load(); calls != 200 {
+		t.Errorf("Expected 200 inspects, got %d", calls)
+	}
+}
+
+func TestContainerInspect_ContextCancelledWhileWaiting(t *testing.T) {
+	SetInspectLimit(1)
+	defer SetInspectLimit(0)
+
+	// Hold the only slot
+	slots := inspectSlots.Load()
+	*slots <- struct{}{}
+	defer func() { <-*slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	cli := &countingInspector{}
+	if _, err := ContainerInspect(ctx, cli, "container"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if cli.calls.Load() != 0 {
+		t.Error("Expected no inspect while the limit is reached")
+	}
+}
+
+func TestContainerInspect_Unlimited(t *testing.T) {
+	SetInspectLimit(0)
+
+	cli := &countingInspector{}
+	if _, err := ContainerInspect(context.Background(), cli, "container"); err != nil {
+		t.Fatalf("ContainerInspect failed: %v", err)
+	}
+	if cli.calls.Load() != 1 {
+		t.Errorf("Expected 1 inspect, got %d", cli.calls.Load())
+	}
+}
@@ -13,6 +13,8 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
 )
 
@@ -199,3 +201,108 @@ networks:
 		t.Log("Service still exists after SIGINT (rollback may have occurred)")
 	}
 }
+
+// TestIntegration_DryRun tests that -dry-run prints the plan without creating anything
+func TestIntegration_DryRun(t *testing.T) {
+	if !isDockerAvailable(t) {
+		t.Skip("Docker is not available or not in swarm mode")
+	}
+
+	stackName := "stackman-dryrun-test"
+	cleanup := func() { cleanupStack(t, stackName) }
+	cleanup()
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	configFile := "testdata/dryrun-app.conf"
+	writeFile(t, configFile, "key=value")
+	defer os.Remove(configFile)
+
+	dryRunCompose := `version: "3.8"
+services:
+  web:
+    image: nginx:alpine
+    deploy:
+      replicas: 1
+    networks:
+      - backend
+    configs:
+      - app-config
+    secrets:
+      - app-secret
+
+networks:
+  backend:
+    driver: overlay
+
+configs:
+  app-config:
+    file: ./testdata/dryrun-app.conf
+
+secrets:
+  app-secret:
+    file: ./testdata/dryrun-app.conf
+`
+	dryRunComposeFile := "testdata/dryrun-test.yml"
+	writeFile(t, dryRunComposeFile, dryRunCompose)
+	defer os.Remove(dryRunComposeFile)
+
+	t.Log("Running apply with -dry-run...")
+	cmd := exec.CommandContext(ctx, "../stackman", "apply",
+		"-n", stackName,
+		"-f", dryRunComposeFile,
+		"-dry-run",
+	)
+	out, err := cmd.CombinedOutput()
+	t.Logf("Output:\n%s", out)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+
+	if !strings.Contains(string(out), "web") {
+		t.Error("Expected plan output to mention service web")
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Fatalf("Failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	filter := filters.NewArgs()
+	filter.Add("label", "com.docker.stack.namespace="+stackName)
+
+	services, err := cli.ServiceList(ctx, types.ServiceListOptions{Filters: filter})
+	if err != nil {
+		t.Fatalf("Failed to list services: %v", err)
+	}
+	if len(services) != 0 {
+		t.Errorf("Expected no services after dry run, found %d", len(services))
+	}
+
+	networks, err := cli.NetworkList(ctx, network.ListOptions{Filters: filter})
+	if err != nil {
+		t.Fatalf("Failed to list networks: %v", err)
+	}
+	if len(networks) != 0 {
+		t.Errorf("Expected no networks after dry run, found %d", len(networks))
+	}
+
+	secrets, err := cli.SecretList(ctx, swarm.SecretListOptions{Filters: filter})
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if len(secrets) != 0 {
+		t.Errorf("Expected no secrets after dry run, found %d", len(secrets))
+	}
+
+	configs, err := cli.ConfigList(ctx, swarm.ConfigListOptions{Filters: filter})
+	if err != nil {
+		t.Fatalf("Failed to list configs: %v", err)
+	}
+	if len(configs) != 0 {
+		t.Errorf("Expected no configs after dry run, found %d", len(configs))
+	}
+}